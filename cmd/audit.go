@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// auditLogFile is the --audit-log flag: when set, every command the local
+// executor runs is appended to this file as one JSON line (see executor.AuditLog).
+var auditLogFile string
+
+// auditLog is the open audit log, closed by closeAuditLog when the command ends.
+var auditLog *executor.AuditLog
+
+// openAuditLog returns the --audit-log sink, opening it on first use, or nil
+// when the flag is unset.
+func openAuditLog() (*executor.AuditLog, error) {
+	if auditLogFile == "" || auditLog != nil {
+		return auditLog, nil
+	}
+	a, err := executor.OpenAuditLog(auditLogFile)
+	if err != nil {
+		return nil, err
+	}
+	auditLog = a
+	return auditLog, nil
+}
+
+// withAuditLog attaches the --audit-log sink to exec.
+func withAuditLog(exec *executor.Executor) error {
+	a, err := openAuditLog()
+	if err != nil {
+		return err
+	}
+	exec.Audit = a
+	return nil
+}
+
+// checkAuditable rejects --audit-log for a dry run, which executes nothing.
+func checkAuditable() error {
+	if auditLogFile != "" && IsDryRun() {
+		return fmt.Errorf("--audit-log records executed commands; drop --dry-run")
+	}
+	return nil
+}
+
+func closeAuditLog() {
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to close audit log: %v\n", err)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/provisioner"
+)
+
+var (
+	bundleOutput      string
+	bundleWithImages  bool
+	bundleExtraImages []string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Collect everything provisioning downloads into an offline bundle",
+	Long: `Download every artifact config.yaml implies into one tarball for an
+air-gapped install: the packages of "provision common" with their
+dependencies, the manifests, Helm charts and binaries of the CNI and of every
+enabled component, and the container images they run. Set offline.bundle to
+the tarball's path on the nodes to provision from it.
+
+Run it on an online machine of the same distribution family and architecture
+as the nodes, ideally a fresh one: it installs packages on that host and
+fetches binaries for its architecture.
+
+Examples:
+  k8s-provisioner bundle -o /vagrant/k8s-bundle.tar.gz
+  k8s-provisioner bundle --image quay.io/prometheus/prometheus:v3.5.0`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkScriptable(); err != nil {
+			return err
+		}
+		fmt.Println("=== Building offline bundle ===")
+		var p *provisioner.Provisioner
+		if IsDryRun() {
+			p = provisioner.NewDryRun(cmd.Context(), GetConfig(), IsVerbose(), dryRunScript())
+		} else {
+			var err error
+			if p, err = realProvisioner(cmd.Context(), ""); err != nil {
+				return err
+			}
+		}
+		defer func() { _ = p.Close() }()
+		return p.Bundle(bundleOutput, bundleWithImages, bundleExtraImages)
+	},
+}
+
+func init() {
+	bundleCmd.Flags().StringVarP(&bundleOutput, "output", "o", "k8s-bundle.tar.gz", "path of the bundle tarball to write")
+	bundleCmd.Flags().BoolVar(&bundleWithImages, "images", true, "save the container images; false only lists them in images.txt")
+	bundleCmd.Flags().StringArrayVar(&bundleExtraImages, "image", nil, "an extra image to bundle (repeatable), e.g. one an operator defaults")
+	rootCmd.AddCommand(bundleCmd)
+}
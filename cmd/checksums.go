@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/fetch"
+	"github.com/techiescamp/k8s-provisioner/internal/provisioner"
+)
+
+var checksumsShipped bool
+
+var checksumsCmd = &cobra.Command{
+	Use:   "checksums",
+	Short: "Pin the SHA-256 of the files provisioning downloads",
+	Long: `Download the manifests and release tarballs config.yaml implies, for
+amd64 and arm64 nodes, and print their SHA-256. Provisioning only applies or
+runs a download whose checksum is pinned, in the checksums shipped with the
+binary or under checksums: in config.yaml. By default only the downloads the
+binary has no pin for are printed, as a checksums: block for config.yaml:
+review it and paste it there after bumping a version.
+
+--shipped prints every download of every component at the versions of
+config.yaml instead, as the checksums manifest built into the binary
+(make checksums).
+
+Examples:
+  k8s-provisioner checksums >> config.yaml
+  k8s-provisioner -c config.yaml checksums --shipped`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shipped, err := fetch.Shipped()
+		if err != nil {
+			return err
+		}
+		cfg := GetConfig()
+		p := provisioner.New(cmd.Context(), cfg, IsVerbose())
+		pins := fetch.Pins{}
+		for _, a := range p.Downloads(checksumsShipped) {
+			if !checksumsShipped {
+				if _, ok := shipped[a.URL]; ok {
+					continue
+				}
+				if _, ok := cfg.Checksums[a.URL]; ok {
+					continue
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Hashing %s\n", a.URL)
+			sum, err := fetch.Sum(a.URL)
+			if err != nil {
+				return err
+			}
+			pins[a.URL] = sum
+		}
+
+		if checksumsShipped {
+			fmt.Print(pins.Render())
+			return nil
+		}
+		if len(pins) == 0 {
+			fmt.Fprintln(os.Stderr, "Every download of config.yaml is pinned")
+			return nil
+		}
+		fmt.Println("checksums:")
+		for _, line := range strings.Split(strings.TrimSuffix(pins.Render(), "\n"), "\n") {
+			fmt.Println("  " + line)
+		}
+		return nil
+	},
+}
+
+func init() {
+	checksumsCmd.Flags().BoolVar(&checksumsShipped, "shipped", false, "print the checksums manifest of every component, as shipped with the binary")
+	rootCmd.AddCommand(checksumsCmd)
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/provisioner"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [component...]",
+	Short: "Check that the installed components actually work",
+	Long: `Run functional checks against every workload component enabled in
+config.yaml, or only the named ones (see "provision workloads --only"): MetalLB
+assigns an IP to a test Service, the Istio gateway answers, Vault is unsealed,
+VSO has synced its secrets, Keycloak serves OIDC discovery, and so on. Prints
+pass or fail per component and exits non-zero if any check fails. The same
+checks run after each component during "provision workloads". Run it on the
+control plane.`,
+	ValidArgs: provisioner.WorkloadIDs(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("=== Checking cluster components ===")
+		p, err := provisionerFor(cmd.Context(), "")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		return p.Doctor(args...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
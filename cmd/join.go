@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/join"
+)
+
+// joinPort and joinServeTTL are the join serve --port and --ttl flags.
+var (
+	joinPort     int
+	joinServeTTL time.Duration
+)
+
+var joinCmd = &cobra.Command{
+	Use:   "join",
+	Short: "Hand out join credentials to the nodes",
+	Long: `Nodes join the cluster with a bootstrap token valid for 15 minutes and the
+hash of the cluster CA, issued on the first control plane for each join. By
+default "provision worker" and "provision controlplane-join" get them over SSH
+with the provisioning.ssh_* credentials. Where nodes cannot SSH to the control
+plane, run "join serve" there and point them at it with provisioning.join.`,
+}
+
+var joinServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve one-shot join credentials over HTTPS on the control plane",
+	Long: `Run on the first control plane. Every worker and additional control plane
+in config.yaml can fetch its join credentials once, from its own nodes[].ip,
+until all have or --ttl passes. The endpoint's certificate is self-signed and
+kept in ` + join.CertPath + `; set the URL and fingerprint
+printed at startup as provisioning.join.url and provisioning.join.fingerprint
+on the nodes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if IsDryRun() {
+			return fmt.Errorf("join serve has no dry run: it only hands out credentials")
+		}
+		p, err := realProvisioner(cmd.Context(), "")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		return p.ServeJoin(joinPort, joinServeTTL)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(joinCmd)
+	joinCmd.AddCommand(joinServeCmd)
+
+	joinServeCmd.Flags().IntVar(&joinPort, "port", join.DefaultPort, "port to serve join credentials on")
+	joinServeCmd.Flags().DurationVar(&joinServeTTL, "ttl", 30*time.Minute,
+		"close the endpoint after this long even if some nodes have not fetched their credentials")
+}
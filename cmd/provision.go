@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
 	"github.com/techiescamp/k8s-provisioner/internal/provisioner"
 )
 
@@ -14,20 +17,118 @@ var provisionCmd = &cobra.Command{
 	Long:  `Provision the current node with Kubernetes components based on its role.`,
 }
 
-// newProvisioner builds a Provisioner honoring the global --dry-run flag.
-func newProvisioner() *provisioner.Provisioner {
+// provisionNode is the --node flag: when set, commands run over SSH on that
+// node (nodes[].name) instead of the local host.
+var provisionNode string
+
+// provisionResume is the provision --resume flag (see Provisioner.Checkpoint).
+var provisionResume bool
+
+// skipPreflight is the provision --skip-preflight flag (see
+// Provisioner.SkipPreflight).
+var skipPreflight bool
+
+// workloadsOnly and workloadsSkip are the provision workloads --only and
+// --skip flags (see Provisioner.SetSelection).
+var workloadsOnly, workloadsSkip []string
+
+// workloadParallel is the provision --parallel flag (see
+// Provisioner.SetParallel).
+var workloadParallel int
+
+// workloadParallelism returns --parallel, or 1 for a dry run or a --record run,
+// whose scripts and cassettes must keep the plan order whatever the scheduling.
+func workloadParallelism() int {
+	if IsDryRun() || recordFile != "" {
+		return 1
+	}
+	return workloadParallel
+}
+
+// newProvisioner builds a Provisioner honoring the global --dry-run,
+// --script-dir and --command-timeout flags and the provision --node flag. ctx is the command
+// context, cancelled on Ctrl-C. The caller must Close the result.
+func newProvisioner(ctx context.Context) (*provisioner.Provisioner, error) {
+	return provisionerFor(ctx, provisionNode)
+}
+
+// provisionerFor builds a Provisioner for node: the local host when node is
+// empty, otherwise the named node over SSH. A real run checkpoints its steps
+// on that node (see --resume).
+func provisionerFor(ctx context.Context, node string) (*provisioner.Provisioner, error) {
+	if err := checkRecordable(node); err != nil {
+		return nil, err
+	}
+	if err := checkAuditable(); err != nil {
+		return nil, err
+	}
+	if err := checkScriptable(); err != nil {
+		return nil, err
+	}
 	if IsDryRun() {
-		return provisioner.NewDryRun(GetConfig(), IsVerbose())
+		if provisionResume {
+			return nil, fmt.Errorf("--resume continues from the node's checkpoint; a dry run keeps none")
+		}
+		p := provisioner.NewDryRun(ctx, GetConfig(), IsVerbose(), dryRunScript())
+		if skipPreflight {
+			p.SkipPreflight()
+		}
+		return p, nil
+	}
+
+	p, err := realProvisioner(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Checkpoint(provisionResume); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	if skipPreflight {
+		p.SkipPreflight()
+	}
+	return p, nil
+}
+
+// realProvisioner builds the executing Provisioner for node (see
+// provisionerFor).
+func realProvisioner(ctx context.Context, node string) (*provisioner.Provisioner, error) {
+	if node != "" {
+		audit, err := openAuditLog()
+		if err != nil {
+			return nil, err
+		}
+		return provisioner.NewRemote(ctx, GetConfig(), node, CommandTimeout(), audit, IsVerbose())
+	}
+	exec := executor.New(IsVerbose())
+	exec.Timeout = CommandTimeout()
+	if err := withAuditLog(exec); err != nil {
+		return nil, err
+	}
+	return provisioner.NewWithExecutor(ctx, GetConfig(), recording(exec), IsVerbose()), nil
+}
+
+// checkLocalWorkloads rejects --node for commands that install workloads.
+// Several installers still touch the local filesystem directly (Vault init
+// data, the cluster CA, generated credential files), which would land on the
+// workstation instead of the control plane.
+func checkLocalWorkloads() error {
+	if provisionNode != "" {
+		return fmt.Errorf("installing workloads over SSH (--node) is not supported yet; run this on the control plane")
 	}
-	return provisioner.New(GetConfig(), IsVerbose())
+	return nil
 }
 
 var provisionCommonCmd = &cobra.Command{
 	Use:   "common",
-	Short: "Install common components (CRI-O, kubeadm, kubelet, kubectl)",
+	Short: "Install common components (container runtime, kubeadm, kubelet, kubectl)",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("=== Installing common components ===")
-		p := newProvisioner()
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
 		return p.InstallCommon()
 	},
 }
@@ -36,8 +137,16 @@ var provisionControlPlaneCmd = &cobra.Command{
 	Use:   "controlplane",
 	Short: "Initialize the control plane node",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkLocalWorkloads(); err != nil {
+			return err
+		}
 		fmt.Println("=== Initializing control plane ===")
-		p := newProvisioner()
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		p.SetParallel(workloadParallelism())
 		return p.InitControlPlane()
 	},
 }
@@ -47,17 +156,45 @@ var provisionWorkerCmd = &cobra.Command{
 	Short: "Join this node as a worker",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("=== Joining cluster as worker ===")
-		p := newProvisioner()
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
 		return p.JoinWorker()
 	},
 }
 
+var provisionControlPlaneJoinCmd = &cobra.Command{
+	Use:   "controlplane-join",
+	Short: "Join this node as an additional control plane (HA)",
+	Long: `Join a controlplane node other than the first one to the cluster as a
+control plane, through network.controlplane_vip. A short-lived bootstrap token
+and a fresh certificate key are issued on the first control plane and fetched
+over SSH, or from "join serve" (see provisioning.join). kube-vip then also
+runs on this node, so the API server stays reachable on the VIP when any one
+control plane goes down.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("=== Joining cluster as control plane ===")
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		return p.JoinControlPlane()
+	},
+}
+
 var provisionInitCmd = &cobra.Command{
 	Use:   "init",
-	Short: "Bootstrap the control plane and generate the worker join command",
+	Short: "Bootstrap the control plane",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("=== Bootstrapping control plane ===")
-		p := newProvisioner()
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
 		if err := p.InstallCommon(); err != nil {
 			return err
 		}
@@ -65,12 +202,51 @@ var provisionInitCmd = &cobra.Command{
 	},
 }
 
+var provisionPreflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check that the node can be provisioned, without changing it",
+	Long: `Run the checks "provision common" and "provision init" run before touching
+the node, and print a report: memory and CPUs for the node's role, the
+overlay and br_netfilter kernel modules, cgroup v2, network.interface carrying
+the node's IP, and the kubelet port. On the control plane also the control
+plane ports (6443, etcd, controller manager, scheduler) and whether the NFS
+server and Vault answer. Exits non-zero on a blocking finding; warnings are
+printed only. The node's role comes from its hostname, or --node.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname := provisionNode
+		if hostname == "" {
+			var err error
+			if hostname, err = os.Hostname(); err != nil {
+				return err
+			}
+		}
+		node := GetConfig().GetNode(hostname)
+
+		fmt.Println("=== Checking node ===")
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		return p.Preflight(node != nil && node.Role == "controlplane")
+	},
+}
+
 var provisionWorkloadsCmd = &cobra.Command{
 	Use:   "workloads",
 	Short: "Install cluster workloads (MetalLB, Istio, Monitoring, Keycloak...)",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkLocalWorkloads(); err != nil {
+			return err
+		}
 		fmt.Println("=== Installing cluster workloads ===")
-		p := newProvisioner()
+		p, err := newProvisioner(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		p.SetParallel(workloadParallelism())
+		p.SetSelection(workloadsOnly, workloadsSkip)
 		return p.InstallWorkloads()
 	},
 }
@@ -79,12 +255,21 @@ var provisionAllCmd = &cobra.Command{
 	Use:   "all",
 	Short: "Run full provisioning based on node role",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		hostname, err := os.Hostname()
+		// The role comes from the target node: --node when driving a node
+		// remotely, otherwise this host's hostname.
+		hostname := provisionNode
+		if hostname == "" {
+			var err error
+			if hostname, err = os.Hostname(); err != nil {
+				return err
+			}
+		}
+
+		p, err := newProvisioner(cmd.Context())
 		if err != nil {
 			return err
 		}
-
-		p := newProvisioner()
+		defer func() { _ = p.Close() }()
 
 		// Install common components
 		fmt.Println("=== Installing common components ===")
@@ -107,6 +292,10 @@ var provisionAllCmd = &cobra.Command{
 		}
 
 		if role == "controlplane" {
+			if first := cfg.GetControlPlane(); first.Name != hostname {
+				fmt.Println("=== Joining cluster as control plane ===")
+				return p.JoinControlPlane()
+			}
 			fmt.Println("=== Bootstrapping control plane ===")
 			return p.InitCluster()
 		} else {
@@ -116,12 +305,143 @@ var provisionAllCmd = &cobra.Command{
 	},
 }
 
+var provisionClusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Provision the control plane and every worker over SSH from this machine",
+	Long: `Drive the whole cluster from a workstation or CI runner: install common
+components and bootstrap the first control plane, join any further control
+planes (HA, see network.controlplane_vip), then install common components on
+each worker and join it. Nodes are reached over SSH using nodes[].ip and the
+provisioning: credentials. Run "provision workloads" on the control plane
+afterwards to install cluster workloads.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := GetConfig()
+		cp := cfg.GetControlPlane()
+		if cp == nil {
+			return fmt.Errorf("no controlplane node in config")
+		}
+
+		if err := provisionNodeRemote(cmd.Context(), cp.Name, func(p *provisioner.Provisioner) error {
+			return p.InitCluster()
+		}); err != nil {
+			return err
+		}
+		for _, other := range cfg.GetControlPlanes()[1:] {
+			if err := provisionNodeRemote(cmd.Context(), other.Name, func(p *provisioner.Provisioner) error {
+				return p.JoinControlPlane()
+			}); err != nil {
+				return err
+			}
+		}
+		for _, w := range cfg.GetWorkers() {
+			if err := provisionNodeRemote(cmd.Context(), w.Name, func(p *provisioner.Provisioner) error {
+				return p.JoinWorker()
+			}); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println("\n=== Cluster nodes provisioned ===")
+		return nil
+	},
+}
+
+var provisionUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade the cluster to versions.kubernetes, one node at a time",
+	Long: `Upgrade Kubernetes after bumping versions.kubernetes in config.yaml: the
+control plane first, then each worker. Each node gets the package repository for
+the new minor version and the matching kubeadm, runs "kubeadm upgrade apply" (first
+control plane) or "kubeadm upgrade node" (the others), and is drained while kubelet and
+kubectl are upgraded, then uncordoned. The version skew policy is checked before
+a node is touched: one minor version at a time, and workers never ahead of the
+control plane. Nodes already at the target version are skipped, so an
+interrupted upgrade can be re-run. Nodes are reached over SSH like "provision
+cluster"; --node upgrades just that node.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := GetConfig()
+		nodes := []string{provisionNode}
+		if provisionNode == "" {
+			cps := cfg.GetControlPlanes()
+			if len(cps) == 0 {
+				return fmt.Errorf("no controlplane node in config")
+			}
+			nodes = nil
+			for _, cp := range cps {
+				nodes = append(nodes, cp.Name)
+			}
+			for _, w := range cfg.GetWorkers() {
+				nodes = append(nodes, w.Name)
+			}
+		}
+
+		for _, node := range nodes {
+			fmt.Printf("\n=== [%s] Upgrading to Kubernetes %s ===\n", node, cfg.Versions.Kubernetes)
+			if err := upgradeNode(cmd.Context(), node); err != nil {
+				return fmt.Errorf("%s: %w", node, err)
+			}
+		}
+
+		fmt.Println("\n=== Cluster upgraded ===")
+		return nil
+	},
+}
+
+// upgradeNode upgrades node over SSH (see Provisioner.Upgrade).
+func upgradeNode(ctx context.Context, node string) error {
+	p, err := provisionerFor(ctx, node)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = p.Close() }()
+	return p.Upgrade(node)
+}
+
+// provisionNodeRemote installs common components on node over SSH, then runs
+// the role-specific phase.
+func provisionNodeRemote(ctx context.Context, node string, phase func(*provisioner.Provisioner) error) error {
+	fmt.Printf("\n=== [%s] Installing common components ===\n", node)
+	p, err := provisionerFor(ctx, node)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = p.Close() }()
+
+	if err := p.InstallCommon(); err != nil {
+		return fmt.Errorf("%s: %w", node, err)
+	}
+	fmt.Printf("\n=== [%s] Configuring node ===\n", node)
+	if err := phase(p); err != nil {
+		return fmt.Errorf("%s: %w", node, err)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(provisionCmd)
 	provisionCmd.AddCommand(provisionCommonCmd)
 	provisionCmd.AddCommand(provisionControlPlaneCmd)
 	provisionCmd.AddCommand(provisionWorkerCmd)
 	provisionCmd.AddCommand(provisionInitCmd)
+	provisionCmd.AddCommand(provisionControlPlaneJoinCmd)
 	provisionCmd.AddCommand(provisionWorkloadsCmd)
 	provisionCmd.AddCommand(provisionAllCmd)
+	provisionCmd.AddCommand(provisionClusterCmd)
+	provisionCmd.AddCommand(provisionUpgradeCmd)
+	provisionCmd.AddCommand(provisionPreflightCmd)
+
+	provisionCmd.PersistentFlags().StringVar(&provisionNode, "node", "",
+		"provision this node (nodes[].name) over SSH instead of the local host")
+	provisionCmd.PersistentFlags().IntVar(&workloadParallel, "parallel", 4,
+		"install up to this many independent workloads at once (1 = one at a time)")
+	provisionCmd.PersistentFlags().BoolVar(&provisionResume, "resume", false,
+		"skip steps the node's checkpoint ("+provisioner.StateFile+") records as completed against the same config")
+	provisionCmd.PersistentFlags().BoolVar(&skipPreflight, "skip-preflight", false,
+		"do not check the node (memory, kernel, ports, network...) before provisioning it")
+
+	ids := strings.Join(provisioner.WorkloadIDs(), ", ")
+	provisionWorkloadsCmd.Flags().StringSliceVar(&workloadsOnly, "only", nil,
+		"install only these components ("+ids+")")
+	provisionWorkloadsCmd.Flags().StringSliceVar(&workloadsSkip, "skip", nil,
+		"install every enabled component except these")
 }
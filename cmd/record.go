@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// recordFile is the --record flag: when set, every command of a local run is
+// captured into a cassette at this path (see executor.Recorder), ready to be
+// used as a golden test fixture.
+var recordFile string
+
+// recorder is the active Recorder, saved by saveRecording when the command ends.
+var recorder *executor.Recorder
+
+// recording wraps exec in a Recorder when --record is set.
+func recording(exec executor.CommandExecutor) executor.CommandExecutor {
+	if recordFile == "" {
+		return exec
+	}
+	recorder = executor.NewRecorder(exec)
+	return recorder
+}
+
+// checkRecordable rejects --record for runs whose commands it cannot capture.
+func checkRecordable(node string) error {
+	if recordFile == "" {
+		return nil
+	}
+	if IsDryRun() {
+		return fmt.Errorf("--record captures real runs; drop --dry-run")
+	}
+	if node != "" {
+		return fmt.Errorf("--record is only supported for local runs (without --node)")
+	}
+	return nil
+}
+
+// saveRecording writes the cassette, including for failed or interrupted runs,
+// since those are often the sequences worth turning into a test.
+func saveRecording() {
+	if recorder == nil {
+		return
+	}
+	c := recorder.Cassette()
+	if err := c.Save(recordFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save recording: %v\n", err)
+		return
+	}
+	fmt.Printf("Recorded %d commands to %s\n", len(c.Interactions), recordFile)
+}
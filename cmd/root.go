@@ -1,21 +1,29 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 )
 
 var (
-	cfgFile string
-	verbose bool
-	dryRun  bool
-	cfg     *config.Config
+	cfgFile        string
+	verbose        bool
+	dryRun         bool
+	commandTimeout time.Duration
+	cfg            *config.Config
 )
 
+// exitInterrupted is the conventional exit status for a run stopped by SIGINT.
+const exitInterrupted = 130
+
 // Commands that don't require config
 var noConfigCommands = map[string]bool{
 	"version":   true,
@@ -34,9 +42,9 @@ var rootCmd = &cobra.Command{
 	Long: `k8s-provisioner is a CLI tool to provision Kubernetes clusters
 for learning and lab environments. It automates the installation of:
 
-- CRI-O (Container Runtime)
+- CRI-O or containerd (Container Runtime)
 - Kubernetes (kubeadm, kubelet, kubectl)
-- Calico (CNI)
+- Calico, Cilium or Flannel (CNI)
 - MetalLB (LoadBalancer)
 - Istio (Service Mesh)`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
@@ -61,9 +69,27 @@ for learning and lab environments. It automates the installation of:
 	},
 }
 
+// Execute runs the root command under a context that is cancelled on Ctrl-C or
+// SIGTERM. Cancellation kills the in-flight command and makes the provisioner
+// report the interrupted step; a second signal falls back to the default
+// handler and terminates immediately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	saveRecording()
+	saveScript()
+	closeAuditLog()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			os.Exit(exitInterrupted)
+		}
 		os.Exit(1)
 	}
 }
@@ -72,6 +98,10 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "/etc/k8s-provisioner/config.yaml", "config file path")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "preview commands without mutating the host")
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "command-timeout", 0, "abort any single command running longer than this (e.g. 15m); 0 disables")
+	rootCmd.PersistentFlags().StringVar(&auditLogFile, "audit-log", "", "append a JSON-lines record of every executed command to this file")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "record every command and its output to this cassette file (golden test fixture)")
+	rootCmd.PersistentFlags().StringVar(&scriptDir, "script-dir", "", "with --dry-run, write each component's commands as a shell script into this directory")
 }
 
 func GetConfig() *config.Config {
@@ -85,3 +115,8 @@ func IsVerbose() bool {
 func IsDryRun() bool {
 	return dryRun
 }
+
+// CommandTimeout returns the per-command deadline set by --command-timeout.
+func CommandTimeout() time.Duration {
+	return commandTimeout
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// scriptDir is the --script-dir flag: when set with --dry-run, the commands of
+// each step are written into this directory as one shell script per component
+// (see executor.Script), to be reviewed before a run touches the cluster.
+var scriptDir string
+
+// script collects the dry run's commands, written out by saveScript.
+var script *executor.Script
+
+// dryRunScript returns the Script for this run, or nil without --script-dir.
+func dryRunScript() *executor.Script {
+	if scriptDir == "" {
+		return nil
+	}
+	if script == nil {
+		script = executor.NewScript()
+	}
+	return script
+}
+
+// checkScriptable rejects --script-dir outside a dry run: a real run executes
+// its commands, and --record is the way to capture those.
+func checkScriptable() error {
+	if scriptDir != "" && !IsDryRun() {
+		return fmt.Errorf("--script-dir requires --dry-run")
+	}
+	return nil
+}
+
+// saveScript writes the collected scripts, including for a failed dry run, so
+// the sequence up to the failure can be inspected.
+func saveScript() {
+	if script == nil {
+		return
+	}
+	paths, err := script.WriteDir(scriptDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write dry-run scripts: %v\n", err)
+		return
+	}
+	fmt.Printf("Wrote %d dry-run scripts to %s\n", len(paths), scriptDir)
+}
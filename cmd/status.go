@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
 	"github.com/techiescamp/k8s-provisioner/internal/version"
 )
@@ -14,16 +15,25 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show cluster and node status",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		exec := executor.New(IsVerbose())
+		local := executor.New(IsVerbose())
+		if err := withAuditLog(local); err != nil {
+			return err
+		}
+		exec := executor.WithContext(cmd.Context(), recording(local))
 
 		hostname, _ := os.Hostname()
 		fmt.Printf("=== Node: %s ===\n\n", hostname)
 
-		// Check CRI-O status
-		fmt.Println("CRI-O Status:")
-		if out, err := exec.Run("systemctl", "is-active", "crio"); err == nil {
-			fmt.Printf("  Service: %s", out)
-		} else {
+		// Check whichever container runtime is running
+		fmt.Println("Container Runtime:")
+		runtimeFound := false
+		for _, service := range []struct{ unit, name string }{{"crio", "CRI-O"}, {"containerd", "containerd"}} {
+			if out, err := exec.Run("systemctl", "is-active", service.unit); err == nil {
+				fmt.Printf("  %s: %s", service.name, out)
+				runtimeFound = true
+			}
+		}
+		if !runtimeFound {
 			fmt.Println("  Service: not installed")
 		}
 
@@ -55,9 +65,33 @@ var statusCmd = &cobra.Command{
 			fmt.Println("  Unable to get nodes (not controlplane or cluster not initialized)")
 		}
 
+		// etcd membership (stacked etcd: only on a control plane)
+		fmt.Println("\netcd Members:")
+		if out, err := etcdMembers(exec); err == nil {
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+				fmt.Printf("  %s\n", line)
+			}
+		} else {
+			fmt.Println("  Unable to list etcd members (not controlplane or cluster not initialized)")
+		}
+
+		if cfg := GetConfig(); cfg != nil && cfg.Network.ControlPlaneVIP != "" {
+			fmt.Println("\nControl Plane VIP:")
+			holder, err := exec.Run("kubectl", "get", "lease", "plndr-cp-lock", "-n", "kube-system",
+				"-o", "jsonpath={.spec.holderIdentity}")
+			if err != nil || strings.TrimSpace(holder) == "" {
+				holder = "unknown"
+			}
+			fmt.Printf("  %s:6443 held by %s\n", cfg.Network.ControlPlaneVIP, strings.TrimSpace(holder))
+		}
+
 		// Check pods in key namespaces
 		fmt.Println("\nCluster Components:")
-		namespaces := []string{"kube-system", "calico-system", "metallb-system", "istio-system"}
+		namespaces := []string{"kube-system"}
+		if ns := cniNamespace(GetConfig()); ns != "kube-system" {
+			namespaces = append(namespaces, ns)
+		}
+		namespaces = append(namespaces, "metallb-system", "istio-system")
 		for _, ns := range namespaces {
 			out, err := exec.Run("kubectl", "get", "pods", "-n", ns, "--no-headers")
 			if err == nil && out != "" {
@@ -76,6 +110,40 @@ var statusCmd = &cobra.Command{
 	},
 }
 
+// etcdMembers lists the etcd cluster members with etcdctl inside the etcd pod
+// of a control plane.
+func etcdMembers(exec executor.CommandExecutor) (string, error) {
+	pod, err := exec.Run("kubectl", "get", "pods", "-n", "kube-system", "-l", "component=etcd",
+		"-o", "jsonpath={.items[0].metadata.name}")
+	if err != nil {
+		return "", err
+	}
+	if pod = strings.TrimSpace(pod); pod == "" {
+		return "", fmt.Errorf("no etcd pod")
+	}
+	return exec.Run("kubectl", "exec", "-n", "kube-system", pod, "--", "etcdctl",
+		"--endpoints=https://127.0.0.1:2379",
+		"--cacert=/etc/kubernetes/pki/etcd/ca.crt",
+		"--cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt",
+		"--key=/etc/kubernetes/pki/etcd/healthcheck-client.key",
+		"member", "list", "-w", "table")
+}
+
+// cniNamespace is where the pods of the configured cluster network run.
+func cniNamespace(cfg *config.Config) string {
+	if cfg == nil {
+		return "calico-system"
+	}
+	switch cfg.CNI() {
+	case "cilium":
+		return "kube-system"
+	case "flannel":
+		return "kube-flannel"
+	default:
+		return "calico-system"
+	}
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show k8s-provisioner version",
@@ -87,8 +155,19 @@ var versionCmd = &cobra.Command{
 		cfg := GetConfig()
 		if cfg != nil {
 			fmt.Printf("  Kubernetes: %s\n", cfg.Versions.Kubernetes)
-			fmt.Printf("  CRI-O: %s\n", cfg.Versions.CriO)
-			fmt.Printf("  Calico: %s\n", cfg.Versions.Calico)
+			if cfg.Runtime() == "containerd" {
+				fmt.Printf("  containerd: %s\n", cfg.Versions.Containerd)
+			} else {
+				fmt.Printf("  CRI-O: %s\n", cfg.Versions.CriO)
+			}
+			switch cfg.CNI() {
+			case "cilium":
+				fmt.Printf("  Cilium: %s\n", cfg.Versions.Cilium)
+			case "flannel":
+				fmt.Printf("  Flannel: %s\n", cfg.Versions.Flannel)
+			default:
+				fmt.Printf("  Calico: %s\n", cfg.Versions.Calico)
+			}
 			fmt.Printf("  MetalLB: %s\n", cfg.Versions.MetalLB)
 			fmt.Printf("  Istio: %s\n", cfg.Versions.Istio)
 		}
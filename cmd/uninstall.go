@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/techiescamp/k8s-provisioner/internal/provisioner"
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall <component>",
+	Short: "Remove an installed workload component from the cluster",
+	Long: `Remove a workload component (see "provision workloads --only") and undo what
+its install changed elsewhere, e.g. the API server OIDC patch for keycloak.
+Refuses while another component enabled in config.yaml depends on it. Run it
+on the control plane.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: provisioner.WorkloadIDs(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Printf("=== Uninstalling %s ===\n", args[0])
+		p, err := provisionerFor(cmd.Context(), "")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = p.Close() }()
+		return p.Uninstall(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+}
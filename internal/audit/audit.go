@@ -0,0 +1,198 @@
+// Package audit holds the kube-apiserver audit policy presets, validates
+// audit.k8s.io/v1 Policy documents and renders the kubeconfig of the audit
+// webhook backend. (The provisioner's own command log is executor.AuditLog.)
+package audit
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const policyHeader = `apiVersion: audit.k8s.io/v1
+kind: Policy
+omitStages:
+  - RequestReceived
+rules:
+`
+
+// secretReads logs who read which secret, at Metadata so the values stay out
+// of the log. It must precede readsNone.
+const secretReads = `  - level: Metadata
+    verbs: ["get", "list", "watch"]
+    resources:
+      - group: ""
+        resources: ["secrets"]
+`
+
+// readsNone and noise drop read/health noise and high-frequency coordination
+// heartbeats (leases/events/endpoints/endpointslices — huge volume, low value).
+const readsNone = `  - level: None
+    verbs: ["get", "list", "watch"]
+`
+
+const noise = `  - level: None
+    nonResourceURLs: ["/healthz*", "/livez*", "/readyz*", "/version", "/metrics"]
+  - level: None
+    resources:
+      - group: "coordination.k8s.io"
+        resources: ["leases"]
+      - group: ""
+        resources: ["events", "endpoints"]
+      - group: "discovery.k8s.io"
+        resources: ["endpointslices"]
+`
+
+// sensitive captures security-sensitive objects at full fidelity and
+// exec/attach at Request.
+const sensitive = `  - level: RequestResponse
+    resources:
+      - group: ""
+        resources: ["secrets", "serviceaccounts"]
+      - group: "rbac.authorization.k8s.io"
+        resources: ["roles", "rolebindings", "clusterroles", "clusterrolebindings"]
+  - level: Request
+    resources:
+      - group: ""
+        resources: ["pods/exec", "pods/attach", "pods/portforward"]
+`
+
+const mutations = `  - level: Metadata
+`
+
+// Presets are the audit.policy values of config.yaml. First match wins in
+// each:
+//
+//   - minimal records every mutation at Metadata: who changed what, never
+//     the object.
+//   - default also keeps security-sensitive objects at full fidelity and
+//     exec/attach at Request. Reads of secrets are not logged (matched by the
+//     get/list/watch=None rule first) to bound volume.
+//   - secrets-read is default plus who read which secret, at Metadata.
+var Presets = map[string]string{
+	"minimal":      policyHeader + readsNone + noise + mutations,
+	"default":      policyHeader + readsNone + noise + sensitive + mutations,
+	"secrets-read": policyHeader + secretReads + readsNone + noise + sensitive + mutations,
+}
+
+// Policy is the subset of audit.k8s.io/v1 Policy the API server reads.
+type Policy struct {
+	APIVersion        string       `yaml:"apiVersion"`
+	Kind              string       `yaml:"kind"`
+	Metadata          yaml.Node    `yaml:"metadata"`
+	Rules             []PolicyRule `yaml:"rules"`
+	OmitStages        []string     `yaml:"omitStages"`
+	OmitManagedFields *bool        `yaml:"omitManagedFields"`
+}
+
+type PolicyRule struct {
+	Level             string           `yaml:"level"`
+	Users             []string         `yaml:"users"`
+	UserGroups        []string         `yaml:"userGroups"`
+	Verbs             []string         `yaml:"verbs"`
+	Resources         []GroupResources `yaml:"resources"`
+	Namespaces        []string         `yaml:"namespaces"`
+	NonResourceURLs   []string         `yaml:"nonResourceURLs"`
+	OmitStages        []string         `yaml:"omitStages"`
+	OmitManagedFields *bool            `yaml:"omitManagedFields"`
+}
+
+type GroupResources struct {
+	Group         string   `yaml:"group"`
+	Resources     []string `yaml:"resources"`
+	ResourceNames []string `yaml:"resourceNames"`
+}
+
+var (
+	levels = []string{"None", "Metadata", "Request", "RequestResponse"}
+	stages = []string{"RequestReceived", "ResponseStarted", "ResponseComplete", "Panic"}
+)
+
+// Validate checks that data is an audit.k8s.io/v1 Policy the API server will
+// load: a misspelt field or level would otherwise only show as a crashlooping
+// kube-apiserver after kubeadm init.
+func Validate(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("audit policy is empty")
+		}
+		return fmt.Errorf("audit policy: %w", err)
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("audit policy: expected a single YAML document")
+	}
+
+	var errs []string
+	if p.APIVersion != "audit.k8s.io/v1" || p.Kind != "Policy" {
+		errs = append(errs, fmt.Sprintf("apiVersion/kind is %q/%q, want audit.k8s.io/v1/Policy", p.APIVersion, p.Kind))
+	}
+	if len(p.Rules) == 0 {
+		errs = append(errs, "no rules: the API server would audit nothing")
+	}
+	errs = append(errs, validateStages("omitStages", p.OmitStages)...)
+	for i, r := range p.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		if !slices.Contains(levels, r.Level) {
+			errs = append(errs, fmt.Sprintf("%s.level %q is invalid (allowed: %s)", field, r.Level, strings.Join(levels, ", ")))
+		}
+		if len(r.NonResourceURLs) > 0 && (len(r.Resources) > 0 || len(r.Namespaces) > 0) {
+			errs = append(errs, fmt.Sprintf("%s: nonResourceURLs cannot be combined with resources or namespaces", field))
+		}
+		for _, u := range r.NonResourceURLs {
+			if (!strings.HasPrefix(u, "/") && u != "*") || strings.Contains(strings.TrimSuffix(u, "*"), "*") {
+				errs = append(errs, fmt.Sprintf("%s.nonResourceURLs: %q must be a path, with * only at the end", field, u))
+			}
+		}
+		for j, gr := range r.Resources {
+			if len(gr.ResourceNames) > 0 && slices.ContainsFunc(gr.Resources, func(res string) bool { return strings.Contains(res, "*") }) {
+				errs = append(errs, fmt.Sprintf("%s.resources[%d]: resourceNames cannot be combined with wildcard resources", field, j))
+			}
+		}
+		errs = append(errs, validateStages(field+".omitStages", r.OmitStages)...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("audit policy: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func validateStages(field string, values []string) []string {
+	var errs []string
+	for _, s := range values {
+		if !slices.Contains(stages, s) {
+			errs = append(errs, fmt.Sprintf("%s: %q is not a stage (allowed: %s)", field, s, strings.Join(stages, ", ")))
+		}
+	}
+	return errs
+}
+
+// WebhookKubeconfig renders the kubeconfig --audit-webhook-config-file points
+// at: the API server posts event batches to url, trusting the PEM bundle ca
+// when set and the system roots otherwise.
+func WebhookKubeconfig(url string, ca []byte) (string, error) {
+	if len(ca) > 0 && !x509.NewCertPool().AppendCertsFromPEM(ca) {
+		return "", fmt.Errorf("audit webhook CA: no PEM certificate found")
+	}
+	cfg := api.NewConfig()
+	cfg.Clusters["audit-webhook"] = &api.Cluster{Server: url, CertificateAuthorityData: ca}
+	cfg.AuthInfos["kube-apiserver"] = &api.AuthInfo{}
+	cfg.Contexts["audit-webhook"] = &api.Context{Cluster: "audit-webhook", AuthInfo: "kube-apiserver"}
+	cfg.CurrentContext = "audit-webhook"
+	data, err := clientcmd.Write(*cfg)
+	if err != nil {
+		return "", fmt.Errorf("render audit webhook kubeconfig: %w", err)
+	}
+	return string(data), nil
+}
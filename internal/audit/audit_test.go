@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresets_AreValidPolicies(t *testing.T) {
+	for name, policy := range Presets {
+		assert.NoError(t, Validate([]byte(policy)), name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{"wrong kind", "apiVersion: audit.k8s.io/v1beta1\nkind: Policy\nrules:\n  - level: Metadata\n", "want audit.k8s.io/v1/Policy"},
+		{"no rules", "apiVersion: audit.k8s.io/v1\nkind: Policy\n", "no rules"},
+		{"misspelt field", "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n  - levl: Metadata\n", "field levl not found"},
+		{"unknown level", "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n  - level: metadata\n", `rules[0].level "metadata" is invalid`},
+		{"unknown stage", "apiVersion: audit.k8s.io/v1\nkind: Policy\nomitStages: [Received]\nrules:\n  - level: Metadata\n", `omitStages: "Received" is not a stage`},
+		{"mixed rule", "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n  - level: None\n    nonResourceURLs: [/healthz]\n    namespaces: [default]\n", "cannot be combined with resources or namespaces"},
+		{"inner wildcard", "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n  - level: None\n    nonResourceURLs: [/api/*/x]\n", "with * only at the end"},
+		{"two documents", "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n  - level: Metadata\n---\nkind: Policy\n", "single YAML document"},
+		{"empty", "", "audit policy is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(tt.policy))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestWebhookKubeconfig(t *testing.T) {
+	out, err := WebhookKubeconfig("https://audit.example.com/events", nil)
+	require.NoError(t, err)
+	assert.Contains(t, out, "server: https://audit.example.com/events\n")
+	assert.Contains(t, out, "current-context: audit-webhook\n")
+
+	_, err = WebhookKubeconfig("https://audit.example.com/events", []byte("not a certificate"))
+	require.Error(t, err)
+}
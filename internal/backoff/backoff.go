@@ -0,0 +1,193 @@
+// Package backoff is the retry policy shared by every readiness wait and
+// retried operation in the provisioner and installers, replacing hand-rolled
+// loops (each with its own attempt count and message) with one declarative
+// Policy and a uniform progress line.
+package backoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+// Default backoff shape: each pause grows by Factor up to MaxInterval, spread
+// by ±Jitter so several components polling the API server do not fall into
+// lockstep.
+const (
+	Factor      = 1.5
+	Jitter      = 0.2
+	MaxInterval = 30 * time.Second
+)
+
+// Policy describes how often and for how long to retry. Delays start at
+// Initial, grow by Factor up to Max, and are spread by ±Jitter.
+type Policy struct {
+	Initial time.Duration
+	Factor  float64
+	Max     time.Duration
+	Jitter  float64 // fraction of each delay, e.g. 0.2 = ±20%
+	// MaxElapsed bounds the whole wait; 0 = bounded by Attempts only, negative
+	// = already expired (one try). Elapsed time counts the larger of wall
+	// clock and time slept, so a condition checked by a slow command still
+	// gives up on schedule.
+	MaxElapsed time.Duration
+	// Attempts bounds the number of tries; 0 = bounded by MaxElapsed only.
+	Attempts int
+	// Sleep pauses between tries; nil uses a timer that stops early when ctx
+	// is cancelled. Tests replace it to skip the waits.
+	Sleep func(ctx context.Context, d time.Duration) error
+	// Out receives the progress lines; nil is os.Stdout.
+	Out io.Writer
+}
+
+// PollUntil is the standard readiness policy: first re-check after interval,
+// backing off to MaxInterval, giving up after timeout. A timeout that has
+// already run out (<= 0) allows a single check rather than none or forever.
+func PollUntil(timeout, interval time.Duration) Policy {
+	if timeout <= 0 {
+		timeout = -1
+	}
+	return Policy{
+		Initial:    interval,
+		Factor:     Factor,
+		Max:        MaxInterval,
+		Jitter:     Jitter,
+		MaxElapsed: timeout,
+	}
+}
+
+// Times retries up to attempts times, interval apart. The interval does not
+// grow, so attempts × interval stays the total budget.
+func Times(attempts int, interval time.Duration) Policy {
+	return Policy{
+		Initial:  interval,
+		Factor:   1,
+		Max:      interval,
+		Jitter:   Jitter,
+		Attempts: attempts,
+	}
+}
+
+// Delay returns the pause before try n+1 (n >= 1), jittered.
+func (b Policy) Delay(n int) time.Duration {
+	d := float64(b.Initial)
+	for i := 1; i < n && d < float64(b.Max); i++ {
+		d *= b.Factor
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d *= 1 + b.Jitter*(2*rand.Float64()-1) //nolint:gosec // jitter, not a secret
+	}
+	return time.Duration(d)
+}
+
+// exhausted reports whether another try after n tries would break the policy.
+func (b Policy) exhausted(n int, elapsed time.Duration) bool {
+	if b.Attempts > 0 && n >= b.Attempts {
+		return true
+	}
+	if b.MaxElapsed < 0 {
+		return true
+	}
+	return b.MaxElapsed > 0 && elapsed >= b.MaxElapsed
+}
+
+func (b Policy) sleep(ctx context.Context, d time.Duration) error {
+	if b.Sleep != nil {
+		return b.Sleep(ctx, d)
+	}
+	return Sleep(ctx, d)
+}
+
+// Sleep pauses for d, returning early with ctx.Err() when ctx is cancelled so
+// poll loops stop on Ctrl-C instead of running out their full deadline.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ExhaustedError is returned by Attempt when the policy runs out: after Tries
+// attempts and Elapsed time, with the last error from try (nil for polls).
+type ExhaustedError struct {
+	Tries   int
+	Elapsed time.Duration
+	Last    error
+}
+
+func (e *ExhaustedError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts (%s): %v", e.Tries, e.Elapsed.Round(time.Second), e.Last)
+}
+
+func (e *ExhaustedError) Unwrap() error { return e.Last }
+
+// Attempt runs try under b until it reports done (nil), the policy runs out
+// (*ExhaustedError) or ctx is cancelled (ctx.Err()). Each pause prints one
+// uniform progress line.
+func Attempt(ctx context.Context, what string, b Policy, try func() (done bool, err error)) error {
+	start := time.Now()
+	var slept time.Duration
+	for n := 1; ; n++ {
+		done, err := try()
+		if done {
+			return nil
+		}
+
+		elapsed := max(time.Since(start), slept)
+		if b.exhausted(n, elapsed) {
+			return &ExhaustedError{Tries: n, Elapsed: elapsed, Last: err}
+		}
+
+		d := b.Delay(n)
+		out := b.Out
+		if out == nil {
+			out = os.Stdout
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Waiting for %s... (attempt %d, %s elapsed, retry in %s: %v)\n",
+				what, n, elapsed.Round(time.Second), d.Round(time.Second), err)
+		} else {
+			fmt.Fprintf(out, "Waiting for %s... (attempt %d, %s elapsed, retry in %s)\n",
+				what, n, elapsed.Round(time.Second), d.Round(time.Second))
+		}
+		if err := b.sleep(ctx, d); err != nil {
+			return err
+		}
+		slept += d
+	}
+}
+
+// WaitFor polls ready under b until it returns true.
+func WaitFor(ctx context.Context, what string, b Policy, ready func() bool) error {
+	err := Attempt(ctx, what, b, func() (bool, error) { return ready(), nil })
+	var ex *ExhaustedError
+	if errors.As(err, &ex) {
+		return fmt.Errorf("timeout waiting for %s after %s", what, ex.Elapsed.Round(time.Second))
+	}
+	return err
+}
+
+// Retry calls fn under b until it succeeds, returning the last error when the
+// policy is exhausted.
+func Retry(ctx context.Context, what string, b Policy, fn func() error) error {
+	err := Attempt(ctx, what, b, func() (bool, error) {
+		err := fn()
+		return err == nil, err
+	})
+	var ex *ExhaustedError
+	if errors.As(err, &ex) {
+		return fmt.Errorf("%s: giving up after %d attempts: %w", what, ex.Tries, ex.Last)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noWait skips the pauses; elapsed time still advances by the delays slept.
+func noWait(b Policy) Policy {
+	b.Sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	return b
+}
+
+func TestDelay_GrowsAndCaps(t *testing.T) {
+	b := Policy{Initial: 10 * time.Second, Factor: 2, Max: 30 * time.Second}
+
+	assert.Equal(t, 10*time.Second, b.Delay(1))
+	assert.Equal(t, 20*time.Second, b.Delay(2))
+	assert.Equal(t, 30*time.Second, b.Delay(3), "capped at max")
+	assert.Equal(t, 30*time.Second, b.Delay(50))
+}
+
+func TestDelay_JitterStaysInBounds(t *testing.T) {
+	b := Policy{Initial: 10 * time.Second, Factor: 1, Max: time.Minute, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		d := b.Delay(1)
+		assert.GreaterOrEqual(t, d, 8*time.Second)
+		assert.LessOrEqual(t, d, 12*time.Second)
+	}
+}
+
+func TestTimes_KeepsIntervalFixed(t *testing.T) {
+	b := Times(30, 10*time.Second)
+	b.Jitter = 0
+	assert.Equal(t, 10*time.Second, b.Delay(1))
+	assert.Equal(t, 10*time.Second, b.Delay(30), "attempts × interval stays the total budget")
+}
+
+func TestWaitFor_ReturnsOnceReady(t *testing.T) {
+	checks := 0
+	err := WaitFor(context.Background(), "thing", noWait(PollUntil(time.Minute, time.Second)), func() bool {
+		checks++
+		return checks == 3
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, checks)
+}
+
+func TestWaitFor_TimesOutOnElapsedTime(t *testing.T) {
+	// Sleeps are skipped, so the deadline is reached through slept time alone.
+	err := WaitFor(context.Background(), "thing", noWait(PollUntil(time.Minute, 10*time.Second)), func() bool { return false })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timeout waiting for thing")
+}
+
+func TestWaitFor_SpentBudgetChecksOnce(t *testing.T) {
+	// A budget already used up by an earlier wait (Keycloak shares one timeout
+	// across two) must not turn into "no limit".
+	for _, timeout := range []time.Duration{0, -time.Second} {
+		checks := 0
+		err := WaitFor(context.Background(), "thing", noWait(PollUntil(timeout, time.Second)), func() bool {
+			checks++
+			return false
+		})
+		require.Error(t, err)
+		assert.Equal(t, 1, checks, "timeout %s", timeout)
+	}
+}
+
+func TestRetry_StopsAfterAttemptsWithLastError(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), "apply", noWait(Times(3, time.Second)), func() error {
+		calls++
+		return errors.New("webhook not ready")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Contains(t, err.Error(), "giving up after 3 attempts: webhook not ready")
+}
+
+func TestRetry_CancelledContextStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Retry(ctx, "apply", noWait(Times(10, time.Second)), func() error {
+		calls++
+		cancel()
+		return errors.New("boom")
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
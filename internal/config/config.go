@@ -1,13 +1,20 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
 	"net"
+	"net/url"
 	"os"
+	"regexp"
 	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
 )
 
 type Config struct {
@@ -21,6 +28,86 @@ type Config struct {
 	Ollama       OllamaConfig       `yaml:"ollama"`
 	Vault        VaultConfig        `yaml:"vault"`
 	Provisioning ProvisioningConfig `yaml:"provisioning"`
+	Kubeadm      KubeadmConfig      `yaml:"kubeadm"`
+	Audit        AuditConfig        `yaml:"audit"`
+	DNS          DNSConfig          `yaml:"dns"`
+	Offline      OfflineConfig      `yaml:"offline"`
+	Registries   []RegistryConfig   `yaml:"registries"`
+	// Checksums pins the SHA-256 of downloads by URL, over the checksums
+	// shipped with the binary, e.g. for a version bumped in versions:.
+	Checksums map[string]string `yaml:"checksums"`
+}
+
+// OfflineConfig makes provisioning read packages, manifests, charts,
+// binaries and images from a bundle built by `k8s-provisioner bundle`
+// instead of the network.
+type OfflineConfig struct {
+	// Bundle is the bundle tarball's path on every node (e.g. on a shared
+	// folder); empty = online.
+	Bundle string `yaml:"bundle"`
+}
+
+// Enabled reports whether provisioning runs from a bundle.
+func (o OfflineConfig) Enabled() bool { return o.Bundle != "" }
+
+// AuditConfig selects the API server audit policy and where events go besides
+// the API server's stdout, which Alloy ships to Loki. Like the kubeadm
+// section, it is applied when a control plane is initialised or joins.
+type AuditConfig struct {
+	Policy string `yaml:"policy"` // minimal, default (default) or secrets-read
+	// PolicyFile is an audit.k8s.io/v1 Policy on the machine running the
+	// provisioner, used instead of a preset.
+	PolicyFile string             `yaml:"policy_file"`
+	Webhook    AuditWebhookConfig `yaml:"webhook"`
+}
+
+// AuditWebhookConfig sends audit events to an HTTPS endpoint as well, in
+// audit.k8s.io/v1 EventList batches.
+type AuditWebhookConfig struct {
+	URL    string `yaml:"url"`     // e.g. https://audit.example.com/events; empty = no webhook
+	CAFile string `yaml:"ca_file"` // PEM bundle to trust for url; empty = system roots
+	Mode   string `yaml:"mode"`    // batch (default), blocking or blocking-strict
+}
+
+// KubeadmConfig tunes the configuration kubeadm init creates the cluster
+// with. Flags the provisioner sets itself (ManagedFlags) cannot be
+// overridden here. Changes only take effect on a new cluster: kubeadm keeps
+// the configuration it was initialised with.
+type KubeadmConfig struct {
+	// FeatureGates are passed to the API server, controller manager,
+	// scheduler and kubelet alike.
+	FeatureGates               map[string]bool   `yaml:"feature_gates"`
+	APIServerExtraArgs         map[string]string `yaml:"apiserver_extra_args"` // flag name without "--" -> value
+	ControllerManagerExtraArgs map[string]string `yaml:"controller_manager_extra_args"`
+	SchedulerExtraArgs         map[string]string `yaml:"scheduler_extra_args"`
+	Kubelet                    KubeletConfig     `yaml:"kubelet"`
+}
+
+// KubeletConfig sets KubeletConfiguration fields on every node. Empty means
+// the kubelet default.
+type KubeletConfig struct {
+	MaxPods        int               `yaml:"max_pods"`        // default 110
+	SystemReserved map[string]string `yaml:"system_reserved"` // e.g. cpu: 100m, memory: 256Mi
+	KubeReserved   map[string]string `yaml:"kube_reserved"`
+	// EvictionHard replaces the kubelet's default thresholds as a whole, e.g.
+	// memory.available: 200Mi.
+	EvictionHard map[string]string `yaml:"eviction_hard"`
+}
+
+// ManagedFlags are the control plane flags the provisioner sets (or kubeadm
+// derives from cluster and network settings), by kubeadm.*_extra_args key.
+// Overriding them would break audit logging, networking or the Keycloak OIDC
+// patch, so validation rejects them.
+var ManagedFlags = map[string][]string{
+	"apiserver_extra_args": {
+		"advertise-address", "secure-port", "service-cluster-ip-range",
+		"audit-policy-file", "audit-log-path", "audit-webhook-config-file", "audit-webhook-mode",
+		"authentication-config", "feature-gates",
+	},
+	"controller_manager_extra_args": {
+		"allocate-node-cidrs", "cluster-cidr", "service-cluster-ip-range", "feature-gates",
+	},
+	"scheduler_extra_args": {"feature-gates"},
 }
 
 type VaultConfig struct {
@@ -30,13 +117,25 @@ type VaultConfig struct {
 }
 
 // ProvisioningConfig controls node-to-node SSH used to transport Vault init data
-// from the controlplane to the storage node. Defaults target the Vagrant lab box;
+// from the controlplane to the storage node and join credentials to the nodes. Defaults target the Vagrant lab box;
 // override ssh_key_path (preferred) or ssh_password for non-lab environments
 // instead of relying on the hard-coded Vagrant credentials.
 type ProvisioningConfig struct {
-	SSHUser     string `yaml:"ssh_user"`     // default: vagrant
-	SSHPassword string `yaml:"ssh_password"` // password auth; ignored when ssh_key_path is set
-	SSHKeyPath  string `yaml:"ssh_key_path"` // private key for key-based auth (preferred)
+	SSHUser     string     `yaml:"ssh_user"`     // default: vagrant
+	SSHPassword string     `yaml:"ssh_password"` // password auth; ignored when ssh_key_path is set
+	SSHKeyPath  string     `yaml:"ssh_key_path"` // private key for key-based auth (preferred)
+	SSHPort     int        `yaml:"ssh_port"`     // default: 22; used by --node remote provisioning
+	Join        JoinConfig `yaml:"join"`         // how nodes get their join credentials
+}
+
+// JoinConfig selects how a node provisioned on itself gets its join
+// credentials (bootstrap token, CA hash). By default it fetches them from the
+// first control plane over SSH with the credentials above; with url set, from
+// the HTTPS endpoint `k8s-provisioner join serve` runs there instead, whose
+// self-signed certificate must have the given fingerprint.
+type JoinConfig struct {
+	URL         string `yaml:"url"`         // e.g. https://192.168.56.10:9444; empty = SSH
+	Fingerprint string `yaml:"fingerprint"` // sha256:<hex>, printed by join serve
 }
 
 // SSHUser returns the configured SSH user, defaulting to the Vagrant box user.
@@ -60,7 +159,10 @@ type ClusterConfig struct {
 type VersionsConfig struct {
 	Kubernetes         string `yaml:"kubernetes"`
 	CriO               string `yaml:"crio"`
+	Containerd         string `yaml:"containerd"` // e.g. 2.1.4; with components.runtime: containerd
 	Calico             string `yaml:"calico"`
+	Cilium             string `yaml:"cilium"`  // e.g. 1.18.2; with components.cni: cilium
+	Flannel            string `yaml:"flannel"` // e.g. v0.27.4; with components.cni: flannel
 	MetalLB            string `yaml:"metallb"`
 	Istio              string `yaml:"istio"`
 	Karpor             string `yaml:"karpor"`
@@ -77,12 +179,51 @@ type VersionsConfig struct {
 	MetricsServer      string `yaml:"metrics_server"`
 	PrometheusOperator string `yaml:"prometheus_operator"`
 	CertManager        string `yaml:"cert_manager"`
+	KubeVIP            string `yaml:"kube_vip"` // required with network.controlplane_vip
 }
 
 type NetworkConfig struct {
 	Interface      string `yaml:"interface"`
 	ControlPlaneIP string `yaml:"controlplane_ip"`
-	MetalLBRange   string `yaml:"metallb_range"`
+	// ControlPlaneVIP is the virtual IP kube-vip serves the API server on when
+	// there are several controlplane nodes (required then).
+	ControlPlaneVIP string `yaml:"controlplane_vip"`
+	MetalLBRange    string `yaml:"metallb_range"`
+}
+
+// DNSConfig sets the resolvers the nodes' /etc/resolv.conf and CoreDNS
+// forward to. Networks that block public resolvers list their own.
+type DNSConfig struct {
+	Upstream      []string `yaml:"upstream"`       // resolver IPs, at most 3; default 8.8.8.8, 1.1.1.1
+	SearchDomains []string `yaml:"search_domains"` // search list of the nodes' resolv.conf
+	// StubZones are resolved by CoreDNS, for pods, other than through the
+	// upstream resolvers.
+	StubZones []StubZone `yaml:"stub_zones"`
+}
+
+// StubZone sends a domain to its own resolvers, or answers every name in it
+// with one address (e.g. local to the Istio ingress IP, for *.local).
+type StubZone struct {
+	Zone    string   `yaml:"zone"`    // e.g. corp.example.com
+	Forward []string `yaml:"forward"` // resolver IPs for zone
+	Address string   `yaml:"address"` // IPv4 address every name in zone resolves to
+}
+
+// RegistryConfig tells CRI-O how to pull from one registry: the mirrors to
+// try first (e.g. a pull-through cache), how to trust it and how to log in.
+// It is rendered into /etc/containers on every node.
+type RegistryConfig struct {
+	// Registry is the host[:port] images name, e.g. docker.io or
+	// harbor.lab:5000.
+	Registry string `yaml:"registry"`
+	// Mirrors are tried in order before Registry, as host[:port][/path]. A
+	// mirror that is listed as a registry of its own takes its insecure flag
+	// from there, and its CA and credentials apply when pulling from it.
+	Mirrors  []string `yaml:"mirrors"`
+	Insecure bool     `yaml:"insecure"` // plain HTTP, or TLS without verification
+	CAFile   string   `yaml:"ca_file"`  // PEM CA bundle on the machine running k8s-provisioner
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"` // or K8S_PROV_REGISTRY_PASSWORD_<REGISTRY>, see RegistryPasswordEnv
 }
 
 type StorageConfig struct {
@@ -98,7 +239,9 @@ type NodeConfig struct {
 }
 
 type ComponentsConfig struct {
-	CNI          string `yaml:"cni"`
+	Runtime      string `yaml:"runtime"` // Options: crio (default), containerd
+	CNI          string `yaml:"cni"`     // Options: calico (default), cilium, flannel
+	Hubble       string `yaml:"hubble"`  // Options: enabled, none (Hubble UI; with cni: cilium)
 	LoadBalancer string `yaml:"load_balancer"`
 	ServiceMesh  string `yaml:"service_mesh"`
 	Monitoring   string `yaml:"monitoring"`
@@ -132,6 +275,7 @@ func Load(path string) (*Config, error) {
 	// Secrets may be supplied via environment so real values never need to live in
 	// the tracked config.yaml (which carries only empty placeholders). Env wins.
 	applyEnvSecrets(&cfg)
+	redact.Add(cfg.Secrets()...)
 
 	// Node IPs in `nodes:` are the single source of truth. Derive the control
 	// plane IP from the controlplane node when network.controlplane_ip is unset,
@@ -165,6 +309,49 @@ func applyEnvSecrets(cfg *Config) {
 	if v := os.Getenv("KARPOR_AUTH_TOKEN"); v != "" {
 		cfg.KarporAI.AuthToken = v
 	}
+	for i := range cfg.Registries {
+		if v := os.Getenv(RegistryPasswordEnv(cfg.Registries[i].Registry)); v != "" {
+			cfg.Registries[i].Password = v
+		}
+	}
+}
+
+// RegistryPasswordEnv is the environment variable overriding the password of
+// registry: K8S_PROV_REGISTRY_PASSWORD_ and the registry uppercased, with every
+// other character than a letter or digit replaced by _ (harbor.lab:5000 gives
+// K8S_PROV_REGISTRY_PASSWORD_HARBOR_LAB_5000).
+func RegistryPasswordEnv(registry string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, registry)
+	return "K8S_PROV_REGISTRY_PASSWORD_" + name
+}
+
+// Secrets returns the secret-bearing values of c (after applyEnvSecrets), for
+// the redaction registry. Empty fields are included; redact.Add skips them.
+func (c *Config) Secrets() []string {
+	secrets := []string{c.Vault.Token, c.Provisioning.SSHPassword, c.Ollama.APIKey, c.KarporAI.AuthToken}
+	for _, r := range c.Registries {
+		secrets = append(secrets, r.Password)
+	}
+	return secrets
+}
+
+// Hash fingerprints the whole configuration, so a checkpoint recorded against
+// it can tell whether config.yaml (or a secret override) changed since.
+func (c *Config) Hash() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 // Validate checks all required fields and formats
@@ -190,8 +377,15 @@ func (c *Config) Validate() error {
 	if c.Versions.Kubernetes == "" {
 		errors = append(errors, "versions.kubernetes is required")
 	}
-	if c.Versions.CriO == "" {
-		errors = append(errors, "versions.crio is required")
+	switch c.Runtime() {
+	case "crio":
+		if c.Versions.CriO == "" {
+			errors = append(errors, "versions.crio is required")
+		}
+	case "containerd":
+		if c.Versions.Containerd == "" {
+			errors = append(errors, "versions.containerd is required with components.runtime: containerd")
+		}
 	}
 
 	// Network validation
@@ -203,6 +397,7 @@ func (c *Config) Validate() error {
 	} else if !isValidIP(c.Network.ControlPlaneIP) {
 		errors = append(errors, fmt.Sprintf("network.controlplane_ip '%s' is not a valid IP address", c.Network.ControlPlaneIP))
 	}
+	errors = append(errors, c.validateControlPlaneVIP()...)
 	if c.Network.MetalLBRange != "" {
 		if err := validateIPRange(c.Network.MetalLBRange); err != nil {
 			errors = append(errors, fmt.Sprintf("network.metallb_range: %v", err))
@@ -224,6 +419,22 @@ func (c *Config) Validate() error {
 	if err := validateSSHPassword(c.Provisioning.SSHPassword); err != nil {
 		errors = append(errors, fmt.Sprintf("provisioning.ssh_password: %v", err))
 	}
+	if p := c.Provisioning.SSHPort; p < 0 || p > 65535 {
+		errors = append(errors, fmt.Sprintf("provisioning.ssh_port %d is out of range (1-65535)", p))
+	}
+	errors = append(errors, validateJoin(c.Provisioning.Join)...)
+	errors = append(errors, validateKubeadm(c.Kubeadm)...)
+	errors = append(errors, validateAudit(c.Audit)...)
+	errors = append(errors, validateDNS(c.DNS)...)
+	if b := c.Offline.Bundle; b != "" && !strings.HasPrefix(b, "/") {
+		errors = append(errors, fmt.Sprintf("offline.bundle '%s' must be an absolute path on the nodes", b))
+	}
+	errors = append(errors, validateRegistries(c.Registries, c.Runtime())...)
+	for _, url := range slices.Sorted(maps.Keys(c.Checksums)) {
+		if sum := c.Checksums[url]; !checksumPattern.MatchString(sum) {
+			errors = append(errors, fmt.Sprintf("checksums: '%s' for %s is not a SHA-256 in lowercase hex", sum, url))
+		}
+	}
 
 	// Nodes validation
 	errors = append(errors, validateNodes(c.Nodes)...)
@@ -240,6 +451,9 @@ func (c *Config) Validate() error {
 		value   string
 		allowed []string
 	}{
+		{"components.runtime", c.Components.Runtime, []string{"crio", "containerd"}},
+		{"components.cni", c.Components.CNI, []string{"calico", "cilium", "flannel"}},
+		{"components.hubble", c.Components.Hubble, []string{"enabled", "disabled", "none"}},
 		{"components.service_mesh", c.Components.ServiceMesh, []string{"istio", "none"}},
 		{"components.monitoring", c.Components.Monitoring, []string{"prometheus-stack", "none"}},
 		{"components.logging", c.Components.Logging, []string{"loki", "none"}},
@@ -259,6 +473,10 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Components.Hubble == "enabled" && c.CNI() != "cilium" {
+		errors = append(errors, "components.hubble requires components.cni: cilium")
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("%s", strings.Join(errors, "; "))
 	}
@@ -289,6 +507,225 @@ func validateNodes(nodes []NodeConfig) []string {
 	return errs
 }
 
+// fingerprintPattern matches a SHA-256 certificate fingerprint as join serve
+// prints it.
+var fingerprintPattern = regexp.MustCompile(`^sha256:[0-9a-fA-F]{64}$`)
+
+// checksumPattern matches a SHA-256 as sha256sum prints it.
+var checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// validateJoin requires an https join endpoint and the fingerprint to pin its
+// certificate to: a node must not take credentials from an impostor.
+func validateJoin(j JoinConfig) []string {
+	if j.URL == "" {
+		if j.Fingerprint != "" {
+			return []string{"provisioning.join.fingerprint is set but provisioning.join.url is empty"}
+		}
+		return nil
+	}
+	var errs []string
+	if u, err := url.Parse(j.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+		errs = append(errs, fmt.Sprintf("provisioning.join.url %q must be an https:// URL", j.URL))
+	}
+	if !fingerprintPattern.MatchString(j.Fingerprint) {
+		errs = append(errs, "provisioning.join.fingerprint must be sha256:<64 hex digits> (printed by join serve) when provisioning.join.url is set")
+	}
+	return errs
+}
+
+// validateKubeadm rejects extra args that would override a managed flag, and
+// kubelet settings the kubelet would refuse at startup.
+func validateKubeadm(k KubeadmConfig) []string {
+	var errs []string
+	for _, args := range []struct {
+		key  string
+		args map[string]string
+	}{
+		{"apiserver_extra_args", k.APIServerExtraArgs},
+		{"controller_manager_extra_args", k.ControllerManagerExtraArgs},
+		{"scheduler_extra_args", k.SchedulerExtraArgs},
+	} {
+		for _, name := range slices.Sorted(maps.Keys(args.args)) {
+			switch {
+			case strings.HasPrefix(name, "-"):
+				errs = append(errs, fmt.Sprintf("kubeadm.%s: write %q without the leading dashes", args.key, name))
+			case name == "feature-gates":
+				errs = append(errs, fmt.Sprintf("kubeadm.%s: set feature gates in kubeadm.feature_gates", args.key))
+			case slices.Contains(ManagedFlags[args.key], name):
+				errs = append(errs, fmt.Sprintf("kubeadm.%s: %s is managed by the provisioner", args.key, name))
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(k.FeatureGates)) {
+		if name == "" || strings.ContainsAny(name, "=, ") {
+			errs = append(errs, fmt.Sprintf("kubeadm.feature_gates: %q is not a feature gate name", name))
+		}
+	}
+
+	if k.Kubelet.MaxPods < 0 {
+		errs = append(errs, "kubeadm.kubelet.max_pods must not be negative")
+	}
+	resources := []string{"cpu", "memory", "ephemeral-storage", "pid"}
+	signals := []string{"memory.available", "nodefs.available", "nodefs.inodesFree", "imagefs.available", "imagefs.inodesFree", "pid.available"}
+	for _, m := range []struct {
+		key     string
+		values  map[string]string
+		allowed []string
+	}{
+		{"system_reserved", k.Kubelet.SystemReserved, resources},
+		{"kube_reserved", k.Kubelet.KubeReserved, resources},
+		{"eviction_hard", k.Kubelet.EvictionHard, signals},
+	} {
+		for _, name := range slices.Sorted(maps.Keys(m.values)) {
+			if !slices.Contains(m.allowed, name) {
+				errs = append(errs, fmt.Sprintf("kubeadm.kubelet.%s: '%s' is invalid (allowed: %s)", m.key, name, strings.Join(m.allowed, ", ")))
+			} else if m.values[name] == "" {
+				errs = append(errs, fmt.Sprintf("kubeadm.kubelet.%s: %s has no value", m.key, name))
+			}
+		}
+	}
+	return errs
+}
+
+// validateAudit checks the audit section's shape. The policy itself is read and
+// validated when it is written to a control plane (see audit.Validate).
+func validateAudit(a AuditConfig) []string {
+	var errs []string
+	if a.Policy != "" && a.PolicyFile != "" {
+		errs = append(errs, "audit.policy and audit.policy_file are mutually exclusive")
+	}
+	if presets := []string{"minimal", "default", "secrets-read"}; a.Policy != "" && !slices.Contains(presets, a.Policy) {
+		errs = append(errs, fmt.Sprintf("audit.policy '%s' is invalid (allowed: %s; or set audit.policy_file)", a.Policy, strings.Join(presets, ", ")))
+	}
+
+	w := a.Webhook
+	if w.URL == "" {
+		if w.CAFile != "" || w.Mode != "" {
+			errs = append(errs, "audit.webhook.ca_file and audit.webhook.mode need audit.webhook.url")
+		}
+		return errs
+	}
+	if u, err := url.Parse(w.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+		errs = append(errs, fmt.Sprintf("audit.webhook.url %q must be an https:// URL", w.URL))
+	}
+	if modes := []string{"batch", "blocking", "blocking-strict"}; w.Mode != "" && !slices.Contains(modes, w.Mode) {
+		errs = append(errs, fmt.Sprintf("audit.webhook.mode '%s' is invalid (allowed: %s)", w.Mode, strings.Join(modes, ", ")))
+	}
+	return errs
+}
+
+// domainPattern matches a DNS domain name such as corp.example.com.
+var domainPattern = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?$`)
+
+// validateDNS checks resolver addresses and domain names before they are
+// written into resolv.conf and the Corefile, where a bad entry would break
+// name resolution for the nodes or every pod.
+// registryHostPattern matches the host[:port] an image reference starts with.
+var registryHostPattern = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?(:[0-9]{1,5})?$`)
+
+// repositoryPattern matches the optional path of a mirror after its host.
+var repositoryPattern = regexp.MustCompile(`^[a-z0-9._/-]*$`)
+
+func validateRegistries(registries []RegistryConfig, runtime string) []string {
+	var errs []string
+	if len(registries) > 0 && runtime != "crio" {
+		errs = append(errs, fmt.Sprintf("registries configures CRI-O; components.runtime is %s", runtime))
+	}
+	seen := map[string]bool{}
+	for i, r := range registries {
+		field := fmt.Sprintf("registries[%d]", i)
+		switch {
+		case !registryHostPattern.MatchString(r.Registry):
+			errs = append(errs, fmt.Sprintf("%s.registry '%s' is not a host[:port]", field, r.Registry))
+		case seen[r.Registry]:
+			errs = append(errs, fmt.Sprintf("%s.registry %s is listed twice", field, r.Registry))
+		}
+		seen[r.Registry] = true
+		for _, m := range r.Mirrors {
+			host, repo, _ := strings.Cut(m, "/")
+			if !registryHostPattern.MatchString(host) || !repositoryPattern.MatchString(repo) {
+				errs = append(errs, fmt.Sprintf("%s.mirrors '%s' is not a host[:port][/path] (no scheme)", field, m))
+			}
+		}
+		if r.Insecure && r.CAFile != "" {
+			errs = append(errs, fmt.Sprintf("%s sets both insecure and ca_file; an insecure registry is not verified", field))
+		}
+		if (r.Username == "") != (r.Password == "") {
+			errs = append(errs, fmt.Sprintf("%s needs both username and password (or %s)", field, RegistryPasswordEnv(r.Registry)))
+		}
+	}
+	return errs
+}
+
+func validateDNS(d DNSConfig) []string {
+	var errs []string
+	if len(d.Upstream) > 3 {
+		errs = append(errs, fmt.Sprintf("dns.upstream has %d resolvers; the resolver only uses the first 3", len(d.Upstream)))
+	}
+	for _, ip := range d.Upstream {
+		if !isValidIP(ip) {
+			errs = append(errs, fmt.Sprintf("dns.upstream '%s' is not a valid IP address", ip))
+		}
+	}
+	for _, domain := range d.SearchDomains {
+		if !domainPattern.MatchString(domain) {
+			errs = append(errs, fmt.Sprintf("dns.search_domains '%s' is not a domain name", domain))
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, z := range d.StubZones {
+		field := fmt.Sprintf("dns.stub_zones[%d]", i)
+		switch {
+		case !domainPattern.MatchString(z.Zone):
+			errs = append(errs, fmt.Sprintf("%s.zone '%s' is not a domain name", field, z.Zone))
+		case z.Zone == "cluster.local" || strings.HasSuffix(z.Zone, ".cluster.local"):
+			errs = append(errs, fmt.Sprintf("%s.zone %s would shadow the cluster domain", field, z.Zone))
+		case seen[z.Zone]:
+			errs = append(errs, fmt.Sprintf("%s.zone %s is listed twice", field, z.Zone))
+		}
+		seen[z.Zone] = true
+		if (len(z.Forward) == 0) == (z.Address == "") {
+			errs = append(errs, fmt.Sprintf("%s needs exactly one of forward or address", field))
+		}
+		for _, ip := range z.Forward {
+			if !isValidIP(ip) {
+				errs = append(errs, fmt.Sprintf("%s.forward '%s' is not a valid IP address", field, ip))
+			}
+		}
+		if ip := net.ParseIP(z.Address); z.Address != "" && (ip == nil || ip.To4() == nil) {
+			errs = append(errs, fmt.Sprintf("%s.address '%s' is not a valid IPv4 address", field, z.Address))
+		}
+	}
+	return errs
+}
+
+// validateControlPlaneVIP requires a virtual IP, distinct from every node IP,
+// when several controlplane nodes share the API server, and a kube-vip version
+// to serve it with.
+func (c *Config) validateControlPlaneVIP() []string {
+	var errs []string
+	vip := c.Network.ControlPlaneVIP
+	if vip == "" {
+		if n := len(c.GetControlPlanes()); n > 1 {
+			errs = append(errs, fmt.Sprintf("network.controlplane_vip is required with %d controlplane nodes", n))
+		}
+		return errs
+	}
+	if !isValidIP(vip) {
+		return append(errs, fmt.Sprintf("network.controlplane_vip '%s' is not a valid IP address", vip))
+	}
+	for _, node := range c.Nodes {
+		if node.IP == vip {
+			errs = append(errs, fmt.Sprintf("network.controlplane_vip '%s' is already the ip of node %s", vip, node.Name))
+		}
+	}
+	if c.Versions.KubeVIP == "" {
+		errs = append(errs, "versions.kube_vip is required with network.controlplane_vip")
+	}
+	return errs
+}
+
 func hasControlPlaneNode(nodes []NodeConfig) bool {
 	for _, node := range nodes {
 		if node.Role == "controlplane" {
@@ -350,6 +787,8 @@ func validateIPRange(ipRange string) error {
 	return nil
 }
 
+// GetControlPlane returns the first controlplane node: the one kubeadm init
+// bootstraps the cluster on, and the one workloads are installed from.
 func (c *Config) GetControlPlane() *NodeConfig {
 	for _, node := range c.Nodes {
 		if node.Role == "controlplane" {
@@ -359,6 +798,37 @@ func (c *Config) GetControlPlane() *NodeConfig {
 	return nil
 }
 
+// GetControlPlanes returns every controlplane node, the first being the one
+// GetControlPlane returns.
+func (c *Config) GetControlPlanes() []NodeConfig {
+	var cps []NodeConfig
+	for _, node := range c.Nodes {
+		if node.Role == "controlplane" {
+			cps = append(cps, node)
+		}
+	}
+	return cps
+}
+
+// ControlPlaneEndpoint returns the address the API server is reached on:
+// network.controlplane_vip when set, otherwise network.controlplane_ip.
+func (c *Config) ControlPlaneEndpoint() string {
+	if c.Network.ControlPlaneVIP != "" {
+		return c.Network.ControlPlaneVIP
+	}
+	return c.Network.ControlPlaneIP
+}
+
+// GetNode returns the node named name, or nil if it is not defined.
+func (c *Config) GetNode(name string) *NodeConfig {
+	for _, node := range c.Nodes {
+		if node.Name == name {
+			return &node
+		}
+	}
+	return nil
+}
+
 func (c *Config) GetStorageNode() *NodeConfig {
 	for _, node := range c.Nodes {
 		if node.Role == "storage" {
@@ -377,6 +847,42 @@ func (c *Config) StorageIP() string {
 	return ""
 }
 
+// Runtime returns components.runtime, the container runtime the nodes run,
+// defaulting to CRI-O.
+func (c *Config) Runtime() string {
+	if c.Components.Runtime == "" {
+		return "crio"
+	}
+	return c.Components.Runtime
+}
+
+// CNI returns components.cni, the cluster network installed with the control
+// plane, defaulting to Calico.
+func (c *Config) CNI() string {
+	if c.Components.CNI == "" {
+		return "calico"
+	}
+	return c.Components.CNI
+}
+
+// DNSUpstream returns the resolvers the nodes and CoreDNS forward to,
+// defaulting to public ones.
+func (c *Config) DNSUpstream() []string {
+	if len(c.DNS.Upstream) == 0 {
+		return []string{"8.8.8.8", "1.1.1.1"}
+	}
+	return c.DNS.Upstream
+}
+
+// CRISocket returns the CRI endpoint of the configured runtime, as kubeadm's
+// nodeRegistration.criSocket expects it.
+func (c *Config) CRISocket() string {
+	if c.Runtime() == "containerd" {
+		return "unix:///run/containerd/containerd.sock"
+	}
+	return "unix:///var/run/crio/crio.sock"
+}
+
 // VaultAddress returns the configured Vault address. vault.addr (which also acts
 // as the Vault enable switch, see VaultConfig.Enabled) takes precedence; when
 // unset it is derived from the storage node IP so no address is hardcoded in Go.
@@ -3,10 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
 )
 
 func TestLoad_DerivesIPsFromNodes(t *testing.T) {
@@ -92,6 +95,14 @@ func TestApplyEnvSecrets(t *testing.T) {
 	assert.Equal(t, "keep-me", cfg2.Provisioning.SSHPassword, "unset env must not clear value")
 }
 
+func TestLoad_RegistersSecretsForRedaction(t *testing.T) {
+	t.Setenv("OLLAMA_API_KEY", "ollama-key-from-env")
+
+	_, err := Load("../../testdata/config_valid.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "--from-literal=key=***", redact.String("--from-literal=key=ollama-key-from-env"))
+}
+
 func TestLoad_ValidFile(t *testing.T) {
 	cfg, err := Load("../../testdata/config_valid.yaml")
 
@@ -507,3 +518,351 @@ func TestValidateIPRange(t *testing.T) {
 		})
 	}
 }
+
+func TestHash_ChangesWithConfig(t *testing.T) {
+	cfg := func() *Config {
+		return &Config{
+			Cluster:  ClusterConfig{Name: "test", PodCIDR: "10.244.0.0/16"},
+			Versions: VersionsConfig{Kubernetes: "1.32", CriO: "v1.32"},
+		}
+	}
+	a, b := cfg(), cfg()
+	assert.Equal(t, a.Hash(), b.Hash())
+
+	b.Versions.Kubernetes = "1.31"
+	assert.NotEqual(t, a.Hash(), b.Hash())
+}
+
+func TestValidate_ControlPlaneVIP(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+			Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34", KubeVIP: "v0.9.2"},
+			Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10", ControlPlaneVIP: "192.168.56.9"},
+			Storage:  StorageConfig{NFSPath: "/exports"},
+			Nodes: []NodeConfig{
+				{Name: "cp1", IP: "192.168.56.10", Role: "controlplane"},
+				{Name: "cp2", IP: "192.168.56.13", Role: "controlplane"},
+				{Name: "cp3", IP: "192.168.56.14", Role: "controlplane"},
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{name: "three control planes behind a VIP", modify: func(*Config) {}},
+		{name: "several control planes without a VIP", modify: func(c *Config) { c.Network.ControlPlaneVIP = "" },
+			err: "network.controlplane_vip is required with 3 controlplane nodes"},
+		{name: "VIP taken by a node", modify: func(c *Config) { c.Network.ControlPlaneVIP = "192.168.56.13" },
+			err: "already the ip of node cp2"},
+		{name: "no kube-vip version", modify: func(c *Config) { c.Versions.KubeVIP = "" },
+			err: "versions.kube_vip is required"},
+		{name: "single control plane needs no VIP", modify: func(c *Config) {
+			c.Network.ControlPlaneVIP = ""
+			c.Nodes = c.Nodes[:1]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestValidate_Join(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+			Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+			Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+			Storage:  StorageConfig{NFSPath: "/exports"},
+			Nodes:    []NodeConfig{{Name: "cp1", IP: "192.168.56.10", Role: "controlplane"}},
+		}
+	}
+	fingerprint := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name string
+		join JoinConfig
+		err  string
+	}{
+		{name: "over SSH by default"},
+		{name: "pinned HTTPS endpoint", join: JoinConfig{URL: "https://192.168.56.10:9444", Fingerprint: fingerprint}},
+		{name: "plain HTTP", join: JoinConfig{URL: "http://192.168.56.10:9444", Fingerprint: fingerprint},
+			err: "must be an https:// URL"},
+		{name: "endpoint without fingerprint", join: JoinConfig{URL: "https://192.168.56.10:9444"},
+			err: "provisioning.join.fingerprint must be sha256:"},
+		{name: "fingerprint without endpoint", join: JoinConfig{Fingerprint: fingerprint},
+			err: "provisioning.join.url is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			cfg.Provisioning.Join = tt.join
+			err := cfg.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestValidate_Runtime(t *testing.T) {
+	cfg := &Config{
+		Cluster:    ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions:   VersionsConfig{Kubernetes: "1.34"},
+		Network:    NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:    StorageConfig{NFSPath: "/exports"},
+		Nodes:      []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Components: ComponentsConfig{Runtime: "containerd"},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "versions.containerd is required with components.runtime: containerd")
+	assert.NotContains(t, err.Error(), "versions.crio", "CRI-O is not installed with containerd")
+
+	cfg.Versions.Containerd = "2.1.4"
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "unix:///run/containerd/containerd.sock", cfg.CRISocket())
+
+	cfg.Components.Runtime = ""
+	assert.Equal(t, "crio", cfg.Runtime())
+	assert.Equal(t, "unix:///var/run/crio/crio.sock", cfg.CRISocket())
+
+	cfg.Components.Runtime = "docker"
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "components.runtime 'docker' is invalid")
+}
+
+func TestValidate_CNI(t *testing.T) {
+	cfg := &Config{
+		Cluster:    ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions:   VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:    NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:    StorageConfig{NFSPath: "/exports"},
+		Nodes:      []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Components: ComponentsConfig{CNI: "weave"},
+	}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "components.cni 'weave' is invalid (allowed: calico, cilium, flannel)")
+
+	cfg.Components = ComponentsConfig{Hubble: "enabled"}
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "components.hubble requires components.cni: cilium")
+	assert.Equal(t, "calico", cfg.CNI())
+
+	cfg.Components.CNI = "cilium"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_Kubeadm(t *testing.T) {
+	cfg := &Config{
+		Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:  StorageConfig{NFSPath: "/exports"},
+		Nodes:    []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Kubeadm: KubeadmConfig{
+			FeatureGates:               map[string]bool{"InPlacePodVerticalScaling": true},
+			APIServerExtraArgs:         map[string]string{"max-requests-inflight": "800"},
+			ControllerManagerExtraArgs: map[string]string{"node-monitor-grace-period": "20s"},
+			Kubelet: KubeletConfig{
+				MaxPods:        200,
+				SystemReserved: map[string]string{"cpu": "100m", "memory": "256Mi"},
+				EvictionHard:   map[string]string{"memory.available": "200Mi"},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Kubeadm.APIServerExtraArgs["audit-log-path"] = "/var/log/audit.log"
+	cfg.Kubeadm.APIServerExtraArgs["--profiling"] = "false"
+	cfg.Kubeadm.ControllerManagerExtraArgs["cluster-cidr"] = "10.0.0.0/8"
+	cfg.Kubeadm.SchedulerExtraArgs = map[string]string{"feature-gates": "A=true"}
+	cfg.Kubeadm.Kubelet.MaxPods = -1
+	cfg.Kubeadm.Kubelet.KubeReserved = map[string]string{"gpu": "1"}
+	cfg.Kubeadm.Kubelet.EvictionHard["memory.available"] = ""
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		`kubeadm.apiserver_extra_args: write "--profiling" without the leading dashes`,
+		"kubeadm.apiserver_extra_args: audit-log-path is managed by the provisioner",
+		"kubeadm.controller_manager_extra_args: cluster-cidr is managed by the provisioner",
+		"kubeadm.scheduler_extra_args: set feature gates in kubeadm.feature_gates",
+		"kubeadm.kubelet.max_pods must not be negative",
+		"kubeadm.kubelet.kube_reserved: 'gpu' is invalid",
+		"kubeadm.kubelet.eviction_hard: memory.available has no value",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestValidate_Audit(t *testing.T) {
+	cfg := &Config{
+		Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:  StorageConfig{NFSPath: "/exports"},
+		Nodes:    []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Audit: AuditConfig{
+			Policy:  "secrets-read",
+			Webhook: AuditWebhookConfig{URL: "https://audit.example.com/events", Mode: "blocking"},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Audit.PolicyFile = "audit-policy.yaml"
+	cfg.Audit.Webhook = AuditWebhookConfig{URL: "http://audit.example.com/events", Mode: "async"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		"audit.policy and audit.policy_file are mutually exclusive",
+		`audit.webhook.url "http://audit.example.com/events" must be an https:// URL`,
+		"audit.webhook.mode 'async' is invalid",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	cfg.Audit = AuditConfig{Policy: "verbose", Webhook: AuditWebhookConfig{CAFile: "ca.pem"}}
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "audit.policy 'verbose' is invalid (allowed: minimal, default, secrets-read; or set audit.policy_file)")
+	assert.Contains(t, err.Error(), "audit.webhook.ca_file and audit.webhook.mode need audit.webhook.url")
+}
+
+func TestValidate_DNS(t *testing.T) {
+	cfg := &Config{
+		Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:  StorageConfig{NFSPath: "/exports"},
+		Nodes:    []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		DNS: DNSConfig{
+			Upstream:      []string{"10.1.0.53"},
+			SearchDomains: []string{"corp.example.com"},
+			StubZones: []StubZone{
+				{Zone: "corp.example.com", Forward: []string{"10.1.0.10"}},
+				{Zone: "local", Address: "192.168.56.200"},
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"8.8.8.8", "1.1.1.1"}, (&Config{}).DNSUpstream())
+
+	cfg.DNS = DNSConfig{
+		Upstream:      []string{"10.1.0.53", "10.1.0.54", "10.1.0.55", "resolver"},
+		SearchDomains: []string{"corp example"},
+		StubZones: []StubZone{
+			{Zone: "svc.cluster.local", Address: "10.0.0.1"},
+			{Zone: "local", Forward: []string{"10.1.0.10"}, Address: "192.168.56.200"},
+			{Zone: "local", Address: "fd00::1"},
+		},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		"dns.upstream has 4 resolvers",
+		"dns.upstream 'resolver' is not a valid IP address",
+		"dns.search_domains 'corp example' is not a domain name",
+		"dns.stub_zones[0].zone svc.cluster.local would shadow the cluster domain",
+		"dns.stub_zones[1] needs exactly one of forward or address",
+		"dns.stub_zones[2].zone local is listed twice",
+		"dns.stub_zones[2].address 'fd00::1' is not a valid IPv4 address",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestValidate_OfflineBundle(t *testing.T) {
+	cfg := &Config{
+		Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:  StorageConfig{NFSPath: "/exports"},
+		Nodes:    []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Offline:  OfflineConfig{Bundle: "/vagrant/k8s-bundle.tar.gz"},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Offline.Enabled())
+
+	cfg.Offline.Bundle = "k8s-bundle.tar.gz"
+	assert.ErrorContains(t, cfg.Validate(), "offline.bundle 'k8s-bundle.tar.gz' must be an absolute path on the nodes")
+}
+
+func TestValidate_Checksums(t *testing.T) {
+	cfg := &Config{
+		Cluster:   ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions:  VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:   NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:   StorageConfig{NFSPath: "/exports"},
+		Nodes:     []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Checksums: map[string]string{"https://example.com/a.yaml": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Checksums["https://example.com/b.yaml"] = "sha256:e3b0c442"
+	assert.ErrorContains(t, cfg.Validate(), "checksums: 'sha256:e3b0c442' for https://example.com/b.yaml is not a SHA-256 in lowercase hex")
+}
+
+func TestValidate_Registries(t *testing.T) {
+	cfg := &Config{
+		Cluster:  ClusterConfig{Name: "t", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12"},
+		Versions: VersionsConfig{Kubernetes: "1.34", CriO: "v1.34"},
+		Network:  NetworkConfig{Interface: "eth1", ControlPlaneIP: "192.168.56.10"},
+		Storage:  StorageConfig{NFSPath: "/exports"},
+		Nodes:    []NodeConfig{{Name: "cp", Role: "controlplane"}},
+		Registries: []RegistryConfig{
+			{Registry: "docker.io", Mirrors: []string{"harbor.lab:5000/dockerhub", "mirror.gcr.io"}},
+			{Registry: "harbor.lab:5000", Insecure: true, Username: "robot", Password: "s3cret"},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Registries = append(cfg.Registries,
+		RegistryConfig{Registry: "https://quay.io", Mirrors: []string{"http://cache.lab"}},
+		RegistryConfig{Registry: "docker.io", Insecure: true, CAFile: "ca.crt", Username: "me"},
+	)
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		"registries[2].registry 'https://quay.io' is not a host[:port]",
+		"registries[2].mirrors 'http://cache.lab' is not a host[:port][/path] (no scheme)",
+		"registries[3].registry docker.io is listed twice",
+		"registries[3] sets both insecure and ca_file",
+		"registries[3] needs both username and password (or K8S_PROV_REGISTRY_PASSWORD_DOCKER_IO)",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	cfg.Registries = cfg.Registries[:2]
+	cfg.Components.Runtime = "containerd"
+	assert.ErrorContains(t, cfg.Validate(), "registries configures CRI-O; components.runtime is containerd")
+}
+
+func TestApplyEnvSecrets_RegistryPassword(t *testing.T) {
+	t.Setenv("K8S_PROV_REGISTRY_PASSWORD_HARBOR_LAB_5000", "from-env")
+
+	cfg := &Config{Registries: []RegistryConfig{{Registry: "harbor.lab:5000", Username: "robot"}, {Registry: "docker.io"}}}
+	applyEnvSecrets(cfg)
+	assert.Equal(t, "from-env", cfg.Registries[0].Password)
+	assert.Empty(t, cfg.Registries[1].Password)
+	assert.Contains(t, cfg.Secrets(), "from-env")
+}
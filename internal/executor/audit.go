@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// maxAuditStderr caps the stderr kept per audit record; the tail is kept, as
+// that is where the failing command usually explains itself.
+const maxAuditStderr = 2048
+
+type stepKey struct{}
+
+// WithStep tags every command run under ctx with step (a host-prep step or
+// workload component) in the audit log.
+func WithStep(ctx context.Context, step string) context.Context {
+	return context.WithValue(ctx, stepKey{}, step)
+}
+
+// StepFrom returns the step set by WithStep, or "".
+func StepFrom(ctx context.Context) string {
+	step, _ := ctx.Value(stepKey{}).(string)
+	return step
+}
+
+// AuditRecord is one line of the audit log. Command and Stderr are scrubbed.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Host is the remote node a command ran on over SSH; empty when local.
+	Host       string `json:"host,omitempty"`
+	Step       string `json:"step,omitempty"`
+	Command    string `json:"command"`
+	DurationMS int64  `json:"duration_ms"`
+	// ExitCode is the command's exit status; -1 when it did not exit normally
+	// (failed to start, killed on timeout or Ctrl-C).
+	ExitCode int    `json:"exit_code"`
+	Stderr   string `json:"stderr,omitempty"`
+}
+
+// AuditLog appends one JSON record per executed command, so a run that fails
+// partway can be reconstructed without the interleaved terminal output.
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAuditLog opens path for appending, creating it (mode 0600) if needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &AuditLog{f: f}, nil
+}
+
+// Close closes the underlying file.
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
+
+// Write appends rec. The log is best-effort: a write failure must not fail
+// the command it describes, so it is reported on stderr instead of returned.
+func (a *AuditLog) Write(rec AuditRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: audit log write failed: %v\n", err)
+	}
+}
+
+// exitCode maps a command error to its exit status (see AuditRecord.ExitCode).
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus()
+	}
+	return -1
+}
+
+// tail returns the last n bytes of s.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "…" + s[len(s)-n:]
+}
+
+// audit records a finished command when an audit log is configured.
+func (e *Executor) audit(ctx context.Context, command string, start time.Time, err error, stderr string) {
+	e.Audit.record(ctx, "", command, start, err, stderr)
+}
+
+// record writes the audit record of a command that ran on host ("" = local)
+// and finished with err. A nil log records nothing.
+func (a *AuditLog) record(ctx context.Context, host, command string, start time.Time, err error, stderr string) {
+	if a == nil {
+		return
+	}
+	a.Write(AuditRecord{
+		Time:       start.UTC(),
+		Host:       host,
+		Step:       StepFrom(ctx),
+		Command:    scrub(command),
+		DurationMS: time.Since(start).Milliseconds(),
+		ExitCode:   exitCode(err),
+		Stderr:     tail(scrub(stderr), maxAuditStderr),
+	})
+}
@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAudit(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	var recs []AuditRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec AuditRecord
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &rec), "each line is one JSON record")
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestAuditLog_RecordsEveryCommand(t *testing.T) {
+	skipOnWindows(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	require.NoError(t, err)
+
+	e := New(false)
+	e.Audit = audit
+	ctx := WithStep(context.Background(), "Installing CRI-O")
+
+	_, err = e.RunShellContext(ctx, "true")
+	require.NoError(t, err)
+	_, err = e.RunShellContext(ctx, "echo boom >&2; exit 3")
+	require.Error(t, err)
+	_, _ = e.RunShell("sshpass -p 'hunter2' ssh node true")
+	require.NoError(t, audit.Close())
+
+	recs := readAudit(t, path)
+	require.Len(t, recs, 3)
+
+	assert.Equal(t, "Installing CRI-O", recs[0].Step)
+	assert.Equal(t, "true", recs[0].Command)
+	assert.Equal(t, 0, recs[0].ExitCode)
+	assert.False(t, recs[0].Time.IsZero())
+
+	assert.Equal(t, 3, recs[1].ExitCode)
+	assert.Equal(t, "boom", strings.TrimSpace(recs[1].Stderr))
+
+	assert.Empty(t, recs[2].Step)
+	assert.NotContains(t, recs[2].Command, "hunter2", "commands are scrubbed before they reach the file")
+}
+
+func TestAuditLog_StepSurvivesNestedBinding(t *testing.T) {
+	skipOnWindows(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	require.NoError(t, err)
+
+	e := New(false)
+	e.Audit = audit
+	// The provisioner binds its executor to the run context; each installer
+	// binds it again to a step context.
+	provisionerExec := WithContext(context.Background(), e)
+	installerExec := WithShellContext(WithStep(context.Background(), "MetalLB"), provisionerExec)
+
+	_, err = installerExec.RunShell("true")
+	require.NoError(t, err)
+	require.NoError(t, audit.Close())
+
+	recs := readAudit(t, path)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "MetalLB", recs[0].Step)
+}
+
+func TestAuditTail_KeepsEndOfLongStderr(t *testing.T) {
+	long := strings.Repeat("x", maxAuditStderr) + "the actual error"
+	got := tail(long, maxAuditStderr)
+	assert.True(t, strings.HasSuffix(got, "the actual error"))
+	assert.LessOrEqual(t, len(got), maxAuditStderr+len("…"))
+}
+
+func TestAuditLog_RecordCarriesHostAndNilIsNoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	require.NoError(t, err)
+
+	var none *AuditLog
+	none.record(context.Background(), "10.0.0.2", "true", time.Now(), nil, "")
+
+	ctx := WithStep(context.Background(), "Joining cluster")
+	audit.record(ctx, "10.0.0.2", "kubeadm join", time.Now(), errors.New("boom"), "refused")
+	require.NoError(t, audit.Close())
+
+	recs := readAudit(t, path)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "10.0.0.2", recs[0].Host)
+	assert.Equal(t, "Joining cluster", recs[0].Step)
+	assert.Equal(t, -1, recs[0].ExitCode, "errors without an exit status are reported as -1")
+	assert.Equal(t, "refused", recs[0].Stderr)
+}
@@ -0,0 +1,279 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Interaction kinds, one per executor method.
+const (
+	KindRun         = "run"
+	KindRunOutput   = "run_output"
+	KindShell       = "shell"
+	KindShellOutput = "shell_output"
+	KindShellStdin  = "shell_stdin"
+	KindWriteFile   = "write_file"
+)
+
+// Interaction is one executor call and its result. Command, Stdin and Output
+// are scrubbed (see scrub), which removes sshpass passwords and Vault tokens
+// only: other secrets a command carries — generated passwords in a manifest,
+// Vault init output — are kept, so review a recording before committing it.
+// Stdin holds the stdin of shell_stdin calls and the file content of write_file.
+// Output of streaming calls (run_output, shell_output) goes to the terminal
+// and is not captured.
+type Interaction struct {
+	Kind    string `yaml:"kind"`
+	Command string `yaml:"command"`
+	Stdin   string `yaml:"stdin,omitempty"`
+	Output  string `yaml:"output,omitempty"`
+	Error   string `yaml:"error,omitempty"`
+}
+
+// Cassette is an ordered recording of executor calls, used to replay a real
+// installer run in a test (see Recorder and Replayer).
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// LoadCassette reads a cassette written by Cassette.Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	var c Cassette
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette as YAML (mode 0600), creating the parent directory.
+// Only the patterns scrub knows are redacted: review a cassette recorded on a
+// real cluster for secrets (e.g. vault operator init output) before committing it.
+func (c *Cassette) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	return nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return scrub(err.Error())
+}
+
+// Recorder is a CommandExecutor decorator that runs every call on next and
+// appends it, with its output, to a Cassette. Wrap the production executor
+// during a real run (--record) to capture a golden sequence for an installer.
+type Recorder struct {
+	next CommandExecutor
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// Compile-time verification that Recorder is a full, cancellable executor.
+var (
+	_ CommandExecutor        = (*Recorder)(nil)
+	_ ContextCommandExecutor = (*Recorder)(nil)
+	_ FileWriter             = (*Recorder)(nil)
+	_ ContextFileWriter      = (*Recorder)(nil)
+)
+
+// NewRecorder records the calls made through it and forwards them to next.
+func NewRecorder(next CommandExecutor) *Recorder {
+	return &Recorder{next: next}
+}
+
+// Cassette returns a copy of everything recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+func (r *Recorder) record(i Interaction) {
+	i.Command = scrub(i.Command)
+	i.Stdin = scrub(i.Stdin)
+	i.Output = scrub(i.Output)
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) Run(name string, args ...string) (string, error) {
+	return r.RunContext(context.Background(), name, args...)
+}
+
+func (r *Recorder) RunContext(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := WithContext(ctx, r.next).Run(name, args...)
+	r.record(Interaction{Kind: KindRun, Command: argv(name, args), Output: out, Error: errString(err)})
+	return out, err
+}
+
+func (r *Recorder) RunWithOutput(name string, args ...string) error {
+	return r.RunWithOutputContext(context.Background(), name, args...)
+}
+
+func (r *Recorder) RunWithOutputContext(ctx context.Context, name string, args ...string) error {
+	err := WithContext(ctx, r.next).RunWithOutput(name, args...)
+	r.record(Interaction{Kind: KindRunOutput, Command: argv(name, args), Error: errString(err)})
+	return err
+}
+
+func (r *Recorder) RunShell(command string) (string, error) {
+	return r.RunShellContext(context.Background(), command)
+}
+
+func (r *Recorder) RunShellContext(ctx context.Context, command string) (string, error) {
+	out, err := WithContext(ctx, r.next).RunShell(command)
+	r.record(Interaction{Kind: KindShell, Command: command, Output: out, Error: errString(err)})
+	return out, err
+}
+
+func (r *Recorder) RunShellWithOutput(command string) error {
+	return r.RunShellWithOutputContext(context.Background(), command)
+}
+
+func (r *Recorder) RunShellWithOutputContext(ctx context.Context, command string) error {
+	err := WithContext(ctx, r.next).RunShellWithOutput(command)
+	r.record(Interaction{Kind: KindShellOutput, Command: command, Error: errString(err)})
+	return err
+}
+
+func (r *Recorder) RunShellWithStdin(command string, stdin string) (string, error) {
+	return r.RunShellWithStdinContext(context.Background(), command, stdin)
+}
+
+func (r *Recorder) RunShellWithStdinContext(ctx context.Context, command string, stdin string) (string, error) {
+	out, err := WithContext(ctx, r.next).RunShellWithStdin(command, stdin)
+	r.record(Interaction{Kind: KindShellStdin, Command: command, Stdin: stdin, Output: out, Error: errString(err)})
+	return out, err
+}
+
+// WriteFile records the rendered file and writes it wherever next runs.
+func (r *Recorder) WriteFile(path, content string) error {
+	return r.WriteFileContext(context.Background(), path, content)
+}
+
+func (r *Recorder) WriteFileContext(ctx context.Context, path, content string) error {
+	err := WriteFileOn(WithShellContext(ctx, r.next), path, content)
+	r.record(Interaction{Kind: KindWriteFile, Command: path, Stdin: content, Error: errString(err)})
+	return err
+}
+
+// Replayer is a CommandExecutor that serves a Cassette back in order: each call
+// must match the next recorded interaction (kind, scrubbed command, stdin or
+// file content), and gets its recorded output and error. The first divergence
+// fails that call and every later one, and is reported by Err, so a golden test
+// catches any change to an installer's command sequence.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	pos      int
+	err      error
+}
+
+// Compile-time verification that Replayer can stand in for any executor.
+var (
+	_ CommandExecutor = (*Replayer)(nil)
+	_ FileWriter      = (*Replayer)(nil)
+)
+
+// NewReplayer replays c.
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{cassette: c}
+}
+
+// Err returns the first divergence from the cassette, or an error naming the
+// first unplayed interaction if the run ended early. Nil means the run made
+// exactly the recorded calls.
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos < len(r.cassette.Interactions) {
+		next := r.cassette.Interactions[r.pos]
+		return fmt.Errorf("replay: %d of %d interactions not played; next is %s %q",
+			len(r.cassette.Interactions)-r.pos, len(r.cassette.Interactions), next.Kind, next.Command)
+	}
+	return nil
+}
+
+func (r *Replayer) play(got Interaction) (string, error) {
+	got.Command = scrub(got.Command)
+	got.Stdin = scrub(got.Stdin)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return "", r.err
+	}
+	if r.pos >= len(r.cassette.Interactions) {
+		r.err = fmt.Errorf("replay: unexpected call #%d beyond end of cassette: %s %q", r.pos+1, got.Kind, got.Command)
+		return "", r.err
+	}
+	want := r.cassette.Interactions[r.pos]
+	if got.Kind != want.Kind || got.Command != want.Command || got.Stdin != want.Stdin {
+		r.err = fmt.Errorf("replay: call #%d diverged:\n  got:  %s %q\n  want: %s %q%s",
+			r.pos+1, got.Kind, got.Command, want.Kind, want.Command, stdinNote(got, want))
+		return "", r.err
+	}
+	r.pos++
+	if want.Error != "" {
+		return want.Output, errors.New(want.Error)
+	}
+	return want.Output, nil
+}
+
+func stdinNote(got, want Interaction) string {
+	if got.Kind == want.Kind && got.Command == want.Command {
+		return " (stdin/content differs)"
+	}
+	return ""
+}
+
+func (r *Replayer) Run(name string, args ...string) (string, error) {
+	return r.play(Interaction{Kind: KindRun, Command: argv(name, args)})
+}
+
+func (r *Replayer) RunWithOutput(name string, args ...string) error {
+	_, err := r.play(Interaction{Kind: KindRunOutput, Command: argv(name, args)})
+	return err
+}
+
+func (r *Replayer) RunShell(command string) (string, error) {
+	return r.play(Interaction{Kind: KindShell, Command: command})
+}
+
+func (r *Replayer) RunShellWithOutput(command string) error {
+	_, err := r.play(Interaction{Kind: KindShellOutput, Command: command})
+	return err
+}
+
+func (r *Replayer) RunShellWithStdin(command string, stdin string) (string, error) {
+	return r.play(Interaction{Kind: KindShellStdin, Command: command, Stdin: stdin})
+}
+
+func (r *Replayer) WriteFile(path, content string) error {
+	_, err := r.play(Interaction{Kind: KindWriteFile, Command: path, Stdin: content})
+	return err
+}
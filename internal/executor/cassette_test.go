@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedExecutor returns canned output per shell command and records writes.
+type scriptedExecutor struct {
+	outputs map[string]string
+	errs    map[string]error
+	written map[string]string
+}
+
+func (s *scriptedExecutor) Run(string, ...string) (string, error)            { return "", nil }
+func (s *scriptedExecutor) RunWithOutput(string, ...string) error            { return nil }
+func (s *scriptedExecutor) RunShellWithOutput(string) error                  { return nil }
+func (s *scriptedExecutor) RunShellWithStdin(string, string) (string, error) { return "", nil }
+func (s *scriptedExecutor) RunShell(command string) (string, error) {
+	return s.outputs[command], s.errs[command]
+}
+
+func (s *scriptedExecutor) WriteFile(path, content string) error {
+	if s.written == nil {
+		s.written = map[string]string{}
+	}
+	s.written[path] = content
+	return nil
+}
+
+func TestRecorder_CapturesSequenceAndRoundTrips(t *testing.T) {
+	next := &scriptedExecutor{
+		outputs: map[string]string{"kubectl get ns": "default kube-system"},
+		errs:    map[string]error{"kubectl delete ns x": errors.New("exit status 1: not found")},
+	}
+	rec := NewRecorder(next)
+
+	out, err := rec.RunShell("kubectl get ns")
+	require.NoError(t, err)
+	assert.Equal(t, "default kube-system", out)
+	_, err = rec.RunShell("kubectl delete ns x")
+	require.Error(t, err)
+	require.NoError(t, WriteFileOn(rec, "/tmp/a.yaml", "kind: A"))
+	_, _ = rec.RunShellWithStdin("sshpass -p 'secret' ssh node cat", "curl -H 'X-Vault-Token: s.root' vault")
+
+	assert.Equal(t, "kind: A", next.written["/tmp/a.yaml"], "writes are forwarded to the wrapped executor")
+
+	path := filepath.Join(t.TempDir(), "run.yaml")
+	require.NoError(t, rec.Cassette().Save(path))
+	loaded, err := LoadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, loaded.Interactions, 4)
+	assert.Equal(t, Interaction{Kind: KindShell, Command: "kubectl get ns", Output: "default kube-system"}, loaded.Interactions[0])
+	assert.Equal(t, "exit status 1: not found", loaded.Interactions[1].Error)
+	assert.Equal(t, Interaction{Kind: KindWriteFile, Command: "/tmp/a.yaml", Stdin: "kind: A"}, loaded.Interactions[2])
+	assert.NotContains(t, loaded.Interactions[3].Command, "secret", "commands are scrubbed before recording")
+	assert.NotContains(t, loaded.Interactions[3].Stdin, "s.root", "stdin is scrubbed before recording")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "cassettes may hold secrets")
+}
+
+func TestReplayer_ServesRecordedOutputsInOrder(t *testing.T) {
+	replay := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Kind: KindShell, Command: "kubectl get ns", Output: "default"},
+		{Kind: KindShell, Command: "kubectl delete ns x", Error: "not found"},
+		{Kind: KindWriteFile, Command: "/tmp/a.yaml", Stdin: "kind: A"},
+	}})
+
+	out, err := replay.RunShell("kubectl get ns")
+	require.NoError(t, err)
+	assert.Equal(t, "default", out)
+	_, err = replay.RunShell("kubectl delete ns x")
+	assert.EqualError(t, err, "not found")
+	require.NoError(t, replay.WriteFile("/tmp/a.yaml", "kind: A"))
+
+	assert.NoError(t, replay.Err())
+}
+
+func TestReplayer_FailsOnDivergence(t *testing.T) {
+	replay := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Kind: KindShell, Command: "kubectl apply -f /tmp/a.yaml"},
+		{Kind: KindShell, Command: "kubectl get pods"},
+	}})
+
+	_, err := replay.RunShell("kubectl apply -f /tmp/b.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "call #1 diverged")
+
+	// Once diverged, every later call fails and Err keeps the first divergence.
+	_, err = replay.RunShell("kubectl get pods")
+	require.Error(t, err)
+	assert.Contains(t, replay.Err().Error(), "/tmp/b.yaml")
+}
+
+func TestReplayer_ReportsChangedFileContent(t *testing.T) {
+	replay := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Kind: KindWriteFile, Command: "/tmp/a.yaml", Stdin: "replicas: 1"},
+	}})
+
+	err := replay.WriteFile("/tmp/a.yaml", "replicas: 2")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "content differs")
+}
+
+func TestReplayer_ErrReportsUnplayedAndExtraCalls(t *testing.T) {
+	replay := NewReplayer(&Cassette{Interactions: []Interaction{
+		{Kind: KindShell, Command: "kubectl get ns"},
+	}})
+	assert.ErrorContains(t, replay.Err(), "1 of 1 interactions not played")
+
+	_, err := replay.RunShell("kubectl get ns")
+	require.NoError(t, err)
+	_, err = replay.RunShell("kubectl get pods")
+	assert.ErrorContains(t, err, "beyond end of cassette")
+}
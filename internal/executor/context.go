@@ -0,0 +1,159 @@
+package executor
+
+import "context"
+
+// ContextShellExecutor is the cancellable counterpart of ShellExecutor: each
+// call is bound to ctx, and cancelling ctx (Ctrl-C, a deadline) aborts the
+// in-flight command instead of leaving it to run to completion.
+type ContextShellExecutor interface {
+	RunShellContext(ctx context.Context, command string) (string, error)
+	RunShellWithOutputContext(ctx context.Context, command string) error
+	RunShellWithStdinContext(ctx context.Context, command string, stdin string) (string, error)
+}
+
+// ContextCommandExecutor adds the cancellable argv-form helpers.
+type ContextCommandExecutor interface {
+	ContextShellExecutor
+	RunContext(ctx context.Context, name string, args ...string) (string, error)
+	RunWithOutputContext(ctx context.Context, name string, args ...string) error
+}
+
+// WithShellContext binds ctx to e, so callers that only know the plain
+// ShellExecutor surface (every installer) still get cancellation. When e has no
+// *Context variants (test fakes), the bound executor refuses to start new
+// commands once ctx is done but cannot interrupt one already running.
+func WithShellContext(ctx context.Context, e ShellExecutor) ShellExecutor {
+	return boundShell{ctx: ctx, next: e}
+}
+
+// WithContext is WithShellContext for the full CommandExecutor surface.
+func WithContext(ctx context.Context, e CommandExecutor) CommandExecutor {
+	return boundCommand{boundShell: boundShell{ctx: ctx, next: e}, next: e}
+}
+
+type boundShell struct {
+	ctx  context.Context
+	next ShellExecutor
+}
+
+// Bound executors are themselves cancellable, so binding one on top of another
+// (an installer wrapping the provisioner's executor) keeps both contexts.
+var (
+	_ ContextShellExecutor   = boundShell{}
+	_ ContextCommandExecutor = boundCommand{}
+	_ ContextFileWriter      = boundShell{}
+)
+
+// join returns ctx cancelled when either ctx or the bound context is done. The
+// values (e.g. the audit step, see WithStep) come from ctx, the more specific.
+func (b boundShell) join(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(b.ctx, func() { cancel(context.Cause(b.ctx)) })
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+func (b boundShell) RunShellContext(ctx context.Context, command string) (string, error) {
+	ctx, cancel := b.join(ctx)
+	defer cancel()
+	return boundShell{ctx: ctx, next: b.next}.RunShell(command)
+}
+
+func (b boundShell) RunShellWithOutputContext(ctx context.Context, command string) error {
+	ctx, cancel := b.join(ctx)
+	defer cancel()
+	return boundShell{ctx: ctx, next: b.next}.RunShellWithOutput(command)
+}
+
+func (b boundShell) RunShellWithStdinContext(ctx context.Context, command string, stdin string) (string, error) {
+	ctx, cancel := b.join(ctx)
+	defer cancel()
+	return boundShell{ctx: ctx, next: b.next}.RunShellWithStdin(command, stdin)
+}
+
+func (b boundShell) RunShell(command string) (string, error) {
+	if ce, ok := b.next.(ContextShellExecutor); ok {
+		return ce.RunShellContext(b.ctx, command)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return "", err
+	}
+	return b.next.RunShell(command)
+}
+
+func (b boundShell) RunShellWithOutput(command string) error {
+	if ce, ok := b.next.(ContextShellExecutor); ok {
+		return ce.RunShellWithOutputContext(b.ctx, command)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.next.RunShellWithOutput(command)
+}
+
+func (b boundShell) RunShellWithStdin(command string, stdin string) (string, error) {
+	if ce, ok := b.next.(ContextShellExecutor); ok {
+		return ce.RunShellWithStdinContext(b.ctx, command, stdin)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return "", err
+	}
+	return b.next.RunShellWithStdin(command, stdin)
+}
+
+// WriteFile forwards to the wrapped executor so files follow the commands to
+// the host they run on (see WriteFileOn).
+func (b boundShell) WriteFile(path, content string) error {
+	if cw, ok := b.next.(ContextFileWriter); ok {
+		return cw.WriteFileContext(b.ctx, path, content)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return WriteFileOn(b.next, path, content)
+}
+
+func (b boundShell) WriteFileContext(ctx context.Context, path, content string) error {
+	ctx, cancel := b.join(ctx)
+	defer cancel()
+	return boundShell{ctx: ctx, next: b.next}.WriteFile(path, content)
+}
+
+type boundCommand struct {
+	boundShell
+	next CommandExecutor
+}
+
+func (b boundCommand) Run(name string, args ...string) (string, error) {
+	if ce, ok := b.next.(ContextCommandExecutor); ok {
+		return ce.RunContext(b.ctx, name, args...)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return "", err
+	}
+	return b.next.Run(name, args...)
+}
+
+func (b boundCommand) RunContext(ctx context.Context, name string, args ...string) (string, error) {
+	ctx, cancel := b.join(ctx)
+	defer cancel()
+	return boundCommand{boundShell: boundShell{ctx: ctx, next: b.next}, next: b.next}.Run(name, args...)
+}
+
+func (b boundCommand) RunWithOutputContext(ctx context.Context, name string, args ...string) error {
+	ctx, cancel := b.join(ctx)
+	defer cancel()
+	return boundCommand{boundShell: boundShell{ctx: ctx, next: b.next}, next: b.next}.RunWithOutput(name, args...)
+}
+
+func (b boundCommand) RunWithOutput(name string, args ...string) error {
+	if ce, ok := b.next.(ContextCommandExecutor); ok {
+		return ce.RunWithOutputContext(b.ctx, name, args...)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.next.RunWithOutput(name, args...)
+}
@@ -1,40 +1,137 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
 
 // DryRunExecutor is a Null-Object CommandExecutor: it prints each command that
-// would run and performs no host mutation. Read-style calls return empty output,
-// so callers that branch on command output should short-circuit their wait loops
-// when dry-run is active (see Provisioner.dryRun).
-type DryRunExecutor struct{}
+// would run, scrubbed like a verbose Executor echo, and performs no host
+// mutation. Read-style calls return empty output
+// unless Responses supplies a canned answer, so callers that branch on command
+// output either short-circuit their wait loops in dry-run (see
+// Provisioner.dryRun) or are given "ready" answers that let them run through.
+type DryRunExecutor struct {
+	// Responses answers read-style calls: the first entry whose Match is a
+	// substring of the command supplies its output. Unmatched calls return "".
+	Responses []DryRunResponse
+	// Script, when set, receives every command and file write, grouped by the
+	// step carried in the call's context (see WithStep).
+	Script *Script
+}
+
+// DryRunResponse is a canned output for commands containing Match.
+type DryRunResponse struct {
+	Match  string
+	Output string
+}
 
 // Compile-time verification that DryRunExecutor implements CommandExecutor.
-var _ CommandExecutor = DryRunExecutor{}
+var (
+	_ CommandExecutor        = DryRunExecutor{}
+	_ ContextCommandExecutor = DryRunExecutor{}
+	_ FileWriter             = DryRunExecutor{}
+	_ ContextFileWriter      = DryRunExecutor{}
+)
+
+// respond returns the canned output for command, or "".
+func (d DryRunExecutor) respond(command string) string {
+	for _, r := range d.Responses {
+		if strings.Contains(command, r.Match) {
+			return r.Output
+		}
+	}
+	return ""
+}
+
+// record adds command to the script under the step carried by ctx.
+func (d DryRunExecutor) record(ctx context.Context, command string) {
+	if d.Script != nil {
+		d.Script.add(StepFrom(ctx), command)
+	}
+}
+
+func (d DryRunExecutor) Run(name string, args ...string) (string, error) {
+	return d.RunContext(context.Background(), name, args...)
+}
+
+func (d DryRunExecutor) RunWithOutput(name string, args ...string) error {
+	return d.RunWithOutputContext(context.Background(), name, args...)
+}
 
-func (DryRunExecutor) Run(name string, args ...string) (string, error) {
-	fmt.Printf("[dry-run] %s %s\n", name, strings.Join(args, " "))
-	return "", nil
+func (d DryRunExecutor) RunShell(command string) (string, error) {
+	return d.RunShellContext(context.Background(), command)
 }
 
-func (DryRunExecutor) RunWithOutput(name string, args ...string) error {
-	fmt.Printf("[dry-run] %s %s\n", name, strings.Join(args, " "))
+func (d DryRunExecutor) RunShellWithOutput(command string) error {
+	return d.RunShellWithOutputContext(context.Background(), command)
+}
+
+func (d DryRunExecutor) RunShellWithStdin(command, stdin string) (string, error) {
+	return d.RunShellWithStdinContext(context.Background(), command, stdin)
+}
+
+// WriteFile prints the intent and leaves the filesystem untouched.
+func (d DryRunExecutor) WriteFile(path, content string) error {
+	return d.WriteFileContext(context.Background(), path, content)
+}
+
+// The *Context variants only honour cancellation and the step for the script:
+// nothing runs, so there is nothing to interrupt beyond refusing to print
+// further commands.
+
+func (d DryRunExecutor) RunContext(ctx context.Context, name string, args ...string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(Output(ctx), "[dry-run] %s\n", scrub(name+" "+strings.Join(args, " ")))
+	d.record(ctx, argv(name, args))
+	return d.respond(name + " " + strings.Join(args, " ")), nil
+}
+
+func (d DryRunExecutor) RunWithOutputContext(ctx context.Context, name string, args ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(Output(ctx), "[dry-run] %s\n", scrub(name+" "+strings.Join(args, " ")))
+	d.record(ctx, argv(name, args))
 	return nil
 }
 
-func (DryRunExecutor) RunShell(command string) (string, error) {
-	fmt.Printf("[dry-run] sh -c %s\n", command)
-	return "", nil
+func (d DryRunExecutor) RunShellContext(ctx context.Context, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(Output(ctx), "[dry-run] sh -c %s\n", scrub(command))
+	d.record(ctx, command)
+	return d.respond(command), nil
 }
 
-func (DryRunExecutor) RunShellWithOutput(command string) error {
-	fmt.Printf("[dry-run] sh -c %s\n", command)
+func (d DryRunExecutor) RunShellWithOutputContext(ctx context.Context, command string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(Output(ctx), "[dry-run] sh -c %s\n", scrub(command))
+	d.record(ctx, command)
 	return nil
 }
 
-func (DryRunExecutor) RunShellWithStdin(command, stdin string) (string, error) {
-	fmt.Printf("[dry-run] sh -c %s (with stdin)\n", command)
-	return "", nil
+func (d DryRunExecutor) RunShellWithStdinContext(ctx context.Context, command, stdin string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(Output(ctx), "[dry-run] sh -c %s (with stdin)\n", scrub(command))
+	d.record(ctx, heredoc(command, stdin))
+	return d.respond(command), nil
+}
+
+// WriteFileContext is WriteFile with the step for the script taken from ctx.
+func (d DryRunExecutor) WriteFileContext(ctx context.Context, path, content string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(Output(ctx), "[dry-run] write %s\n", path)
+	d.record(ctx, writeFileCommand(path, content))
+	return nil
 }
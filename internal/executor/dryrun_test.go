@@ -1,6 +1,12 @@
 package executor
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
+)
 
 func TestDryRunExecutor_NoMutationEmptyOutput(t *testing.T) {
 	var e CommandExecutor = DryRunExecutor{}
@@ -18,3 +24,53 @@ func TestDryRunExecutor_NoMutationEmptyOutput(t *testing.T) {
 		t.Fatalf("RunShellWithStdin: want \"\",nil got %q,%v", out, err)
 	}
 }
+
+func TestDryRunExecutor_FirstMatchingResponseWins(t *testing.T) {
+	e := DryRunExecutor{Responses: []DryRunResponse{
+		{Match: "{.items[*].status.phase}", Output: "Running Running"},
+		{Match: ".status.phase}", Output: "Running"},
+	}}
+
+	out, _ := e.RunShell("kubectl get pods -o jsonpath='{.items[*].status.phase}'")
+	if out != "Running Running" {
+		t.Fatalf("specific match: got %q", out)
+	}
+	out, _ = e.RunShell("kubectl get pod x -o jsonpath='{.status.phase}'")
+	if out != "Running" {
+		t.Fatalf("general match: got %q", out)
+	}
+	if out, _ = e.RunShell("kubectl get nodes"); out != "" {
+		t.Fatalf("unmatched: got %q", out)
+	}
+}
+
+func TestDryRunExecutor_RecordsUnderContextStep(t *testing.T) {
+	script := NewScript()
+	e := DryRunExecutor{Script: script}
+
+	exec := WithShellContext(WithStep(context.Background(), "MetalLB"), e)
+	if _, err := exec.RunShell("kubectl apply -f metallb.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileOn(exec, "/tmp/pool.yaml", "kind: IPAddressPool"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := script.Render("MetalLB")
+	if !strings.Contains(got, "kubectl apply -f metallb.yaml") || !strings.Contains(got, "cat > '/tmp/pool.yaml'") {
+		t.Fatalf("commands missing from step script:\n%s", got)
+	}
+}
+
+func TestDryRunExecutor_ScriptMasksRegisteredSecrets(t *testing.T) {
+	redact.Add("grafana-pw-from-vault")
+	script := NewScript()
+	e := DryRunExecutor{Script: script}
+
+	_, _ = e.RunShellWithStdin("kubectl apply -f -", "stringData:\n  admin-password: grafana-pw-from-vault")
+	_, _ = e.RunShell("kubectl create secret generic g --from-literal=p=grafana-pw-from-vault")
+
+	if got := script.Render(""); strings.Contains(got, "grafana-pw-from-vault") {
+		t.Fatalf("secret leaked into the script:\n%s", got)
+	}
+}
@@ -1,9 +1,11 @@
 package executor
 
 import (
+	"context"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -58,3 +60,88 @@ func TestRun_NonZeroExitReturnsError(t *testing.T) {
 
 	require.Error(t, err)
 }
+
+func TestRunShellContext_CancelKillsCommand(t *testing.T) {
+	skipOnWindows(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := New(false).RunShellContext(ctx, "sleep 30 | cat")
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 10*time.Second, "cancel must not wait for the pipeline to finish")
+}
+
+func TestExecutorTimeout_AbortsLongCommand(t *testing.T) {
+	skipOnWindows(t)
+
+	e := New(false)
+	e.Timeout = 100 * time.Millisecond
+
+	_, err := e.RunShell("sleep 30")
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithContext_PlainExecutorRefusesAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := &recordingShell{}
+	_, err := WithShellContext(ctx, rec).RunShell("kubectl apply -f -")
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, rec.calls, "no command may start once the context is done")
+}
+
+// recordingShell is a ShellExecutor without *Context variants, standing in for
+// test fakes that WithShellContext must still guard.
+type recordingShell struct{ calls []string }
+
+func (r *recordingShell) RunShell(c string) (string, error) {
+	r.calls = append(r.calls, c)
+	return "", nil
+}
+func (r *recordingShell) RunShellWithOutput(c string) error {
+	r.calls = append(r.calls, c)
+	return nil
+}
+func (r *recordingShell) RunShellWithStdin(c, _ string) (string, error) {
+	r.calls = append(r.calls, c)
+	return "", nil
+}
+
+func TestWithContext_WriteFilePassesContextToWriter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := &ctxWriter{}
+	err := WriteFileOn(WithShellContext(ctx, w), "/tmp/a.yaml", "kind: A")
+
+	require.ErrorIs(t, err, context.Canceled, "a cancelled upload must not start")
+	assert.Empty(t, w.paths)
+}
+
+// ctxWriter is a ShellExecutor that writes files through ContextFileWriter,
+// standing in for SSHExecutor.
+type ctxWriter struct {
+	recordingShell
+	paths []string
+}
+
+func (w *ctxWriter) WriteFile(path, content string) error {
+	return w.WriteFileContext(context.Background(), path, content)
+}
+
+func (w *ctxWriter) WriteFileContext(ctx context.Context, path, _ string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w.paths = append(w.paths, path)
+	return nil
+}
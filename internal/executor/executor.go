@@ -2,11 +2,16 @@ package executor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
 )
 
 var (
@@ -17,10 +22,13 @@ var (
 	vaultTokenRe = regexp.MustCompile(`(?i)(X-Vault-Token:\s*)[^\s'"]+`)
 )
 
-// scrub redacts credential material (sshpass passwords, Vault tokens) so it does
-// not leak into error messages or verbose command echoes. Applied to every
-// command string we print and to stderr we surface in errors.
+// scrub redacts credential material so it does not leak into error messages or
+// verbose command echoes: every literal secret registered with the redact
+// package (config secrets, values read from Vault), then sshpass passwords and
+// Vault tokens by shape. Applied to every command string we print and to
+// stderr we surface in errors.
 func scrub(s string) string {
+	s = redact.String(s)
 	s = sshpassRe.ReplaceAllString(s, "-p '***'")
 	s = vaultTokenRe.ReplaceAllString(s, "${1}***")
 	return s
@@ -47,77 +55,149 @@ type CommandExecutor interface {
 // Executor implements CommandExecutor
 type Executor struct {
 	Verbose bool
+	// Timeout bounds every command run by this executor; zero means no limit.
+	// A caller needing a tighter deadline for one command passes a context with
+	// its own deadline to the *Context variants — the earlier deadline wins.
+	Timeout time.Duration
+	// Audit, when set, receives one record per command (see AuditLog).
+	Audit *AuditLog
 }
 
-// Compile-time verification that Executor implements CommandExecutor
-var _ CommandExecutor = (*Executor)(nil)
+// Compile-time verification that Executor implements CommandExecutor and its
+// cancellable counterpart.
+var (
+	_ CommandExecutor        = (*Executor)(nil)
+	_ ContextCommandExecutor = (*Executor)(nil)
+)
 
 func New(verbose bool) *Executor {
 	return &Executor{Verbose: verbose}
 }
 
-// Run executes a command and returns the output
-func (e *Executor) Run(name string, args ...string) (string, error) {
-	if e.Verbose {
-		fmt.Printf(">>> %s\n", scrub(name+" "+strings.Join(args, " ")))
+// command builds an *exec.Cmd bound to ctx, narrowed by the executor-wide
+// Timeout. The returned context is the one the command actually runs under
+// (used to classify failures); cancel must be called once the command is done.
+func (e *Executor) command(ctx context.Context, name string, args ...string) (*exec.Cmd, context.Context, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if e.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
 	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessGroup(cmd)
+	return cmd, ctx, cancel
+}
 
-	cmd := exec.Command(name, args...)
+// runErr maps a failed command to the error returned to callers. A cancelled
+// or expired context wins over the exit status (usually "signal: killed"), so
+// callers can tell an interrupted step from a failed one with errors.Is.
+func runErr(ctx context.Context, err error, stderr string) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %s", ctxErr, scrub(stderr))
+	}
+	return fmt.Errorf("%v: %s", err, scrub(stderr))
+}
+
+// capture runs cmd with stdout/stderr buffered and returns stdout. command is
+// the caller-facing form of cmd, as recorded in the audit log.
+func (e *Executor) capture(ctx context.Context, cmd *exec.Cmd, command string) (string, error) {
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
+	start := time.Now()
 	err := cmd.Run()
+	e.audit(ctx, command, start, err, stderr.String())
 	if err != nil {
-		return "", fmt.Errorf("%v: %s", err, scrub(stderr.String()))
+		return "", runErr(ctx, err, stderr.String())
 	}
-
 	return stdout.String(), nil
 }
 
-// RunWithOutput executes a command and streams output to stdout
-func (e *Executor) RunWithOutput(name string, args ...string) error {
+// stream runs cmd with its output attached to the console (see WithOutput). When auditing,
+// stderr is also teed into a buffer for the audit record.
+func (e *Executor) stream(ctx context.Context, cmd *exec.Cmd, command string) error {
+	var stderr bytes.Buffer
+	cmd.Stdout = Output(ctx)
+	cmd.Stderr = errOutput(ctx)
+	if e.Audit != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	e.audit(ctx, command, start, err, stderr.String())
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// Run executes a command and returns the output
+func (e *Executor) Run(name string, args ...string) (string, error) {
+	return e.RunContext(context.Background(), name, args...)
+}
+
+// RunContext is Run bound to ctx: cancelling ctx kills the command.
+func (e *Executor) RunContext(ctx context.Context, name string, args ...string) (string, error) {
 	if e.Verbose {
-		fmt.Printf(">>> %s\n", scrub(name+" "+strings.Join(args, " ")))
+		fmt.Fprintf(Output(ctx), ">>> %s\n", scrub(name+" "+strings.Join(args, " ")))
 	}
 
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd, ctx, cancel := e.command(ctx, name, args...)
+	defer cancel()
+	return e.capture(ctx, cmd, name+" "+strings.Join(args, " "))
+}
 
-	return cmd.Run()
+// RunWithOutput executes a command and streams output to stdout
+func (e *Executor) RunWithOutput(name string, args ...string) error {
+	return e.RunWithOutputContext(context.Background(), name, args...)
 }
 
-// RunShell executes a shell command
-func (e *Executor) RunShell(command string) (string, error) {
+// RunWithOutputContext is RunWithOutput bound to ctx.
+func (e *Executor) RunWithOutputContext(ctx context.Context, name string, args ...string) error {
 	if e.Verbose {
-		fmt.Printf(">>> sh -c %s\n", scrub(command))
+		fmt.Fprintf(Output(ctx), ">>> %s\n", scrub(name+" "+strings.Join(args, " ")))
 	}
 
-	cmd := exec.Command("sh", "-c", command)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd, ctx, cancel := e.command(ctx, name, args...)
+	defer cancel()
+	return e.stream(ctx, cmd, name+" "+strings.Join(args, " "))
+}
 
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("%v: %s", err, scrub(stderr.String()))
+// RunShell executes a shell command
+func (e *Executor) RunShell(command string) (string, error) {
+	return e.RunShellContext(context.Background(), command)
+}
+
+// RunShellContext is RunShell bound to ctx. The whole `sh -c` process group is
+// killed on cancellation, so pipelines such as `curl | bash` do not linger.
+func (e *Executor) RunShellContext(ctx context.Context, command string) (string, error) {
+	if e.Verbose {
+		fmt.Fprintf(Output(ctx), ">>> sh -c %s\n", scrub(command))
 	}
 
-	return stdout.String(), nil
+	cmd, ctx, cancel := e.command(ctx, "sh", "-c", command)
+	defer cancel()
+	return e.capture(ctx, cmd, command)
 }
 
 // RunShellWithOutput executes a shell command and streams output
 func (e *Executor) RunShellWithOutput(command string) error {
+	return e.RunShellWithOutputContext(context.Background(), command)
+}
+
+// RunShellWithOutputContext is RunShellWithOutput bound to ctx.
+func (e *Executor) RunShellWithOutputContext(ctx context.Context, command string) error {
 	if e.Verbose {
-		fmt.Printf(">>> sh -c %s\n", scrub(command))
+		fmt.Fprintf(Output(ctx), ">>> sh -c %s\n", scrub(command))
 	}
 
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
+	cmd, ctx, cancel := e.command(ctx, "sh", "-c", command)
+	defer cancel()
+	return e.stream(ctx, cmd, command)
 }
 
 // FileExists checks if a file exists
@@ -142,22 +222,19 @@ func ReadFileContents(path string) (string, error) {
 
 // RunShellWithStdin executes a shell command with stdin input
 func (e *Executor) RunShellWithStdin(command string, stdin string) (string, error) {
+	return e.RunShellWithStdinContext(context.Background(), command, stdin)
+}
+
+// RunShellWithStdinContext is RunShellWithStdin bound to ctx.
+func (e *Executor) RunShellWithStdinContext(ctx context.Context, command string, stdin string) (string, error) {
 	if e.Verbose {
-		fmt.Printf(">>> sh -c %s (with stdin)\n", scrub(command))
+		fmt.Fprintf(Output(ctx), ">>> sh -c %s (with stdin)\n", scrub(command))
 	}
 
-	cmd := exec.Command("sh", "-c", command)
+	cmd, ctx, cancel := e.command(ctx, "sh", "-c", command)
+	defer cancel()
 	cmd.Stdin = strings.NewReader(stdin)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("%v: %s", err, scrub(stderr.String()))
-	}
-
-	return stdout.String(), nil
+	return e.capture(ctx, cmd, command)
 }
 
 // AppendToFile appends content to a file
@@ -0,0 +1,28 @@
+package executor
+
+import "context"
+
+// FileWriter is implemented by executors whose target is not the local host
+// (SSHExecutor) or that must not touch it (DryRunExecutor), so files written
+// next to the commands they run land on the same machine.
+type FileWriter interface {
+	WriteFile(path, content string) error
+}
+
+// ContextFileWriter is a FileWriter whose writes can be cancelled, so a Ctrl-C
+// also interrupts an upload to a hung remote node. Writers also get the call's
+// values (DryRunExecutor files each write under the step carried by ctx).
+type ContextFileWriter interface {
+	WriteFileContext(ctx context.Context, path, content string) error
+}
+
+// WriteFileOn writes content to path on the host e operates on: through e when
+// it is a FileWriter, otherwise on the local filesystem. Installers render
+// manifests to /tmp and then `kubectl apply -f` them, so the file must be
+// written wherever the command will run.
+func WriteFileOn(e ShellExecutor, path, content string) error {
+	if fw, ok := e.(FileWriter); ok {
+		return fw.WriteFile(path, content)
+	}
+	return WriteFile(path, content)
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+type outputKey struct{}
+
+// WithOutput returns ctx carrying w as the console for calls bound to it: the
+// verbose command echo, the streamed output (stdout and stderr) of
+// RunShellWithOutput, and the progress lines of installers and readiness waits.
+// Components installed concurrently each get a Prefixed writer this way.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// Output returns the console carried by ctx (see WithOutput), or os.Stdout.
+func Output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stdout
+}
+
+// errOutput is where streamed stderr goes: the WithOutput console when ctx
+// carries one, so a component's errors keep its prefix, otherwise os.Stderr.
+func errOutput(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stderr
+}
+
+// Prefixed returns a writer that writes each complete line to w as
+// "[name] line", serialised by mu so lines from writers sharing w never
+// interleave mid-line. Blank lines are dropped. Call Flush once the writer is done to emit a trailing
+// partial line.
+func Prefixed(w io.Writer, mu *sync.Mutex, name string) *PrefixWriter {
+	return &PrefixWriter{w: w, mu: mu, prefix: []byte("[" + name + "] ")}
+}
+
+// PrefixWriter is the line-prefixing writer returned by Prefixed.
+type PrefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix []byte
+
+	bufMu sync.Mutex
+	buf   []byte
+}
+
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.bufMu.Lock()
+	defer p.bufMu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.emit(p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes a pending partial line, terminated by a newline.
+func (p *PrefixWriter) Flush() error {
+	p.bufMu.Lock()
+	defer p.bufMu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.emit(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *PrefixWriter) emit(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil // blank separators are noise once lines carry a prefix
+	}
+	if _, err := p.w.Write(p.prefix); err != nil {
+		return err
+	}
+	_, err := p.w.Write(line)
+	return err
+}
@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixed_PrefixesWholeLines(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	w := Prefixed(&buf, &mu, "Loki Stack")
+
+	_, _ = w.Write([]byte("\n>>> Installing Loki"))
+	_, _ = w.Write([]byte(" Stack...\nWaiting for Loki...\npartial"))
+	assert.Equal(t, "[Loki Stack] >>> Installing Loki Stack...\n[Loki Stack] Waiting for Loki...\n", buf.String(),
+		"blank lines dropped, partial line held back")
+
+	_ = w.Flush()
+	assert.Equal(t, "[Loki Stack] partial\n", buf.String()[buf.Len()-len("[Loki Stack] partial\n"):])
+}
+
+func TestOutput_DefaultsToStdout(t *testing.T) {
+	assert.Equal(t, os.Stdout, Output(context.Background()))
+
+	var buf bytes.Buffer
+	ctx := WithOutput(context.Background(), &buf)
+	_, _ = DryRunExecutor{}.RunShellContext(ctx, "kubectl get nodes")
+	assert.Equal(t, "[dry-run] sh -c kubectl get nodes\n", buf.String())
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package executor
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// processGroupGrace is how long a cancelled command gets to exit after SIGTERM
+// before its process group is SIGKILLed and its pipes are closed.
+const processGroupGrace = 5 * time.Second
+
+// killProcessGroup runs cmd in its own process group and, on context
+// cancellation, signals the whole group. exec.CommandContext alone only kills
+// `sh`, leaving children such as `kubectl wait` or the `bash` end of
+// `curl | bash` running (and holding stdout open) after Ctrl-C.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = processGroupGrace
+}
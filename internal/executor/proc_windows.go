@@ -0,0 +1,9 @@
+//go:build windows
+
+package executor
+
+import "os/exec"
+
+// killProcessGroup is a no-op on Windows: exec.CommandContext already kills the
+// direct child, and provisioning itself only ever runs on Linux nodes.
+func killProcessGroup(cmd *exec.Cmd) {}
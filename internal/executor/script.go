@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// heredocEOF delimits stdin and file content in a rendered script. It is long
+// and specific enough not to occur as a line of a manifest.
+const heredocEOF = "K8S_PROVISIONER_EOF"
+
+// Script collects the commands a dry run would execute, grouped by step (see
+// WithStep) in the order each step first issued a command, so a run can be
+// reviewed — and replayed by hand — one component at a time. Every line is
+// scrubbed (see scrub) before it is stored.
+type Script struct {
+	mu    sync.Mutex
+	steps []scriptStep
+}
+
+type scriptStep struct {
+	name  string
+	lines []string
+}
+
+// NewScript returns an empty Script for DryRunExecutor.Script.
+func NewScript() *Script {
+	return &Script{}
+}
+
+// add appends one command (possibly spanning several lines) to step.
+func (s *Script) add(step, command string) {
+	command = scrub(command)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.steps {
+		if s.steps[i].name == step {
+			s.steps[i].lines = append(s.steps[i].lines, command)
+			return
+		}
+	}
+	s.steps = append(s.steps, scriptStep{name: step, lines: []string{command}})
+}
+
+// Steps returns the step names in the order they first ran. Commands issued
+// outside any step are grouped under "".
+func (s *Script) Steps() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(s.steps))
+	for i, st := range s.steps {
+		names[i] = st.name
+	}
+	return names
+}
+
+// Render returns the shell script for step, or "" if it ran no command.
+func (s *Script) Render(step string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.steps {
+		if st.name == step {
+			return renderScript(st)
+		}
+	}
+	return ""
+}
+
+func renderScript(st scriptStep) string {
+	name := st.name
+	if name == "" {
+		name = "(no step)"
+	}
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# %s\n", name)
+	b.WriteString("#\n")
+	b.WriteString("# Generated by k8s-provisioner --dry-run: the commands this step issues, in\n")
+	b.WriteString("# order. Readiness polls appear once, as the dry run answered them \"ready\";\n")
+	b.WriteString("# failures the installer tolerates are tolerated here too, so there is no set -e.\n")
+	for _, line := range st.lines {
+		b.WriteString("\n")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// WriteDir writes one script per step into dir as NN-<step>.sh, numbered in
+// run order, and returns the paths written. Scripts are for review, so they are
+// not executable; run one with sh after reading it.
+func (s *Script) WriteDir(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create script directory: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for i, st := range s.steps {
+		path := filepath.Join(dir, fmt.Sprintf("%02d-%s.sh", i+1, scriptSlug(st.name)))
+		if err := os.WriteFile(path, []byte(renderScript(st)), 0644); err != nil {
+			return paths, fmt.Errorf("write script: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// scriptSlug turns a step name such as "VPA (Vertical Pod Autoscaler)" into a
+// file name stem ("vpa-vertical-pod-autoscaler").
+func scriptSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "commands"
+	}
+	return slug
+}
+
+// heredoc renders command fed body on stdin. The braces give the whole command
+// (which may be a pipeline) the here-document, as `sh -c` did.
+func heredoc(command, body string) string {
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return fmt.Sprintf("{\n%s\n} <<'%s'\n%s%s", command, heredocEOF, body, heredocEOF)
+}
+
+// writeFileCommand renders a file write as the equivalent shell command.
+func writeFileCommand(path, content string) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fmt.Sprintf("cat > %s <<'%s'\n%s%s", shellQuote(path), heredocEOF, content, heredocEOF)
+}
@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScript_GroupsByStepInFirstRunOrder(t *testing.T) {
+	s := NewScript()
+	s.add("MetalLB", "kubectl apply -f a.yaml")
+	s.add("Istio", "istioctl install -y")
+	s.add("MetalLB", "kubectl apply -f b.yaml")
+
+	assert.Equal(t, []string{"MetalLB", "Istio"}, s.Steps())
+
+	got := s.Render("MetalLB")
+	assert.Contains(t, got, "# MetalLB\n")
+	assert.Less(t, strings.Index(got, "a.yaml"), strings.Index(got, "b.yaml"), "commands keep their order")
+	assert.NotContains(t, got, "istioctl")
+	assert.Empty(t, s.Render("Kiali"))
+}
+
+func TestScript_ScrubsCommands(t *testing.T) {
+	s := NewScript()
+	s.add("", "sshpass -p 'hunter2' ssh node true")
+	assert.NotContains(t, s.Render(""), "hunter2")
+}
+
+func TestScriptSlug(t *testing.T) {
+	for name, want := range map[string]string{
+		"VPA (Vertical Pod Autoscaler)":         "vpa-vertical-pod-autoscaler",
+		"Tracing Stack (Tempo + OpenTelemetry)": "tracing-stack-tempo-opentelemetry",
+		"cert-manager":                          "cert-manager",
+		"":                                      "commands",
+	} {
+		assert.Equal(t, want, scriptSlug(name), name)
+	}
+}
+
+func TestHeredoc_FeedsBodyToWholeCommand(t *testing.T) {
+	got := heredoc("cat | kubectl apply -f -", "kind: Secret")
+	assert.Equal(t, "{\ncat | kubectl apply -f -\n} <<'"+heredocEOF+"'\nkind: Secret\n"+heredocEOF, got)
+}
+
+func TestScript_WriteDirNumbersStepsAndIsNotExecutable(t *testing.T) {
+	skipOnWindows(t)
+	s := NewScript()
+	s.add("MetalLB", "kubectl apply -f a.yaml")
+	s.add("VPA (Vertical Pod Autoscaler)", "helm install vpa")
+
+	dir := filepath.Join(t.TempDir(), "scripts")
+	paths, err := s.WriteDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "01-metallb.sh"),
+		filepath.Join(dir, "02-vpa-vertical-pod-autoscaler.sh"),
+	}, paths)
+
+	info, err := os.Stat(paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	data, err := os.ReadFile(paths[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "helm install vpa")
+}
@@ -0,0 +1,307 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshDialTimeout bounds the TCP connect + handshake to a node.
+const sshDialTimeout = 15 * time.Second
+
+// SSHOptions holds the credentials used to reach a node. KeyPath takes
+// precedence over Password, mirroring provisioning.ssh_key_path/ssh_password.
+type SSHOptions struct {
+	User     string
+	Password string
+	KeyPath  string
+	Port     int // default 22
+	// KnownHostsPath is the known_hosts file used for host-key checking
+	// (default ~/.ssh/known_hosts). Unknown hosts are trusted on first use and
+	// recorded; a changed key is rejected — the same policy as the
+	// StrictHostKeyChecking=accept-new used for the Vault init copy.
+	KnownHostsPath string
+}
+
+// SSHExecutor is a CommandExecutor that runs every command on a remote node
+// over SSH, so a whole cluster can be driven from one workstation or CI runner
+// instead of running the binary inside each VM. Commands run through
+// `sudo -n sh -c` unless the login user is root, matching the root shell the
+// local Executor runs under during provisioning.
+type SSHExecutor struct {
+	Verbose bool
+	// Timeout bounds every command, as Executor.Timeout does.
+	Timeout time.Duration
+	// Audit, when set, receives one record per command, tagged with the host.
+	Audit *AuditLog
+
+	host   string
+	client *ssh.Client
+	sudo   bool
+}
+
+// Compile-time verification that SSHExecutor is a full, cancellable executor
+// that also writes files on the remote host.
+var (
+	_ CommandExecutor        = (*SSHExecutor)(nil)
+	_ ContextCommandExecutor = (*SSHExecutor)(nil)
+	_ FileWriter             = (*SSHExecutor)(nil)
+	_ ContextFileWriter      = (*SSHExecutor)(nil)
+)
+
+// DialSSH connects to host with opts. The caller must Close the executor.
+func DialSSH(host string, opts SSHOptions, verbose bool) (*SSHExecutor, error) {
+	auth, err := sshAuth(opts)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := acceptNewHostKey(opts.KnownHostsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	port := opts.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            opts.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeys,
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s@%s: %w", opts.User, addr, err)
+	}
+
+	return &SSHExecutor{
+		Verbose: verbose,
+		host:    host,
+		client:  client,
+		sudo:    opts.User != "root",
+	}, nil
+}
+
+// Host returns the address this executor runs commands on.
+func (s *SSHExecutor) Host() string { return s.host }
+
+// Close terminates the SSH connection.
+func (s *SSHExecutor) Close() error { return s.client.Close() }
+
+func sshAuth(opts SSHOptions) (ssh.AuthMethod, error) {
+	if opts.KeyPath != "" {
+		pem, err := os.ReadFile(opts.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read ssh key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse ssh key %s: %w", opts.KeyPath, err)
+		}
+		return ssh.PublicKeys(signer), nil
+	}
+	if opts.Password == "" {
+		return nil, fmt.Errorf("no ssh credentials: set provisioning.ssh_key_path or provisioning.ssh_password")
+	}
+	return ssh.Password(opts.Password), nil
+}
+
+// acceptNewHostKey returns a trust-on-first-use host key callback backed by
+// path: unknown hosts are appended, known hosts must present the recorded key.
+func acceptNewHostKey(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("locate known_hosts: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open known_hosts: %w", err)
+	}
+	_ = f.Close()
+
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts %s: %w", path, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+			return AppendToFile(path, line+"\n")
+		}
+		return err
+	}, nil
+}
+
+// shellQuote wraps s in single quotes for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remoteCommand is the command line sent to the remote sshd.
+func (s *SSHExecutor) remoteCommand(command string) string {
+	if s.sudo {
+		return "sudo -n sh -c " + shellQuote(command)
+	}
+	return "sh -c " + shellQuote(command)
+}
+
+// argv renders an argv-form call as one shell command, quoting each argument.
+func argv(name string, args []string) string {
+	parts := []string{shellQuote(name)}
+	for _, a := range args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// run executes command on the remote host and records it in the audit log.
+// When auditing, stderr is also teed into a buffer for the audit record.
+func (s *SSHExecutor) run(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var errBuf bytes.Buffer
+	if s.Audit != nil {
+		stderr = io.MultiWriter(stderr, &errBuf)
+	}
+	start := time.Now()
+	err := s.runSession(ctx, command, stdin, stdout, stderr)
+	s.Audit.record(ctx, s.host, command, start, err, errBuf.String())
+	return err
+}
+
+// runSession runs command in a new SSH session. On cancellation the session is
+// signalled and closed; sshd then hangs up on the remote process.
+func (s *SSHExecutor) runSession(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	session, err := s.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("%s: open ssh session: %w", s.host, err)
+	}
+	defer func() { _ = session.Close() }()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if err := session.Start(s.remoteCommand(command)); err != nil {
+		return fmt.Errorf("%s: %w", s.host, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGTERM)
+		_ = session.Close()
+		return ctx.Err()
+	}
+}
+
+func (s *SSHExecutor) capture(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := s.run(ctx, command, stdin, &stdout, &stderr); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("%s: %w: %s", s.host, ctxErr, scrub(stderr.String()))
+		}
+		return "", fmt.Errorf("%s: %v: %s", s.host, err, scrub(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (s *SSHExecutor) echo(ctx context.Context, command, suffix string) {
+	if s.Verbose {
+		fmt.Fprintf(Output(ctx), ">>> [%s] sh -c %s%s\n", s.host, scrub(command), suffix)
+	}
+}
+
+func (s *SSHExecutor) Run(name string, args ...string) (string, error) {
+	return s.RunContext(context.Background(), name, args...)
+}
+
+func (s *SSHExecutor) RunContext(ctx context.Context, name string, args ...string) (string, error) {
+	command := argv(name, args)
+	s.echo(ctx, command, "")
+	return s.capture(ctx, command, nil)
+}
+
+func (s *SSHExecutor) RunWithOutput(name string, args ...string) error {
+	return s.RunWithOutputContext(context.Background(), name, args...)
+}
+
+func (s *SSHExecutor) RunWithOutputContext(ctx context.Context, name string, args ...string) error {
+	command := argv(name, args)
+	s.echo(ctx, command, "")
+	return s.run(ctx, command, nil, Output(ctx), errOutput(ctx))
+}
+
+func (s *SSHExecutor) RunShell(command string) (string, error) {
+	return s.RunShellContext(context.Background(), command)
+}
+
+func (s *SSHExecutor) RunShellContext(ctx context.Context, command string) (string, error) {
+	s.echo(ctx, command, "")
+	return s.capture(ctx, command, nil)
+}
+
+func (s *SSHExecutor) RunShellWithOutput(command string) error {
+	return s.RunShellWithOutputContext(context.Background(), command)
+}
+
+func (s *SSHExecutor) RunShellWithOutputContext(ctx context.Context, command string) error {
+	s.echo(ctx, command, "")
+	return s.run(ctx, command, nil, Output(ctx), errOutput(ctx))
+}
+
+func (s *SSHExecutor) RunShellWithStdin(command string, stdin string) (string, error) {
+	return s.RunShellWithStdinContext(context.Background(), command, stdin)
+}
+
+func (s *SSHExecutor) RunShellWithStdinContext(ctx context.Context, command string, stdin string) (string, error) {
+	s.echo(ctx, command, " (with stdin)")
+	return s.capture(ctx, command, strings.NewReader(stdin))
+}
+
+// WriteFile uploads content to path on the remote host (mode 0644, owned by
+// root when sudo is in effect), creating the parent directory if needed.
+func (s *SSHExecutor) WriteFile(path, content string) error {
+	return s.WriteFileContext(context.Background(), path, content)
+}
+
+// WriteFileContext is WriteFile bound to ctx: cancelling ctx aborts the upload.
+func (s *SSHExecutor) WriteFileContext(ctx context.Context, path, content string) error {
+	upload := fmt.Sprintf("mkdir -p %s && umask 022 && cat > %s",
+		shellQuote(filepath.Dir(path)), shellQuote(path))
+	if s.Verbose {
+		fmt.Fprintf(Output(ctx), ">>> [%s] upload %s\n", s.host, path)
+	}
+	_, err := s.capture(ctx, upload, strings.NewReader(content))
+	return err
+}
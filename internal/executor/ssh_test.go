@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'plain'`, shellQuote("plain"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, `'$(rm -rf /)'`, shellQuote("$(rm -rf /)"))
+}
+
+func TestSSHRemoteCommand_SudoUnlessRoot(t *testing.T) {
+	s := &SSHExecutor{sudo: true}
+	assert.Equal(t, `sudo -n sh -c 'echo '\''hi'\'''`, s.remoteCommand("echo 'hi'"))
+
+	s.sudo = false
+	assert.Equal(t, `sh -c 'kubeadm version'`, s.remoteCommand("kubeadm version"))
+}
+
+func TestSSHArgv_QuotesEachArgument(t *testing.T) {
+	assert.Equal(t, `'kubectl' 'get' 'pods' '-l' 'app=a b'`,
+		argv("kubectl", []string{"get", "pods", "-l", "app=a b"}))
+}
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func TestAcceptNewHostKey_TrustsOnFirstUseRejectsChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	addr := &net.TCPAddr{IP: net.ParseIP("192.168.56.11"), Port: 22}
+	key := newHostKey(t)
+
+	cb, err := acceptNewHostKey(path)
+	require.NoError(t, err)
+	require.NoError(t, cb("192.168.56.11:22", addr, key), "unknown host is trusted on first use")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "192.168.56.11 "), "host recorded in known_hosts: %q", data)
+
+	// A fresh callback (next run) accepts the recorded key and rejects a new one.
+	cb, err = acceptNewHostKey(path)
+	require.NoError(t, err)
+	assert.NoError(t, cb("192.168.56.11:22", addr, key))
+	assert.Error(t, cb("192.168.56.11:22", addr, newHostKey(t)), "changed host key must be rejected")
+}
+
+func TestSSHAuth_RequiresCredentials(t *testing.T) {
+	_, err := sshAuth(SSHOptions{User: "vagrant"})
+	assert.Error(t, err)
+
+	_, err = sshAuth(SSHOptions{User: "vagrant", KeyPath: filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorContains(t, err, "read ssh key")
+}
@@ -0,0 +1,218 @@
+// Package fetch downloads the manifests, scripts and release tarballs
+// provisioning installs into a cache on the node, and verifies each against
+// the SHA-256 pinned for its URL in checksums.yaml, which ships inside the
+// binary, or under checksums: in config.yaml. Installers only apply or execute
+// the verified copy; a missing pin or a mismatch stops the install.
+package fetch
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// CacheDir is where verified downloads are kept on the node, one directory
+// per checksum, so a re-run does not download them again.
+const CacheDir = "/var/cache/k8s-provisioner"
+
+// shippedFile is the checksums manifest embedded at build time; `make
+// checksums` regenerates it for the default versions.
+//
+//go:embed checksums.yaml
+var shippedFile []byte
+
+// Pins maps a download URL, which names the version and architecture, to the
+// SHA-256 of the file behind it.
+type Pins map[string]string
+
+var (
+	shippedOnce sync.Once
+	shipped     Pins
+	shippedErr  error
+)
+
+// Shipped returns the pins embedded in the binary.
+func Shipped() (Pins, error) {
+	shippedOnce.Do(func() {
+		shipped, shippedErr = Parse(shippedFile)
+	})
+	return shipped, shippedErr
+}
+
+var sha256Hex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Parse reads a checksums manifest and rejects malformed checksums.
+func Parse(data []byte) (Pins, error) {
+	pins := Pins{}
+	if err := yaml.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("parse checksums: %w", err)
+	}
+	for url, sum := range pins {
+		if !sha256Hex.MatchString(sum) {
+			return nil, fmt.Errorf("checksum of %s is not a SHA-256 in lowercase hex: %q", url, sum)
+		}
+	}
+	return pins, nil
+}
+
+// Render returns pins as a checksums manifest, sorted by URL.
+func (p Pins) Render() string {
+	var b strings.Builder
+	b.WriteString("# SHA-256 of every file k8s-provisioner downloads, by URL. Generated by\n")
+	b.WriteString("# `k8s-provisioner checksums`; do not edit by hand.\n")
+	urls := make([]string, 0, len(p))
+	for url := range p {
+		urls = append(urls, url)
+	}
+	slices.Sort(urls)
+	for _, url := range urls {
+		fmt.Fprintf(&b, "%s: %s\n", url, p[url])
+	}
+	return b.String()
+}
+
+// Sum downloads url and returns its SHA-256, to pin it.
+func Sum(url string) (string, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("download %s: %w", url, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unpinned stands in for the checksum of an unpinned URL in a dry run.
+const unpinned = "UNPINNED"
+
+// Fetcher fetches File artifacts through Exec, on the node: from their URL
+// into CacheDir online, from the unpacked bundle with offline.bundle set.
+type Fetcher struct {
+	Exec   executor.ShellExecutor
+	Config *config.Config
+	// Out receives progress and dry-run notes.
+	Out io.Writer
+	// DryRun reports an unpinned URL instead of failing on it, and always
+	// shows the download.
+	DryRun bool
+}
+
+// Fetch returns the path of the File artifact a on the node once its SHA-256
+// matches the pin for a.URL. A cached copy that still matches is reused.
+func (f Fetcher) Fetch(a offline.Artifact) (string, error) {
+	sum, err := f.pin(a.URL)
+	if err != nil {
+		return "", err
+	}
+	if f.Config.Offline.Enabled() {
+		path := a.Path()
+		return path, f.verify(a.URL, path, sum)
+	}
+
+	path := CacheDir + "/" + sum + "/" + a.Name
+	if !f.DryRun {
+		if _, err := f.Exec.RunShell(checkCmd(path, sum)); err == nil {
+			fmt.Fprintf(f.Out, "Using cached %s\n", path)
+			return path, nil
+		}
+	}
+	fmt.Fprintf(f.Out, "Downloading %s...\n", a.URL)
+	// Each download gets its own temporary file: steps running in parallel
+	// may fetch the same artifact.
+	part, err := f.Exec.RunShell(fmt.Sprintf("mkdir -p %s/%s && mktemp %s.XXXXXX", CacheDir, sum, path))
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", a.URL, err)
+	}
+	part = strings.TrimSpace(part)
+	if part == "" { // a dry run
+		part = path + ".XXXXXX"
+	}
+	if _, err := f.Exec.RunShell(fmt.Sprintf("curl -fsSL --connect-timeout 10 --max-time 300 -o %s %s", part, a.URL)); err != nil {
+		_, _ = f.Exec.RunShell("rm -f " + part)
+		return "", fmt.Errorf("download %s: %w", a.URL, err)
+	}
+	if err := f.verify(a.URL, part, sum); err != nil {
+		_, _ = f.Exec.RunShell("rm -f " + part)
+		return "", err
+	}
+	if _, err := f.Exec.RunShell(fmt.Sprintf("mv %s %s", part, path)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// pin returns the SHA-256 pinned for url: config.yaml's checksums first, then
+// the shipped manifest.
+func (f Fetcher) pin(url string) (string, error) {
+	if sum, ok := f.Config.Checksums[url]; ok {
+		return sum, nil
+	}
+	pins, err := Shipped()
+	if err != nil {
+		return "", err
+	}
+	if sum, ok := pins[url]; ok {
+		return sum, nil
+	}
+	if f.DryRun {
+		fmt.Fprintf(f.Out, "[dry-run] no SHA-256 pinned for %s: a real run stops here\n", url)
+		return unpinned, nil
+	}
+	return "", fmt.Errorf("no SHA-256 pinned for %s: pin it under checksums: in config.yaml (`k8s-provisioner checksums` prints the pins of a config)", url)
+}
+
+// verify fails unless the file at path has the checksum sum.
+func (f Fetcher) verify(url, path, sum string) error {
+	if _, err := f.Exec.RunShell(checkCmd(path, sum)); err != nil {
+		got, _ := f.Exec.RunShell(fmt.Sprintf("sha256sum %s | cut -d' ' -f1", path))
+		return fmt.Errorf("checksum mismatch for %s: %s has sha256 %s, pinned %s", url, path, strings.TrimSpace(got), sum)
+	}
+	return nil
+}
+
+func checkCmd(path, sum string) string {
+	return fmt.Sprintf("echo '%s  %s' | sha256sum -c --status -", sum, path)
+}
+
+// Arch returns the architecture suffix of release downloads for the node exec
+// runs on, amd64 or arm64. A dry run, where uname prints nothing, gets amd64.
+func Arch(exec executor.ShellExecutor, dryRun bool) (string, error) {
+	out, err := exec.RunShell("uname -m")
+	if err != nil {
+		return "", err
+	}
+	switch machine := strings.TrimSpace(out); machine {
+	case "x86_64":
+		return "amd64", nil
+	case "aarch64", "arm64":
+		return "arm64", nil
+	case "":
+		if dryRun {
+			return "amd64", nil
+		}
+		return "", fmt.Errorf("uname -m printed nothing")
+	default:
+		return "", fmt.Errorf("unsupported architecture %s: releases are published for x86_64 and aarch64", machine)
+	}
+}
@@ -0,0 +1,151 @@
+package fetch
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+const testSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// shell records commands and fails those containing a key of errs.
+type shell struct {
+	calls   []string
+	outputs map[string]string
+	errs    map[string]error
+}
+
+func (s *shell) RunShell(cmd string) (string, error) {
+	s.calls = append(s.calls, cmd)
+	for sub, err := range s.errs {
+		if strings.Contains(cmd, sub) {
+			return "", err
+		}
+	}
+	for sub, out := range s.outputs {
+		if strings.Contains(cmd, sub) {
+			return out, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *shell) RunShellWithOutput(cmd string) error {
+	_, err := s.RunShell(cmd)
+	return err
+}
+
+func (s *shell) RunShellWithStdin(cmd, _ string) (string, error) { return s.RunShell(cmd) }
+
+var manifest = offline.Artifact{Kind: offline.File, Name: "metallb-native.yaml", URL: "https://example.com/v1/metallb-native.yaml"}
+
+func TestShipped_Parses(t *testing.T) {
+	_, err := Shipped()
+	require.NoError(t, err)
+}
+
+func TestParse_RejectsMalformedChecksums(t *testing.T) {
+	pins, err := Parse([]byte("https://example.com/a: " + testSum + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, testSum, pins["https://example.com/a"])
+
+	_, err = Parse([]byte("https://example.com/a: " + strings.ToUpper(testSum) + "\n"))
+	require.Error(t, err)
+	_, err = Parse([]byte("https://example.com/a: sha256:abc\n"))
+	require.Error(t, err)
+}
+
+func TestPins_RenderRoundTrips(t *testing.T) {
+	pins := Pins{"https://example.com/b": testSum, "https://example.com/a": strings.Repeat("0", 64)}
+	out := pins.Render()
+	assert.Less(t, strings.Index(out, "example.com/a"), strings.Index(out, "example.com/b"), "sorted by URL")
+	parsed, err := Parse([]byte(out))
+	require.NoError(t, err)
+	assert.Equal(t, pins, parsed)
+}
+
+func TestFetch_DownloadsAndVerifies(t *testing.T) {
+	exec := &shell{
+		outputs: map[string]string{"mktemp": CacheDir + "/" + testSum + "/metallb-native.yaml.Ab12Cd\n"},
+		errs:    map[string]error{"/metallb-native.yaml' | sha256sum -c": errors.New("exit status 1")},
+	}
+	cfg := &config.Config{Checksums: map[string]string{manifest.URL: testSum}}
+
+	// The cache check of the final path fails: nothing cached yet.
+	path, err := Fetcher{Exec: exec, Config: cfg, Out: &bytes.Buffer{}}.Fetch(manifest)
+	require.NoError(t, err)
+	dir := CacheDir + "/" + testSum
+	assert.Equal(t, dir+"/metallb-native.yaml", path)
+	part := path + ".Ab12Cd"
+	assert.Equal(t, []string{
+		"echo '" + testSum + "  " + path + "' | sha256sum -c --status -",
+		"mkdir -p " + dir + " && mktemp " + path + ".XXXXXX",
+		"curl -fsSL --connect-timeout 10 --max-time 300 -o " + part + " " + manifest.URL,
+		"echo '" + testSum + "  " + part + "' | sha256sum -c --status -",
+		"mv " + part + " " + path,
+	}, exec.calls)
+}
+
+func TestFetch_MismatchIsFatal(t *testing.T) {
+	exec := &shell{
+		outputs: map[string]string{"| cut": "0badc0de\n", "mktemp": CacheDir + "/" + testSum + "/metallb-native.yaml.Ab12Cd\n"},
+		errs:    map[string]error{"sha256sum -c": errors.New("exit status 1")},
+	}
+	cfg := &config.Config{Checksums: map[string]string{manifest.URL: testSum}}
+
+	_, err := Fetcher{Exec: exec, Config: cfg, Out: &bytes.Buffer{}}.Fetch(manifest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch for "+manifest.URL)
+	assert.Contains(t, err.Error(), "has sha256 0badc0de")
+	assert.Equal(t, "rm -f "+CacheDir+"/"+testSum+"/metallb-native.yaml.Ab12Cd", exec.calls[len(exec.calls)-1])
+}
+
+func TestFetch_OfflineVerifiesTheBundledFile(t *testing.T) {
+	exec := &shell{errs: map[string]error{"sha256sum -c": errors.New("exit status 1")}}
+	cfg := &config.Config{
+		Offline:   config.OfflineConfig{Bundle: "/vagrant/k8s-bundle.tar.gz"},
+		Checksums: map[string]string{manifest.URL: testSum},
+	}
+
+	_, err := Fetcher{Exec: exec, Config: cfg, Out: &bytes.Buffer{}}.Fetch(manifest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/opt/k8s-offline/files/metallb-native.yaml")
+	for _, c := range exec.calls {
+		assert.NotContains(t, c, "curl")
+	}
+}
+
+func TestFetch_UnpinnedURL(t *testing.T) {
+	exec := &shell{}
+	_, err := Fetcher{Exec: exec, Config: &config.Config{}, Out: &bytes.Buffer{}}.Fetch(manifest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no SHA-256 pinned for "+manifest.URL)
+	assert.Empty(t, exec.calls, "nothing is downloaded")
+
+	var out bytes.Buffer
+	_, err = Fetcher{Exec: exec, Config: &config.Config{}, Out: &out, DryRun: true}.Fetch(manifest)
+	require.NoError(t, err, "a dry run reports it and goes on")
+	assert.Contains(t, out.String(), "[dry-run] no SHA-256 pinned for "+manifest.URL)
+}
+
+func TestArch(t *testing.T) {
+	for machine, want := range map[string]string{"x86_64\n": "amd64", "aarch64\n": "arm64", "arm64": "arm64"} {
+		arch, err := Arch(&shell{outputs: map[string]string{"uname -m": machine}}, false)
+		require.NoError(t, err)
+		assert.Equal(t, want, arch)
+	}
+	_, err := Arch(&shell{outputs: map[string]string{"uname -m": "riscv64"}}, false)
+	assert.ErrorContains(t, err, "unsupported architecture riscv64")
+	_, err = Arch(&shell{}, false)
+	assert.Error(t, err)
+	arch, err := Arch(&shell{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "amd64", arch)
+}
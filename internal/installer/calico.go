@@ -1,36 +1,42 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type Calico struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewCalico(cfg *config.Config, exec executor.ShellExecutor) *Calico {
-	return &Calico{config: cfg, exec: exec}
+func NewCalico(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Calico {
+	return &Calico{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (c *Calico) Install() error {
 	version := c.config.Versions.Calico
 
 	// Install Tigera operator
-	fmt.Printf("Installing Tigera operator (Calico %s)...\n", version)
-	operatorURL := fmt.Sprintf("https://raw.githubusercontent.com/projectcalico/calico/v%s/manifests/tigera-operator.yaml", version)
-	if _, err := c.exec.RunShell(fmt.Sprintf("kubectl create -f %s", operatorURL)); err != nil {
+	fmt.Fprintf(console(c.ctx), "Installing Tigera operator (Calico %s)...\n", version)
+	operator, err := fetchFile(c.ctx, c.config, c.exec, c.operator())
+	if err != nil {
+		return err
+	}
+	if _, err := c.exec.RunShell("kubectl create -f " + operator); err != nil {
 		return err
 	}
 
 	// Poll until the Tigera CRDs are registered — a fixed sleep is unreliable
 	// because the operator takes variable time to register them.
-	fmt.Println("Waiting for Tigera CRDs...")
+	fmt.Fprintln(console(c.ctx), "Waiting for Tigera CRDs...")
 	if err := c.waitForTigeraCRDs(defaultReadyTimeout); err != nil {
 		return err
 	}
@@ -55,7 +61,7 @@ metadata:
   name: default
 spec: {}`, c.config.Cluster.PodCIDR)
 
-	if err := executor.WriteFile("/tmp/calico-installation.yaml", installation); err != nil {
+	if err := executor.WriteFileOn(c.exec, "/tmp/calico-installation.yaml", installation); err != nil {
 		return err
 	}
 
@@ -64,36 +70,67 @@ spec: {}`, c.config.Cluster.PodCIDR)
 	}
 
 	// Wait for Calico to be ready
-	fmt.Println("Waiting for Calico to be ready...")
+	fmt.Fprintln(console(c.ctx), "Waiting for Calico to be ready...")
 	return c.waitForReady(defaultReadyTimeout)
 }
 
+func (c *Calico) operator() offline.Artifact {
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "tigera-operator.yaml",
+		URL:  fmt.Sprintf("https://raw.githubusercontent.com/projectcalico/calico/v%s/manifests/tigera-operator.yaml", c.config.Versions.Calico),
+	}
+}
+
+// Artifacts returns the Tigera operator manifest and the Calico images the
+// operator deploys, which no manifest names.
+func (c *Calico) Artifacts(string) []offline.Artifact {
+	artifacts := []offline.Artifact{c.operator()}
+	for _, name := range []string{"node", "cni", "kube-controllers", "typha", "apiserver", "csi", "node-driver-registrar", "pod2daemon-flexvol"} {
+		artifacts = append(artifacts, images(fmt.Sprintf("docker.io/calico/%s:v%s", name, c.config.Versions.Calico))...)
+	}
+	return artifacts
+}
+
 func (c *Calico) waitForTigeraCRDs(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	if err := waitFor(c.ctx, "Tigera CRDs to be registered", pollUntil(timeout, longPollInterval), func() bool {
 		out, err := c.exec.RunShell("kubectl get crd installations.operator.tigera.io 2>/dev/null")
-		if err == nil && strings.Contains(out, "installations.operator.tigera.io") {
-			fmt.Println("Tigera CRDs are ready!")
-			return nil
-		}
-		fmt.Println("Waiting for Tigera CRDs to be registered...")
-		time.Sleep(longPollInterval)
+		return err == nil && strings.Contains(out, "installations.operator.tigera.io")
+	}); err != nil {
+		return err
 	}
-	return fmt.Errorf("timeout waiting for Tigera CRDs")
+	fmt.Fprintln(console(c.ctx), "Tigera CRDs are ready!")
+	return nil
 }
 
 func (c *Calico) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	err := waitFor(c.ctx, "Calico pods", pollUntil(timeout, longPollInterval), func() bool {
 		out, err := c.exec.RunShell(
 			"kubectl rollout status daemonset/calico-node -n calico-system --timeout=10s 2>&1")
-		if err == nil && strings.Contains(out, "successfully rolled out") {
-			fmt.Println("Calico is ready!")
-			return nil
-		}
-		fmt.Println("Waiting for Calico pods...")
-		time.Sleep(longPollInterval)
+		return err == nil && strings.Contains(out, "successfully rolled out")
+	})
+	if err != nil && c.ctx.Err() == nil {
+		fmt.Fprintln(console(c.ctx), "Warning: Calico pods may still be starting")
+		return nil
+	}
+	if err == nil {
+		fmt.Fprintln(console(c.ctx), "Calico is ready!")
+	}
+	return err
+}
+
+// AfterAPIServerRestart restarts calico-node: an API server restart (the
+// Keycloak OIDC patch) invalidates the CNI kubeconfig token calico-node wrote
+// at install time, so each node needs a fresh token before workers join.
+// Failures only warn.
+func (c *Calico) AfterAPIServerRestart() error {
+	fmt.Fprintln(console(c.ctx), "\n>>> Refreshing Calico CNI kubeconfig after API server restart...")
+	if _, err := c.exec.RunShell("kubectl rollout restart daemonset/calico-node -n calico-system"); err != nil {
+		fmt.Fprintf(console(c.ctx), "Warning: calico-node restart failed: %v\n", err)
+		return nil
+	}
+	if _, err := c.exec.RunShell("kubectl rollout status daemonset/calico-node -n calico-system --timeout=3m"); err != nil {
+		fmt.Fprintf(console(c.ctx), "Warning: calico-node rollout status: %v\n", err)
 	}
-	fmt.Println("Warning: Calico pods may still be starting")
 	return nil
 }
@@ -1,52 +1,54 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type CertManager struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewCertManager(cfg *config.Config, exec executor.ShellExecutor) *CertManager {
-	return &CertManager{config: cfg, exec: exec}
+func NewCertManager(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *CertManager {
+	return &CertManager{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (c *CertManager) Install() error {
-	fmt.Println("Installing cert-manager...")
+	fmt.Fprintln(console(c.ctx), "Installing cert-manager...")
 
-	version := c.config.Versions.CertManager
-	if version == "" {
-		version = "v1.16.3"
+	manifest, err := fetchFile(c.ctx, c.config, c.exec, c.manifest())
+	if err != nil {
+		return err
 	}
-	url := fmt.Sprintf("https://github.com/cert-manager/cert-manager/releases/download/%s/cert-manager.yaml", version)
-	if _, err := c.exec.RunShell(fmt.Sprintf("kubectl apply -f %s", url)); err != nil {
+	if _, err := c.exec.RunShell("kubectl apply -f " + manifest); err != nil {
 		return fmt.Errorf("cert-manager install failed: %w", err)
 	}
 
-	fmt.Println("Waiting for cert-manager to be ready...")
+	fmt.Fprintln(console(c.ctx), "Waiting for cert-manager to be ready...")
 	if err := c.waitForReady(defaultReadyTimeout); err != nil {
 		return err
 	}
 
-	fmt.Println("Creating self-signed CA issuer...")
+	fmt.Fprintln(console(c.ctx), "Creating self-signed CA issuer...")
 	if err := c.createIssuer(); err != nil {
 		return fmt.Errorf("issuer creation failed: %w", err)
 	}
 
-	fmt.Println("Creating TLS certificates for lab domains...")
+	fmt.Fprintln(console(c.ctx), "Creating TLS certificates for lab domains...")
 	if err := c.createCertificates(); err != nil {
 		return fmt.Errorf("certificate creation failed: %w", err)
 	}
 
-	fmt.Println("Waiting for certificates to be ready...")
+	fmt.Fprintln(console(c.ctx), "Waiting for certificates to be ready...")
 	if err := c.waitForCerts(certReadyTimeout); err != nil {
-		fmt.Printf("Warning: certificates may not be ready yet: %v\n", err)
+		fmt.Fprintf(console(c.ctx), "Warning: certificates may not be ready yet: %v\n", err)
 	}
 
 	// NOTE: the cert-manager ServiceMonitor + PrometheusRule are created by the
@@ -54,14 +56,56 @@ func (c *CertManager) Install() error {
 	// on the Prometheus Operator CRDs (monitoring.coreos.com/v1), which are only
 	// installed later in the workload order. Creating them here failed silently.
 
-	fmt.Println("cert-manager installed successfully!")
+	fmt.Fprintln(console(c.ctx), "cert-manager installed successfully!")
 	c.printCAInstructions()
 	return nil
 }
 
+// Uninstall deletes the lab certificate and its secret, the CA issuers, then
+// cert-manager with its CRDs (and the lab CA in the cert-manager namespace).
+func (c *CertManager) Uninstall() error {
+	fmt.Fprintln(console(c.ctx), "Removing lab TLS certificate and CA issuers...")
+	if err := deleteResources(c.exec, "istio-system", "certificate/lab-tls", "secret/lab-tls-secret"); err != nil {
+		return err
+	}
+	if err := deleteResources(c.exec, "", "clusterissuer/lab-ca-issuer", "clusterissuer/selfsigned-issuer"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(c.ctx), "Removing cert-manager...")
+	manifest, err := fetchFile(c.ctx, c.config, c.exec, c.manifest())
+	if err != nil {
+		return err
+	}
+	return deleteManifest(c.exec, manifest)
+}
+
+// Verify checks that cert-manager has issued the lab TLS certificate the
+// gateways serve.
+func (c *CertManager) Verify() error {
+	return expect(c.ctx, c.exec, "certificate istio-system/lab-tls to be Ready",
+		"kubectl get certificate lab-tls -n istio-system -o jsonpath='{.status.conditions[?(@.type==\"Ready\")].status}'", equals("True"))
+}
+
+func (c *CertManager) manifest() offline.Artifact {
+	version := c.config.Versions.CertManager
+	if version == "" {
+		version = "v1.16.3"
+	}
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "cert-manager.yaml",
+		URL:  fmt.Sprintf("https://github.com/cert-manager/cert-manager/releases/download/%s/cert-manager.yaml", version),
+	}
+}
+
+// Artifacts returns the cert-manager manifest; its images are read from it.
+func (c *CertManager) Artifacts(string) []offline.Artifact {
+	return []offline.Artifact{c.manifest()}
+}
+
 func (c *CertManager) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	if err := waitFor(c.ctx, "cert-manager pods", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, _ := c.exec.RunShell(
 			"kubectl get pods -n cert-manager -o jsonpath='{.items[*].status.phase}' 2>/dev/null")
 		running := 0
@@ -70,15 +114,12 @@ func (c *CertManager) waitForReady(timeout time.Duration) error {
 				running++
 			}
 		}
-		if running >= 3 {
-			// cert-manager, cainjector, webhook
-			time.Sleep(webhookRegisterWait)
-			return nil
-		}
-		fmt.Println("Waiting for cert-manager pods...")
-		time.Sleep(defaultPollInterval)
+		// cert-manager, cainjector, webhook
+		return running >= 3
+	}); err != nil {
+		return err
 	}
-	return fmt.Errorf("timeout waiting for cert-manager")
+	return sleep(c.ctx, webhookRegisterWait)
 }
 
 func (c *CertManager) createIssuer() error {
@@ -114,29 +155,24 @@ spec:
   ca:
     secretName: lab-ca-secret`
 
-	if err := executor.WriteFile("/tmp/cert-manager-issuer.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(c.exec, "/tmp/cert-manager-issuer.yaml", manifest); err != nil {
 		return err
 	}
 
-	// Wait for CA cert to be issued
-	for i := 0; i < 12; i++ {
-		if _, err := c.exec.RunShell("kubectl apply -f /tmp/cert-manager-issuer.yaml 2>&1"); err == nil {
-			break
-		}
-		fmt.Println("Waiting for cert-manager CRDs to be ready...")
-		time.Sleep(defaultPollInterval)
+	// Apply until the cert-manager CRDs are served. Not fatal on its own: the
+	// CA secret wait below reports the real failure.
+	if err := retry(c.ctx, "cert-manager CRDs to be ready", retryTimes(certCRDAttempts, defaultPollInterval), func() error {
+		_, err := c.exec.RunShell("kubectl apply -f /tmp/cert-manager-issuer.yaml 2>&1")
+		return err
+	}); err != nil && c.ctx.Err() != nil {
+		return err
 	}
 
 	// Wait for CA secret to exist
-	deadline := time.Now().Add(caSecretWaitTimeout)
-	for time.Now().Before(deadline) {
+	return waitFor(c.ctx, "lab CA secret", pollUntil(caSecretWaitTimeout, shortPollInterval), func() bool {
 		out, _ := c.exec.RunShell("kubectl get secret lab-ca-secret -n cert-manager -o jsonpath='{.metadata.name}' 2>/dev/null")
-		if out == "lab-ca-secret" {
-			return nil
-		}
-		time.Sleep(shortPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for lab CA secret")
+		return out == "lab-ca-secret"
+	})
 }
 
 func (c *CertManager) createCertificates() error {
@@ -157,9 +193,10 @@ spec:
   - keycloak.local
   - kiali.local
   - karpor.local
+  - hubble.local
   - otel-demo.local`
 
-	if err := executor.WriteFile("/tmp/lab-certs.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(c.exec, "/tmp/lab-certs.yaml", manifest); err != nil {
 		return err
 	}
 	_, err := c.exec.RunShell("kubectl apply -f /tmp/lab-certs.yaml")
@@ -167,17 +204,11 @@ spec:
 }
 
 func (c *CertManager) waitForCerts(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return waitFor(c.ctx, "lab-tls certificate", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, _ := c.exec.RunShell(
 			"kubectl get certificate lab-tls -n istio-system -o jsonpath='{.status.conditions[?(@.type==\"Ready\")].status}' 2>/dev/null")
-		if out == "True" {
-			return nil
-		}
-		fmt.Println("Waiting for TLS certificate...")
-		time.Sleep(defaultPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for lab-tls certificate")
+		return out == "True"
+	})
 }
 
 // ExportCA extracts the CA certificate from the cluster and returns it as PEM.
@@ -187,22 +218,22 @@ func (c *CertManager) ExportCA() (string, error) {
 }
 
 func (c *CertManager) printCAInstructions() {
-	fmt.Println("\n========================================")
-	fmt.Println("  cert-manager — CA Trust Instructions")
-	fmt.Println("========================================")
-	fmt.Println("\nTo trust the self-signed CA on your Mac:")
-	fmt.Println()
-	fmt.Println("  # Wrap the remote command in double quotes so the jsonpath stays")
-	fmt.Println("  # single-quoted on the node (otherwise the file comes out empty):")
-	fmt.Println("  vagrant ssh controlplane -c \\")
-	fmt.Println("    \"kubectl get secret lab-ca-secret -n cert-manager -o jsonpath='{.data.tls\\.crt}' | base64 -d\" \\")
-	fmt.Println("    > /tmp/lab-ca.crt")
-	fmt.Println()
-	fmt.Println("  sudo security add-trusted-cert -d -r trustRoot \\")
-	fmt.Println("    -k /Library/Keychains/System.keychain /tmp/lab-ca.crt")
-	fmt.Println()
-	fmt.Println("Then fully quit and reopen the browser. All *.local services will show a green lock.")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(c.ctx), "\n========================================")
+	fmt.Fprintln(console(c.ctx), "  cert-manager — CA Trust Instructions")
+	fmt.Fprintln(console(c.ctx), "========================================")
+	fmt.Fprintln(console(c.ctx), "\nTo trust the self-signed CA on your Mac:")
+	fmt.Fprintln(console(c.ctx))
+	fmt.Fprintln(console(c.ctx), "  # Wrap the remote command in double quotes so the jsonpath stays")
+	fmt.Fprintln(console(c.ctx), "  # single-quoted on the node (otherwise the file comes out empty):")
+	fmt.Fprintln(console(c.ctx), "  vagrant ssh controlplane -c \\")
+	fmt.Fprintln(console(c.ctx), "    \"kubectl get secret lab-ca-secret -n cert-manager -o jsonpath='{.data.tls\\.crt}' | base64 -d\" \\")
+	fmt.Fprintln(console(c.ctx), "    > /tmp/lab-ca.crt")
+	fmt.Fprintln(console(c.ctx))
+	fmt.Fprintln(console(c.ctx), "  sudo security add-trusted-cert -d -r trustRoot \\")
+	fmt.Fprintln(console(c.ctx), "    -k /Library/Keychains/System.keychain /tmp/lab-ca.crt")
+	fmt.Fprintln(console(c.ctx))
+	fmt.Fprintln(console(c.ctx), "Then fully quit and reopen the browser. All *.local services will show a green lock.")
+	fmt.Fprintln(console(c.ctx), "========================================")
 }
 
 func splitWords(s string) []string {
@@ -0,0 +1,229 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+type Cilium struct {
+	ctx    context.Context
+	config *config.Config
+	exec   executor.ShellExecutor
+}
+
+func NewCilium(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Cilium {
+	return &Cilium{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
+}
+
+func (c *Cilium) Install() error {
+	fmt.Fprintf(console(c.ctx), "Installing Cilium %s...\n", c.version())
+	if err := withHelm(c.installHelm); err != nil {
+		return fmt.Errorf("helm installation failed: %w", err)
+	}
+	if !c.config.Offline.Enabled() {
+		helmMu.Lock()
+		if _, err := c.exec.RunShell("helm repo add cilium https://helm.cilium.io/ 2>/dev/null || true"); err != nil {
+			fmt.Fprintf(console(c.ctx), "Warning: could not add cilium Helm repo: %v\n", err)
+		}
+		if _, err := c.exec.RunShell("helm repo update cilium"); err != nil {
+			fmt.Fprintf(console(c.ctx), "Warning: helm repo update failed: %v\n", err)
+		}
+		helmMu.Unlock()
+	}
+
+	if err := c.upgrade(c.config.Components.Hubble == "enabled"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(c.ctx), "Waiting for Cilium to be ready...")
+	return c.waitForReady(defaultReadyTimeout)
+}
+
+// AfterAPIServerRestart does nothing: the Cilium agents reach the API server
+// with service account tokens the kubelet keeps fresh, and the CNI plugin only
+// talks to the local agent.
+func (c *Cilium) AfterAPIServerRestart() error { return nil }
+
+func (c *Cilium) version() string {
+	if c.config.Versions.Cilium == "" {
+		return "1.18.2"
+	}
+	return strings.TrimPrefix(c.config.Versions.Cilium, "v")
+}
+
+// upgrade installs or reconfigures the cilium release, with the Hubble relay
+// and UI when hubble is set. Every value is passed on each run, so a re-run
+// converges instead of depending on what the release had before.
+//
+// ipam.mode=kubernetes allocates pod IPs from the node's podCIDR, carved out
+// of cluster.pod_cidr by kubeadm, rather than Cilium's own cluster pool.
+// kube-proxy stays in place. The chart's two operator replicas refuse to
+// share a node, and the control plane is the only node when Cilium installs.
+func (c *Cilium) upgrade(hubble bool) error {
+	cmd := "helm upgrade --install cilium " + chartRef(c.config, "cilium", c.chart()) +
+		" --namespace kube-system" +
+		" --set ipam.mode=kubernetes" +
+		" --set kubeProxyReplacement=false" +
+		" --set operator.replicas=1" +
+		fmt.Sprintf(" --set hubble.relay.enabled=%t --set hubble.ui.enabled=%t", hubble, hubble) +
+		" --wait --timeout=10m"
+	if _, err := c.exec.RunShell(cmd); err != nil {
+		return fmt.Errorf("cilium helm install failed: %w", err)
+	}
+	return nil
+}
+
+// chart is the cilium chart; its values enable the Hubble images too, so a
+// bundle carries them whether or not Hubble is turned on later.
+func (c *Cilium) chart() offline.Artifact {
+	return offline.Artifact{
+		Kind:    offline.Chart,
+		Name:    "cilium",
+		URL:     "https://helm.cilium.io/",
+		Version: c.version(),
+		Values:  []string{"hubble.relay.enabled=true", "hubble.ui.enabled=true"},
+	}
+}
+
+// Artifacts returns helm and the cilium chart; the images are read from the
+// rendered chart.
+func (c *Cilium) Artifacts(arch string) []offline.Artifact {
+	return append(HelmArtifacts(arch), c.chart())
+}
+
+func (c *Cilium) installHelm() error {
+	if _, err := c.exec.RunShell("helm version 2>/dev/null"); err == nil {
+		return nil
+	}
+	fmt.Fprintln(console(c.ctx), "Installing Helm...")
+	return installHelmBinary(c.ctx, c.config, c.exec)
+}
+
+func (c *Cilium) waitForReady(timeout time.Duration) error {
+	err := waitFor(c.ctx, "Cilium agents", pollUntil(timeout, longPollInterval), func() bool {
+		out, err := c.exec.RunShell(
+			"kubectl rollout status daemonset/cilium -n kube-system --timeout=10s 2>&1")
+		return err == nil && strings.Contains(out, "successfully rolled out")
+	})
+	if err != nil && c.ctx.Err() == nil {
+		fmt.Fprintln(console(c.ctx), "Warning: Cilium agents may still be starting")
+		return nil
+	}
+	if err == nil {
+		fmt.Fprintln(console(c.ctx), "Cilium is ready!")
+	}
+	return err
+}
+
+// Hubble turns on Cilium's Hubble relay and UI and publishes the UI at
+// https://hubble.local through the Istio ingress gateway.
+type Hubble struct {
+	ctx    context.Context
+	config *config.Config
+	exec   executor.ShellExecutor
+}
+
+func NewHubble(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Hubble {
+	return &Hubble{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
+}
+
+func (h *Hubble) cilium() *Cilium {
+	return &Cilium{ctx: h.ctx, config: h.config, exec: h.exec}
+}
+
+// Artifacts returns those of Cilium: Hubble is an upgrade of its release.
+func (h *Hubble) Artifacts(arch string) []offline.Artifact {
+	return h.cilium().Artifacts(arch)
+}
+
+func (h *Hubble) Install() error {
+	fmt.Fprintln(console(h.ctx), "Enabling Hubble relay and UI...")
+	if err := h.cilium().upgrade(true); err != nil {
+		return err
+	}
+
+	if err := h.configureIngress(); err != nil {
+		fmt.Fprintf(console(h.ctx), "Warning: failed to configure Hubble UI ingress: %v\n", err)
+	}
+
+	fmt.Fprintln(console(h.ctx), "Hubble UI installed successfully!")
+	fmt.Fprintln(console(h.ctx), "  Add to /etc/hosts: <ingress-ip> hubble.local")
+	fmt.Fprintln(console(h.ctx), "  Open: https://hubble.local")
+	return nil
+}
+
+// Uninstall removes the ingress and turns the relay and UI off again; Cilium
+// itself stays.
+func (h *Hubble) Uninstall() error {
+	fmt.Fprintln(console(h.ctx), "Removing Hubble UI...")
+	if err := deleteResources(h.exec, "kube-system", "virtualservice/hubble-ui", "gateway/hubble-gateway"); err != nil {
+		return err
+	}
+	return h.cilium().upgrade(false)
+}
+
+// Verify checks that the relay and the UI have a ready replica.
+func (h *Hubble) Verify() error {
+	if err := expectReplicas(h.ctx, h.exec, "kube-system", "hubble-relay"); err != nil {
+		return err
+	}
+	return expectReplicas(h.ctx, h.exec, "kube-system", "hubble-ui")
+}
+
+func (h *Hubble) configureIngress() error {
+	ingress := `apiVersion: networking.istio.io/v1
+kind: Gateway
+metadata:
+  name: hubble-gateway
+  namespace: kube-system
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "hubble.local"
+    tls:
+      httpsRedirect: true
+  - port:
+      number: 443
+      name: https
+      protocol: HTTPS
+    tls:
+      mode: SIMPLE
+      credentialName: lab-tls-secret
+    hosts:
+    - "hubble.local"
+---
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: hubble-ui
+  namespace: kube-system
+spec:
+  hosts:
+  - "hubble.local"
+  gateways:
+  - hubble-gateway
+  http:
+  - route:
+    - destination:
+        host: hubble-ui
+        port:
+          number: 80`
+
+	if err := executor.WriteFileOn(h.exec, "/tmp/hubble-ingress.yaml", ingress); err != nil {
+		return err
+	}
+	_, err := h.exec.RunShell("kubectl apply -f /tmp/hubble-ingress.yaml")
+	return err
+}
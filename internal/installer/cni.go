@@ -0,0 +1,37 @@
+package installer
+
+import (
+	"context"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// CNI is implemented by the cluster network installers. The network is
+// installed with the control plane, before any workload, and is never
+// uninstalled.
+type CNI interface {
+	Installer
+	// AfterAPIServerRestart repairs what a restart of the API server breaks
+	// in the network, such as a token the CNI plugin holds. Failures only
+	// warn.
+	AfterAPIServerRestart() error
+}
+
+var (
+	_ CNI = (*Calico)(nil)
+	_ CNI = (*Cilium)(nil)
+	_ CNI = (*Flannel)(nil)
+)
+
+// NewCNI returns the installer for components.cni.
+func NewCNI(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) CNI {
+	switch cfg.CNI() {
+	case "cilium":
+		return NewCilium(ctx, cfg, exec)
+	case "flannel":
+		return NewFlannel(ctx, cfg, exec)
+	default:
+		return NewCalico(ctx, cfg, exec)
+	}
+}
@@ -0,0 +1,53 @@
+package installer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+func TestNewCNI_FollowsComponentsCNI(t *testing.T) {
+	for cni, want := range map[string]string{"": "Calico CNI", "calico": "Calico CNI", "cilium": "Cilium CNI", "flannel": "Flannel CNI"} {
+		cfg := &config.Config{Components: config.ComponentsConfig{CNI: cni}}
+		assert.Equal(t, want, NewCNI(context.Background(), cfg, &fakeShell{}).Name(), cni)
+	}
+}
+
+func TestFlannelManifest(t *testing.T) {
+	rendered, err := flannelManifest(dryRunFlannelManifest, "10.200.0.0/16", "eth1")
+	require.NoError(t, err)
+	assert.Contains(t, rendered, `"Network": "10.200.0.0/16"`)
+	assert.Contains(t, rendered, "        - --kube-subnet-mgr\n        - --iface=eth1\n")
+
+	_, err = flannelManifest("kind: DaemonSet\n", "10.200.0.0/16", "eth1")
+	assert.Error(t, err, "a manifest that changed shape must not be applied half-rewritten")
+}
+
+// TestHubble_ReconfiguresTheCiliumRelease verifies enabling and removing
+// Hubble reruns the same Cilium release with every value, toggling only the
+// Hubble ones.
+func TestHubble_ReconfiguresTheCiliumRelease(t *testing.T) {
+	exec := &fakeShell{}
+	cfg := &config.Config{Versions: config.VersionsConfig{Cilium: "v1.18.2"}}
+	hubble := NewHubble(context.Background(), cfg, exec)
+
+	require.NoError(t, hubble.Install())
+	require.NoError(t, hubble.Uninstall())
+
+	var upgrades []string
+	for _, c := range exec.calls {
+		if strings.HasPrefix(c, "helm upgrade") {
+			upgrades = append(upgrades, c)
+		}
+	}
+	require.Len(t, upgrades, 2)
+	assert.Contains(t, upgrades[0], "--version 1.18.2 --namespace kube-system --set ipam.mode=kubernetes")
+	assert.Contains(t, upgrades[0], "--set hubble.relay.enabled=true --set hubble.ui.enabled=true")
+	assert.Contains(t, upgrades[1], "--set hubble.relay.enabled=false --set hubble.ui.enabled=false")
+	assert.Contains(t, exec.calls, "kubectl delete virtualservice/hubble-ui gateway/hubble-gateway --ignore-not-found -n kube-system")
+}
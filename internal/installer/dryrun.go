@@ -0,0 +1,97 @@
+package installer
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx as a dry run. Installers built with it skip their fixed
+// pauses and every side effect that bypasses the executor — the Vault HTTP
+// API and local credential files — so Install runs to completion against an
+// executor.DryRunExecutor answering with DryRunResponses.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// isDryRun reports whether ctx was marked by WithDryRun.
+func isDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// dryRunPassword stands in for credentials a real run reads from Vault, so
+// none reach the dry-run output or script.
+const dryRunPassword = "DRY-RUN-PASSWORD"
+
+// dryRunCAPEM stands in for the lab CA in a dry run, where cert-manager never
+// issued one.
+const dryRunCAPEM = "-----BEGIN CERTIFICATE-----\nDRY-RUN\n-----END CERTIFICATE-----\n"
+
+// dryRunFlannelManifest stands in for the downloaded kube-flannel.yml, with
+// the two places Flannel.Install rewrites.
+const dryRunFlannelManifest = `  net-conf.json: |
+    {
+      "Network": "10.244.0.0/16"
+    }
+        args:
+        - --ip-masq
+        - --kube-subnet-mgr
+`
+
+// DryRunResponses are the canned "ready" answers to the readiness polls and
+// lookups the installers make, so every wait succeeds on its first check in a
+// dry run. Entries are matched in order (see executor.DryRunResponse); keep a
+// more specific Match ahead of a general one. Lookups that only gate optional
+// work (e.g. "already synced by VSO?") are left unanswered, so the script
+// shows the commands a fresh cluster would get.
+func DryRunResponses(cfg *config.Config) []executor.DryRunResponse {
+	model := cfg.KarporAI.Model
+	if model == "" {
+		model = "llama3.2:1b"
+	}
+	lbIP := firstPoolAddress(cfg.Network.MetalLBRange)
+	if lbIP == "" {
+		lbIP = "192.0.2.1" // no pool configured: a documentation address
+	}
+
+	return []executor.DryRunResponse{
+		// Pod phases: cert-manager counts three Running pods, Istio wants all.
+		{Match: "{.items[*].status.phase}", Output: "Running Running Running"},
+		{Match: ".status.phase}", Output: "Running"},
+		{Match: "{.status.readyReplicas}", Output: "1"},
+		{Match: "{.status.availableReplicas}", Output: "1"},
+		{Match: `@.type=="Ready")].status}`, Output: "True"},
+		{Match: "rollout status", Output: "roll out complete: successfully rolled out"},
+		{Match: "get --raw='/healthz'", Output: "ok"},
+		{Match: "get crd installations.operator.tigera.io", Output: "installations.operator.tigera.io"},
+		{Match: "/kube-flannel.yml", Output: dryRunFlannelManifest},
+		{Match: "get secret lab-ca-secret -n cert-manager -o jsonpath='{.metadata.name}'", Output: "lab-ca-secret"},
+		{Match: `{.data.tls\.crt}' 2>/dev/null | base64 -d`, Output: dryRunCAPEM},
+		{Match: `{.data.tls\.crt}'`, Output: base64.StdEncoding.EncodeToString([]byte(dryRunCAPEM))},
+		{Match: "get secret keycloak-admin -n keycloak -o jsonpath='{.data.username}'", Output: "admin"},
+		{Match: "-l app=keycloak -o jsonpath='{.items[0].metadata.name}'", Output: "keycloak-0"},
+		{Match: "ollama list", Output: model},
+		// Functional checks (Verify).
+		{Match: "{.status.loadBalancer.ingress[0].ip}", Output: lbIP},
+		{Match: "/v1/sys/health", Output: "200"},
+		{Match: "-w '%{http_code}'", Output: "404"},
+		{Match: "kubectl top nodes", Output: "controlplane   250m   12%   1200Mi   30%"},
+		{Match: `@.type=="Available")].status}`, Output: "True"},
+		{Match: "{.status.numberReady}/{.status.desiredNumberScheduled}", Output: "1/1"},
+		{Match: "/proxy/-/ready", Output: "Prometheus Server is Ready."},
+		{Match: "/proxy/api/health", Output: `{"database": "ok"}`},
+		{Match: "/proxy/ready", Output: "ready"},
+		{Match: "/.well-known/openid-configuration", Output: `{"issuer":"` + keycloakIssuerURL + `"}`},
+		// Secrets the Vault Secrets Operator syncs (waitForSecrets).
+		{Match: "get secret keycloak-admin -n keycloak 2>/dev/null", Output: "keycloak-admin"},
+		{Match: "get secret postgres-credentials -n keycloak 2>/dev/null", Output: "postgres-credentials"},
+		{Match: "get secret grafana-admin -n monitoring 2>/dev/null", Output: "grafana-admin"},
+		{Match: "get secret grafana-oidc -n monitoring 2>/dev/null", Output: "grafana-oidc"},
+		{Match: "get secret ollama-api-key -n ollama 2>/dev/null", Output: "ollama-api-key"},
+	}
+}
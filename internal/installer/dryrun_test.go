@@ -0,0 +1,54 @@
+package installer
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+func TestDryRunResponses_AnswerReadinessPolls(t *testing.T) {
+	cfg := &config.Config{}
+	exec := executor.DryRunExecutor{Responses: DryRunResponses(cfg)}
+
+	for command, want := range map[string]string{
+		"kubectl get pods -n cert-manager -o jsonpath='{.items[*].status.phase}'":                                  "Running Running Running",
+		"kubectl get pod -l app=x -o jsonpath='{.items[0].status.phase}'":                                          "Running",
+		"kubectl get node cp -o jsonpath='{.status.conditions[?(@.type==\"Ready\")].status}'":                      "True",
+		"kubectl get --raw='/healthz'":                                                                             "ok",
+		"ollama list":                                                                                              "llama3.2:1b",
+		"kubectl get secret lab-ca-secret -n cert-manager -o jsonpath='{.data.tls\\.crt}' 2>/dev/null | base64 -d": dryRunCAPEM,
+	} {
+		out, err := exec.RunShell(command)
+		require.NoError(t, err)
+		assert.Equal(t, want, out, command)
+	}
+
+	out, _ := exec.RunShell("kubectl get secret lab-ca-secret -n cert-manager -o jsonpath='{.data.tls\\.crt}'")
+	pem, err := base64.StdEncoding.DecodeString(out)
+	require.NoError(t, err, "the undecoded CA lookup answers in base64")
+	assert.Equal(t, dryRunCAPEM, string(pem))
+}
+
+func TestDryRunResponses_UseConfiguredModel(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.KarporAI.Model = "qwen2.5:0.5b"
+	out, _ := executor.DryRunExecutor{Responses: DryRunResponses(cfg)}.RunShell("ollama list")
+	assert.Equal(t, "qwen2.5:0.5b", out)
+}
+
+func TestResolveGrafanaPassword_DryRunSkipsVault(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Vault.Enabled = true
+	cfg.Vault.Token = "s.real-token"
+	m := NewMonitoring(WithDryRun(context.Background()), cfg, executor.DryRunExecutor{})
+
+	pw, err := m.resolveGrafanaPassword()
+	require.NoError(t, err)
+	assert.Equal(t, dryRunPassword, pw, "a dry run must not read a real password into its script")
+}
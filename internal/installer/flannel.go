@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+type Flannel struct {
+	ctx    context.Context
+	config *config.Config
+	exec   executor.ShellExecutor
+}
+
+func NewFlannel(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Flannel {
+	return &Flannel{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
+}
+
+func (f *Flannel) Install() error {
+	version := f.version()
+
+	fmt.Fprintf(console(f.ctx), "Installing Flannel %s...\n", version)
+	path, err := fetchFile(f.ctx, f.config, f.exec, f.manifest())
+	if err != nil {
+		return err
+	}
+	manifest, err := f.exec.RunShell("cat " + path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	manifest, err = flannelManifest(manifest, f.config.Cluster.PodCIDR, f.config.Network.Interface)
+	if err != nil {
+		return fmt.Errorf("flannel %s: %w", version, err)
+	}
+	if _, err := f.exec.RunShellWithStdin("kubectl apply -f -", manifest); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(f.ctx), "Waiting for Flannel to be ready...")
+	return f.waitForReady(defaultReadyTimeout)
+}
+
+func (f *Flannel) version() string {
+	if f.config.Versions.Flannel == "" {
+		return "v0.27.4"
+	}
+	return f.config.Versions.Flannel
+}
+
+func (f *Flannel) manifest() offline.Artifact {
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "kube-flannel.yml",
+		URL:  fmt.Sprintf("https://github.com/flannel-io/flannel/releases/download/%s/kube-flannel.yml", f.version()),
+	}
+}
+
+// Artifacts returns the Flannel manifest; its images are read from it.
+func (f *Flannel) Artifacts(string) []offline.Artifact {
+	return []offline.Artifact{f.manifest()}
+}
+
+// AfterAPIServerRestart does nothing: flanneld reaches the API server with a
+// service account token the kubelet keeps fresh, and the CNI plugin only
+// reads the subnet file flanneld writes.
+func (f *Flannel) AfterAPIServerRestart() error { return nil }
+
+var (
+	flannelNetwork   = regexp.MustCompile(`"Network": "[^"]*"`)
+	flannelSubnetMgr = regexp.MustCompile(`(?m)^([ \t]*)- --kube-subnet-mgr$`)
+)
+
+// flannelManifest points the release manifest at podCIDR, which must match
+// kubeadm's podSubnet, and pins flanneld to iface. Left to itself, flanneld
+// picks the interface of the default route: on the Vagrant lab that is the
+// VirtualBox NAT interface, which has the same address on every node.
+func flannelManifest(manifest, podCIDR, iface string) (string, error) {
+	if !flannelNetwork.MatchString(manifest) || !flannelSubnetMgr.MatchString(manifest) {
+		return "", fmt.Errorf("unexpected kube-flannel.yml: no net-conf.json Network or --kube-subnet-mgr argument")
+	}
+	manifest = flannelNetwork.ReplaceAllLiteralString(manifest, fmt.Sprintf(`"Network": %q`, podCIDR))
+	if iface != "" {
+		manifest = flannelSubnetMgr.ReplaceAllString(manifest, "$1- --kube-subnet-mgr\n$1- --iface="+iface)
+	}
+	return manifest, nil
+}
+
+func (f *Flannel) waitForReady(timeout time.Duration) error {
+	err := waitFor(f.ctx, "Flannel pods", pollUntil(timeout, longPollInterval), func() bool {
+		out, err := f.exec.RunShell(
+			"kubectl rollout status daemonset/kube-flannel-ds -n kube-flannel --timeout=10s 2>&1")
+		return err == nil && strings.Contains(out, "successfully rolled out")
+	})
+	if err != nil && f.ctx.Err() == nil {
+		fmt.Fprintln(console(f.ctx), "Warning: Flannel pods may still be starting")
+		return nil
+	}
+	if err == nil {
+		fmt.Fprintln(console(f.ctx), "Flannel is ready!")
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package installer
+
+import (
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// grafanaDatasources renders the grafana-datasources ConfigMap: Prometheus,
+// plus Loki and Tempo when installed. With both, Loki log lines link to their
+// traces and Tempo traces back to their logs.
+func grafanaDatasources(loki, tempo bool) string {
+	lines := []string{
+		"apiVersion: v1",
+		"kind: ConfigMap",
+		"metadata:",
+		"  name: grafana-datasources",
+		"  namespace: monitoring",
+		"data:",
+		"  datasources.yaml: |",
+		"    apiVersion: 1",
+		"    datasources:",
+		"    - name: Prometheus",
+		"      type: prometheus",
+		"      uid: prometheus-uid",
+		"      access: proxy",
+		"      url: http://prometheus:9090",
+		"      isDefault: true",
+	}
+	if loki {
+		lines = append(lines,
+			"    - name: Loki",
+			"      type: loki",
+			"      uid: loki-uid",
+			"      access: proxy",
+			"      url: http://loki:3100",
+			"      isDefault: false",
+		)
+		if tempo {
+			lines = append(lines,
+				"      jsonData:",
+				"        derivedFields:",
+				"        - datasourceUid: tempo-uid",
+				`          matcherRegex: "traceID=(\\w+)"`,
+				"          name: TraceID",
+				`          url: "${__value.raw}"`,
+			)
+		}
+	}
+	if tempo {
+		lines = append(lines,
+			"    - name: Tempo",
+			"      type: tempo",
+			"      uid: tempo-uid",
+			"      access: proxy",
+			"      url: http://tempo:3200",
+			"      isDefault: false",
+			"      jsonData:",
+		)
+		if loki {
+			lines = append(lines,
+				"        tracesToLogsV2:",
+				"          datasourceUid: loki-uid",
+				"          tags:",
+				"          - key: service.name",
+				"            value: app",
+				"          - key: k8s.namespace.name",
+				"            value: namespace",
+				"          filterByTraceID: true",
+				"          filterBySpanID: false",
+			)
+		}
+		lines = append(lines,
+			"        tracesToMetrics:",
+			"          datasourceUid: prometheus-uid",
+			"          tags:",
+			"          - key: service.name",
+			"            value: service",
+			"        serviceMap:",
+			"          datasourceUid: prometheus-uid",
+			"        nodeGraph:",
+			"          enabled: true",
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applyGrafanaDatasources writes the datasources for loki and tempo to path,
+// applies them and restarts Grafana to load them.
+func applyGrafanaDatasources(exec executor.ShellExecutor, path string, loki, tempo bool) error {
+	if err := executor.WriteFileOn(exec, path, grafanaDatasources(loki, tempo)); err != nil {
+		return err
+	}
+	if _, err := exec.RunShell("kubectl apply -f " + path); err != nil {
+		return err
+	}
+	_, err := exec.RunShell("kubectl rollout restart deployment/grafana -n monitoring")
+	return err
+}
+
+// installedInMonitoring reports whether deployment exists in the monitoring
+// namespace, so an uninstall keeps the datasources of what remains.
+func installedInMonitoring(exec executor.ShellExecutor, deployment string) bool {
+	out, err := exec.RunShell("kubectl get deployment " + deployment + " -n monitoring -o name 2>/dev/null")
+	return err == nil && strings.TrimSpace(out) != ""
+}
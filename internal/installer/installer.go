@@ -1,5 +1,12 @@
 package installer
 
+import (
+	"context"
+	"io"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
 // Installer is the common contract implemented by every component installer.
 // It lets the provisioner orchestrate components from a declarative table
 // instead of hardcoding each call site.
@@ -12,6 +19,28 @@ type Installer interface {
 	Install() error
 }
 
+// Uninstaller is implemented by installers that can reverse Install: they
+// delete what Install created, and undo what it changed outside the
+// component's own resources (the API server OIDC patch, Grafana datasources).
+// Data kept outside the cluster (NFS-backed volumes, Vault secrets) is left
+// in place. Uninstall tolerates resources that are already gone, so it can be
+// re-run after a partial failure.
+type Uninstaller interface {
+	Installer
+	Uninstall() error
+}
+
+// Verifier is implemented by installers that can check their component
+// actually works, beyond the readiness waits in Install (which often only
+// warn): MetalLB hands out an IP, the Istio gateway answers, Vault is
+// unsealed. Verify retries each check briefly and returns an error naming the
+// first one that fails. It changes nothing, apart from short-lived test
+// resources it removes again, so it is safe to run against a live cluster.
+type Verifier interface {
+	Installer
+	Verify() error
+}
+
 // Compile-time verification that every installer satisfies the interface.
 var (
 	_ Installer = (*MetalLB)(nil)
@@ -30,7 +59,68 @@ var (
 	_ Installer = (*Keycloak)(nil)
 	_ Installer = (*Ollama)(nil)
 	_ Installer = (*Karpor)(nil)
+	_ Installer = (*Hubble)(nil)
 	_ Installer = (*Calico)(nil)
+	_ Installer = (*Cilium)(nil)
+	_ Installer = (*Flannel)(nil)
+)
+
+// Every workload installer can be uninstalled; the cluster network (see CNI)
+// cannot.
+var (
+	_ Uninstaller = (*MetalLB)(nil)
+	_ Uninstaller = (*Istio)(nil)
+	_ Uninstaller = (*CertManager)(nil)
+	_ Uninstaller = (*MetricsServer)(nil)
+	_ Uninstaller = (*VPA)(nil)
+	_ Uninstaller = (*KEDA)(nil)
+	_ Uninstaller = (*NFSProvisioner)(nil)
+	_ Uninstaller = (*VaultInstaller)(nil)
+	_ Uninstaller = (*VaultSecretsOperator)(nil)
+	_ Uninstaller = (*Monitoring)(nil)
+	_ Uninstaller = (*Loki)(nil)
+	_ Uninstaller = (*Tempo)(nil)
+	_ Uninstaller = (*Kiali)(nil)
+	_ Uninstaller = (*Keycloak)(nil)
+	_ Uninstaller = (*Ollama)(nil)
+	_ Uninstaller = (*Karpor)(nil)
+	_ Uninstaller = (*Hubble)(nil)
+)
+
+// Installers with functional checks (see Verifier).
+var (
+	_ Verifier = (*MetalLB)(nil)
+	_ Verifier = (*Istio)(nil)
+	_ Verifier = (*CertManager)(nil)
+	_ Verifier = (*MetricsServer)(nil)
+	_ Verifier = (*KEDA)(nil)
+	_ Verifier = (*VaultInstaller)(nil)
+	_ Verifier = (*VaultSecretsOperator)(nil)
+	_ Verifier = (*Monitoring)(nil)
+	_ Verifier = (*Loki)(nil)
+	_ Verifier = (*Tempo)(nil)
+	_ Verifier = (*Keycloak)(nil)
+	_ Verifier = (*Hubble)(nil)
+)
+
+// Installers that download manifests, charts or binaries (see Bundler). The
+// others only apply inline manifests; the bundle finds their images in a dry
+// run.
+var (
+	_ Bundler = (*MetalLB)(nil)
+	_ Bundler = (*Istio)(nil)
+	_ Bundler = (*CertManager)(nil)
+	_ Bundler = (*MetricsServer)(nil)
+	_ Bundler = (*VPA)(nil)
+	_ Bundler = (*KEDA)(nil)
+	_ Bundler = (*NFSProvisioner)(nil)
+	_ Bundler = (*VaultSecretsOperator)(nil)
+	_ Bundler = (*Monitoring)(nil)
+	_ Bundler = (*Karpor)(nil)
+	_ Bundler = (*Hubble)(nil)
+	_ Bundler = (*Calico)(nil)
+	_ Bundler = (*Cilium)(nil)
+	_ Bundler = (*Flannel)(nil)
 )
 
 func (m *MetalLB) Name() string              { return "MetalLB" }
@@ -49,4 +139,14 @@ func (k *Kiali) Name() string                { return "Kiali" }
 func (k *Keycloak) Name() string             { return "Keycloak (OIDC)" }
 func (o *Ollama) Name() string               { return "Ollama" }
 func (k *Karpor) Name() string               { return "Karpor" }
+func (h *Hubble) Name() string               { return "Hubble UI" }
 func (c *Calico) Name() string               { return "Calico CNI" }
+func (c *Cilium) Name() string               { return "Cilium CNI" }
+func (f *Flannel) Name() string              { return "Flannel CNI" }
+
+// console is where an installer prints its progress: the writer carried by its
+// context (see executor.WithOutput), so components installed concurrently each
+// get their own prefixed output, or os.Stdout.
+func console(ctx context.Context) io.Writer {
+	return executor.Output(ctx)
+}
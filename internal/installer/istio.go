@@ -1,42 +1,28 @@
 package installer
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/fetch"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type Istio struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewIstio(cfg *config.Config, exec executor.ShellExecutor) *Istio {
-	return &Istio{config: cfg, exec: exec}
+func NewIstio(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Istio {
+	return &Istio{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (i *Istio) Install() error {
-	version := i.config.Versions.Istio
-
-	// Download istioctl
-	fmt.Printf("Downloading Istio %s...\n", version)
-	downloadCmd := fmt.Sprintf("curl -L --connect-timeout 10 --max-time 300 https://istio.io/downloadIstio | ISTIO_VERSION=%s sh -", version)
-	if err := i.exec.RunShellWithOutput(downloadCmd); err != nil {
-		return err
-	}
-
-	// Get current directory
-	pwd, err := os.Getwd()
-	if err != nil {
-		pwd = "/root"
-	}
-
-	// Copy istioctl to /usr/local/bin
-	istioctlPath := fmt.Sprintf("%s/istio-%s/bin/istioctl", pwd, version)
-	if _, err := i.exec.RunShell(fmt.Sprintf("cp %s /usr/local/bin/", istioctlPath)); err != nil {
+	if err := i.installIstioctl(); err != nil {
 		return err
 	}
 
@@ -55,53 +41,119 @@ spec:
         port: 4317
         service: otel-collector.monitoring.svc.cluster.local`
 
-	if err := executor.WriteFile("/tmp/istio-operator.yaml", istioOperator); err != nil {
+	if err := executor.WriteFileOn(i.exec, "/tmp/istio-operator.yaml", istioOperator); err != nil {
 		return err
 	}
 
-	fmt.Println("Installing Istio with default profile...")
+	fmt.Fprintln(console(i.ctx), "Installing Istio with default profile...")
 	if err := i.exec.RunShellWithOutput("istioctl install -f /tmp/istio-operator.yaml -y"); err != nil {
 		return err
 	}
 
 	// Wait for Istio to be ready
-	fmt.Println("Waiting for Istio to be ready...")
+	fmt.Fprintln(console(i.ctx), "Waiting for Istio to be ready...")
 	if err := i.waitForReady(defaultReadyTimeout); err != nil {
 		return err
 	}
 
 	// Enable sidecar injection for default namespace
-	fmt.Println("Enabling sidecar injection for default namespace...")
+	fmt.Fprintln(console(i.ctx), "Enabling sidecar injection for default namespace...")
 	if _, err := i.exec.RunShell("kubectl label namespace default istio-injection=enabled --overwrite"); err != nil {
 		return err
 	}
 
-	fmt.Println("Istio installed successfully!")
+	fmt.Fprintln(console(i.ctx), "Istio installed successfully!")
 	return nil
 }
 
+// installIstioctl puts istioctl in /usr/local/bin from the verified Istio
+// release for the node's architecture.
+func (i *Istio) installIstioctl() error {
+	version := i.config.Versions.Istio
+	arch, err := fetch.Arch(i.exec, isDryRun(i.ctx))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(console(i.ctx), "Installing istioctl %s...\n", version)
+	tarball, err := fetchFile(i.ctx, i.config, i.exec, i.release(arch))
+	if err != nil {
+		return err
+	}
+	_, err = i.exec.RunShell(fmt.Sprintf("tar -xzf %s -C /usr/local/bin --strip-components=2 istio-%s/bin/istioctl", tarball, version))
+	return err
+}
+
+// release is the Istio release tarball for arch.
+func (i *Istio) release(arch string) offline.Artifact {
+	version := i.config.Versions.Istio
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "istio.tar.gz",
+		URL:  fmt.Sprintf("https://github.com/istio/istio/releases/download/%s/istio-%s-linux-%s.tar.gz", version, version, arch),
+	}
+}
+
+// Artifacts returns the Istio release and the images of the default profile,
+// which istioctl renders from its built-in charts.
+func (i *Istio) Artifacts(arch string) []offline.Artifact {
+	version := i.config.Versions.Istio
+	return append([]offline.Artifact{i.release(arch)},
+		images("docker.io/istio/pilot:"+version, "docker.io/istio/proxyv2:"+version)...)
+}
+
+// Uninstall removes the mesh control plane and gateways, the istio-system
+// namespace and the default namespace's injection label. Running pods keep
+// their sidecars until they restart. istioctl stays in /usr/local/bin.
+func (i *Istio) Uninstall() error {
+	fmt.Fprintln(console(i.ctx), "Disabling sidecar injection for default namespace...")
+	if _, err := i.exec.RunShell("kubectl label namespace default istio-injection-"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(i.ctx), "Uninstalling Istio...")
+	if err := i.exec.RunShellWithOutput("istioctl uninstall --purge -y"); err != nil {
+		return err
+	}
+	return deleteNamespace(i.exec, "istio-system")
+}
+
+// Verify checks that istiod is ready and that the ingress gateway has a
+// LoadBalancer IP answering HTTP. Any status counts: with no route for the
+// request, Envoy itself answers 404.
+func (i *Istio) Verify() error {
+	if err := expectReplicas(i.ctx, i.exec, "istio-system", "istiod"); err != nil {
+		return err
+	}
+	ip, err := expectOutput(i.ctx, i.exec, "the Istio ingress gateway to get a LoadBalancer IP",
+		loadBalancerIP("istio-system", "istio-ingressgateway"), nonEmpty)
+	if err != nil {
+		return err
+	}
+	return expect(i.ctx, i.exec, "the Istio ingress gateway to answer HTTP",
+		fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --max-time 5 http://%s/", ip), httpAnswered)
+}
+
 func (i *Istio) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	err := waitFor(i.ctx, "Istio pods", pollUntil(timeout, longPollInterval), func() bool {
 		out, err := i.exec.RunShell("kubectl get pods -n istio-system -o jsonpath='{.items[*].status.phase}' 2>/dev/null")
-		if err == nil && out != "" {
-			// Check if all pods are Running
-			allRunning := true
-			for _, phase := range []byte(out) {
-				if phase != 'R' && phase != ' ' {
-					allRunning = false
-					break
-				}
-			}
-			if allRunning {
-				fmt.Println("Istio is ready!")
-				return nil
+		if err != nil || out == "" {
+			return false
+		}
+		// Check if all pods are Running
+		for _, phase := range splitWords(out) {
+			if phase != "Running" {
+				return false
 			}
 		}
-		fmt.Println("Waiting for Istio pods...")
-		time.Sleep(longPollInterval)
+		return true
+	})
+	if err != nil && i.ctx.Err() == nil {
+		// Don't fail, just warn
+		fmt.Fprintln(console(i.ctx), "Warning: Istio pods may still be starting")
+		return nil
 	}
-	// Don't fail, just warn
-	fmt.Println("Warning: Istio pods may still be starting")
-	return nil
+	if err == nil {
+		fmt.Fprintln(console(i.ctx), "Istio is ready!")
+	}
+	return err
 }
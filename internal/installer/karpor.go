@@ -1,15 +1,18 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type Karpor struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
@@ -25,86 +28,105 @@ func (k *Karpor) resolveAuthToken() string {
 	return NewSecretResolver(k.config).Resolve("Karpor auth token", def, "karpor_auth_token", "ollama_api_key")
 }
 
-func NewKarpor(cfg *config.Config, exec executor.ShellExecutor) *Karpor {
-	return &Karpor{config: cfg, exec: exec}
+func NewKarpor(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Karpor {
+	return &Karpor{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (k *Karpor) Install() error {
-	fmt.Println("Installing Karpor (Kubernetes Explorer)...")
+	fmt.Fprintln(console(k.ctx), "Installing Karpor (Kubernetes Explorer)...")
 
 	// Detect architecture
 	arch := k.detectArchitecture()
-	fmt.Printf("Detected architecture: %s\n", arch)
+	fmt.Fprintf(console(k.ctx), "Detected architecture: %s\n", arch)
 
 	// Install Helm if not present
-	fmt.Println("Checking Helm installation...")
-	if err := k.installHelm(); err != nil {
+	fmt.Fprintln(console(k.ctx), "Checking Helm installation...")
+	if err := withHelm(k.installHelm); err != nil {
 		return err
 	}
 
 	// Add Helm repository
-	fmt.Println("Adding Karpor Helm repository...")
-	if _, err := k.exec.RunShell("helm repo add kusionstack https://kusionstack.github.io/charts"); err != nil {
-		return err
-	}
-	if _, err := k.exec.RunShell("helm repo update"); err != nil {
-		return err
+	if !k.config.Offline.Enabled() {
+		fmt.Fprintln(console(k.ctx), "Adding Karpor Helm repository...")
+		if err := withHelm(func() error {
+			if _, err := k.exec.RunShell("helm repo add kusionstack https://kusionstack.github.io/charts"); err != nil {
+				return err
+			}
+			_, err := k.exec.RunShell("helm repo update")
+			return err
+		}); err != nil {
+			return err
+		}
 	}
 
 	// Create namespace with Helm labels to avoid conflicts
-	fmt.Println("Creating Karpor namespace...")
+	fmt.Fprintln(console(k.ctx), "Creating Karpor namespace...")
 	if err := k.createNamespace(); err != nil {
 		return err
 	}
 
 	// Create PVs for Karpor storage
-	fmt.Println("Creating storage for Karpor...")
+	fmt.Fprintln(console(k.ctx), "Creating storage for Karpor...")
 	if err := k.createStorage(); err != nil {
 		return err
 	}
 
 	// Install via Helm (base resource/storage flags + optional AI flags).
-	fmt.Println("Installing Karpor via Helm...")
+	fmt.Fprintln(console(k.ctx), "Installing Karpor via Helm...")
 	if err := k.exec.RunShellWithOutput(k.baseHelmArgs() + k.aiHelmArgs()); err != nil {
 		return err
 	}
 
 	// Create kubeconfig ConfigMap for karpor-syncer to access the cluster
-	fmt.Println("Creating kubeconfig for Karpor syncer...")
+	fmt.Fprintln(console(k.ctx), "Creating kubeconfig for Karpor syncer...")
 	if err := k.createKubeconfig(); err != nil {
-		fmt.Printf("Warning: Failed to create kubeconfig: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: Failed to create kubeconfig: %v\n", err)
 	}
 
 	// Patch elasticsearch for ARM64 compatibility (disable SVE instructions)
 	if arch == "arm64" {
-		fmt.Println("Patching Elasticsearch for ARM64 compatibility...")
+		fmt.Fprintln(console(k.ctx), "Patching Elasticsearch for ARM64 compatibility...")
 		if err := k.patchElasticsearchForARM64(); err != nil {
-			fmt.Printf("Warning: Failed to patch Elasticsearch: %v\n", err)
+			fmt.Fprintf(console(k.ctx), "Warning: Failed to patch Elasticsearch: %v\n", err)
 		}
 	}
 
 	// Wait for components to be ready
-	fmt.Println("Waiting for Karpor to be ready...")
+	fmt.Fprintln(console(k.ctx), "Waiting for Karpor to be ready...")
 	if err := k.waitForReady(defaultReadyTimeout); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: %v\n", err)
 	}
 
 	// Create Istio Gateway if Istio is enabled
 	if k.config.Components.ServiceMesh == "istio" {
-		fmt.Println("Creating Istio Gateway for Karpor...")
+		fmt.Fprintln(console(k.ctx), "Creating Istio Gateway for Karpor...")
 		if err := k.createIstioGateway(); err != nil {
-			fmt.Printf("Warning: Failed to create Karpor gateway: %v\n", err)
+			fmt.Fprintf(console(k.ctx), "Warning: Failed to create Karpor gateway: %v\n", err)
 		}
 	}
 
 	// Wait for Ollama model and restart karpor-server to enable AI
 	k.enableAIAfterInstall()
 
-	fmt.Println("Karpor installed successfully!")
+	fmt.Fprintln(console(k.ctx), "Karpor installed successfully!")
 	k.printAccessInfo()
 	return nil
 }
 
+// Uninstall removes the Karpor helm release, its namespace (gateway and
+// syncer kubeconfig included) and its volumes. Index data stays on the NFS
+// export.
+func (k *Karpor) Uninstall() error {
+	fmt.Fprintln(console(k.ctx), "Removing Karpor...")
+	if err := helmUninstall(k.exec, "karpor", "karpor"); err != nil {
+		return err
+	}
+	if err := deleteNamespace(k.exec, "karpor"); err != nil {
+		return err
+	}
+	return deleteResources(k.exec, "", "pv/karpor-etcd-pv", "pv/karpor-elasticsearch-pv")
+}
+
 // createNamespace applies the Karpor namespace pre-labelled as Helm-managed so the
 // subsequent `helm upgrade --install` does not conflict over ownership.
 func (k *Karpor) createNamespace() error {
@@ -117,7 +139,7 @@ metadata:
   annotations:
     meta.helm.sh/release-name: karpor
     meta.helm.sh/release-namespace: karpor`
-	if err := executor.WriteFile("/tmp/karpor-ns.yaml", nsManifest); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/karpor-ns.yaml", nsManifest); err != nil {
 		return err
 	}
 	_, err := k.exec.RunShell("kubectl apply -f /tmp/karpor-ns.yaml")
@@ -129,7 +151,7 @@ metadata:
 // etcd/elasticsearch resource requests/limits to fit smaller lab nodes. The
 // install runs without --wait; readiness is polled separately.
 func (k *Karpor) baseHelmArgs() string {
-	a := fmt.Sprintf("helm upgrade --install karpor kusionstack/karpor -n karpor --version %s", k.config.Versions.Karpor)
+	a := fmt.Sprintf("helm upgrade --install karpor %s -n karpor", chartRef(k.config, "kusionstack", k.chart()))
 	a += " --set etcd.persistence.storageClass=nfs-static"
 	a += " --set elasticsearch.persistence.storageClass=nfs-static"
 	a += " --set elasticsearch.resources.requests.cpu=500m"
@@ -192,17 +214,19 @@ func (k *Karpor) enableAIAfterInstall() {
 	if !k.config.KarporAI.Enabled || k.config.KarporAI.Backend != "ollama" {
 		return
 	}
-	fmt.Println("Waiting for Ollama model to be ready...")
+	fmt.Fprintln(console(k.ctx), "Waiting for Ollama model to be ready...")
 	if err := k.waitForOllamaModel(); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: %v\n", err)
 		return
 	}
-	fmt.Println("Restarting Karpor server to connect to AI...")
+	fmt.Fprintln(console(k.ctx), "Restarting Karpor server to connect to AI...")
 	_, _ = k.exec.RunShell("kubectl rollout restart deployment/karpor-server -n karpor")
 	// Wait for karpor-server to be ready again
-	time.Sleep(10 * time.Second)
+	if sleep(k.ctx, 10*time.Second) != nil {
+		return
+	}
 	_, _ = k.exec.RunShell("kubectl wait --for=condition=Ready pods -l app.kubernetes.io/component=karpor-server -n karpor --timeout=120s")
-	fmt.Println("Karpor AI should be functional now.")
+	fmt.Fprintln(console(k.ctx), "Karpor AI should be functional now.")
 }
 
 func (k *Karpor) detectArchitecture() string {
@@ -234,10 +258,10 @@ func (k *Karpor) createStorage() error {
 	}
 
 	// Create directories via local NFS mount (mounted at /mnt/nfs-storage on controlplane)
-	fmt.Println("Creating Karpor storage directories on NFS...")
+	fmt.Fprintln(console(k.ctx), "Creating Karpor storage directories on NFS...")
 	mkdirCmd := "mkdir -p /mnt/nfs-storage/karpor-etcd /mnt/nfs-storage/karpor-elasticsearch && chmod 777 /mnt/nfs-storage/karpor-etcd /mnt/nfs-storage/karpor-elasticsearch"
 	if _, err := k.exec.RunShell(mkdirCmd); err != nil {
-		fmt.Printf("Warning: Failed to create directories on NFS: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: Failed to create directories on NFS: %v\n", err)
 	}
 
 	// Create PVs with claimRef to bind directly to the PVCs created by Helm
@@ -279,7 +303,7 @@ spec:
     server: %s
     path: %s/karpor-elasticsearch`, nfsServer, nfsPath, nfsServer, nfsPath)
 
-	if err := executor.WriteFile("/tmp/karpor-storage.yaml", storage); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/karpor-storage.yaml", storage); err != nil {
 		return err
 	}
 
@@ -287,16 +311,24 @@ spec:
 	return err
 }
 
+func (k *Karpor) chart() offline.Artifact {
+	return offline.Artifact{Kind: offline.Chart, Name: "karpor", URL: "https://kusionstack.github.io/charts", Version: k.config.Versions.Karpor}
+}
+
+// Artifacts returns helm and the karpor chart.
+func (k *Karpor) Artifacts(arch string) []offline.Artifact {
+	return append(HelmArtifacts(arch), k.chart())
+}
+
 func (k *Karpor) installHelm() error {
 	// Check if helm is already installed
 	if _, err := k.exec.RunShell("which helm"); err == nil {
-		fmt.Println("Helm is already installed")
+		fmt.Fprintln(console(k.ctx), "Helm is already installed")
 		return nil
 	}
 
-	fmt.Println("Installing Helm...")
-	installCmd := "curl -fsSL --connect-timeout 10 --max-time 300 https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3 | bash"
-	if err := k.exec.RunShellWithOutput(installCmd); err != nil {
+	fmt.Fprintln(console(k.ctx), "Installing Helm...")
+	if err := installHelmBinary(k.ctx, k.config, k.exec); err != nil {
 		return fmt.Errorf("failed to install Helm: %w", err)
 	}
 
@@ -305,28 +337,26 @@ func (k *Karpor) installHelm() error {
 		return fmt.Errorf("helm installation verification failed: %w", err)
 	}
 
-	fmt.Println("Helm installed successfully")
+	fmt.Fprintln(console(k.ctx), "Helm installed successfully")
 	return nil
 }
 
 func (k *Karpor) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	err := waitFor(k.ctx, "Karpor pods", pollUntil(timeout, longPollInterval), func() bool {
 		// Check if all pods are running using kubectl wait
 		_, err := k.exec.RunShell("kubectl wait --for=condition=Ready pods --all -n karpor --timeout=10s 2>/dev/null")
-		if err == nil {
-			fmt.Println("Karpor is ready!")
-			return nil
-		}
-
-		fmt.Println("Waiting for Karpor pods...")
-		time.Sleep(longPollInterval)
+		return err == nil
+	})
+	if err != nil && k.ctx.Err() == nil {
+		// Don't fail, just warn - pods might still be pulling images
+		fmt.Fprintln(console(k.ctx), "Warning: Karpor pods may still be starting (timeout reached)")
+		_ = k.exec.RunShellWithOutput("kubectl get pods -n karpor")
+		return nil
 	}
-
-	// Don't fail, just warn - pods might still be pulling images
-	fmt.Println("Warning: Karpor pods may still be starting (timeout reached)")
-	_ = k.exec.RunShellWithOutput("kubectl get pods -n karpor")
-	return nil
+	if err == nil {
+		fmt.Fprintln(console(k.ctx), "Karpor is ready!")
+	}
+	return err
 }
 
 func (k *Karpor) patchElasticsearchForARM64() error {
@@ -365,29 +395,19 @@ func (k *Karpor) waitForOllamaModel() error {
 		model = "llama3.2:1b"
 	}
 
-	// Wait up to 10 minutes for the model to be available
-	deadline := time.Now().Add(10 * time.Minute)
-	for time.Now().Before(deadline) {
+	if err := waitFor(k.ctx, fmt.Sprintf("Ollama model %s to be pulled", model), pollUntil(ollamaModelTimeout, defaultPollInterval), func() bool {
 		// Check if Ollama pod is ready
-		_, err := k.exec.RunShell("kubectl wait --for=condition=Ready pods -l app=ollama -n ollama --timeout=10s 2>/dev/null")
-		if err != nil {
-			fmt.Println("Waiting for Ollama pod...")
-			time.Sleep(10 * time.Second)
-			continue
+		if _, err := k.exec.RunShell("kubectl wait --for=condition=Ready pods -l app=ollama -n ollama --timeout=10s 2>/dev/null"); err != nil {
+			return false
 		}
-
 		// Check if model is available
 		out, err := k.exec.RunShell("kubectl exec -n ollama deployment/ollama -- ollama list 2>/dev/null")
-		if err == nil && strings.Contains(out, model) {
-			fmt.Printf("Model %s is ready!\n", model)
-			return nil
-		}
-
-		fmt.Printf("Waiting for model %s to be pulled...\n", model)
-		time.Sleep(15 * time.Second)
+		return err == nil && strings.Contains(out, model)
+	}); err != nil {
+		return err
 	}
-
-	return fmt.Errorf("timeout waiting for Ollama model %s", model)
+	fmt.Fprintf(console(k.ctx), "Model %s is ready!\n", model)
+	return nil
 }
 
 func (k *Karpor) createIstioGateway() error {
@@ -449,7 +469,7 @@ spec:
         port:
           number: 7443`
 
-	if err := executor.WriteFile("/tmp/karpor-gateway.yaml", gateway); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/karpor-gateway.yaml", gateway); err != nil {
 		return err
 	}
 
@@ -458,27 +478,27 @@ spec:
 }
 
 func (k *Karpor) printAccessInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("Karpor Access Information")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(k.ctx), "\n========================================")
+	fmt.Fprintln(console(k.ctx), "Karpor Access Information")
+	fmt.Fprintln(console(k.ctx), "========================================")
 	if k.config.Components.ServiceMesh == "istio" {
-		fmt.Println("\nAccess via Istio Ingress:")
-		fmt.Println("  1. Get Istio Ingress IP:")
-		fmt.Println("     INGRESS_IP=$(kubectl get svc -n istio-system istio-ingressgateway -o jsonpath='{.status.loadBalancer.ingress[0].ip}')")
-		fmt.Println("  2. Add to /etc/hosts:")
-		fmt.Println("     echo \"$INGRESS_IP karpor.local\" | sudo tee -a /etc/hosts")
-		fmt.Println("  3. Access: http://karpor.local")
+		fmt.Fprintln(console(k.ctx), "\nAccess via Istio Ingress:")
+		fmt.Fprintln(console(k.ctx), "  1. Get Istio Ingress IP:")
+		fmt.Fprintln(console(k.ctx), "     INGRESS_IP=$(kubectl get svc -n istio-system istio-ingressgateway -o jsonpath='{.status.loadBalancer.ingress[0].ip}')")
+		fmt.Fprintln(console(k.ctx), "  2. Add to /etc/hosts:")
+		fmt.Fprintln(console(k.ctx), "     echo \"$INGRESS_IP karpor.local\" | sudo tee -a /etc/hosts")
+		fmt.Fprintln(console(k.ctx), "  3. Access: http://karpor.local")
 	} else {
-		fmt.Println("\nAccess via port-forward:")
-		fmt.Println("  kubectl port-forward -n karpor svc/karpor-server 7443:7443")
-		fmt.Println("  Then access: http://localhost:7443")
+		fmt.Fprintln(console(k.ctx), "\nAccess via port-forward:")
+		fmt.Fprintln(console(k.ctx), "  kubectl port-forward -n karpor svc/karpor-server 7443:7443")
+		fmt.Fprintln(console(k.ctx), "  Then access: http://localhost:7443")
 	}
 	if k.config.KarporAI.Enabled {
-		fmt.Println("\nAI Features: Enabled")
-		fmt.Printf("  Backend: %s\n", k.config.KarporAI.Backend)
+		fmt.Fprintln(console(k.ctx), "\nAI Features: Enabled")
+		fmt.Fprintf(console(k.ctx), "  Backend: %s\n", k.config.KarporAI.Backend)
 	} else {
-		fmt.Println("\nAI Features: Disabled")
-		fmt.Println("  To enable AI, configure karpor_ai in config.yaml")
+		fmt.Fprintln(console(k.ctx), "\nAI Features: Disabled")
+		fmt.Fprintln(console(k.ctx), "  To enable AI, configure karpor_ai in config.yaml")
 	}
-	fmt.Println("========================================")
+	fmt.Fprintln(console(k.ctx), "========================================")
 }
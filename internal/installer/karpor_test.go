@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,7 +12,7 @@ import (
 func TestKarpor_BaseHelmArgs(t *testing.T) {
 	cfg := &config.Config{}
 	cfg.Versions.Karpor = "0.7.6"
-	k := NewKarpor(cfg, &fakeShell{})
+	k := NewKarpor(context.Background(), cfg, &fakeShell{})
 
 	a := k.baseHelmArgs()
 
@@ -21,7 +22,7 @@ func TestKarpor_BaseHelmArgs(t *testing.T) {
 }
 
 func TestKarpor_AIHelmArgs_EmptyWhenDisabled(t *testing.T) {
-	k := NewKarpor(&config.Config{}, &fakeShell{})
+	k := NewKarpor(context.Background(), &config.Config{}, &fakeShell{})
 
 	assert.Equal(t, "", k.aiHelmArgs())
 }
@@ -30,7 +31,7 @@ func TestKarpor_AIHelmArgs_OllamaMapsToOpenAIBackend(t *testing.T) {
 	cfg := &config.Config{}
 	cfg.KarporAI.Enabled = true
 	cfg.KarporAI.Backend = "ollama"
-	k := NewKarpor(cfg, &fakeShell{})
+	k := NewKarpor(context.Background(), cfg, &fakeShell{})
 
 	a := k.aiHelmArgs()
 
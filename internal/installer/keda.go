@@ -1,45 +1,52 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type KEDA struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewKEDA(cfg *config.Config, exec executor.ShellExecutor) *KEDA {
-	return &KEDA{config: cfg, exec: exec}
+func NewKEDA(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *KEDA {
+	return &KEDA{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (k *KEDA) Install() error {
-	fmt.Println("Installing KEDA (Kubernetes Event-Driven Autoscaling)...")
+	fmt.Fprintln(console(k.ctx), "Installing KEDA (Kubernetes Event-Driven Autoscaling)...")
 
-	if err := k.installHelm(); err != nil {
+	if err := withHelm(k.installHelm); err != nil {
 		return fmt.Errorf("helm installation failed: %w", err)
 	}
 
-	if _, err := k.exec.RunShell("helm repo add kedacore https://kedacore.github.io/charts 2>/dev/null || true"); err != nil {
-		fmt.Printf("Warning: could not add kedacore Helm repo: %v\n", err)
-	}
-	if _, err := k.exec.RunShell("helm repo update kedacore"); err != nil {
-		fmt.Printf("Warning: helm repo update failed: %v\n", err)
+	if !k.config.Offline.Enabled() {
+		helmMu.Lock()
+		if _, err := k.exec.RunShell("helm repo add kedacore https://kedacore.github.io/charts 2>/dev/null || true"); err != nil {
+			fmt.Fprintf(console(k.ctx), "Warning: could not add kedacore Helm repo: %v\n", err)
+		}
+		if _, err := k.exec.RunShell("helm repo update kedacore"); err != nil {
+			fmt.Fprintf(console(k.ctx), "Warning: helm repo update failed: %v\n", err)
+		}
+		helmMu.Unlock()
 	}
 
-	cmd := "helm upgrade --install keda kedacore/keda" +
+	cmd := "helm upgrade --install keda " + chartRef(k.config, "kedacore", kedaChart) +
 		" --namespace keda --create-namespace" +
 		" --wait --timeout=3m"
 	if _, err := k.exec.RunShell(cmd); err != nil {
 		return fmt.Errorf("keda helm install failed: %w", err)
 	}
 
-	fmt.Println("Waiting for KEDA to be ready...")
+	fmt.Fprintln(console(k.ctx), "Waiting for KEDA to be ready...")
 	if err := k.waitForReady(shortReadyTimeout); err != nil {
 		return fmt.Errorf("keda did not become ready: %w", err)
 	}
@@ -48,37 +55,56 @@ func (k *KEDA) Install() error {
 	return nil
 }
 
+// Uninstall removes the KEDA helm release and its namespace.
+func (k *KEDA) Uninstall() error {
+	fmt.Fprintln(console(k.ctx), "Removing KEDA...")
+	if err := helmUninstall(k.exec, "keda", "keda"); err != nil {
+		return err
+	}
+	return deleteNamespace(k.exec, "keda")
+}
+
+// Verify checks that the KEDA operator is ready and serves the external
+// metrics API its ScaledObjects rely on.
+func (k *KEDA) Verify() error {
+	if err := expectReplicas(k.ctx, k.exec, "keda", "keda-operator"); err != nil {
+		return err
+	}
+	return expect(k.ctx, k.exec, "the external metrics APIService to be Available",
+		"kubectl get apiservice v1beta1.external.metrics.k8s.io -o jsonpath='{.status.conditions[?(@.type==\"Available\")].status}'", equals("True"))
+}
+
+var kedaChart = offline.Artifact{Kind: offline.Chart, Name: "keda", URL: "https://kedacore.github.io/charts"}
+
+// Artifacts returns helm and the keda chart.
+func (k *KEDA) Artifacts(arch string) []offline.Artifact {
+	return append(HelmArtifacts(arch), kedaChart)
+}
+
 func (k *KEDA) installHelm() error {
 	if _, err := k.exec.RunShell("helm version 2>/dev/null"); err == nil {
 		return nil
 	}
-	fmt.Println("Installing Helm...")
-	_, err := k.exec.RunShell("curl -fsSL --connect-timeout 10 --max-time 300 https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3 | bash")
-	return err
+	fmt.Fprintln(console(k.ctx), "Installing Helm...")
+	return installHelmBinary(k.ctx, k.config, k.exec)
 }
 
 func (k *KEDA) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return waitFor(k.ctx, "KEDA operator", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, err := k.exec.RunShell(
 			"kubectl get deployment keda-operator -n keda -o jsonpath='{.status.readyReplicas}' 2>/dev/null",
 		)
-		if err == nil && strings.TrimSpace(out) == "1" {
-			return nil
-		}
-		fmt.Println("Waiting for KEDA operator...")
-		time.Sleep(defaultPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for KEDA operator")
+		return err == nil && strings.TrimSpace(out) == "1"
+	})
 }
 
 func (k *KEDA) printAccessInfo() {
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("   KEDA instalado!")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Println("\nEscalers disponíveis: Prometheus, Kafka, Redis, RabbitMQ, HTTP, Cron e mais.")
-	fmt.Println("\nExemplo — ScaledObject com Prometheus:")
-	fmt.Println(`  apiVersion: keda.sh/v1alpha1
+	fmt.Fprintln(console(k.ctx), "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(console(k.ctx), "   KEDA instalado!")
+	fmt.Fprintln(console(k.ctx), strings.Repeat("=", 50))
+	fmt.Fprintln(console(k.ctx), "\nEscalers disponíveis: Prometheus, Kafka, Redis, RabbitMQ, HTTP, Cron e mais.")
+	fmt.Fprintln(console(k.ctx), "\nExemplo — ScaledObject com Prometheus:")
+	fmt.Fprintln(console(k.ctx), `  apiVersion: keda.sh/v1alpha1
   kind: ScaledObject
   metadata:
     name: my-app-scaler
@@ -94,8 +120,8 @@ func (k *KEDA) printAccessInfo() {
         metricName: http_requests_total
         threshold: "100"
         query: sum(rate(http_requests_total[2m]))`)
-	fmt.Println("\nPara verificar:")
-	fmt.Println("  kubectl get scaledobject -A")
-	fmt.Println("  kubectl get hpa -A  # KEDA cria um HPA por baixo")
-	fmt.Println(strings.Repeat("=", 50))
+	fmt.Fprintln(console(k.ctx), "\nPara verificar:")
+	fmt.Fprintln(console(k.ctx), "  kubectl get scaledobject -A")
+	fmt.Fprintln(console(k.ctx), "  kubectl get hpa -A  # KEDA cria um HPA por baixo")
+	fmt.Fprintln(console(k.ctx), strings.Repeat("=", 50))
 }
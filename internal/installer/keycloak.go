@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -9,7 +10,12 @@ import (
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
 )
 
+// keycloakIssuerURL is the OIDC issuer of the k8s realm: KC_HOSTNAME pins
+// it, whichever way Keycloak is reached.
+const keycloakIssuerURL = "https://keycloak.local/realms/k8s"
+
 type Keycloak struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
@@ -24,15 +30,15 @@ type keycloakCreds struct {
 	developerPassword string
 }
 
-func NewKeycloak(cfg *config.Config, exec executor.ShellExecutor) *Keycloak {
-	return &Keycloak{config: cfg, exec: exec}
+func NewKeycloak(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Keycloak {
+	return &Keycloak{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (k *Keycloak) Install() error {
-	fmt.Println("Installing Keycloak (OIDC Identity Provider)...")
+	fmt.Fprintln(console(k.ctx), "Installing Keycloak (OIDC Identity Provider)...")
 
-	cpIP := k.config.Network.ControlPlaneIP
-	issuerURL := "https://keycloak.local/realms/k8s"
+	cpIP := k.config.ControlPlaneEndpoint()
+	issuerURL := keycloakIssuerURL
 
 	creds, err := k.resolveCredentials()
 	if err != nil {
@@ -42,9 +48,9 @@ func (k *Keycloak) Install() error {
 	// Wait for VSO to sync the freshly-written Vault credentials into the keycloak-admin K8s
 	// secret before creating the pod — env vars are captured at container start time and
 	// Keycloak 26.x will not create the admin account if KEYCLOAK_ADMIN is empty.
-	fmt.Println("Waiting for VSO to sync keycloak-admin secret...")
+	fmt.Fprintln(console(k.ctx), "Waiting for VSO to sync keycloak-admin secret...")
 	if err := k.waitForAdminSecret(adminSecretSyncTimeout); err != nil {
-		fmt.Printf("Warning: keycloak-admin secret may not be ready: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: keycloak-admin secret may not be ready: %v\n", err)
 	}
 
 	// Apply the Postgres mTLS policy BEFORE deploying Keycloak so the very first
@@ -52,155 +58,156 @@ func (k *Keycloak) Install() error {
 	// running would tear down the established plaintext connections at the cutover
 	// (a transient "connection has been closed" blip before the pool reconnects).
 	if k.config.Components.ServiceMesh == "istio" {
-		fmt.Println("Enforcing mTLS on Postgres (PeerAuthentication STRICT)...")
+		fmt.Fprintln(console(k.ctx), "Enforcing mTLS on Postgres (PeerAuthentication STRICT)...")
 		if err := k.createPostgresMTLS(); err != nil {
-			fmt.Printf("Warning: Failed to apply Postgres mTLS PeerAuthentication: %v\n", err)
+			fmt.Fprintf(console(k.ctx), "Warning: Failed to apply Postgres mTLS PeerAuthentication: %v\n", err)
 		}
 	}
 
-	fmt.Println("Deploying Keycloak...")
+	fmt.Fprintln(console(k.ctx), "Deploying Keycloak...")
 	if err := k.deployKeycloak(creds); err != nil {
 		return err
 	}
 
-	fmt.Println("Waiting for Keycloak to be ready (first start includes build step, ~5-8 min)...")
+	fmt.Fprintln(console(k.ctx), "Waiting for Keycloak to be ready (first start includes build step, ~5-8 min)...")
 	if err := k.waitForReady(keycloakStartTimeout); err != nil {
 		return fmt.Errorf("keycloak did not become ready: %w", err)
 	}
 
-	fmt.Println("Configuring realm, clients, and users...")
+	fmt.Fprintln(console(k.ctx), "Configuring realm, clients, and users...")
 	if err := k.configureRealm(cpIP, creds); err != nil {
 		return fmt.Errorf("realm configuration failed: %w", err)
 	}
 
-	fmt.Println("Patching API server with OIDC authentication...")
+	fmt.Fprintln(console(k.ctx), "Patching API server with OIDC authentication...")
 	if err := k.patchAPIServer(issuerURL); err != nil {
-		fmt.Printf("Warning: API server patch failed: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: API server patch failed: %v\n", err)
+	}
+	if n := len(k.config.GetControlPlanes()); n > 1 {
+		fmt.Fprintf(console(k.ctx), "Warning: only this control plane's API server accepts OIDC logins; the other %d are not patched\n", n-1)
 	}
 
 	if k.config.Components.ServiceMesh == "istio" {
-		fmt.Println("Creating Istio Gateway for Keycloak...")
+		fmt.Fprintln(console(k.ctx), "Creating Istio Gateway for Keycloak...")
 		if err := k.createGateway(); err != nil {
-			fmt.Printf("Warning: Failed to create Keycloak gateway: %v\n", err)
+			fmt.Fprintf(console(k.ctx), "Warning: Failed to create Keycloak gateway: %v\n", err)
 		}
 	}
 
-	fmt.Println("Storing kubeconfig-oidc in Vault for distribution...")
+	fmt.Fprintln(console(k.ctx), "Storing kubeconfig-oidc in Vault for distribution...")
 	if err := k.storeKubeconfigInVault(cpIP, issuerURL); err != nil {
-		fmt.Printf("Warning: could not store kubeconfig in Vault: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: could not store kubeconfig in Vault: %v\n", err)
 	}
 
-	fmt.Println("Keycloak installed successfully!")
+	fmt.Fprintln(console(k.ctx), "Keycloak installed successfully!")
 	k.printAccessInfo(issuerURL)
 	return nil
 }
 
+// Verify checks that Keycloak serves the k8s realm's OIDC discovery document,
+// which the API server and kubelogin depend on. With Istio it is fetched the
+// way they reach it, as keycloak.local through the ingress gateway; otherwise
+// through the keycloak Service.
+func (k *Keycloak) Verify() error {
+	const discovery = "/realms/k8s/.well-known/openid-configuration"
+	issuer := contains(fmt.Sprintf(`"issuer":"%s"`, keycloakIssuerURL))
+	if k.config.Components.ServiceMesh != "istio" {
+		return expectServiceProxy(k.ctx, k.exec, "Keycloak OIDC discovery", "keycloak", "keycloak:8080", discovery, issuer)
+	}
+	// -k: the lab CA is checked by cert-manager's Verify; this is about
+	// Keycloak answering behind the gateway.
+	return expect(k.ctx, k.exec, "Keycloak OIDC discovery through the Istio gateway",
+		fmt.Sprintf("curl -sk --max-time 10 --resolve keycloak.local:443:%s https://keycloak.local%s", k.ingressIP(), discovery), issuer)
+}
+
 // ConfigureGrafanaOAuth applies Grafana OAuth2 configuration after Keycloak is fully installed.
 // Called from the provisioner as a separate step so it runs even if Install() had partial failures.
 func (k *Keycloak) ConfigureGrafanaOAuth() error {
 	if k.config.Components.Monitoring != "prometheus-stack" {
 		return nil
 	}
-	cpIP := k.config.Network.ControlPlaneIP
+	cpIP := k.config.ControlPlaneEndpoint()
 	creds, err := k.resolveCredentials()
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Configuring Grafana OAuth2 with Keycloak...")
-	var oauthErr error
-	for attempt := 1; attempt <= 3; attempt++ {
-		if oauthErr = k.configureGrafanaOAuth(cpIP, creds); oauthErr == nil {
-			break
-		}
-		fmt.Printf("Attempt %d/3 failed: %v — retrying in 20s...\n", attempt, oauthErr)
-		time.Sleep(oauthRetryDelay)
-	}
-	return oauthErr
+	fmt.Fprintln(console(k.ctx), "Configuring Grafana OAuth2 with Keycloak...")
+	return retry(k.ctx, "Grafana OAuth2 configuration", retryTimes(oauthAttempts, oauthRetryDelay), func() error {
+		return k.configureGrafanaOAuth(cpIP, creds)
+	})
 }
 
 func (k *Keycloak) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
+	start := time.Now()
 
 	// Wait for PostgreSQL StatefulSet rollout
-	for time.Now().Before(deadline) {
+	if err := waitFor(k.ctx, "PostgreSQL", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, _ := k.exec.RunShell("kubectl rollout status statefulset/postgres -n keycloak --timeout=10s 2>&1")
-		if strings.Contains(out, "rolling update complete") || strings.Contains(out, "roll out complete") {
-			fmt.Println("PostgreSQL is running!")
-			break
-		}
-		fmt.Println("Waiting for PostgreSQL...")
-		time.Sleep(defaultPollInterval)
+		return strings.Contains(out, "rolling update complete") || strings.Contains(out, "roll out complete")
+	}); err != nil {
+		return fmt.Errorf("timeout waiting for Keycloak to be ready: %w", err)
 	}
+	fmt.Fprintln(console(k.ctx), "PostgreSQL is running!")
 
 	// Wait for Keycloak Deployment rollout — reliable for pods with Istio sidecars
 	// since rollout status requires ALL containers (including sidecar) to be ready.
-	for time.Now().Before(deadline) {
+	// Both waits share timeout; a budget already spent by PostgreSQL leaves a
+	// single check (see backoff.PollUntil).
+	if err := waitFor(k.ctx, "Keycloak to be healthy (first start includes build step)", pollUntil(timeout-time.Since(start), defaultPollInterval), func() bool {
 		out, _ := k.exec.RunShell("kubectl rollout status deployment/keycloak -n keycloak --timeout=10s 2>&1")
-		if strings.Contains(out, "successfully rolled out") {
-			fmt.Println("Keycloak is ready!")
-			return nil
-		}
-		fmt.Println("Waiting for Keycloak to be healthy (first start includes build step)...")
-		time.Sleep(defaultPollInterval)
+		return strings.Contains(out, "successfully rolled out")
+	}); err != nil {
+		return fmt.Errorf("timeout waiting for Keycloak to be ready: %w", err)
 	}
-
-	return fmt.Errorf("timeout waiting for Keycloak to be ready")
+	fmt.Fprintln(console(k.ctx), "Keycloak is ready!")
+	return nil
 }
 
 // waitForAdminSecret blocks until the keycloak-admin K8s secret (managed by VSO) has a
 // non-empty username field. This prevents a race where the pod is created before VSO has
 // synced the Vault credentials, causing Keycloak to start without an admin account.
 func (k *Keycloak) waitForAdminSecret(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	if err := waitFor(k.ctx, "keycloak-admin secret to be populated by VSO", pollUntil(timeout, shortPollInterval), func() bool {
 		out, _ := k.exec.RunShell(`kubectl get secret keycloak-admin -n keycloak -o jsonpath='{.data.username}' 2>/dev/null | base64 -d 2>/dev/null`)
-		if strings.TrimSpace(out) != "" {
-			fmt.Println("keycloak-admin secret synced!")
-			return nil
-		}
-		fmt.Println("Waiting for keycloak-admin secret to be populated by VSO...")
-		time.Sleep(shortPollInterval)
+		return strings.TrimSpace(out) != ""
+	}); err != nil {
+		return err
 	}
-	return fmt.Errorf("timeout: keycloak-admin secret not populated within %s", timeout)
+	fmt.Fprintln(console(k.ctx), "keycloak-admin secret synced!")
+	return nil
 }
 
 func (k *Keycloak) waitForSecret(namespace, name string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return waitFor(k.ctx, fmt.Sprintf("secret %s/%s", namespace, name), pollUntil(timeout, defaultPollInterval), func() bool {
 		_, err := k.exec.RunShell(fmt.Sprintf(
 			"kubectl get secret %s -n %s 2>/dev/null", name, namespace))
-		if err == nil {
-			return nil
-		}
-		fmt.Printf("Waiting for secret %s/%s...\n", namespace, name)
-		time.Sleep(defaultPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for secret %s/%s", namespace, name)
+		return err == nil
+	})
 }
 
 func (k *Keycloak) printAccessInfo(issuerURL string) {
-	fmt.Println("\n========================================")
-	fmt.Println("Keycloak Access Information")
-	fmt.Println("========================================")
-	fmt.Println("\nAdmin Console: https://keycloak.local  (Istio Gateway, TLS)")
-	fmt.Println("  Requires a hosts entry for keycloak.local → ingress IP (see README Quick Start step 5).")
-	fmt.Println("\nAdmin credentials (stored in Vault):")
-	fmt.Println("  vault kv get -field=keycloak_admin_username secret/k8s-provisioner/api-keys")
-	fmt.Println("  vault kv get -field=keycloak_admin_password secret/k8s-provisioner/api-keys")
-	fmt.Println("\nTest users (realm: k8s) — senhas no Vault:")
-	fmt.Println("  k8sadmin  (group: k8s-admins  → cluster-admin)")
-	fmt.Println("    vault kv get -field=keycloak_k8sadmin_password secret/k8s-provisioner/api-keys")
-	fmt.Println("  developer (group: k8s-developers → view)")
-	fmt.Println("    vault kv get -field=keycloak_developer_password secret/k8s-provisioner/api-keys")
-	fmt.Println("\n--- kubectl OIDC login (kubelogin) ---")
-	fmt.Println("Install kubelogin:")
-	fmt.Println("  brew install int128/kubelogin/kubelogin   # Mac")
-	fmt.Println("  kubectl krew install oidc-login           # via krew")
-	fmt.Println("\nEasiest: fetch the ready-made kubeconfig (CA-verified, no insecure flags) from Vault:")
-	fmt.Println("  k8s-provisioner vault get k8s-provisioner/kubeconfig-oidc config > ~/.kube/config-oidc")
-	fmt.Println("\nOr add OIDC credentials manually (trust the lab CA — do NOT skip TLS verification):")
-	fmt.Printf(`  # export the lab CA first:
+	fmt.Fprintln(console(k.ctx), "\n========================================")
+	fmt.Fprintln(console(k.ctx), "Keycloak Access Information")
+	fmt.Fprintln(console(k.ctx), "========================================")
+	fmt.Fprintln(console(k.ctx), "\nAdmin Console: https://keycloak.local  (Istio Gateway, TLS)")
+	fmt.Fprintln(console(k.ctx), "  Requires a hosts entry for keycloak.local → ingress IP (see README Quick Start step 5).")
+	fmt.Fprintln(console(k.ctx), "\nAdmin credentials (stored in Vault):")
+	fmt.Fprintln(console(k.ctx), "  vault kv get -field=keycloak_admin_username secret/k8s-provisioner/api-keys")
+	fmt.Fprintln(console(k.ctx), "  vault kv get -field=keycloak_admin_password secret/k8s-provisioner/api-keys")
+	fmt.Fprintln(console(k.ctx), "\nTest users (realm: k8s) — senhas no Vault:")
+	fmt.Fprintln(console(k.ctx), "  k8sadmin  (group: k8s-admins  → cluster-admin)")
+	fmt.Fprintln(console(k.ctx), "    vault kv get -field=keycloak_k8sadmin_password secret/k8s-provisioner/api-keys")
+	fmt.Fprintln(console(k.ctx), "  developer (group: k8s-developers → view)")
+	fmt.Fprintln(console(k.ctx), "    vault kv get -field=keycloak_developer_password secret/k8s-provisioner/api-keys")
+	fmt.Fprintln(console(k.ctx), "\n--- kubectl OIDC login (kubelogin) ---")
+	fmt.Fprintln(console(k.ctx), "Install kubelogin:")
+	fmt.Fprintln(console(k.ctx), "  brew install int128/kubelogin/kubelogin   # Mac")
+	fmt.Fprintln(console(k.ctx), "  kubectl krew install oidc-login           # via krew")
+	fmt.Fprintln(console(k.ctx), "\nEasiest: fetch the ready-made kubeconfig (CA-verified, no insecure flags) from Vault:")
+	fmt.Fprintln(console(k.ctx), "  k8s-provisioner vault get k8s-provisioner/kubeconfig-oidc config > ~/.kube/config-oidc")
+	fmt.Fprintln(console(k.ctx), "\nOr add OIDC credentials manually (trust the lab CA — do NOT skip TLS verification):")
+	fmt.Fprintf(console(k.ctx), `  # export the lab CA first:
   k8s-provisioner vault get k8s-provisioner/api-keys >/dev/null  # ensure Vault reachable
   kubectl get secret lab-ca-secret -n cert-manager -o jsonpath='{.data.tls\.crt}' | base64 -d > ~/.kube/lab-ca.crt
   kubectl config set-credentials oidc \
@@ -214,10 +221,10 @@ func (k *Keycloak) printAccessInfo(issuerURL string) {
     --exec-arg=--certificate-authority=%s/.kube/lab-ca.crt \
     --exec-arg=--listen-address=%s
 `, issuerURL, "$HOME", kubeloginListenAddr)
-	fmt.Println("\nTest login:")
-	fmt.Println("  kubectl get nodes --user=oidc")
-	fmt.Println("\n--- Grafana SSO ---")
-	fmt.Println("  Grafana now uses Keycloak for login.")
-	fmt.Println("  Local admin login still works (user 'admin'; password in Vault or shown during install).")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(k.ctx), "\nTest login:")
+	fmt.Fprintln(console(k.ctx), "  kubectl get nodes --user=oidc")
+	fmt.Fprintln(console(k.ctx), "\n--- Grafana SSO ---")
+	fmt.Fprintln(console(k.ctx), "  Grafana now uses Keycloak for login.")
+	fmt.Fprintln(console(k.ctx), "  Local admin login still works (user 'admin'; password in Vault or shown during install).")
+	fmt.Fprintln(console(k.ctx), "========================================")
 }
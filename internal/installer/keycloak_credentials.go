@@ -33,9 +33,15 @@ func (k *Keycloak) resolveCredentials() (keycloakCreds, error) {
 		developerPassword: gen["developer"],
 	}
 
+	// A dry run must not seed Vault or write the credentials file; the
+	// generated values only fill in the rendered commands.
+	if isDryRun(k.ctx) {
+		return creds, nil
+	}
+
 	resolver := NewSecretResolver(k.config)
 	if !resolver.Enabled() {
-		fmt.Println("Warning: Vault not configured — generated random Keycloak credentials.")
+		fmt.Fprintln(console(k.ctx), "Warning: Vault not configured — generated random Keycloak credentials.")
 		contents := fmt.Sprintf(
 			"keycloak admin: %s / %s\npostgres:       %s / %s\nk8s-admin OIDC: %s\ndeveloper OIDC: %s\n",
 			creds.adminUsername, creds.adminPassword,
@@ -46,11 +52,11 @@ func (k *Keycloak) resolveCredentials() (keycloakCreds, error) {
 		// logs. Fall back to stdout only if the file cannot be written — otherwise
 		// these unrecoverable credentials would be lost entirely.
 		if err := os.WriteFile(keycloakCredsFile, []byte(contents), 0600); err != nil {
-			fmt.Printf("Warning: could not write %s (%v) — printing credentials once instead.\n", keycloakCredsFile, err)
-			fmt.Println("  SAVE THESE NOW (they are not persisted anywhere):")
-			fmt.Print("    " + contents)
+			fmt.Fprintf(console(k.ctx), "Warning: could not write %s (%v) — printing credentials once instead.\n", keycloakCredsFile, err)
+			fmt.Fprintln(console(k.ctx), "  SAVE THESE NOW (they are not persisted anywhere):")
+			fmt.Fprint(console(k.ctx), "    "+contents)
 		} else {
-			fmt.Printf("  Credentials written to %s (mode 0600) — back them up; they are not stored in Vault.\n", keycloakCredsFile)
+			fmt.Fprintf(console(k.ctx), "  Credentials written to %s (mode 0600) — back them up; they are not stored in Vault.\n", keycloakCredsFile)
 		}
 		return creds, nil
 	}
@@ -60,7 +66,7 @@ func (k *Keycloak) resolveCredentials() (keycloakCreds, error) {
 
 	existing, err := vault.ReadSecret(vaultPath)
 	if err != nil {
-		fmt.Printf("Warning: could not read Vault secrets: %v — using generated values (not persisted)\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: could not read Vault secrets: %v — using generated values (not persisted)\n", err)
 		return creds, nil
 	}
 
@@ -85,9 +91,9 @@ func (k *Keycloak) resolveCredentials() (keycloakCreds, error) {
 			merged[key] = val
 		}
 		if werr := vault.WriteSecret(vaultPath, merged); werr != nil {
-			fmt.Printf("Warning: could not write Keycloak secrets to Vault: %v\n", werr)
+			fmt.Fprintf(console(k.ctx), "Warning: could not write Keycloak secrets to Vault: %v\n", werr)
 		} else {
-			fmt.Printf("Keycloak secrets written to Vault at %s\n", vaultPath)
+			fmt.Fprintf(console(k.ctx), "Keycloak secrets written to Vault at %s\n", vaultPath)
 		}
 	}
 
@@ -230,7 +230,7 @@ spec:
 	}
 	manifests := fmt.Sprintf(secrets+rest, pgVersion, kcVersion)
 
-	if err := executor.WriteFile("/tmp/keycloak.yaml", manifests); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/keycloak.yaml", manifests); err != nil {
 		return err
 	}
 
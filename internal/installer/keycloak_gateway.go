@@ -47,7 +47,7 @@ spec:
         port:
           number: 8080`
 
-	if err := executor.WriteFile("/tmp/keycloak-gateway.yaml", gateway); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/keycloak-gateway.yaml", gateway); err != nil {
 		return err
 	}
 
@@ -74,7 +74,7 @@ spec:
   mtls:
     mode: STRICT`
 
-	if err := executor.WriteFile("/tmp/keycloak-postgres-mtls.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/keycloak-postgres-mtls.yaml", manifest); err != nil {
 		return err
 	}
 
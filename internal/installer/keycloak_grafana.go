@@ -88,7 +88,7 @@ metadata:
 binaryData:
   ca.crt: %s`, indented.String(), labCA)
 
-	if err := executor.WriteFile("/tmp/grafana-keycloak.yaml", resources); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/grafana-keycloak.yaml", resources); err != nil {
 		return err
 	}
 
@@ -106,14 +106,14 @@ binaryData:
   {"op":"add","path":"/spec/template/spec/containers/0/volumeMounts/-","value":{"name":"keycloak-ca","mountPath":"/etc/grafana/keycloak-ca"}},
   {"op":"add","path":"/spec/template/spec/containers/0/env/-","value":{"name":"GF_AUTH_GENERIC_OAUTH_CLIENT_SECRET","valueFrom":{"secretKeyRef":{"name":"grafana-oidc","key":"client-secret"}}}}
 ]`
-		if err := executor.WriteFile("/tmp/grafana-oidc-patch.json", patch); err != nil {
+		if err := executor.WriteFileOn(k.exec, "/tmp/grafana-oidc-patch.json", patch); err != nil {
 			return err
 		}
 		if _, err := k.exec.RunShell("kubectl patch deployment grafana -n monitoring --type=json --patch-file=/tmp/grafana-oidc-patch.json"); err != nil {
 			return err
 		}
 	} else {
-		fmt.Println("Grafana deployment already patched for OAuth, skipping")
+		fmt.Fprintln(console(k.ctx), "Grafana deployment already patched for OAuth, skipping")
 	}
 
 	_, err = k.exec.RunShell("kubectl rollout restart deployment/grafana -n monitoring")
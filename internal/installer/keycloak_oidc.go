@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
 )
@@ -35,6 +34,10 @@ func (k *Keycloak) labCABase64() (string, error) {
 }
 
 func (k *Keycloak) storeKubeconfigInVault(cpIP, issuerURL string) error {
+	if isDryRun(k.ctx) {
+		fmt.Fprintln(console(k.ctx), "[dry-run] skip storing kubeconfig-oidc in Vault")
+		return nil
+	}
 	token := ResolveVaultToken(k.config.Vault.Token)
 	if !k.config.Vault.Enabled || k.config.VaultAddress() == "" || token == "" {
 		return fmt.Errorf("vault not configured")
@@ -85,7 +88,7 @@ users:
 		return err
 	}
 
-	fmt.Println("kubeconfig-oidc stored at: secret/k8s-provisioner/kubeconfig-oidc")
+	fmt.Fprintln(console(k.ctx), "kubeconfig-oidc stored at: secret/k8s-provisioner/kubeconfig-oidc")
 	return nil
 }
 
@@ -123,7 +126,7 @@ jwt:
       prefix: "oidc:"
 `, issuerURL, ca.String())
 
-	if err := executor.WriteFile("/etc/kubernetes/pki/auth-config.yaml", authConfig); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/etc/kubernetes/pki/auth-config.yaml", authConfig); err != nil {
 		return err
 	}
 
@@ -161,21 +164,14 @@ jwt:
 		}
 		patched = true
 	} else {
-		fmt.Println("API server already has --authentication-config flag")
+		fmt.Fprintln(console(k.ctx), "API server already has --authentication-config flag")
 	}
 
 	if patched {
-		fmt.Println("Waiting for API server to restart with OIDC config...")
-		time.Sleep(apiServerRestartWait)
-
-		deadline := time.Now().Add(apiServerHealthTimeout)
-		for time.Now().Before(deadline) {
-			out, err := k.exec.RunShell("kubectl get --raw='/healthz' 2>/dev/null")
-			if err == nil && strings.Contains(out, "ok") {
-				fmt.Println("API server is back online!")
-				break
-			}
-			time.Sleep(defaultPollInterval)
+		fmt.Fprintln(console(k.ctx), "Waiting for API server to restart with OIDC config...")
+		// Not fatal on timeout: the RBAC apply below surfaces a dead API server.
+		if err := k.waitForAPIServerRestart(); err != nil {
+			return err
 		}
 	}
 
@@ -205,7 +201,7 @@ subjects:
   name: "oidc:k8s-developers"
   apiGroup: rbac.authorization.k8s.io`
 
-	if err := executor.WriteFile("/tmp/oidc-rbac.yaml", rbac); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/oidc-rbac.yaml", rbac); err != nil {
 		return err
 	}
 
@@ -213,6 +209,26 @@ subjects:
 	return err
 }
 
+// waitForAPIServerRestart gives kubelet time to restart the apiserver static
+// pod after a manifest edit, then polls /healthz. Only a cancellation is an
+// error: a timeout is left for the next kubectl call to surface.
+func (k *Keycloak) waitForAPIServerRestart() error {
+	if err := sleep(k.ctx, apiServerRestartWait); err != nil {
+		return err
+	}
+	err := waitFor(k.ctx, "API server to come back online", pollUntil(apiServerHealthTimeout, defaultPollInterval), func() bool {
+		out, err := k.exec.RunShell("kubectl get --raw='/healthz' 2>/dev/null")
+		return err == nil && strings.Contains(out, "ok")
+	})
+	if k.ctx.Err() != nil {
+		return err
+	}
+	if err == nil {
+		fmt.Fprintln(console(k.ctx), "API server is back online!")
+	}
+	return nil
+}
+
 // ingressIP returns the IP the apiserver should use to reach keycloak.local. It
 // prefers the live Istio ingress LoadBalancer IP and falls back to the first
 // address of the configured MetalLB range.
@@ -224,11 +240,14 @@ func (k *Keycloak) ingressIP() string {
 			return ip
 		}
 	}
-	if r := k.config.Network.MetalLBRange; r != "" {
-		if i := strings.IndexByte(r, '-'); i > 0 {
-			return strings.TrimSpace(r[:i])
-		}
-		return strings.TrimSpace(r)
+	return firstPoolAddress(k.config.Network.MetalLBRange)
+}
+
+// firstPoolAddress returns the first address of a MetalLB range ("a-b" or a
+// CIDR), the one MetalLB hands to the first LoadBalancer Service.
+func firstPoolAddress(r string) string {
+	if i := strings.IndexByte(r, '-'); i > 0 {
+		return strings.TrimSpace(r[:i])
 	}
-	return ""
+	return strings.TrimSpace(r)
 }
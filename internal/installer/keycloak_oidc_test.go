@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,7 +10,7 @@ import (
 )
 
 func TestIngressIP_PrefersLiveLoadBalancerIP(t *testing.T) {
-	k := NewKeycloak(&config.Config{}, &fakeShell{
+	k := NewKeycloak(context.Background(), &config.Config{}, &fakeShell{
 		outputs: map[string]string{"istio-ingressgateway": "192.168.56.205"},
 	})
 
@@ -20,13 +21,13 @@ func TestIngressIP_FallsBackToMetalLBRangeStart(t *testing.T) {
 	cfg := &config.Config{}
 	cfg.Network.MetalLBRange = "192.168.56.200-192.168.56.250"
 	// fakeShell returns "" for the svc lookup -> fallback to the range start.
-	k := NewKeycloak(cfg, &fakeShell{})
+	k := NewKeycloak(context.Background(), cfg, &fakeShell{})
 
 	assert.Equal(t, "192.168.56.200", k.ingressIP())
 }
 
 func TestIngressIP_EmptyWhenNoLBAndNoRange(t *testing.T) {
-	k := NewKeycloak(&config.Config{}, &fakeShell{})
+	k := NewKeycloak(context.Background(), &config.Config{}, &fakeShell{})
 
 	assert.Equal(t, "", k.ingressIP())
 }
@@ -0,0 +1,157 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// Uninstall reverses Install: it unwires Grafana from Keycloak, removes the
+// OIDC authentication config from the API server (restarting it), deletes
+// the OIDC RBAC bindings and the Keycloak and PostgreSQL workloads with their
+// data. The keycloak namespace is kept: it holds the VSO-synced credentials a
+// reinstall waits for. The OIDC kubeconfig stored in Vault is left in place.
+func (k *Keycloak) Uninstall() error {
+	fmt.Fprintln(console(k.ctx), "Uninstalling Keycloak (OIDC Identity Provider)...")
+
+	if k.config.Components.Monitoring == "prometheus-stack" {
+		fmt.Fprintln(console(k.ctx), "Removing Grafana OAuth2 configuration...")
+		if err := k.unconfigureGrafanaOAuth(); err != nil {
+			fmt.Fprintf(console(k.ctx), "Warning: Grafana OAuth2 removal failed: %v\n", err)
+		}
+	}
+
+	fmt.Fprintln(console(k.ctx), "Removing OIDC authentication from the API server...")
+	if err := k.unpatchAPIServer(); err != nil {
+		return err
+	}
+	if err := deleteResources(k.exec, "", "clusterrolebinding/oidc-k8s-admins", "clusterrolebinding/oidc-k8s-developers"); err != nil {
+		return err
+	}
+
+	if k.config.Components.ServiceMesh == "istio" {
+		fmt.Fprintln(console(k.ctx), "Removing Keycloak Istio Gateway and Postgres mTLS policy...")
+		if err := deleteResources(k.exec, "keycloak",
+			"virtualservice/keycloak", "gateway/keycloak-gateway", "peerauthentication/postgres-mtls"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(console(k.ctx), "Removing Keycloak and PostgreSQL...")
+	return deleteResources(k.exec, "keycloak",
+		"deployment/keycloak", "service/keycloak", "serviceaccount/keycloak",
+		"statefulset/postgres", "service/postgres", "serviceaccount/postgres", "pvc/data-postgres-0")
+}
+
+// unpatchAPIServer reverses patchAPIServer: it drops the
+// --authentication-config flag and the keycloak.local host alias from the
+// apiserver manifest, waits for the API server to restart without them, and
+// only then deletes the AuthenticationConfiguration it no longer reads.
+func (k *Keycloak) unpatchAPIServer() error {
+	patched := false
+	if _, err := k.exec.RunShell(fmt.Sprintf("grep -q 'authentication-config=' %s", apiServerManifest)); err == nil {
+		if _, err := k.exec.RunShell(fmt.Sprintf(
+			`sed -i '\#- --authentication-config=/etc/kubernetes/pki/auth-config.yaml#d' %s`, apiServerManifest)); err != nil {
+			return err
+		}
+		patched = true
+	}
+	// The alias is the four-line hostAliases block patchAPIServer inserted
+	// after "spec:".
+	if _, err := k.exec.RunShell(fmt.Sprintf("grep -q 'keycloak.local' %s", apiServerManifest)); err == nil {
+		if _, err := k.exec.RunShell(fmt.Sprintf(
+			`sed -i '/^  hostAliases:$/{N;N;N;/keycloak\.local/d}' %s`, apiServerManifest)); err != nil {
+			return fmt.Errorf("failed to remove hostAliases from apiserver: %w", err)
+		}
+		patched = true
+	}
+
+	if patched {
+		fmt.Fprintln(console(k.ctx), "Waiting for API server to restart without OIDC config...")
+		if err := k.waitForAPIServerRestart(); err != nil {
+			return err
+		}
+	}
+	_, err := k.exec.RunShell("rm -f /etc/kubernetes/pki/auth-config.yaml")
+	return err
+}
+
+// unconfigureGrafanaOAuth reverses configureGrafanaOAuth: it removes the
+// volumes, mounts and env var it patched into the Grafana deployment, then
+// the grafana-ini and keycloak-ca ConfigMaps, and restarts Grafana.
+func (k *Keycloak) unconfigureGrafanaOAuth() error {
+	deployment, err := k.exec.RunShell("kubectl get deployment grafana -n monitoring -o json")
+	if err != nil {
+		return err
+	}
+	patch, err := grafanaOAuthRemoval(deployment)
+	if err != nil {
+		return err
+	}
+	if patch != "" {
+		if err := executor.WriteFileOn(k.exec, "/tmp/grafana-oidc-unpatch.json", patch); err != nil {
+			return err
+		}
+		if _, err := k.exec.RunShell("kubectl patch deployment grafana -n monitoring --type=json --patch-file=/tmp/grafana-oidc-unpatch.json"); err != nil {
+			return err
+		}
+	}
+	if err := deleteResources(k.exec, "monitoring", "configmap/grafana-ini", "configmap/keycloak-ca"); err != nil {
+		return err
+	}
+	_, err = k.exec.RunShell("kubectl rollout restart deployment/grafana -n monitoring")
+	return err
+}
+
+// grafanaOAuthRemoval returns the JSON patch that removes, from the Grafana
+// deployment JSON, what configureGrafanaOAuth added, or "" when none of it is
+// there. Each list is trimmed from its last index down, so a removal never
+// shifts an index still to be removed.
+func grafanaOAuthRemoval(deployment string) (string, error) {
+	type named struct {
+		Name string `json:"name"`
+	}
+	var d struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Volumes    []named `json:"volumes"`
+					Containers []struct {
+						VolumeMounts []named `json:"volumeMounts"`
+						Env          []named `json:"env"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if strings.TrimSpace(deployment) == "" {
+		return "", nil // a dry run reads nothing back
+	}
+	if err := json.Unmarshal([]byte(deployment), &d); err != nil {
+		return "", fmt.Errorf("parse grafana deployment: %w", err)
+	}
+
+	var ops []string
+	remove := func(path string, items []named, names ...string) {
+		for i := len(items) - 1; i >= 0; i-- {
+			for _, name := range names {
+				if items[i].Name == name {
+					ops = append(ops, fmt.Sprintf(`{"op":"remove","path":"%s/%d"}`, path, i))
+				}
+			}
+		}
+	}
+	spec := d.Spec.Template.Spec
+	remove("/spec/template/spec/volumes", spec.Volumes, "grafana-ini", "keycloak-ca")
+	if len(spec.Containers) > 0 {
+		c := spec.Containers[0]
+		remove("/spec/template/spec/containers/0/volumeMounts", c.VolumeMounts, "grafana-ini", "keycloak-ca")
+		remove("/spec/template/spec/containers/0/env", c.Env, "GF_AUTH_GENERIC_OAUTH_CLIENT_SECRET")
+	}
+	if len(ops) == 0 {
+		return "", nil
+	}
+	return "[\n  " + strings.Join(ops, ",\n  ") + "\n]", nil
+}
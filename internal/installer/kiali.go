@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,21 +10,22 @@ import (
 )
 
 type Kiali struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewKiali(cfg *config.Config, exec executor.ShellExecutor) *Kiali {
-	return &Kiali{config: cfg, exec: exec}
+func NewKiali(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Kiali {
+	return &Kiali{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (k *Kiali) Install() error {
-	fmt.Println("Installing Kiali (Service Mesh Observability)...")
+	fmt.Fprintln(console(k.ctx), "Installing Kiali (Service Mesh Observability)...")
 
 	grafanaPassword, err := k.exec.RunShell(
 		"kubectl get secret grafana-admin -n monitoring -o jsonpath='{.data.password}' 2>/dev/null | base64 -d")
 	if err != nil || grafanaPassword == "" {
-		fmt.Println("Warning: could not read grafana-admin secret, Kiali-Grafana integration may require manual auth config")
+		fmt.Fprintln(console(k.ctx), "Warning: could not read grafana-admin secret, Kiali-Grafana integration may require manual auth config")
 		grafanaPassword = ""
 	}
 
@@ -32,19 +34,31 @@ func (k *Kiali) Install() error {
 	}
 
 	if err := k.configureIngress(); err != nil {
-		fmt.Printf("Warning: failed to configure Kiali ingress: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: failed to configure Kiali ingress: %v\n", err)
 	}
 
-	fmt.Println("Waiting for Kiali to be ready...")
+	fmt.Fprintln(console(k.ctx), "Waiting for Kiali to be ready...")
 	if err := k.waitForReady(defaultReadyTimeout); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(console(k.ctx), "Warning: %v\n", err)
 	}
 
-	fmt.Println("Kiali installed successfully!")
+	fmt.Fprintln(console(k.ctx), "Kiali installed successfully!")
 	k.printAccessInfo()
 	return nil
 }
 
+// Uninstall deletes Kiali, its ingress and its RBAC from istio-system.
+func (k *Kiali) Uninstall() error {
+	fmt.Fprintln(console(k.ctx), "Removing Kiali...")
+	if err := deleteResources(k.exec, "istio-system",
+		"virtualservice/kiali", "gateway/kiali-gateway",
+		"deployment/kiali", "service/kiali", "configmap/kiali", "serviceaccount/kiali",
+		"rolebinding/kiali-controlplane", "role/kiali-controlplane"); err != nil {
+		return err
+	}
+	return deleteResources(k.exec, "", "clusterrolebinding/kiali", "clusterrole/kiali")
+}
+
 func (k *Kiali) installKiali(grafanaPassword string) error {
 	tracingEnabled := k.config.Components.Tracing == "otel-tempo"
 	loggingEnabled := k.config.Components.Logging == "loki"
@@ -364,7 +378,7 @@ spec:
         port:
           number: 20001`
 
-	if err := executor.WriteFile("/tmp/kiali-ingress.yaml", ingress); err != nil {
+	if err := executor.WriteFileOn(k.exec, "/tmp/kiali-ingress.yaml", ingress); err != nil {
 		return err
 	}
 
@@ -373,40 +387,37 @@ spec:
 }
 
 func (k *Kiali) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	if err := waitFor(k.ctx, "Kiali", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, _ := k.exec.RunShell("kubectl get pods -n istio-system -l app=kiali -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
-		if out == "Running" {
-			fmt.Println("Kiali is ready!")
-			return nil
-		}
-		fmt.Println("Waiting for Kiali...")
-		time.Sleep(defaultPollInterval)
+		return out == "Running"
+	}); err != nil {
+		return err
 	}
-	return fmt.Errorf("timeout waiting for Kiali")
+	fmt.Fprintln(console(k.ctx), "Kiali is ready!")
+	return nil
 }
 
 func (k *Kiali) printAccessInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("Kiali Access Information")
-	fmt.Println("========================================")
-	fmt.Println("\nService Mesh Observability:")
-	fmt.Println("  1. Add to /etc/hosts:")
-	fmt.Println("     <ingress-ip> kiali.local")
-	fmt.Println("  2. Open: http://kiali.local/kiali")
-	fmt.Println("\nIntegrations active:")
-	fmt.Println("  Metrics  → Prometheus (http://prometheus.monitoring:9090)")
-	fmt.Println("  Dashboards → Grafana (http://grafana.local)")
+	fmt.Fprintln(console(k.ctx), "\n========================================")
+	fmt.Fprintln(console(k.ctx), "Kiali Access Information")
+	fmt.Fprintln(console(k.ctx), "========================================")
+	fmt.Fprintln(console(k.ctx), "\nService Mesh Observability:")
+	fmt.Fprintln(console(k.ctx), "  1. Add to /etc/hosts:")
+	fmt.Fprintln(console(k.ctx), "     <ingress-ip> kiali.local")
+	fmt.Fprintln(console(k.ctx), "  2. Open: http://kiali.local/kiali")
+	fmt.Fprintln(console(k.ctx), "\nIntegrations active:")
+	fmt.Fprintln(console(k.ctx), "  Metrics  → Prometheus (http://prometheus.monitoring:9090)")
+	fmt.Fprintln(console(k.ctx), "  Dashboards → Grafana (http://grafana.local)")
 	if k.config.Components.Tracing == "otel-tempo" {
-		fmt.Println("  Traces   → Grafana Tempo (http://tempo.monitoring:3200)")
+		fmt.Fprintln(console(k.ctx), "  Traces   → Grafana Tempo (http://tempo.monitoring:3200)")
 	}
 	if k.config.Components.Logging == "loki" {
-		fmt.Println("  Logs     → Loki (http://loki.monitoring:3100)")
+		fmt.Fprintln(console(k.ctx), "  Logs     → Loki (http://loki.monitoring:3100)")
 	}
-	fmt.Println("\nFeatures:")
-	fmt.Println("  Service Graph  - visual topology of the mesh")
-	fmt.Println("  Traffic Metrics - RPS, error rate, latency per service")
-	fmt.Println("  Config Validation - detects misconfigured Istio resources")
-	fmt.Println("  Workload Details  - drill down into any pod/deployment")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(k.ctx), "\nFeatures:")
+	fmt.Fprintln(console(k.ctx), "  Service Graph  - visual topology of the mesh")
+	fmt.Fprintln(console(k.ctx), "  Traffic Metrics - RPS, error rate, latency per service")
+	fmt.Fprintln(console(k.ctx), "  Config Validation - detects misconfigured Istio resources")
+	fmt.Fprintln(console(k.ctx), "  Workload Details  - drill down into any pod/deployment")
+	fmt.Fprintln(console(k.ctx), "========================================")
 }
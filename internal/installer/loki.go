@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,42 +10,74 @@ import (
 )
 
 type Loki struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewLoki(cfg *config.Config, exec executor.ShellExecutor) *Loki {
-	return &Loki{config: cfg, exec: exec}
+func NewLoki(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Loki {
+	return &Loki{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (l *Loki) Install() error {
-	fmt.Println("Installing Loki Stack (Loki + Grafana Alloy)...")
+	fmt.Fprintln(console(l.ctx), "Installing Loki Stack (Loki + Grafana Alloy)...")
 
-	fmt.Println("Installing Loki...")
+	fmt.Fprintln(console(l.ctx), "Installing Loki...")
 	if err := l.installLoki(); err != nil {
 		return err
 	}
 
-	fmt.Println("Installing Grafana Alloy (log collector)...")
+	fmt.Fprintln(console(l.ctx), "Installing Grafana Alloy (log collector)...")
 	if err := l.installAlloy(); err != nil {
 		return err
 	}
 
-	fmt.Println("Configuring Loki datasource in Grafana...")
+	fmt.Fprintln(console(l.ctx), "Configuring Loki datasource in Grafana...")
 	if err := l.configureLokiDatasource(); err != nil {
-		fmt.Printf("Warning: Failed to configure Loki datasource: %v\n", err)
+		fmt.Fprintf(console(l.ctx), "Warning: Failed to configure Loki datasource: %v\n", err)
 	}
 
-	fmt.Println("Waiting for Loki stack to be ready...")
+	fmt.Fprintln(console(l.ctx), "Waiting for Loki stack to be ready...")
 	if err := l.waitForReady(shortReadyTimeout); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(console(l.ctx), "Warning: %v\n", err)
 	}
 
-	fmt.Println("Loki stack installed successfully!")
+	fmt.Fprintln(console(l.ctx), "Loki stack installed successfully!")
 	l.printAccessInfo()
 	return nil
 }
 
+// Uninstall deletes Loki (with its volume) and Alloy, and drops the Loki
+// datasource from Grafana, keeping Tempo's if tracing is installed.
+func (l *Loki) Uninstall() error {
+	fmt.Fprintln(console(l.ctx), "Removing Grafana Alloy...")
+	if err := deleteResources(l.exec, "monitoring",
+		"daemonset/alloy", "service/alloy", "configmap/alloy-config", "serviceaccount/alloy"); err != nil {
+		return err
+	}
+	if err := deleteResources(l.exec, "", "clusterrolebinding/alloy", "clusterrole/alloy"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(l.ctx), "Removing Loki...")
+	if err := deleteResources(l.exec, "monitoring",
+		"deployment/loki", "service/loki", "configmap/loki-config", "serviceaccount/loki", "pvc/loki-pvc"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(l.ctx), "Removing Loki datasource from Grafana...")
+	return applyGrafanaDatasources(l.exec, "/tmp/grafana-datasources.yaml", false, installedInMonitoring(l.exec, "tempo"))
+}
+
+// Verify checks that Loki reports ready and that Alloy, which ships the
+// logs, runs on every node.
+func (l *Loki) Verify() error {
+	if err := expectServiceProxy(l.ctx, l.exec, "Loki to be ready", "monitoring", "loki:3100", "/ready", contains("ready")); err != nil {
+		return err
+	}
+	return expectDaemonSet(l.ctx, l.exec, "monitoring", "alloy")
+}
+
 func (l *Loki) installLoki() error {
 	loki := `apiVersion: v1
 kind: PersistentVolumeClaim
@@ -214,7 +247,7 @@ spec:
 	}
 	loki = fmt.Sprintf(loki, lokiVersion, lokiVersion)
 
-	if err := executor.WriteFile("/tmp/loki.yaml", loki); err != nil {
+	if err := executor.WriteFileOn(l.exec, "/tmp/loki.yaml", loki); err != nil {
 		return err
 	}
 
@@ -419,7 +452,7 @@ spec:
 	}
 	alloy = fmt.Sprintf(alloy, alloyVersion, alloyVersion)
 
-	if err := executor.WriteFile("/tmp/alloy.yaml", alloy); err != nil {
+	if err := executor.WriteFileOn(l.exec, "/tmp/alloy.yaml", alloy); err != nil {
 		return err
 	}
 
@@ -428,79 +461,39 @@ spec:
 }
 
 func (l *Loki) configureLokiDatasource() error {
-	datasources := `apiVersion: v1
-kind: ConfigMap
-metadata:
-  name: grafana-datasources
-  namespace: monitoring
-data:
-  datasources.yaml: |
-    apiVersion: 1
-    datasources:
-    - name: Prometheus
-      type: prometheus
-      uid: prometheus-uid
-      access: proxy
-      url: http://prometheus:9090
-      isDefault: true
-    - name: Loki
-      type: loki
-      uid: loki-uid
-      access: proxy
-      url: http://loki:3100
-      isDefault: false`
-
-	if err := executor.WriteFile("/tmp/grafana-datasources.yaml", datasources); err != nil {
-		return err
-	}
-
-	if _, err := l.exec.RunShell("kubectl apply -f /tmp/grafana-datasources.yaml"); err != nil {
-		return err
-	}
-
-	_, err := l.exec.RunShell("kubectl rollout restart deployment/grafana -n monitoring")
-	return err
+	return applyGrafanaDatasources(l.exec, "/tmp/grafana-datasources.yaml", true, false)
 }
 
 func (l *Loki) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	if err := waitFor(l.ctx, "Loki stack (Loki, Alloy)", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, _ := l.exec.RunShell("kubectl get pods -n monitoring -l app=loki -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
 		if out != "Running" {
-			fmt.Println("Waiting for Loki...")
-			time.Sleep(defaultPollInterval)
-			continue
+			return false
 		}
-
 		out, _ = l.exec.RunShell("kubectl get pods -n monitoring -l app=alloy -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
-		if out != "Running" {
-			fmt.Println("Waiting for Alloy...")
-			time.Sleep(defaultPollInterval)
-			continue
-		}
-
-		fmt.Println("Loki stack is ready!")
-		return nil
+		return out == "Running"
+	}); err != nil {
+		return err
 	}
-
-	return fmt.Errorf("timeout waiting for Loki stack")
+	fmt.Fprintln(console(l.ctx), "Loki stack is ready!")
+	return nil
 }
 
 func (l *Loki) printAccessInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("Loki Stack Access Information")
-	fmt.Println("========================================")
-	fmt.Println("\nAccess logs via Grafana:")
-	fmt.Println("  1. Open Grafana (http://grafana.local)")
-	fmt.Println("  2. Go to Explore (left sidebar)")
-	fmt.Println("  3. Select 'Loki' as datasource")
-	fmt.Println("\nAlloy UI (log pipeline status):")
-	fmt.Println("  kubectl port-forward -n monitoring svc/alloy 12345:12345")
-	fmt.Println("  Open: http://localhost:12345")
-	fmt.Println("\nExample LogQL queries:")
-	fmt.Println("  {namespace=\"default\"}")
-	fmt.Println("  {namespace=\"kube-system\"}")
-	fmt.Println("  {pod=~\"nginx.*\"}")
-	fmt.Println("  {container=\"app\"} |= \"error\"")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(l.ctx), "\n========================================")
+	fmt.Fprintln(console(l.ctx), "Loki Stack Access Information")
+	fmt.Fprintln(console(l.ctx), "========================================")
+	fmt.Fprintln(console(l.ctx), "\nAccess logs via Grafana:")
+	fmt.Fprintln(console(l.ctx), "  1. Open Grafana (http://grafana.local)")
+	fmt.Fprintln(console(l.ctx), "  2. Go to Explore (left sidebar)")
+	fmt.Fprintln(console(l.ctx), "  3. Select 'Loki' as datasource")
+	fmt.Fprintln(console(l.ctx), "\nAlloy UI (log pipeline status):")
+	fmt.Fprintln(console(l.ctx), "  kubectl port-forward -n monitoring svc/alloy 12345:12345")
+	fmt.Fprintln(console(l.ctx), "  Open: http://localhost:12345")
+	fmt.Fprintln(console(l.ctx), "\nExample LogQL queries:")
+	fmt.Fprintln(console(l.ctx), "  {namespace=\"default\"}")
+	fmt.Fprintln(console(l.ctx), "  {namespace=\"kube-system\"}")
+	fmt.Fprintln(console(l.ctx), "  {pod=~\"nginx.*\"}")
+	fmt.Fprintln(console(l.ctx), "  {container=\"app\"} |= \"error\"")
+	fmt.Fprintln(console(l.ctx), "========================================")
 }
@@ -1,48 +1,95 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type MetalLB struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewMetalLB(cfg *config.Config, exec executor.ShellExecutor) *MetalLB {
-	return &MetalLB{config: cfg, exec: exec}
+func NewMetalLB(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *MetalLB {
+	return &MetalLB{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (m *MetalLB) Install() error {
 	version := m.config.Versions.MetalLB
 
 	// Install MetalLB
-	fmt.Printf("Installing MetalLB %s...\n", version)
-	manifestURL := fmt.Sprintf("https://raw.githubusercontent.com/metallb/metallb/v%s/config/manifests/metallb-native.yaml", version)
-	if _, err := m.exec.RunShell(fmt.Sprintf("kubectl apply -f %s", manifestURL)); err != nil {
+	fmt.Fprintf(console(m.ctx), "Installing MetalLB %s...\n", version)
+	manifest, err := fetchFile(m.ctx, m.config, m.exec, m.manifest())
+	if err != nil {
+		return err
+	}
+	if _, err := m.exec.RunShell("kubectl apply -f " + manifest); err != nil {
 		return err
 	}
 
 	// Wait for MetalLB controller to be ready
-	fmt.Println("Waiting for MetalLB controller...")
+	fmt.Fprintln(console(m.ctx), "Waiting for MetalLB controller...")
 	if err := m.waitForReady(defaultReadyTimeout); err != nil {
 		return err
 	}
 
 	// Wait for webhook to stabilize
-	fmt.Println("Waiting for MetalLB webhook to stabilize...")
-	time.Sleep(metalLBConfigureDelay)
+	fmt.Fprintln(console(m.ctx), "Waiting for MetalLB webhook to stabilize...")
+	if err := sleep(m.ctx, metalLBConfigureDelay); err != nil {
+		return err
+	}
 
 	// Configure IPAddressPool and L2Advertisement
 	return m.configure()
 }
 
+// Uninstall deletes MetalLB with its CRDs, which takes the address pool
+// along. LoadBalancer Services lose their external IPs.
+func (m *MetalLB) Uninstall() error {
+	fmt.Fprintf(console(m.ctx), "Removing MetalLB %s...\n", m.config.Versions.MetalLB)
+	manifest, err := fetchFile(m.ctx, m.config, m.exec, m.manifest())
+	if err != nil {
+		return err
+	}
+	return deleteManifest(m.exec, manifest)
+}
+
+// Verify creates a throwaway LoadBalancer Service and checks that MetalLB
+// assigns it an address from the pool.
+func (m *MetalLB) Verify() error {
+	const svc = "k8s-provisioner-verify"
+	// A run interrupted mid-check leaves the Service behind.
+	if err := deleteResources(m.exec, "metallb-system", "service/"+svc); err != nil {
+		return err
+	}
+	if _, err := m.exec.RunShell(fmt.Sprintf("kubectl create service loadbalancer %s --tcp=80:80 -n metallb-system", svc)); err != nil {
+		return err
+	}
+	defer func() { _ = deleteResources(m.exec, "metallb-system", "service/"+svc) }()
+	return expect(m.ctx, m.exec, "MetalLB to assign a LoadBalancer IP", loadBalancerIP("metallb-system", svc), nonEmpty)
+}
+
+func (m *MetalLB) manifest() offline.Artifact {
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "metallb-native.yaml",
+		URL:  fmt.Sprintf("https://raw.githubusercontent.com/metallb/metallb/v%s/config/manifests/metallb-native.yaml", m.config.Versions.MetalLB),
+	}
+}
+
+// Artifacts returns the MetalLB manifest; its images are read from it.
+func (m *MetalLB) Artifacts(string) []offline.Artifact {
+	return []offline.Artifact{m.manifest()}
+}
+
 func (m *MetalLB) configure() error {
-	fmt.Println("Configuring MetalLB IP pool...")
+	fmt.Fprintln(console(m.ctx), "Configuring MetalLB IP pool...")
 
 	config := fmt.Sprintf(`apiVersion: metallb.io/v1beta1
 kind: IPAddressPool
@@ -62,47 +109,43 @@ spec:
   ipAddressPools:
   - default-pool`, m.config.Network.MetalLBRange)
 
-	if err := executor.WriteFile("/tmp/metallb-config.yaml", config); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/metallb-config.yaml", config); err != nil {
 		return err
 	}
 
-	// Wait for webhook to be ready
-	fmt.Println("Waiting for MetalLB webhook to be ready...")
-	for i := 1; i <= 30; i++ {
+	// Wait for webhook to be ready. Not fatal on its own: the apply below
+	// retries until the webhook answers.
+	fmt.Fprintln(console(m.ctx), "Waiting for MetalLB webhook to be ready...")
+	if err := retry(m.ctx, "MetalLB controller pod", retryTimes(metalLBAttempts, shortPollInterval), func() error {
 		_, err := m.exec.RunShell("kubectl wait --for=condition=Ready pods -l component=controller -n metallb-system --timeout=10s 2>/dev/null")
-		if err == nil {
-			break
-		}
-		fmt.Printf("Waiting for controller pod... (%d/30)\n", i)
-		time.Sleep(shortPollInterval)
+		return err
+	}); err != nil && m.ctx.Err() != nil {
+		return err
 	}
 
-	// Retry loop for applying config (webhook may not be ready)
-	for i := 1; i <= 30; i++ {
+	// Retry applying config (webhook may not be ready)
+	if err := retry(m.ctx, "MetalLB webhook to accept the config", retryTimes(metalLBAttempts, defaultPollInterval), func() error {
 		_, err := m.exec.RunShell("kubectl apply -f /tmp/metallb-config.yaml 2>/dev/null")
-		if err == nil {
-			fmt.Println("MetalLB configured successfully!")
-			return nil
-		}
-		fmt.Printf("Attempt %d/30 failed, waiting for webhook... (retry in 10s)\n", i)
-		time.Sleep(defaultPollInterval)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to configure MetalLB: %w", err)
 	}
-
-	return fmt.Errorf("failed to configure MetalLB after 30 attempts")
+	fmt.Fprintln(console(m.ctx), "MetalLB configured successfully!")
+	return nil
 }
 
 func (m *MetalLB) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	err := waitFor(m.ctx, "MetalLB controller", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, err := m.exec.RunShell("kubectl get pods -n metallb-system -l component=controller -o jsonpath='{.items[0].status.phase}'")
-		if err == nil && out == "Running" {
-			fmt.Println("MetalLB controller is ready!")
-			return nil
-		}
-		fmt.Println("Waiting for MetalLB controller...")
-		time.Sleep(defaultPollInterval)
+		return err == nil && out == "Running"
+	})
+	if err != nil && m.ctx.Err() == nil {
+		// Don't fail, continue with configuration
+		fmt.Fprintln(console(m.ctx), "Warning: MetalLB controller may still be starting")
+		return nil
 	}
-	// Don't fail, continue with configuration
-	fmt.Println("Warning: MetalLB controller may still be starting")
-	return nil
+	if err == nil {
+		fmt.Fprintln(console(m.ctx), "MetalLB controller is ready!")
+	}
+	return err
 }
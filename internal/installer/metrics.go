@@ -1,34 +1,33 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type MetricsServer struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewMetricsServer(cfg *config.Config, exec executor.ShellExecutor) *MetricsServer {
-	return &MetricsServer{config: cfg, exec: exec}
+func NewMetricsServer(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *MetricsServer {
+	return &MetricsServer{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (m *MetricsServer) Install() error {
-	fmt.Println("Installing Metrics Server...")
+	fmt.Fprintln(console(m.ctx), "Installing Metrics Server...")
 
-	// Pin to a specific version to avoid GitHub redirect issues and ensure
-	// compatibility with Kubernetes 1.32. v0.7.2 is validated against k8s 1.32.
-	version := m.config.Versions.MetricsServer
-	if version == "" {
-		version = "v0.7.2"
+	manifest, err := fetchFile(m.ctx, m.config, m.exec, m.manifest())
+	if err != nil {
+		return err
 	}
-	metricsServerURL := fmt.Sprintf("https://github.com/kubernetes-sigs/metrics-server/releases/download/%s/components.yaml", version)
-
-	if _, err := m.exec.RunShell(fmt.Sprintf("curl -fsSL --connect-timeout 10 --max-time 300 %s -o /tmp/metrics-server.yaml", metricsServerURL)); err != nil {
+	if _, err := m.exec.RunShell("cp " + manifest + " /tmp/metrics-server.yaml"); err != nil {
 		return fmt.Errorf("failed to download metrics-server manifest: %w", err)
 	}
 
@@ -46,38 +45,72 @@ func (m *MetricsServer) Install() error {
 	}
 
 	// Wait for metrics-server to be ready
-	fmt.Println("Waiting for Metrics Server to be ready...")
+	fmt.Fprintln(console(m.ctx), "Waiting for Metrics Server to be ready...")
 	if err := m.waitForReady(shortReadyTimeout); err != nil {
 		return err
 	}
 
-	fmt.Println("Metrics Server installed successfully!")
+	fmt.Fprintln(console(m.ctx), "Metrics Server installed successfully!")
 	m.printAccessInfo()
 	return nil
 }
 
+// Uninstall deletes the metrics-server manifest, APIService included;
+// kubectl top and resource-based HPAs stop working.
+func (m *MetricsServer) Uninstall() error {
+	fmt.Fprintln(console(m.ctx), "Removing Metrics Server...")
+	manifest, err := fetchFile(m.ctx, m.config, m.exec, m.manifest())
+	if err != nil {
+		return err
+	}
+	return deleteManifest(m.exec, manifest)
+}
+
+// Verify checks that the metrics API serves node usage (kubectl top).
+func (m *MetricsServer) Verify() error {
+	return expect(m.ctx, m.exec, "the metrics API to report node usage", "kubectl top nodes --no-headers", nonEmpty)
+}
+
+// manifest is pinned to a specific version to avoid GitHub redirect issues
+// and ensure compatibility with Kubernetes 1.32. v0.7.2 is validated against
+// k8s 1.32.
+func (m *MetricsServer) manifest() offline.Artifact {
+	version := m.config.Versions.MetricsServer
+	if version == "" {
+		version = "v0.7.2"
+	}
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "metrics-server.yaml",
+		URL:  fmt.Sprintf("https://github.com/kubernetes-sigs/metrics-server/releases/download/%s/components.yaml", version),
+	}
+}
+
+// Artifacts returns the metrics-server manifest; its images are read from it.
+func (m *MetricsServer) Artifacts(string) []offline.Artifact {
+	return []offline.Artifact{m.manifest()}
+}
+
 func (m *MetricsServer) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	err := waitFor(m.ctx, "Metrics Server deployment", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, err := m.exec.RunShell("kubectl get deployment metrics-server -n kube-system -o jsonpath='{.status.availableReplicas}' 2>/dev/null")
-		if err == nil && out == "1" {
-			return nil
-		}
-		fmt.Println("Waiting for Metrics Server deployment...")
-		time.Sleep(defaultPollInterval)
+		return err == nil && out == "1"
+	})
+	if err != nil && m.ctx.Err() == nil {
+		fmt.Fprintln(console(m.ctx), "Warning: Metrics Server may still be starting")
+		return nil
 	}
-	fmt.Println("Warning: Metrics Server may still be starting")
-	return nil
+	return err
 }
 
 func (m *MetricsServer) printAccessInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("Metrics Server Installed")
-	fmt.Println("========================================")
-	fmt.Println("\nUsage:")
-	fmt.Println("  kubectl top nodes    # Node CPU/Memory")
-	fmt.Println("  kubectl top pods     # Pod CPU/Memory")
-	fmt.Println("  kubectl top pods -A  # All namespaces")
-	fmt.Println("\nNote: Metrics may take 1-2 minutes to be available")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(m.ctx), "\n========================================")
+	fmt.Fprintln(console(m.ctx), "Metrics Server Installed")
+	fmt.Fprintln(console(m.ctx), "========================================")
+	fmt.Fprintln(console(m.ctx), "\nUsage:")
+	fmt.Fprintln(console(m.ctx), "  kubectl top nodes    # Node CPU/Memory")
+	fmt.Fprintln(console(m.ctx), "  kubectl top pods     # Pod CPU/Memory")
+	fmt.Fprintln(console(m.ctx), "  kubectl top pods -A  # All namespaces")
+	fmt.Fprintln(console(m.ctx), "\nNote: Metrics may take 1-2 minutes to be available")
+	fmt.Fprintln(console(m.ctx), "========================================")
 }
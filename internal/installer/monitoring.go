@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,16 +10,17 @@ import (
 )
 
 type Monitoring struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewMonitoring(cfg *config.Config, exec executor.ShellExecutor) *Monitoring {
-	return &Monitoring{config: cfg, exec: exec}
+func NewMonitoring(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Monitoring {
+	return &Monitoring{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (m *Monitoring) Install() error {
-	fmt.Println("Installing Monitoring Stack (Prometheus + Grafana)...")
+	fmt.Fprintln(console(m.ctx), "Installing Monitoring Stack (Prometheus + Grafana)...")
 
 	// Create monitoring namespace with Istio sidecar injection
 	ns := `apiVersion: v1
@@ -27,7 +29,7 @@ metadata:
   name: monitoring
   labels:
     istio-injection: enabled`
-	if err := executor.WriteFile("/tmp/monitoring-ns.yaml", ns); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/monitoring-ns.yaml", ns); err != nil {
 		return err
 	}
 	if _, err := m.exec.RunShell("kubectl apply -f /tmp/monitoring-ns.yaml"); err != nil {
@@ -35,53 +37,55 @@ metadata:
 	}
 
 	// Create NFS StorageClass and PVs
-	fmt.Println("Creating NFS Storage resources...")
+	fmt.Fprintln(console(m.ctx), "Creating NFS Storage resources...")
 	if err := m.createNFSStorage(); err != nil {
 		return err
 	}
 
 	// Install Prometheus Operator CRDs and Operator
-	fmt.Println("Installing Prometheus Operator...")
+	fmt.Fprintln(console(m.ctx), "Installing Prometheus Operator...")
 	if err := m.installPrometheusOperator(); err != nil {
 		return err
 	}
 
 	// Wait for CRDs to be established
-	fmt.Println("Waiting for CRDs to be established...")
-	time.Sleep(monitoringInitDelay)
+	fmt.Fprintln(console(m.ctx), "Waiting for CRDs to be established...")
+	if err := sleep(m.ctx, monitoringInitDelay); err != nil {
+		return err
+	}
 
 	// Install Prometheus instance
-	fmt.Println("Installing Prometheus...")
+	fmt.Fprintln(console(m.ctx), "Installing Prometheus...")
 	if err := m.installPrometheus(); err != nil {
 		return err
 	}
 
 	// Install Grafana
-	fmt.Println("Installing Grafana...")
+	fmt.Fprintln(console(m.ctx), "Installing Grafana...")
 	if err := m.installGrafana(); err != nil {
 		return err
 	}
 
 	// Install Node Exporter
-	fmt.Println("Installing Node Exporter...")
+	fmt.Fprintln(console(m.ctx), "Installing Node Exporter...")
 	if err := m.installNodeExporter(); err != nil {
 		return err
 	}
 
 	// Install kube-state-metrics
-	fmt.Println("Installing kube-state-metrics...")
+	fmt.Fprintln(console(m.ctx), "Installing kube-state-metrics...")
 	if err := m.installKubeStateMetrics(); err != nil {
 		return err
 	}
 
 	// Install Alertmanager
-	fmt.Println("Installing Alertmanager...")
+	fmt.Fprintln(console(m.ctx), "Installing Alertmanager...")
 	if err := m.installAlertmanager(); err != nil {
 		return err
 	}
 
 	// Wait for all components to be ready
-	fmt.Println("Waiting for monitoring stack to be ready...")
+	fmt.Fprintln(console(m.ctx), "Waiting for monitoring stack to be ready...")
 	if err := m.waitForReady(defaultReadyTimeout); err != nil {
 		return err
 	}
@@ -90,80 +94,112 @@ metadata:
 	// monitoring namespace and need the Prometheus Operator CRDs installed above;
 	// cert-manager itself is installed earlier in the workload order, so its
 	// Service already exists by now.
-	fmt.Println("Creating cert-manager ServiceMonitor + PrometheusRule...")
+	fmt.Fprintln(console(m.ctx), "Creating cert-manager ServiceMonitor + PrometheusRule...")
 	if err := m.installCertManagerMonitoring(); err != nil {
-		fmt.Printf("Warning: Failed to create cert-manager monitoring resources: %v\n", err)
+		fmt.Fprintf(console(m.ctx), "Warning: Failed to create cert-manager monitoring resources: %v\n", err)
 	}
 
 	// Create Istio Gateways and scrape configs if Istio is enabled
 	if m.config.Components.ServiceMesh == "istio" {
-		fmt.Println("Creating Istio Gateways for monitoring...")
+		fmt.Fprintln(console(m.ctx), "Creating Istio Gateways for monitoring...")
 		if err := m.createMonitoringGateways(); err != nil {
-			fmt.Printf("Warning: Failed to create monitoring gateways: %v\n", err)
+			fmt.Fprintf(console(m.ctx), "Warning: Failed to create monitoring gateways: %v\n", err)
 		}
-		fmt.Println("Creating Istio scrape targets (PodMonitor + ServiceMonitor)...")
+		fmt.Fprintln(console(m.ctx), "Creating Istio scrape targets (PodMonitor + ServiceMonitor)...")
 		if err := m.installIstioMonitoring(); err != nil {
-			fmt.Printf("Warning: Failed to create Istio monitoring resources: %v\n", err)
+			fmt.Fprintf(console(m.ctx), "Warning: Failed to create Istio monitoring resources: %v\n", err)
 		}
 	}
 
-	fmt.Println("Monitoring stack installed successfully!")
+	fmt.Fprintln(console(m.ctx), "Monitoring stack installed successfully!")
 	m.printAccessInfo()
 	return nil
 }
 
+// Uninstall deletes the monitoring namespace (Prometheus, Grafana,
+// Alertmanager, exporters and their gateways), the Prometheus Operator with
+// its CRDs, and the cluster-scoped RBAC, StorageClass and volumes. Data on
+// the NFS export and the Grafana credentials file are kept. VSO's Grafana
+// secrets live in the namespace too: re-run "provision workloads --only vso"
+// before reinstalling.
+func (m *Monitoring) Uninstall() error {
+	fmt.Fprintln(console(m.ctx), "Deleting monitoring namespace...")
+	if err := deleteNamespace(m.exec, "monitoring"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(m.ctx), "Removing Prometheus Operator...")
+	operator, err := fetchFile(m.ctx, m.config, m.exec, m.operatorBundle())
+	if err != nil {
+		return err
+	}
+	if _, err := m.exec.RunShell("sed 's/namespace: default/namespace: monitoring/g' " + operator + " | kubectl delete --ignore-not-found -f -"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(m.ctx), "Removing monitoring RBAC and storage...")
+	if err := deleteResources(m.exec, "",
+		"clusterrolebinding/prometheus", "clusterrole/prometheus",
+		"clusterrolebinding/kube-state-metrics", "clusterrole/kube-state-metrics"); err != nil {
+		return err
+	}
+	return deleteResources(m.exec, "", "pv/prometheus-pv", "pv/grafana-pv", "pv/loki-pv", "storageclass/nfs-storage")
+}
+
+// Verify checks that Prometheus and Grafana answer their health endpoints.
+func (m *Monitoring) Verify() error {
+	if err := expectServiceProxy(m.ctx, m.exec, "Prometheus to be ready", "monitoring", "prometheus:9090", "/-/ready", contains("Ready")); err != nil {
+		return err
+	}
+	return expectServiceProxy(m.ctx, m.exec, "Grafana to be healthy", "monitoring", "grafana:3000", "/api/health", contains(`"ok"`))
+}
+
 func (m *Monitoring) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	err := waitFor(m.ctx, "monitoring stack (Prometheus Operator, Grafana)", pollUntil(timeout, defaultPollInterval), func() bool {
 		// Check Prometheus Operator
 		out, _ := m.exec.RunShell("kubectl get pods -n monitoring -l app.kubernetes.io/name=prometheus-operator -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
 		if out != "Running" {
-			fmt.Println("Waiting for Prometheus Operator...")
-			time.Sleep(defaultPollInterval)
-			continue
+			return false
 		}
-
 		// Check Grafana
 		out, _ = m.exec.RunShell("kubectl get pods -n monitoring -l app=grafana -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
-		if out != "Running" {
-			fmt.Println("Waiting for Grafana...")
-			time.Sleep(defaultPollInterval)
-			continue
-		}
-
-		fmt.Println("Monitoring stack is ready!")
+		return out == "Running"
+	})
+	if err != nil && m.ctx.Err() == nil {
+		fmt.Fprintln(console(m.ctx), "Warning: Some monitoring components may still be starting")
 		return nil
 	}
-
-	fmt.Println("Warning: Some monitoring components may still be starting")
-	return nil
+	if err == nil {
+		fmt.Fprintln(console(m.ctx), "Monitoring stack is ready!")
+	}
+	return err
 }
 
 func (m *Monitoring) printAccessInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("Monitoring Stack Access Information")
-	fmt.Println("========================================")
-	fmt.Println("\n1. Get Istio Ingress IP:")
-	fmt.Println("   INGRESS_IP=$(kubectl get svc -n istio-system istio-ingressgateway -o jsonpath='{.status.loadBalancer.ingress[0].ip}')")
-	fmt.Println("\n2. Add to /etc/hosts:")
-	fmt.Println("   echo \"$INGRESS_IP grafana.local prometheus.local alertmanager.local\" | sudo tee -a /etc/hosts")
-	fmt.Println("\n3. Access:")
-	fmt.Println("   - Grafana:      http://grafana.local")
-	fmt.Println("   - Prometheus:   http://prometheus.local")
-	fmt.Println("   - Alertmanager: http://alertmanager.local")
-	fmt.Println("\nGrafana Credentials:")
-	fmt.Println("  User: admin")
+	fmt.Fprintln(console(m.ctx), "\n========================================")
+	fmt.Fprintln(console(m.ctx), "Monitoring Stack Access Information")
+	fmt.Fprintln(console(m.ctx), "========================================")
+	fmt.Fprintln(console(m.ctx), "\n1. Get Istio Ingress IP:")
+	fmt.Fprintln(console(m.ctx), "   INGRESS_IP=$(kubectl get svc -n istio-system istio-ingressgateway -o jsonpath='{.status.loadBalancer.ingress[0].ip}')")
+	fmt.Fprintln(console(m.ctx), "\n2. Add to /etc/hosts:")
+	fmt.Fprintln(console(m.ctx), "   echo \"$INGRESS_IP grafana.local prometheus.local alertmanager.local\" | sudo tee -a /etc/hosts")
+	fmt.Fprintln(console(m.ctx), "\n3. Access:")
+	fmt.Fprintln(console(m.ctx), "   - Grafana:      http://grafana.local")
+	fmt.Fprintln(console(m.ctx), "   - Prometheus:   http://prometheus.local")
+	fmt.Fprintln(console(m.ctx), "   - Alertmanager: http://alertmanager.local")
+	fmt.Fprintln(console(m.ctx), "\nGrafana Credentials:")
+	fmt.Fprintln(console(m.ctx), "  User: admin")
 	if m.config.Vault.Enabled {
-		fmt.Println("  Password: (stored in Vault)")
-		fmt.Println("  Retrieve: k8s-provisioner vault get-secret k8s-provisioner/api-keys")
-		fmt.Println("\nAlertmanager Config:")
-		fmt.Println("  Config: (stored in Vault as 'alertmanager_config')")
-		fmt.Println("  Store:  vault kv put secret/k8s-provisioner/api-keys alertmanager_config=@alertmanager.yaml")
+		fmt.Fprintln(console(m.ctx), "  Password: (stored in Vault)")
+		fmt.Fprintln(console(m.ctx), "  Retrieve: k8s-provisioner vault get-secret k8s-provisioner/api-keys")
+		fmt.Fprintln(console(m.ctx), "\nAlertmanager Config:")
+		fmt.Fprintln(console(m.ctx), "  Config: (stored in Vault as 'alertmanager_config')")
+		fmt.Fprintln(console(m.ctx), "  Store:  vault kv put secret/k8s-provisioner/api-keys alertmanager_config=@alertmanager.yaml")
 	} else {
-		fmt.Println("  Password: (random — shown above during install)")
-		fmt.Println("\nAlertmanager Config:")
-		fmt.Println("  Default receiver: null (no notifications)")
-		fmt.Println("  To configure: kubectl edit secret alertmanager-alertmanager -n monitoring")
+		fmt.Fprintln(console(m.ctx), "  Password: (random — shown above during install)")
+		fmt.Fprintln(console(m.ctx), "\nAlertmanager Config:")
+		fmt.Fprintln(console(m.ctx), "  Default receiver: null (no notifications)")
+		fmt.Fprintln(console(m.ctx), "  To configure: kubectl edit secret alertmanager-alertmanager -n monitoring")
 	}
-	fmt.Println("========================================")
+	fmt.Fprintln(console(m.ctx), "========================================")
 }
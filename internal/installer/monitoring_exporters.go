@@ -121,7 +121,7 @@ spec:
 	}
 	nodeExporter = fmt.Sprintf(nodeExporter, neVersion)
 
-	if err := executor.WriteFile("/tmp/node-exporter.yaml", nodeExporter); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/node-exporter.yaml", nodeExporter); err != nil {
 		return err
 	}
 
@@ -283,7 +283,7 @@ spec:
 	}
 	ksm = fmt.Sprintf(ksm, ksmVersion)
 
-	if err := executor.WriteFile("/tmp/kube-state-metrics.yaml", ksm); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/kube-state-metrics.yaml", ksm); err != nil {
 		return err
 	}
 
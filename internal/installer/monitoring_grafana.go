@@ -134,7 +134,7 @@ spec:
 	}
 	grafana = fmt.Sprintf(grafana, version, version)
 
-	if err := executor.WriteFile("/tmp/grafana.yaml", grafana); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/grafana.yaml", grafana); err != nil {
 		return err
 	}
 
@@ -147,6 +147,11 @@ spec:
 // disabled or the key is missing. A generated password is printed once, since it
 // is not persisted anywhere in that mode.
 func (m *Monitoring) resolveGrafanaPassword() (string, error) {
+	// A dry run neither reads Vault (whose password would land in the rendered
+	// script) nor writes the credentials file.
+	if isDryRun(m.ctx) {
+		return dryRunPassword, nil
+	}
 	resolver := NewSecretResolver(m.config)
 	if resolver.Enabled() {
 		if pw := resolver.Resolve("Grafana password", "", "grafana_admin_password"); pw != "" {
@@ -157,16 +162,16 @@ func (m *Monitoring) resolveGrafanaPassword() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("generate grafana password: %w", err)
 	}
-	fmt.Println("Warning: Grafana admin password not in Vault — generated a random one.")
+	fmt.Fprintln(console(m.ctx), "Warning: Grafana admin password not in Vault — generated a random one.")
 	// Prefer a 0600 file over stdout so the credential doesn't end up in captured
 	// provisioning/CI logs (cluster-up.sh redirects stdout to *.out.txt). Fall back
 	// to stdout only if the file can't be written — otherwise it would be lost.
 	contents := fmt.Sprintf("grafana admin: admin / %s\n", pw)
 	if err := os.WriteFile(grafanaCredsFile, []byte(contents), 0600); err != nil {
-		fmt.Printf("Warning: could not write %s (%v) — printing once instead.\n", grafanaCredsFile, err)
-		fmt.Printf("  SAVE THIS NOW (not persisted): admin / %s\n", pw)
+		fmt.Fprintf(console(m.ctx), "Warning: could not write %s (%v) — printing once instead.\n", grafanaCredsFile, err)
+		fmt.Fprintf(console(m.ctx), "  SAVE THIS NOW (not persisted): admin / %s\n", pw)
 	} else {
-		fmt.Printf("  Grafana admin password written to %s (mode 0600) — back it up; not stored in Vault.\n", grafanaCredsFile)
+		fmt.Fprintf(console(m.ctx), "  Grafana admin password written to %s (mode 0600) — back it up; not stored in Vault.\n", grafanaCredsFile)
 	}
 	return pw, nil
 }
@@ -174,7 +179,7 @@ func (m *Monitoring) resolveGrafanaPassword() (string, error) {
 func (m *Monitoring) createGrafanaSecret(password string) error {
 	// Skip if already managed by Vault Secrets Operator
 	if out, _ := m.exec.RunShell("kubectl get secret grafana-admin -n monitoring -o name 2>/dev/null"); out != "" {
-		fmt.Println("Grafana admin secret already synced by Vault Secrets Operator, skipping direct creation")
+		fmt.Fprintln(console(m.ctx), "Grafana admin secret already synced by Vault Secrets Operator, skipping direct creation")
 		return nil
 	}
 	// Build the Secret as a manifest and pipe it via stdin so the password is
@@ -191,6 +196,6 @@ stringData:
 	if _, err := m.exec.RunShellWithStdin("kubectl apply -f -", manifest); err != nil {
 		return fmt.Errorf("failed to create grafana-admin secret: %w", err)
 	}
-	fmt.Println("Grafana admin secret created")
+	fmt.Fprintln(console(m.ctx), "Grafana admin secret created")
 	return nil
 }
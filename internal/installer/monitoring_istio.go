@@ -85,7 +85,7 @@ spec:
         port:
           number: 9093`
 
-	if err := executor.WriteFile("/tmp/monitoring-gateway.yaml", gateway); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/monitoring-gateway.yaml", gateway); err != nil {
 		return err
 	}
 
@@ -132,7 +132,7 @@ spec:
   - port: http-monitoring
     interval: 15s`
 
-	if err := executor.WriteFile("/tmp/istio-monitoring.yaml", resources); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/istio-monitoring.yaml", resources); err != nil {
 		return err
 	}
 	_, err := m.exec.RunShell("kubectl apply -f /tmp/istio-monitoring.yaml")
@@ -201,7 +201,7 @@ spec:
         summary: "Certificado não está pronto"
         description: "O certificado {{ $labels.name }} no namespace {{ $labels.namespace }} não está no estado Ready."`
 
-	if err := executor.WriteFile("/tmp/cert-manager-monitoring.yaml", resources); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/cert-manager-monitoring.yaml", resources); err != nil {
 		return err
 	}
 	_, err := m.exec.RunShell("kubectl apply -f /tmp/cert-manager-monitoring.yaml")
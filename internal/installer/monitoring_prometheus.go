@@ -2,33 +2,49 @@ package installer
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
-func (m *Monitoring) installPrometheusOperator() error {
-	// Using prometheus-operator bundle
+// operatorBundle is the prometheus-operator bundle (CRDs, operator, RBAC).
+func (m *Monitoring) operatorBundle() offline.Artifact {
 	promOpVersion := m.config.Versions.PrometheusOperator
 	if promOpVersion == "" {
 		promOpVersion = "v0.90.1"
 	}
-	bundleURL := fmt.Sprintf("https://raw.githubusercontent.com/prometheus-operator/prometheus-operator/%s/bundle.yaml", promOpVersion)
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "prometheus-operator-bundle.yaml",
+		URL:  fmt.Sprintf("https://raw.githubusercontent.com/prometheus-operator/prometheus-operator/%s/bundle.yaml", promOpVersion),
+	}
+}
+
+// Artifacts returns the prometheus-operator bundle. The Prometheus and
+// Alertmanager images the operator defaults are not named anywhere; pass them
+// to `bundle --image`.
+func (m *Monitoring) Artifacts(string) []offline.Artifact {
+	return []offline.Artifact{m.operatorBundle()}
+}
 
+func (m *Monitoring) installPrometheusOperator() error {
 	// Download and modify to use monitoring namespace
-	if _, err := m.exec.RunShell(fmt.Sprintf("curl -sL --connect-timeout 10 --max-time 300 %s | sed 's/namespace: default/namespace: monitoring/g' | kubectl apply --server-side -f -", bundleURL)); err != nil {
+	operator, err := fetchFile(m.ctx, m.config, m.exec, m.operatorBundle())
+	if err != nil {
+		return err
+	}
+	if _, err := m.exec.RunShell("sed 's/namespace: default/namespace: monitoring/g' " + operator + " | kubectl apply --server-side -f -"); err != nil {
 		return err
 	}
 
-	// Wait for operator to be ready
-	for i := 0; i < 30; i++ {
+	// Wait for operator to be ready. Not fatal: the Prometheus CR applied next
+	// is reconciled once the operator comes up.
+	if err := waitFor(m.ctx, "Prometheus Operator", retryTimes(prometheusAttempts, shortPollInterval), func() bool {
 		out, err := m.exec.RunShell("kubectl get pods -n monitoring -l app.kubernetes.io/name=prometheus-operator -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
-		if err == nil && out == "Running" {
-			return nil
-		}
-		time.Sleep(shortPollInterval)
+		return err == nil && out == "Running"
+	}); err != nil && m.ctx.Err() != nil {
+		return err
 	}
-
 	return nil
 }
 
@@ -118,7 +134,7 @@ spec:
   selector:
     prometheus: prometheus`
 
-	if err := executor.WriteFile("/tmp/prometheus.yaml", prometheus); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/prometheus.yaml", prometheus); err != nil {
 		return err
 	}
 
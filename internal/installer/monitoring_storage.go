@@ -68,7 +68,7 @@ spec:
     server: %s
     path: %s/pv03`, nfsServer, nfsPath, nfsServer, nfsPath, nfsServer, nfsPath)
 
-	if err := executor.WriteFile("/tmp/nfs-storage.yaml", storage); err != nil {
+	if err := executor.WriteFileOn(m.exec, "/tmp/nfs-storage.yaml", storage); err != nil {
 		return err
 	}
 
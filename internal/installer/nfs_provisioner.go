@@ -1,54 +1,71 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type NFSProvisioner struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewNFSProvisioner(cfg *config.Config, exec executor.ShellExecutor) *NFSProvisioner {
-	return &NFSProvisioner{config: cfg, exec: exec}
+func NewNFSProvisioner(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *NFSProvisioner {
+	return &NFSProvisioner{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (n *NFSProvisioner) Install() error {
-	fmt.Println("Installing NFS Storage Provisioner...")
+	fmt.Fprintln(console(n.ctx), "Installing NFS Storage Provisioner...")
 
 	// Install Helm if not present
-	if err := n.installHelm(); err != nil {
+	if err := withHelm(n.installHelm); err != nil {
 		return err
 	}
 
 	// Create static StorageClass (for manual PV/PVC)
-	fmt.Println("Creating nfs-static StorageClass...")
+	fmt.Fprintln(console(n.ctx), "Creating nfs-static StorageClass...")
 	if err := n.createStaticStorageClass(); err != nil {
 		return err
 	}
 
 	// Install dynamic provisioner
-	fmt.Println("Installing NFS dynamic provisioner...")
+	fmt.Fprintln(console(n.ctx), "Installing NFS dynamic provisioner...")
 	if err := n.installDynamicProvisioner(); err != nil {
 		return err
 	}
 
 	// Wait for provisioner to be ready
-	fmt.Println("Waiting for NFS provisioner to be ready...")
+	fmt.Fprintln(console(n.ctx), "Waiting for NFS provisioner to be ready...")
 	if err := n.waitForReady(defaultReadyTimeout); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(console(n.ctx), "Warning: %v\n", err)
 	}
 
-	fmt.Println("NFS Storage Provisioner installed successfully!")
+	fmt.Fprintln(console(n.ctx), "NFS Storage Provisioner installed successfully!")
 	n.printStorageInfo()
 	return nil
 }
 
+// Uninstall removes the dynamic provisioner and both StorageClasses. Bound
+// volumes and their data on the NFS export are kept.
+func (n *NFSProvisioner) Uninstall() error {
+	fmt.Fprintln(console(n.ctx), "Removing NFS dynamic provisioner...")
+	if err := helmUninstall(n.exec, "nfs-provisioner", "nfs-provisioner"); err != nil {
+		return err
+	}
+	if err := deleteNamespace(n.exec, "nfs-provisioner"); err != nil {
+		return err
+	}
+	fmt.Fprintln(console(n.ctx), "Removing nfs-static StorageClass...")
+	return deleteResources(n.exec, "", "storageclass/nfs-static")
+}
+
 func (n *NFSProvisioner) createStaticStorageClass() error {
 	staticSC := `apiVersion: storage.k8s.io/v1
 kind: StorageClass
@@ -58,7 +75,7 @@ provisioner: kubernetes.io/no-provisioner
 volumeBindingMode: WaitForFirstConsumer
 reclaimPolicy: Retain`
 
-	if err := executor.WriteFile("/tmp/nfs-static-sc.yaml", staticSC); err != nil {
+	if err := executor.WriteFileOn(n.exec, "/tmp/nfs-static-sc.yaml", staticSC); err != nil {
 		return err
 	}
 
@@ -86,19 +103,24 @@ func (n *NFSProvisioner) installDynamicProvisioner() error {
 	}
 
 	// Add Helm repo
-	if _, err := n.exec.RunShell("helm repo add nfs-subdir-external-provisioner https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner"); err != nil {
-		return err
-	}
-	if _, err := n.exec.RunShell("helm repo update"); err != nil {
-		return err
+	if !n.config.Offline.Enabled() {
+		if err := withHelm(func() error {
+			if _, err := n.exec.RunShell("helm repo add nfs-subdir-external-provisioner https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner"); err != nil {
+				return err
+			}
+			_, err := n.exec.RunShell("helm repo update")
+			return err
+		}); err != nil {
+			return err
+		}
 	}
 
 	// Create namespace
 	_, _ = n.exec.RunShell("kubectl create namespace nfs-provisioner 2>/dev/null || true")
 
 	// Install the provisioner (single line to avoid shell interpretation issues)
-	helmCmd := fmt.Sprintf("helm upgrade --install nfs-provisioner nfs-subdir-external-provisioner/nfs-subdir-external-provisioner --namespace nfs-provisioner --set nfs.server=%s --set nfs.path=%s --set storageClass.name=nfs-dynamic --set storageClass.defaultClass=%t --set storageClass.reclaimPolicy=Delete --set storageClass.archiveOnDelete=true",
-		nfsIP, nfsPath, n.config.Storage.DefaultDynamic)
+	helmCmd := fmt.Sprintf("helm upgrade --install nfs-provisioner %s --namespace nfs-provisioner --set nfs.server=%s --set nfs.path=%s --set storageClass.name=nfs-dynamic --set storageClass.defaultClass=%t --set storageClass.reclaimPolicy=Delete --set storageClass.archiveOnDelete=true",
+		chartRef(n.config, "nfs-subdir-external-provisioner", nfsChart), nfsIP, nfsPath, n.config.Storage.DefaultDynamic)
 
 	return n.exec.RunShellWithOutput(helmCmd)
 }
@@ -116,15 +138,21 @@ func (n *NFSProvisioner) resolveNFSServer(server string) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+var nfsChart = offline.Artifact{Kind: offline.Chart, Name: "nfs-subdir-external-provisioner", URL: "https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner"}
+
+// Artifacts returns helm and the nfs-subdir-external-provisioner chart.
+func (n *NFSProvisioner) Artifacts(arch string) []offline.Artifact {
+	return append(HelmArtifacts(arch), nfsChart)
+}
+
 func (n *NFSProvisioner) installHelm() error {
 	// Check if helm is already installed
 	if _, err := n.exec.RunShell("which helm"); err == nil {
 		return nil
 	}
 
-	fmt.Println("Installing Helm...")
-	installCmd := "curl -fsSL --connect-timeout 10 --max-time 300 https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3 | bash"
-	if err := n.exec.RunShellWithOutput(installCmd); err != nil {
+	fmt.Fprintln(console(n.ctx), "Installing Helm...")
+	if err := installHelmBinary(n.ctx, n.config, n.exec); err != nil {
 		return fmt.Errorf("failed to install Helm: %w", err)
 	}
 
@@ -132,30 +160,25 @@ func (n *NFSProvisioner) installHelm() error {
 }
 
 func (n *NFSProvisioner) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return waitFor(n.ctx, "NFS provisioner", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, err := n.exec.RunShell("kubectl get pods -n nfs-provisioner -l app=nfs-subdir-external-provisioner -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
-		if err == nil && out == "Running" {
-			return nil
-		}
-		time.Sleep(defaultPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for NFS provisioner")
+		return err == nil && out == "Running"
+	})
 }
 
 func (n *NFSProvisioner) printStorageInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("NFS Storage Configuration")
-	fmt.Println("========================================")
-	fmt.Println("\nStorageClasses available:")
-	fmt.Println("  - nfs-dynamic: Automatic PV provisioning")
-	fmt.Println("  - nfs-static:  Manual PV/PVC creation")
-	fmt.Println("\nUsage examples:")
-	fmt.Println("\n  Dynamic (automatic):")
-	fmt.Println("    spec:")
-	fmt.Println("      storageClassName: nfs-dynamic")
-	fmt.Println("\n  Static (manual PV required):")
-	fmt.Println("    spec:")
-	fmt.Println("      storageClassName: nfs-static")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(n.ctx), "\n========================================")
+	fmt.Fprintln(console(n.ctx), "NFS Storage Configuration")
+	fmt.Fprintln(console(n.ctx), "========================================")
+	fmt.Fprintln(console(n.ctx), "\nStorageClasses available:")
+	fmt.Fprintln(console(n.ctx), "  - nfs-dynamic: Automatic PV provisioning")
+	fmt.Fprintln(console(n.ctx), "  - nfs-static:  Manual PV/PVC creation")
+	fmt.Fprintln(console(n.ctx), "\nUsage examples:")
+	fmt.Fprintln(console(n.ctx), "\n  Dynamic (automatic):")
+	fmt.Fprintln(console(n.ctx), "    spec:")
+	fmt.Fprintln(console(n.ctx), "      storageClassName: nfs-dynamic")
+	fmt.Fprintln(console(n.ctx), "\n  Static (manual PV required):")
+	fmt.Fprintln(console(n.ctx), "    spec:")
+	fmt.Fprintln(console(n.ctx), "      storageClassName: nfs-static")
+	fmt.Fprintln(console(n.ctx), "========================================")
 }
@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/fetch"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// Bundler is implemented by installers that download something — manifests,
+// charts, binaries or container images — so `k8s-provisioner bundle` can
+// collect it for an offline install. arch is the architecture of the nodes,
+// amd64 or arm64. With offline.bundle set, Install reads each artifact from
+// the unpacked bundle instead of the network.
+type Bundler interface {
+	Installer
+	Artifacts(arch string) []offline.Artifact
+}
+
+// fetchFile returns the path on the node of the File artifact a, verified
+// against its pinned checksum: downloaded into the cache online, in the
+// unpacked bundle offline (see fetch.Fetcher).
+func fetchFile(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor, a offline.Artifact) (string, error) {
+	f := fetch.Fetcher{Exec: exec, Config: cfg, Out: console(ctx), DryRun: isDryRun(ctx)}
+	return f.Fetch(a)
+}
+
+// chartRef is the chart argument of `helm upgrade --install` for the Chart
+// artifact a, from the Helm repository added as repo online.
+func chartRef(cfg *config.Config, repo string, a offline.Artifact) string {
+	if cfg.Offline.Enabled() {
+		return a.Path()
+	}
+	ref := repo + "/" + a.Name
+	if a.Version != "" {
+		ref += " --version " + a.Version
+	}
+	return ref
+}
+
+// helmArtifact is the Helm release for arch.
+func helmArtifact(arch string) offline.Artifact {
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "helm.tar.gz",
+		URL:  fmt.Sprintf("https://get.helm.sh/helm-%s-linux-%s.tar.gz", offline.HelmVersion, arch),
+	}
+}
+
+// helmMu serializes changes to the node's helm setup, the binary and the
+// repository list, which workload steps running in parallel share.
+var helmMu sync.Mutex
+
+// withHelm runs fn, which installs helm or adds a repository, holding helmMu.
+func withHelm(fn func() error) error {
+	helmMu.Lock()
+	defer helmMu.Unlock()
+	return fn()
+}
+
+// installHelmBinary puts helm in /usr/local/bin from the verified release for
+// the node's architecture. Call it through withHelm.
+func installHelmBinary(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) error {
+	arch, err := fetch.Arch(exec, isDryRun(ctx))
+	if err != nil {
+		return err
+	}
+	tarball, err := fetchFile(ctx, cfg, exec, helmArtifact(arch))
+	if err != nil {
+		return err
+	}
+	_, err = exec.RunShell(fmt.Sprintf("tar -xzf %s -C /usr/local/bin --strip-components=1 --wildcards '*/helm'", tarball))
+	return err
+}
+
+// HelmArtifacts lists what every chart-based install downloads besides its
+// chart: the helm binary itself.
+func HelmArtifacts(arch string) []offline.Artifact {
+	return []offline.Artifact{helmArtifact(arch)}
+}
+
+// images lists refs as Image artifacts.
+func images(refs ...string) []offline.Artifact {
+	var artifacts []offline.Artifact
+	for _, ref := range refs {
+		artifacts = append(artifacts, offline.Artifact{Kind: offline.Image, Name: ref})
+	}
+	return artifacts
+}
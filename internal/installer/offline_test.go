@@ -0,0 +1,103 @@
+package installer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// testSum is a well-formed checksum the fake shell accepts.
+const testSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// TestOffline_InstallsFromTheBundle verifies that with offline.bundle set,
+// manifests, charts and helm itself come from the unpacked bundle, checked
+// against their pins, and no Helm repository is contacted.
+func TestOffline_InstallsFromTheBundle(t *testing.T) {
+	exec := &fakeShell{
+		outputs: map[string]string{"helm version": "", "uname -m": "x86_64"},
+		errs:    map[string]error{"helm version": assert.AnError},
+	}
+	cfg := &config.Config{Offline: config.OfflineConfig{Bundle: "/vagrant/k8s-bundle.tar.gz"}}
+	metallb := NewMetalLB(context.Background(), cfg, exec)
+	cfg.Checksums = map[string]string{
+		helmArtifact("amd64").URL: testSum,
+		metallb.manifest().URL:    testSum,
+	}
+
+	require.NoError(t, NewKEDA(context.Background(), cfg, exec).installHelm())
+	assert.Equal(t, "/opt/k8s-offline/charts/keda.tgz", chartRef(cfg, "kedacore", kedaChart))
+	assert.Contains(t, exec.calls, "echo '"+testSum+"  /opt/k8s-offline/files/helm.tar.gz' | sha256sum -c --status -")
+	assert.Contains(t, exec.calls, "tar -xzf /opt/k8s-offline/files/helm.tar.gz -C /usr/local/bin --strip-components=1 --wildcards '*/helm'")
+
+	require.NoError(t, metallb.Uninstall())
+	assert.Contains(t, exec.calls, "kubectl delete -f /opt/k8s-offline/files/metallb-native.yaml --ignore-not-found")
+	for _, c := range exec.calls {
+		assert.False(t, strings.HasPrefix(c, "helm repo") || strings.Contains(c, "curl"), c)
+	}
+}
+
+// TestArtifacts_Online verifies the chart reference online and that
+// chart-based installers bundle helm for the nodes' architecture.
+func TestArtifacts_Online(t *testing.T) {
+	cfg := &config.Config{Versions: config.VersionsConfig{Cilium: "v1.18.2", Karpor: "0.6.4"}}
+
+	karpor := NewKarpor(context.Background(), cfg, &fakeShell{})
+	assert.Equal(t, "kusionstack/karpor --version 0.6.4", chartRef(cfg, "kusionstack", karpor.chart()))
+
+	artifacts := NewCilium(context.Background(), cfg, &fakeShell{}).Artifacts("arm64")
+	require.Len(t, artifacts, 2)
+	assert.Equal(t, offline.Artifact{Kind: offline.File, Name: "helm.tar.gz", URL: "https://get.helm.sh/helm-" + offline.HelmVersion + "-linux-arm64.tar.gz"}, artifacts[0])
+	assert.Equal(t, "1.18.2", artifacts[1].Version)
+	assert.Contains(t, artifacts[1].Values, "hubble.ui.enabled=true")
+}
+
+// TestIstioctl_FromVerifiedRelease verifies that istioctl is unpacked from
+// the pinned release tarball, downloaded into the cache, instead of piping
+// the downloadIstio script into a shell.
+func TestIstioctl_FromVerifiedRelease(t *testing.T) {
+	exec := &fakeShell{outputs: map[string]string{"uname -m": "aarch64"}}
+	cfg := &config.Config{Versions: config.VersionsConfig{Istio: "1.27.1"}}
+	istio := NewIstio(context.Background(), cfg, exec)
+	cfg.Checksums = map[string]string{istio.release("arm64").URL: testSum}
+
+	require.NoError(t, istio.installIstioctl())
+	tarball := "/var/cache/k8s-provisioner/" + testSum + "/istio.tar.gz"
+	assert.Equal(t, []string{
+		"uname -m",
+		"echo '" + testSum + "  " + tarball + "' | sha256sum -c --status -",
+		"tar -xzf " + tarball + " -C /usr/local/bin --strip-components=2 istio-1.27.1/bin/istioctl",
+	}, exec.calls, "a cached copy that still matches is reused")
+}
+
+// TestWithHelm_OneStepAtATime verifies parallel workload steps never set up
+// helm at the same time.
+func TestWithHelm_OneStepAtATime(t *testing.T) {
+	var running, most atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = withHelm(func() error {
+				n := running.Add(1)
+				if n > most.Load() {
+					most.Store(n)
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), most.Load())
+}
@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,12 +10,13 @@ import (
 )
 
 type Ollama struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewOllama(cfg *config.Config, exec executor.ShellExecutor) *Ollama {
-	return &Ollama{config: cfg, exec: exec}
+func NewOllama(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Ollama {
+	return &Ollama{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 // isCloudModel checks if the model is a cloud model (e.g., minimax-m2.5:cloud)
@@ -34,31 +36,31 @@ func (o *Ollama) hasAPIKey() bool {
 }
 
 func (o *Ollama) Install() error {
-	fmt.Println("Installing Ollama...")
+	fmt.Fprintln(console(o.ctx), "Installing Ollama...")
 
 	model := o.config.KarporAI.Model
 	isCloud := o.isCloudModel()
 
 	if isCloud {
-		fmt.Printf("Using cloud model: %s\n", model)
+		fmt.Fprintf(console(o.ctx), "Using cloud model: %s\n", model)
 		if !o.hasAPIKey() {
-			fmt.Println("WARNING: Cloud model requires API key. Get one at https://ollama.com/settings/keys")
-			fmt.Println("         Set ollama.api_key in config.yaml")
+			fmt.Fprintln(console(o.ctx), "WARNING: Cloud model requires API key. Get one at https://ollama.com/settings/keys")
+			fmt.Fprintln(console(o.ctx), "         Set ollama.api_key in config.yaml")
 		}
 	} else {
-		fmt.Printf("Using local model: %s\n", model)
+		fmt.Fprintf(console(o.ctx), "Using local model: %s\n", model)
 	}
 
 	// Label node01 for AI workloads (may fail if node01 hasn't joined yet)
 	_, _ = o.exec.RunShell("kubectl label node node01 workload/ai=true --overwrite 2>/dev/null")
 
 	// Create namespace
-	fmt.Println("Creating Ollama namespace...")
+	fmt.Fprintln(console(o.ctx), "Creating Ollama namespace...")
 	ns := `apiVersion: v1
 kind: Namespace
 metadata:
   name: ollama`
-	if err := executor.WriteFile("/tmp/ollama-ns.yaml", ns); err != nil {
+	if err := executor.WriteFileOn(o.exec, "/tmp/ollama-ns.yaml", ns); err != nil {
 		return err
 	}
 	if _, err := o.exec.RunShell("kubectl apply -f /tmp/ollama-ns.yaml"); err != nil {
@@ -67,7 +69,7 @@ metadata:
 
 	// Create API key secret if provided
 	if o.hasAPIKey() {
-		fmt.Println("Creating Ollama API key secret...")
+		fmt.Fprintln(console(o.ctx), "Creating Ollama API key secret...")
 		if err := o.createAPIKeySecret(); err != nil {
 			return err
 		}
@@ -75,17 +77,17 @@ metadata:
 
 	// Create persistent storage for Ollama models (only needed for local models)
 	if !isCloud {
-		fmt.Println("Creating Ollama storage...")
+		fmt.Fprintln(console(o.ctx), "Creating Ollama storage...")
 		if err := o.createStorage(); err != nil {
 			return err
 		}
 	}
 
 	// Create deployment and service
-	fmt.Println("Deploying Ollama...")
+	fmt.Fprintln(console(o.ctx), "Deploying Ollama...")
 	manifest := o.buildDeploymentManifest(isCloud)
 
-	if err := executor.WriteFile("/tmp/ollama-deploy.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(o.exec, "/tmp/ollama-deploy.yaml", manifest); err != nil {
 		return err
 	}
 	if _, err := o.exec.RunShell("kubectl apply -f /tmp/ollama-deploy.yaml"); err != nil {
@@ -94,24 +96,40 @@ metadata:
 
 	// Create a Job to pull the model (only for local models)
 	if !isCloud && model != "" {
-		fmt.Printf("Creating model pull job for: %s...\n", model)
+		fmt.Fprintf(console(o.ctx), "Creating model pull job for: %s...\n", model)
 		if err := o.createModelPullJob(model); err != nil {
-			fmt.Printf("Warning: Failed to create model pull job: %v\n", err)
+			fmt.Fprintf(console(o.ctx), "Warning: Failed to create model pull job: %v\n", err)
 		}
 	} else if isCloud {
-		fmt.Printf("Cloud model %s will be accessed via Ollama cloud API\n", model)
+		fmt.Fprintf(console(o.ctx), "Cloud model %s will be accessed via Ollama cloud API\n", model)
 	}
 
-	fmt.Println("Ollama installed successfully!")
+	fmt.Fprintln(console(o.ctx), "Ollama installed successfully!")
 	if isCloud {
-		fmt.Println("Ollama is configured for cloud models at: http://ollama.ollama.svc:11434")
-		fmt.Println("Cloud models: minimax-m2.5:cloud, qwen3-coder:480b-cloud, glm-4.7:cloud")
+		fmt.Fprintln(console(o.ctx), "Ollama is configured for cloud models at: http://ollama.ollama.svc:11434")
+		fmt.Fprintln(console(o.ctx), "Cloud models: minimax-m2.5:cloud, qwen3-coder:480b-cloud, glm-4.7:cloud")
 	} else {
-		fmt.Println("Ollama is available at: http://ollama.ollama.svc:11434")
+		fmt.Fprintln(console(o.ctx), "Ollama is available at: http://ollama.ollama.svc:11434")
 	}
 	return nil
 }
 
+// Uninstall deletes the ollama namespace (with the VSO-synced API key), the
+// model volume and the node's AI workload label. Pulled models stay on the
+// NFS export.
+func (o *Ollama) Uninstall() error {
+	fmt.Fprintln(console(o.ctx), "Deleting Ollama namespace...")
+	if err := deleteNamespace(o.exec, "ollama"); err != nil {
+		return err
+	}
+	if err := deleteResources(o.exec, "", "pv/ollama-pv"); err != nil {
+		return err
+	}
+	// Like the label in Install, node01 may be gone.
+	_, _ = o.exec.RunShell("kubectl label node node01 workload/ai- 2>/dev/null")
+	return nil
+}
+
 func (o *Ollama) buildDeploymentManifest(isCloud bool) string {
 	// Base environment variables
 	envVars := `        env:
@@ -229,7 +247,7 @@ spec:
 
 func (o *Ollama) createAPIKeySecret() error {
 	if out, _ := o.exec.RunShell("kubectl get secret ollama-api-key -n ollama -o name 2>/dev/null"); out != "" {
-		fmt.Println("Ollama API key secret already synced by Vault Secrets Operator, skipping direct creation")
+		fmt.Fprintln(console(o.ctx), "Ollama API key secret already synced by Vault Secrets Operator, skipping direct creation")
 		return nil
 	}
 
@@ -252,7 +270,7 @@ stringData:
 	if _, err := o.exec.RunShellWithStdin("kubectl apply -f -", manifest); err != nil {
 		return fmt.Errorf("failed to create API key secret: %w", err)
 	}
-	fmt.Println("Ollama API key secret created successfully")
+	fmt.Fprintln(console(o.ctx), "Ollama API key secret created successfully")
 	return nil
 }
 
@@ -267,10 +285,10 @@ func (o *Ollama) createStorage() error {
 	}
 
 	// Create directory on NFS via local mount
-	fmt.Println("Creating Ollama storage directory on NFS...")
+	fmt.Fprintln(console(o.ctx), "Creating Ollama storage directory on NFS...")
 	mkdirCmd := "mkdir -p /mnt/nfs-storage/ollama && chmod 777 /mnt/nfs-storage/ollama"
 	if _, err := o.exec.RunShell(mkdirCmd); err != nil {
-		fmt.Printf("Warning: Failed to create directory on NFS: %v\n", err)
+		fmt.Fprintf(console(o.ctx), "Warning: Failed to create directory on NFS: %v\n", err)
 	}
 
 	// Create PV and PVC for Ollama data
@@ -305,7 +323,7 @@ spec:
     requests:
       storage: 10Gi`, nfsServer, nfsPath)
 
-	if err := executor.WriteFile("/tmp/ollama-storage.yaml", storage); err != nil {
+	if err := executor.WriteFileOn(o.exec, "/tmp/ollama-storage.yaml", storage); err != nil {
 		return err
 	}
 
@@ -343,7 +361,7 @@ spec:
           curl -X POST http://ollama.ollama.svc:11434/api/pull -d '{"name": "%s"}' --max-time 600
           echo "Model pull completed!"`, model, model)
 
-	if err := executor.WriteFile("/tmp/ollama-model-job.yaml", job); err != nil {
+	if err := executor.WriteFileOn(o.exec, "/tmp/ollama-model-job.yaml", job); err != nil {
 		return err
 	}
 
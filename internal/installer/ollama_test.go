@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,7 +10,7 @@ import (
 )
 
 func TestBuildDeploymentManifest_LocalHasPVCAndLargeResources(t *testing.T) {
-	o := NewOllama(&config.Config{}, &fakeShell{})
+	o := NewOllama(context.Background(), &config.Config{}, &fakeShell{})
 
 	m := o.buildDeploymentManifest(false)
 
@@ -19,7 +20,7 @@ func TestBuildDeploymentManifest_LocalHasPVCAndLargeResources(t *testing.T) {
 }
 
 func TestBuildDeploymentManifest_CloudHasNoPVCAndSmallResources(t *testing.T) {
-	o := NewOllama(&config.Config{}, &fakeShell{})
+	o := NewOllama(context.Background(), &config.Config{}, &fakeShell{})
 
 	m := o.buildDeploymentManifest(true)
 
@@ -30,7 +31,7 @@ func TestBuildDeploymentManifest_CloudHasNoPVCAndSmallResources(t *testing.T) {
 func TestBuildDeploymentManifest_InjectsAPIKeyEnvWhenConfigured(t *testing.T) {
 	cfg := &config.Config{} // Vault disabled -> resolver returns the config key
 	cfg.Ollama.APIKey = "olka_test"
-	o := NewOllama(cfg, &fakeShell{})
+	o := NewOllama(context.Background(), cfg, &fakeShell{})
 
 	m := o.buildDeploymentManifest(true)
 
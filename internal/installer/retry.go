@@ -0,0 +1,37 @@
+package installer
+
+import (
+	"context"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/backoff"
+)
+
+// pollUntil is the standard readiness policy (see backoff.PollUntil), pausing
+// through the package sleep so tests and dry runs can skip the waits.
+func pollUntil(timeout, interval time.Duration) backoff.Policy {
+	b := backoff.PollUntil(timeout, interval)
+	b.Sleep = sleep
+	return b
+}
+
+// retryTimes retries up to attempts times, interval apart (see backoff.Times).
+func retryTimes(attempts int, interval time.Duration) backoff.Policy {
+	b := backoff.Times(attempts, interval)
+	b.Sleep = sleep
+	return b
+}
+
+// waitFor polls ready under b until it returns true, reporting progress on
+// the installer's console.
+func waitFor(ctx context.Context, what string, b backoff.Policy, ready func() bool) error {
+	b.Out = console(ctx)
+	return backoff.WaitFor(ctx, what, b, ready)
+}
+
+// retry calls fn under b until it succeeds, returning the last error when the
+// policy is exhausted.
+func retry(ctx context.Context, what string, b backoff.Policy, fn func() error) error {
+	b.Out = console(ctx)
+	return backoff.Retry(ctx, what, b, fn)
+}
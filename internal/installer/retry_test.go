@@ -0,0 +1,35 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitFor_PausesThroughPackageSleep(t *testing.T) {
+	noWait(t)
+	checks := 0
+	// Without the package sleep this would really wait 10s between checks.
+	err := waitFor(context.Background(), "thing", pollUntil(time.Minute, 10*time.Second), func() bool {
+		checks++
+		return checks == 3
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, checks)
+}
+
+func TestRetry_StopsAfterAttemptsWithLastError(t *testing.T) {
+	noWait(t)
+	calls := 0
+	err := retry(context.Background(), "apply", retryTimes(3, time.Second), func() error {
+		calls++
+		return errors.New("webhook not ready")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Contains(t, err.Error(), "giving up after 3 attempts: webhook not ready")
+}
@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,47 +10,86 @@ import (
 )
 
 type Tempo struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewTempo(cfg *config.Config, exec executor.ShellExecutor) *Tempo {
-	return &Tempo{config: cfg, exec: exec}
+func NewTempo(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *Tempo {
+	return &Tempo{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (t *Tempo) Install() error {
-	fmt.Println("Installing Tracing Stack (Grafana Tempo + OpenTelemetry Collector)...")
+	fmt.Fprintln(console(t.ctx), "Installing Tracing Stack (Grafana Tempo + OpenTelemetry Collector)...")
 
-	fmt.Println("Installing Grafana Tempo...")
+	fmt.Fprintln(console(t.ctx), "Installing Grafana Tempo...")
 	if err := t.installTempo(); err != nil {
 		return err
 	}
 
-	fmt.Println("Installing OpenTelemetry Collector...")
+	fmt.Fprintln(console(t.ctx), "Installing OpenTelemetry Collector...")
 	if err := t.installOtelCollector(); err != nil {
 		return err
 	}
 
-	fmt.Println("Configuring Tempo datasource in Grafana...")
+	fmt.Fprintln(console(t.ctx), "Configuring Tempo datasource in Grafana...")
 	if err := t.configureTempoDataSource(); err != nil {
-		fmt.Printf("Warning: failed to configure Tempo datasource: %v\n", err)
+		fmt.Fprintf(console(t.ctx), "Warning: failed to configure Tempo datasource: %v\n", err)
 	}
 
-	fmt.Println("Activating Istio mesh tracing (forwarding to OTel Collector)...")
+	fmt.Fprintln(console(t.ctx), "Activating Istio mesh tracing (forwarding to OTel Collector)...")
 	if err := t.configureIstioTracing(); err != nil {
-		fmt.Printf("Warning: failed to configure Istio tracing: %v\n", err)
+		fmt.Fprintf(console(t.ctx), "Warning: failed to configure Istio tracing: %v\n", err)
 	}
 
-	fmt.Println("Waiting for tracing stack to be ready...")
+	fmt.Fprintln(console(t.ctx), "Waiting for tracing stack to be ready...")
 	if err := t.waitForReady(defaultReadyTimeout); err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(console(t.ctx), "Warning: %v\n", err)
 	}
 
-	fmt.Println("Tracing stack installed successfully!")
+	fmt.Fprintln(console(t.ctx), "Tracing stack installed successfully!")
 	t.printAccessInfo()
 	return nil
 }
 
+// Uninstall stops mesh tracing, deletes the OpenTelemetry Collector and
+// Tempo (with its volume), and drops the Tempo datasource from Grafana. The
+// otel-tracing provider in the Istio mesh config stays, unused.
+func (t *Tempo) Uninstall() error {
+	fmt.Fprintln(console(t.ctx), "Deactivating Istio mesh tracing...")
+	if err := deleteResources(t.exec, "istio-system", "telemetry/mesh-default"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(t.ctx), "Removing OpenTelemetry Collector...")
+	if err := deleteResources(t.exec, "monitoring",
+		"daemonset/otel-collector", "service/otel-collector", "configmap/otel-collector-config",
+		"serviceaccount/otel-collector", "destinationrule/otel-collector-plaintext"); err != nil {
+		return err
+	}
+	if err := deleteResources(t.exec, "", "clusterrolebinding/otel-collector", "clusterrole/otel-collector"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(t.ctx), "Removing Grafana Tempo...")
+	if err := deleteResources(t.exec, "monitoring",
+		"deployment/tempo", "service/tempo", "configmap/tempo-config", "serviceaccount/tempo", "pvc/tempo-pvc"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(console(t.ctx), "Removing Tempo datasource from Grafana...")
+	return applyGrafanaDatasources(t.exec, "/tmp/grafana-datasources.yaml", installedInMonitoring(t.exec, "loki"), false)
+}
+
+// Verify checks that Tempo reports ready and that the OpenTelemetry Collector
+// feeding it runs on every node.
+func (t *Tempo) Verify() error {
+	if err := expectServiceProxy(t.ctx, t.exec, "Tempo to be ready", "monitoring", "tempo:3200", "/ready", contains("ready")); err != nil {
+		return err
+	}
+	return expectDaemonSet(t.ctx, t.exec, "monitoring", "otel-collector")
+}
+
 func (t *Tempo) installTempo() error {
 	tempo := `apiVersion: v1
 kind: ServiceAccount
@@ -202,7 +242,7 @@ spec:
 	}
 	tempo = fmt.Sprintf(tempo, version, version)
 
-	if err := executor.WriteFile("/tmp/tempo.yaml", tempo); err != nil {
+	if err := executor.WriteFileOn(t.exec, "/tmp/tempo.yaml", tempo); err != nil {
 		return err
 	}
 
@@ -381,7 +421,7 @@ spec:
 	}
 	otel = fmt.Sprintf(otel, otelVersion)
 
-	if err := executor.WriteFile("/tmp/otel-collector.yaml", otel); err != nil {
+	if err := executor.WriteFileOn(t.exec, "/tmp/otel-collector.yaml", otel); err != nil {
 		return err
 	}
 
@@ -392,70 +432,7 @@ spec:
 // configureTempoDataSource atualiza o ConfigMap do Grafana com Prometheus + Loki + Tempo.
 // UIDs fixos permitem correlação entre traces, logs e métricas.
 func (t *Tempo) configureTempoDataSource() error {
-	datasources := `apiVersion: v1
-kind: ConfigMap
-metadata:
-  name: grafana-datasources
-  namespace: monitoring
-data:
-  datasources.yaml: |
-    apiVersion: 1
-    datasources:
-    - name: Prometheus
-      type: prometheus
-      uid: prometheus-uid
-      access: proxy
-      url: http://prometheus:9090
-      isDefault: true
-    - name: Loki
-      type: loki
-      uid: loki-uid
-      access: proxy
-      url: http://loki:3100
-      isDefault: false
-      jsonData:
-        derivedFields:
-        - datasourceUid: tempo-uid
-          matcherRegex: "traceID=(\\w+)"
-          name: TraceID
-          url: "${__value.raw}"
-    - name: Tempo
-      type: tempo
-      uid: tempo-uid
-      access: proxy
-      url: http://tempo:3200
-      isDefault: false
-      jsonData:
-        tracesToLogsV2:
-          datasourceUid: loki-uid
-          tags:
-          - key: service.name
-            value: app
-          - key: k8s.namespace.name
-            value: namespace
-          filterByTraceID: true
-          filterBySpanID: false
-        tracesToMetrics:
-          datasourceUid: prometheus-uid
-          tags:
-          - key: service.name
-            value: service
-        serviceMap:
-          datasourceUid: prometheus-uid
-        nodeGraph:
-          enabled: true`
-
-	if err := executor.WriteFile("/tmp/grafana-datasources-full.yaml", datasources); err != nil {
-		return err
-	}
-
-	if _, err := t.exec.RunShell("kubectl apply -f /tmp/grafana-datasources-full.yaml"); err != nil {
-		return err
-	}
-
-	// Reinicia o Grafana para carregar os novos datasources
-	_, err := t.exec.RunShell("kubectl rollout restart deployment/grafana -n monitoring")
-	return err
+	return applyGrafanaDatasources(t.exec, "/tmp/grafana-datasources-full.yaml", true, true)
 }
 
 // configureIstioTracing activates the otel-tracing extension provider (defined at Istio install
@@ -473,7 +450,7 @@ spec:
     - name: otel-tracing
     randomSamplingPercentage: 100.0`
 
-	if err := executor.WriteFile("/tmp/istio-telemetry.yaml", telemetry); err != nil {
+	if err := executor.WriteFileOn(t.exec, "/tmp/istio-telemetry.yaml", telemetry); err != nil {
 		return err
 	}
 
@@ -482,36 +459,32 @@ spec:
 }
 
 func (t *Tempo) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	if err := waitFor(t.ctx, "Tempo", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, _ := t.exec.RunShell("kubectl get pods -n monitoring -l app=tempo -o jsonpath='{.items[0].status.phase}' 2>/dev/null")
-		if out != "Running" {
-			fmt.Println("Waiting for Tempo...")
-			time.Sleep(defaultPollInterval)
-			continue
-		}
-		fmt.Println("Tracing stack is ready!")
-		return nil
+		return out == "Running"
+	}); err != nil {
+		return err
 	}
-	return fmt.Errorf("timeout waiting for Tempo")
+	fmt.Fprintln(console(t.ctx), "Tracing stack is ready!")
+	return nil
 }
 
 func (t *Tempo) printAccessInfo() {
-	fmt.Println("\n========================================")
-	fmt.Println("Tracing Stack Access Information")
-	fmt.Println("========================================")
-	fmt.Println("\nAcesse traces via Grafana:")
-	fmt.Println("  1. Abra o Grafana (http://grafana.local)")
-	fmt.Println("  2. Vá em Explore (sidebar esquerda)")
-	fmt.Println("  3. Selecione 'Tempo' como datasource")
-	fmt.Println("  4. Busque por TraceID ou use Service Graph")
-	fmt.Println("\nEnviar traces das suas apps:")
-	fmt.Println("  OTLP gRPC: otel-collector.monitoring.svc:4317")
-	fmt.Println("  OTLP HTTP: otel-collector.monitoring.svc:4318")
-	fmt.Println("  OTLP gRPC (host): <node-ip>:4317 (via hostPort)")
-	fmt.Println("\nCorrelações habilitadas:")
-	fmt.Println("  Traces → Logs  (Tempo → Loki via TraceID)")
-	fmt.Println("  Traces → Métricas (Tempo → Prometheus via service.name)")
-	fmt.Println("  Service Map (via Prometheus metrics)")
-	fmt.Println("========================================")
+	fmt.Fprintln(console(t.ctx), "\n========================================")
+	fmt.Fprintln(console(t.ctx), "Tracing Stack Access Information")
+	fmt.Fprintln(console(t.ctx), "========================================")
+	fmt.Fprintln(console(t.ctx), "\nAcesse traces via Grafana:")
+	fmt.Fprintln(console(t.ctx), "  1. Abra o Grafana (http://grafana.local)")
+	fmt.Fprintln(console(t.ctx), "  2. Vá em Explore (sidebar esquerda)")
+	fmt.Fprintln(console(t.ctx), "  3. Selecione 'Tempo' como datasource")
+	fmt.Fprintln(console(t.ctx), "  4. Busque por TraceID ou use Service Graph")
+	fmt.Fprintln(console(t.ctx), "\nEnviar traces das suas apps:")
+	fmt.Fprintln(console(t.ctx), "  OTLP gRPC: otel-collector.monitoring.svc:4317")
+	fmt.Fprintln(console(t.ctx), "  OTLP HTTP: otel-collector.monitoring.svc:4318")
+	fmt.Fprintln(console(t.ctx), "  OTLP gRPC (host): <node-ip>:4317 (via hostPort)")
+	fmt.Fprintln(console(t.ctx), "\nCorrelações habilitadas:")
+	fmt.Fprintln(console(t.ctx), "  Traces → Logs  (Tempo → Loki via TraceID)")
+	fmt.Fprintln(console(t.ctx), "  Traces → Métricas (Tempo → Prometheus via service.name)")
+	fmt.Fprintln(console(t.ctx), "  Service Map (via Prometheus metrics)")
+	fmt.Fprintln(console(t.ctx), "========================================")
 }
@@ -1,6 +1,11 @@
 package installer
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/backoff"
+)
 
 // Timeout constants for installer operations
 const (
@@ -24,11 +29,31 @@ const (
 	// inline literal — see NM-4).
 	keycloakStartTimeout   = 20 * time.Minute // first start includes a build step
 	adminSecretSyncTimeout = 2 * time.Minute  // VSO sync of the keycloak-admin secret
-	oauthRetryDelay        = 20 * time.Second  // backoff between Grafana OAuth attempts
-	apiServerRestartWait   = 20 * time.Second  // settle time before polling /healthz
-	apiServerHealthTimeout = 2 * time.Minute   // apiserver back-online after OIDC patch
-	webhookRegisterWait    = 10 * time.Second  // let the cert-manager webhook register
-	caSecretWaitTimeout    = 60 * time.Second  // wait for the lab CA secret to exist
-	certReadyTimeout       = 2 * time.Minute   // wait for the lab TLS certificate
-	vaultReadyTimeout      = 3 * time.Minute   // wait for Vault to be reachable
+	oauthRetryDelay        = 20 * time.Second // backoff between Grafana OAuth attempts
+	apiServerRestartWait   = 20 * time.Second // settle time before polling /healthz
+	apiServerHealthTimeout = 2 * time.Minute  // apiserver back-online after OIDC patch
+	webhookRegisterWait    = 10 * time.Second // let the cert-manager webhook register
+	caSecretWaitTimeout    = 60 * time.Second // wait for the lab CA secret to exist
+	certReadyTimeout       = 2 * time.Minute  // wait for the lab TLS certificate
+	vaultReadyTimeout      = 3 * time.Minute  // wait for Vault to be reachable
+	ollamaModelTimeout     = 10 * time.Minute // Karpor AI: model pulled into Ollama
+	namespaceDeleteTimeout = 5 * time.Minute  // uninstall: namespace finalizers to finish
+	verifyTimeout          = time.Minute      // Verify: one functional check to pass
+
+	// Attempt-bounded retries (see retryTimes).
+	metalLBAttempts    = 30 // controller pod wait, then config apply until the webhook answers
+	certCRDAttempts    = 12 // apply the CA issuer until cert-manager CRDs are served
+	prometheusAttempts = 30 // Prometheus Operator pod to be Running
+	oauthAttempts      = 3  // Grafana OAuth2 configuration, oauthRetryDelay apart
 )
+
+// sleep pauses for d, returning early with ctx.Err() when ctx is cancelled so
+// poll loops stop on Ctrl-C instead of running out their full deadline. A dry
+// run (see WithDryRun) does not pause at all. It is a variable so cassette
+// replay tests can skip the waits (see golden_test.go).
+var sleep = func(ctx context.Context, d time.Duration) error {
+	if isDryRun(ctx) {
+		return ctx.Err()
+	}
+	return backoff.Sleep(ctx, d)
+}
@@ -0,0 +1,39 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// deleteResources deletes refs ("kind/name") from namespace, or cluster-scoped
+// ones when namespace is "". Resources already gone are not an error.
+func deleteResources(exec executor.ShellExecutor, namespace string, refs ...string) error {
+	cmd := "kubectl delete " + strings.Join(refs, " ") + " --ignore-not-found"
+	if namespace != "" {
+		cmd += " -n " + namespace
+	}
+	_, err := exec.RunShell(cmd)
+	return err
+}
+
+// deleteManifest deletes every resource of the manifest at url, the reverse
+// of a "kubectl apply -f url".
+func deleteManifest(exec executor.ShellExecutor, url string) error {
+	_, err := exec.RunShell(fmt.Sprintf("kubectl delete -f %s --ignore-not-found", url))
+	return err
+}
+
+// deleteNamespace deletes namespace and waits for its contents to be gone.
+func deleteNamespace(exec executor.ShellExecutor, namespace string) error {
+	_, err := exec.RunShell(fmt.Sprintf("kubectl delete namespace %s --ignore-not-found --timeout=%s", namespace, namespaceDeleteTimeout))
+	return err
+}
+
+// helmUninstall removes a helm release. A release already gone is not an
+// error.
+func helmUninstall(exec executor.ShellExecutor, release, namespace string) error {
+	_, err := exec.RunShell(fmt.Sprintf("helm uninstall %s -n %s --ignore-not-found --wait", release, namespace))
+	return err
+}
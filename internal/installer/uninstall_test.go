@@ -0,0 +1,69 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+func TestGrafanaOAuthRemoval_RemovesFromTheEnd(t *testing.T) {
+	deployment := `{"spec":{"template":{"spec":{
+		"volumes":[{"name":"datasources"},{"name":"grafana-ini"},{"name":"tmp"},{"name":"keycloak-ca"}],
+		"containers":[{
+			"volumeMounts":[{"name":"datasources"},{"name":"grafana-ini"},{"name":"keycloak-ca"}],
+			"env":[{"name":"GF_SECURITY_ADMIN_USER"},{"name":"GF_AUTH_GENERIC_OAUTH_CLIENT_SECRET"}]}]}}}}`
+
+	patch, err := grafanaOAuthRemoval(deployment)
+	require.NoError(t, err)
+	assert.Equal(t, `[
+  {"op":"remove","path":"/spec/template/spec/volumes/3"},
+  {"op":"remove","path":"/spec/template/spec/volumes/1"},
+  {"op":"remove","path":"/spec/template/spec/containers/0/volumeMounts/2"},
+  {"op":"remove","path":"/spec/template/spec/containers/0/volumeMounts/1"},
+  {"op":"remove","path":"/spec/template/spec/containers/0/env/1"}
+]`, patch)
+
+	patch, err = grafanaOAuthRemoval(`{"spec":{"template":{"spec":{"volumes":[{"name":"tmp"}]}}}}`)
+	require.NoError(t, err)
+	assert.Empty(t, patch, "an unpatched Grafana needs no patch")
+}
+
+func TestKeycloakUninstall_UnpatchedAPIServerIsNotRestarted(t *testing.T) {
+	noWait(t)
+	notFound := errors.New("exit status 1")
+	f := &fakeShell{
+		outputs: map[string]string{"grep -q": ""},
+		errs:    map[string]error{"grep -q": notFound},
+	}
+	require.NoError(t, NewKeycloak(context.Background(), &config.Config{}, f).Uninstall())
+
+	for _, call := range f.calls {
+		assert.NotContains(t, call, "sed -i")
+		assert.NotContains(t, call, "/healthz")
+	}
+	assert.Contains(t, f.calls, "rm -f /etc/kubernetes/pki/auth-config.yaml")
+}
+
+func TestVSOUninstall_SkipsCustomResourcesOnceCRDsAreGone(t *testing.T) {
+	f := &fakeShell{
+		outputs: map[string]string{"kubectl get crd vaultstaticsecrets": ""},
+		errs:    map[string]error{"kubectl get crd vaultstaticsecrets": errors.New("NotFound")},
+	}
+	require.NoError(t, NewVaultSecretsOperator(context.Background(), &config.Config{}, f).Uninstall())
+
+	for _, call := range f.calls {
+		assert.NotContains(t, call, "vaultstaticsecret/")
+	}
+	assert.Contains(t, f.calls, "helm uninstall vault-secrets-operator -n vault-secrets-operator-system --ignore-not-found --wait")
+}
+
+func TestGrafanaDatasources_TempoWithoutLokiDropsLogLinks(t *testing.T) {
+	ds := grafanaDatasources(false, true)
+	assert.Contains(t, ds, "name: Tempo")
+	assert.NotContains(t, ds, "loki")
+}
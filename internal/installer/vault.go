@@ -2,6 +2,7 @@ package installer
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
 )
 
 // vaultHTTPClient bounds the Vault bootstrap calls (health/init/unseal/KV setup)
@@ -22,13 +24,14 @@ import (
 var vaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
 
 type VaultInstaller struct {
+	ctx     context.Context
 	config  *config.Config
 	exec    executor.ShellExecutor
 	address string
 }
 
-func NewVaultInstaller(cfg *config.Config, exec executor.ShellExecutor) *VaultInstaller {
-	return &VaultInstaller{config: cfg, exec: exec, address: cfg.VaultAddress()}
+func NewVaultInstaller(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *VaultInstaller {
+	return &VaultInstaller{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec), address: cfg.VaultAddress()}
 }
 
 type vaultInitRequest struct {
@@ -43,7 +46,14 @@ type vaultInitResponse struct {
 }
 
 func (v *VaultInstaller) Install() error {
-	fmt.Println("Configuring HashiCorp Vault on storage node...")
+	fmt.Fprintln(console(v.ctx), "Configuring HashiCorp Vault on storage node...")
+
+	// Bootstrap talks to the Vault HTTP API directly, not through the executor,
+	// so a dry run has nothing to show and must not init or unseal anything.
+	if isDryRun(v.ctx) {
+		fmt.Fprintf(console(v.ctx), "[dry-run] skip Vault bootstrap via its HTTP API at %s\n", v.address)
+		return nil
+	}
 
 	if err := v.waitForVault(vaultReadyTimeout); err != nil {
 		return fmt.Errorf("vault not reachable at %s: %w", v.address, err)
@@ -56,68 +66,97 @@ func (v *VaultInstaller) Install() error {
 
 	var rootToken string
 	if !initialized {
-		fmt.Println("Initializing Vault...")
+		fmt.Fprintln(console(v.ctx), "Initializing Vault...")
 		rootToken, err = v.initialize()
 		if err != nil {
 			return fmt.Errorf("vault initialization failed: %w", err)
 		}
-		fmt.Println("Vault initialized and unsealed successfully")
+		fmt.Fprintln(console(v.ctx), "Vault initialized and unsealed successfully")
 	} else {
-		fmt.Println("Vault already initialized, loading stored credentials...")
+		fmt.Fprintln(console(v.ctx), "Vault already initialized, loading stored credentials...")
 		rootToken, err = v.loadRootToken()
 		if err != nil {
 			return fmt.Errorf("failed to load vault root token: %w", err)
 		}
 	}
 
-	fmt.Println("Enabling KV v2 secrets engine...")
+	fmt.Fprintln(console(v.ctx), "Enabling KV v2 secrets engine...")
 	if err := v.enableKVSecrets(rootToken); err != nil {
-		fmt.Printf("Warning: failed to enable KV secrets engine: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Warning: failed to enable KV secrets engine: %v\n", err)
 	}
 
 	if true {
-		fmt.Println("Configuring Kubernetes auth method...")
+		fmt.Fprintln(console(v.ctx), "Configuring Kubernetes auth method...")
 		if err := v.configureK8sAuth(rootToken); err != nil {
-			fmt.Printf("Warning: failed to configure k8s auth: %v\n", err)
+			fmt.Fprintf(console(v.ctx), "Warning: failed to configure k8s auth: %v\n", err)
 		}
 	}
 
-	fmt.Println("Storing API secrets in Vault...")
+	fmt.Fprintln(console(v.ctx), "Storing API secrets in Vault...")
 	if err := v.storeAPISecrets(rootToken); err != nil {
-		fmt.Printf("Warning: failed to store API secrets: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Warning: failed to store API secrets: %v\n", err)
 	}
 
 	v.printAccessInfo()
 	return nil
 }
 
-func (v *VaultInstaller) waitForVault(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		resp, err := vaultHTTPClient.Get(v.address + "/v1/sys/health")
-		if err == nil {
-			if closeErr := resp.Body.Close(); closeErr != nil {
-				fmt.Printf("Warning: failed to close response body: %v\n", closeErr)
-			}
-			// 200=active, 429=standby, 501=not initialized, 503=sealed — all mean API is up
-			if resp.StatusCode != 0 {
-				return nil
-			}
+// Uninstall deletes the vault-auth ServiceAccount and its TokenReview
+// binding, so Vault's Kubernetes auth method can no longer log workloads in.
+// Vault itself runs on the storage node, outside the cluster: it stays
+// initialized and keeps its secrets.
+func (v *VaultInstaller) Uninstall() error {
+	fmt.Fprintln(console(v.ctx), "Removing Vault Kubernetes auth service account...")
+	if err := deleteResources(v.exec, "", "clusterrolebinding/vault-auth-tokenreview"); err != nil {
+		return err
+	}
+	if err := deleteResources(v.exec, "kube-system", "serviceaccount/vault-auth"); err != nil {
+		return err
+	}
+	fmt.Fprintf(console(v.ctx), "Vault at %s and its secrets are left in place.\n", v.address)
+	return nil
+}
+
+// Verify checks, from the node it runs on, that Vault is reachable,
+// initialized and unsealed: /v1/sys/health answers 200 (active) or 429
+// (standby).
+func (v *VaultInstaller) Verify() error {
+	code, err := expectOutput(v.ctx, v.exec, "Vault at "+v.address+" to be unsealed",
+		fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --max-time 5 %s/v1/sys/health", v.address),
+		func(code string) bool { return code == "200" || code == "429" })
+	if err != nil && v.ctx.Err() == nil {
+		switch code {
+		case "501":
+			return fmt.Errorf("vault at %s is not initialized", v.address)
+		case "503":
+			return fmt.Errorf("vault at %s is sealed", v.address)
 		}
-		fmt.Printf("Waiting for Vault at %s...\n", v.address)
-		time.Sleep(shortPollInterval)
 	}
-	return fmt.Errorf("timed out after %s", timeout)
+	return err
+}
+
+func (v *VaultInstaller) waitForVault(timeout time.Duration) error {
+	return waitFor(v.ctx, "Vault at "+v.address, pollUntil(timeout, shortPollInterval), func() bool {
+		resp, err := v.vaultHTTPGet("/v1/sys/health")
+		if err != nil {
+			return false
+		}
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			fmt.Fprintf(console(v.ctx), "Warning: failed to close response body: %v\n", closeErr)
+		}
+		// 200=active, 429=standby, 501=not initialized, 503=sealed — all mean API is up
+		return resp.StatusCode != 0
+	})
 }
 
 func (v *VaultInstaller) isInitialized() (bool, error) {
-	resp, err := vaultHTTPClient.Get(v.address + "/v1/sys/init")
+	resp, err := v.vaultHTTPGet("/v1/sys/init")
 	if err != nil {
 		return false, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			fmt.Printf("Warning: failed to close response body: %v\n", err)
+			fmt.Fprintf(console(v.ctx), "Warning: failed to close response body: %v\n", err)
 		}
 	}()
 
@@ -145,7 +184,7 @@ func (v *VaultInstaller) initialize() (string, error) {
 	}
 
 	// Unseal with first 3 keys (threshold=3)
-	fmt.Println("Unsealing Vault...")
+	fmt.Fprintln(console(v.ctx), "Unsealing Vault...")
 	for i := 0; i < 3; i++ {
 		key, _ := keys[i].(string)
 		if _, err := v.vaultPut("/v1/sys/unseal", "", map[string]interface{}{"key": key}); err != nil {
@@ -159,7 +198,7 @@ func (v *VaultInstaller) initialize() (string, error) {
 	// scoped token but falls back), so a failure here is non-fatal.
 	provToken, perr := v.createProvisionerToken(rootToken)
 	if perr != nil {
-		fmt.Printf("Warning: scoped provisioner token not created (%v) — components will use the root token\n", perr)
+		fmt.Fprintf(console(v.ctx), "Warning: scoped provisioner token not created (%v) — components will use the root token\n", perr)
 	}
 
 	// Persist init data on controlplane
@@ -174,11 +213,11 @@ func (v *VaultInstaller) initialize() (string, error) {
 	// regenerated, and without them Vault is unrecoverable once it seals. Dump
 	// them to stdout as a last-resort capture, then fail hard.
 	if err := v.saveInitData(initData); err != nil {
-		fmt.Println("\n!!! CRITICAL: could not persist Vault init data !!!")
-		fmt.Println("!!! Save the following NOW or Vault becomes unrecoverable: !!!")
-		fmt.Printf("root_token: %s\n", rootToken)
+		fmt.Fprintln(console(v.ctx), "\n!!! CRITICAL: could not persist Vault init data !!!")
+		fmt.Fprintln(console(v.ctx), "!!! Save the following NOW or Vault becomes unrecoverable: !!!")
+		fmt.Fprintf(console(v.ctx), "root_token: %s\n", rootToken)
 		for i, k := range initData.Keys {
-			fmt.Printf("unseal_key_%d: %s\n", i+1, k)
+			fmt.Fprintf(console(v.ctx), "unseal_key_%d: %s\n", i+1, k)
 		}
 		return "", fmt.Errorf("vault initialized but init data not persisted: %w", err)
 	}
@@ -288,7 +327,7 @@ func (v *VaultInstaller) saveInitData(data vaultInitResponse) error {
 	env, opts, user, usesPassword := v.sshConn()
 	if usesPassword {
 		if _, err := v.exec.RunShell("apt-get install -y sshpass 2>/dev/null || true"); err != nil {
-			fmt.Printf("Warning: could not install sshpass: %v\n", err)
+			fmt.Fprintf(console(v.ctx), "Warning: could not install sshpass: %v\n", err)
 		}
 	}
 
@@ -302,16 +341,16 @@ func (v *VaultInstaller) saveInitData(data vaultInitResponse) error {
 	)
 
 	if _, err := v.exec.RunShell(scpCmd); err != nil {
-		fmt.Printf("Warning: could not scp vault-init.json to storage node: %v\n", err)
-		fmt.Printf("Vault init data saved locally at %s\n", localPath)
+		fmt.Fprintf(console(v.ctx), "Warning: could not scp vault-init.json to storage node: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Vault init data saved locally at %s\n", localPath)
 		return nil
 	}
 	if _, err := v.exec.RunShell(moveCmd); err != nil {
-		fmt.Printf("Warning: could not move vault-init.json on storage node: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Warning: could not move vault-init.json on storage node: %v\n", err)
 	}
 
-	fmt.Printf("Vault init data saved to %s:%s\n", storageIP, VaultInitFileRemote)
-	fmt.Printf("Backup local em: %s\n", localPath)
+	fmt.Fprintf(console(v.ctx), "Vault init data saved to %s:%s\n", storageIP, VaultInitFileRemote)
+	fmt.Fprintf(console(v.ctx), "Backup local em: %s\n", localPath)
 	return nil
 }
 
@@ -320,7 +359,7 @@ func (v *VaultInstaller) enableKVSecrets(token string) error {
 	mounts, err := v.vaultGet("/v1/sys/mounts", token)
 	if err == nil {
 		if _, exists := mounts["secret/"]; exists {
-			fmt.Println("KV v2 secrets engine already enabled")
+			fmt.Fprintln(console(v.ctx), "KV v2 secrets engine already enabled")
 			return nil
 		}
 	}
@@ -366,7 +405,7 @@ subjects:
   name: vault-auth
   namespace: kube-system`
 
-	if err := executor.WriteFile("/tmp/vault-auth-sa.yaml", saManifest); err != nil {
+	if err := executor.WriteFileOn(v.exec, "/tmp/vault-auth-sa.yaml", saManifest); err != nil {
 		return err
 	}
 	if _, err := v.exec.RunShell("kubectl apply -f /tmp/vault-auth-sa.yaml"); err != nil {
@@ -381,7 +420,7 @@ subjects:
 		return fmt.Errorf("create SA token: %w", err)
 	}
 
-	k8sHost := fmt.Sprintf("https://%s:%d", v.config.Network.ControlPlaneIP, apiServerPort)
+	k8sHost := fmt.Sprintf("https://%s:%d", v.config.ControlPlaneEndpoint(), apiServerPort)
 	if _, err := v.vaultPost("/v1/auth/kubernetes/config", token, map[string]interface{}{
 		"kubernetes_host":    k8sHost,
 		"kubernetes_ca_cert": string(caCert),
@@ -409,7 +448,7 @@ path "secret/metadata/k8s-provisioner/*" { capabilities = ["read", "list"] }`
 		return fmt.Errorf("create k8s role: %w", err)
 	}
 
-	fmt.Println("Kubernetes auth method configured successfully")
+	fmt.Fprintln(console(v.ctx), "Kubernetes auth method configured successfully")
 	return nil
 }
 
@@ -456,7 +495,7 @@ func (v *VaultInstaller) storeAPISecrets(token string) error {
 		return err
 	}
 
-	fmt.Printf("Stored %d secret(s) at secret/data/k8s-provisioner/api-keys\n", len(secrets))
+	fmt.Fprintf(console(v.ctx), "Stored %d secret(s) at secret/data/k8s-provisioner/api-keys\n", len(secrets))
 	return nil
 }
 
@@ -500,9 +539,20 @@ func generatePassword(length int) (string, error) {
 		}
 		result[i] = chars[n.Int64()]
 	}
+	redact.Add(string(result))
 	return string(result), nil
 }
 
+// vaultHTTPGet performs an unauthenticated GET (health/init probes), bound to
+// the installer context so Ctrl-C aborts it.
+func (v *VaultInstaller) vaultHTTPGet(path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(v.ctx, http.MethodGet, v.address+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return vaultHTTPClient.Do(req)
+}
+
 // vaultGet performs an authenticated GET to the Vault API.
 func (v *VaultInstaller) vaultGet(path, token string) (map[string]interface{}, error) {
 	return v.vaultRequest("GET", path, token, nil)
@@ -528,7 +578,7 @@ func (v *VaultInstaller) vaultRequest(method, path, token string, body interface
 		reqBody = bytes.NewReader(raw)
 	}
 
-	req, err := http.NewRequest(method, v.address+path, reqBody)
+	req, err := http.NewRequestWithContext(v.ctx, method, v.address+path, reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -543,7 +593,7 @@ func (v *VaultInstaller) vaultRequest(method, path, token string, body interface
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			fmt.Printf("Warning: failed to close response body: %v\n", err)
+			fmt.Fprintf(console(v.ctx), "Warning: failed to close response body: %v\n", err)
 		}
 	}()
 
@@ -565,20 +615,20 @@ func (v *VaultInstaller) vaultRequest(method, path, token string, body interface
 }
 
 func (v *VaultInstaller) printAccessInfo() {
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("   HashiCorp Vault configurado com sucesso!")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Printf("\nVault UI:  %s/ui\n", v.address)
-	fmt.Printf("Vault API: %s\n", v.address)
-	fmt.Printf("\nCredenciais salvas em: %s\n", VaultInitFileLocal)
-	fmt.Println("\nPara usar o Vault:")
-	fmt.Printf("  export VAULT_ADDR=%s\n", v.address)
-	fmt.Printf("  export VAULT_TOKEN=$(cat %s | jq -r .root_token)\n", VaultInitFileLocal)
-	fmt.Println("\nPara ler todos os secrets:")
-	fmt.Println("  vault kv get secret/k8s-provisioner/api-keys")
-	fmt.Println("\nSenha do Grafana:")
-	fmt.Println("  vault kv get -field=grafana_admin_password secret/k8s-provisioner/api-keys")
-	fmt.Println("\nAutenticação Kubernetes (em pods):")
-	fmt.Println("  vault write auth/kubernetes/login role=k8s-provisioner jwt=$SA_TOKEN")
-	fmt.Println(strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "   HashiCorp Vault configurado com sucesso!")
+	fmt.Fprintln(console(v.ctx), strings.Repeat("=", 50))
+	fmt.Fprintf(console(v.ctx), "\nVault UI:  %s/ui\n", v.address)
+	fmt.Fprintf(console(v.ctx), "Vault API: %s\n", v.address)
+	fmt.Fprintf(console(v.ctx), "\nCredenciais salvas em: %s\n", VaultInitFileLocal)
+	fmt.Fprintln(console(v.ctx), "\nPara usar o Vault:")
+	fmt.Fprintf(console(v.ctx), "  export VAULT_ADDR=%s\n", v.address)
+	fmt.Fprintf(console(v.ctx), "  export VAULT_TOKEN=$(cat %s | jq -r .root_token)\n", VaultInitFileLocal)
+	fmt.Fprintln(console(v.ctx), "\nPara ler todos os secrets:")
+	fmt.Fprintln(console(v.ctx), "  vault kv get secret/k8s-provisioner/api-keys")
+	fmt.Fprintln(console(v.ctx), "\nSenha do Grafana:")
+	fmt.Fprintln(console(v.ctx), "  vault kv get -field=grafana_admin_password secret/k8s-provisioner/api-keys")
+	fmt.Fprintln(console(v.ctx), "\nAutenticação Kubernetes (em pods):")
+	fmt.Fprintln(console(v.ctx), "  vault write auth/kubernetes/login role=k8s-provisioner jwt=$SA_TOKEN")
+	fmt.Fprintln(console(v.ctx), strings.Repeat("=", 50))
 }
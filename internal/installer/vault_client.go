@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
 )
 
 const vaultMount = "secret"
@@ -45,10 +47,12 @@ func ResolveVaultToken(configToken string) string {
 		return ""
 	}
 
-	if init.ProvisionerToken != "" {
-		return init.ProvisionerToken
+	token := init.ProvisionerToken
+	if token == "" {
+		token = init.RootToken
 	}
-	return init.RootToken
+	redact.Add(token)
+	return token
 }
 
 type VaultClient struct {
@@ -109,6 +113,11 @@ func (v *VaultClient) ReadSecret(path string) (map[string]string, error) {
 		return nil, err
 	}
 
+	// Everything under the KV mount is a credential; mask it wherever a
+	// command echoes, records or audits it.
+	for _, val := range result.Data.Data {
+		redact.Add(val)
+	}
 	return result.Data.Data, nil
 }
 
@@ -1,28 +1,31 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type VaultSecretsOperator struct {
+	ctx     context.Context
 	config  *config.Config
 	exec    executor.ShellExecutor
 	address string
 }
 
-func NewVaultSecretsOperator(cfg *config.Config, exec executor.ShellExecutor) *VaultSecretsOperator {
-	return &VaultSecretsOperator{config: cfg, exec: exec, address: cfg.VaultAddress()}
+func NewVaultSecretsOperator(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *VaultSecretsOperator {
+	return &VaultSecretsOperator{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec), address: cfg.VaultAddress()}
 }
 
 func (v *VaultSecretsOperator) Install() error {
-	fmt.Println("Installing Vault Secrets Operator...")
+	fmt.Fprintln(console(v.ctx), "Installing Vault Secrets Operator...")
 
-	if err := v.installHelm(); err != nil {
+	if err := withHelm(v.installHelm); err != nil {
 		return fmt.Errorf("helm installation failed: %w", err)
 	}
 
@@ -30,75 +33,128 @@ func (v *VaultSecretsOperator) Install() error {
 		return fmt.Errorf("VSO helm install failed: %w", err)
 	}
 
-	fmt.Println("Waiting for VSO controller to be ready...")
+	fmt.Fprintln(console(v.ctx), "Waiting for VSO controller to be ready...")
 	if err := v.waitForVSO(shortReadyTimeout); err != nil {
 		return fmt.Errorf("VSO did not become ready: %w", err)
 	}
 
 	if err := v.createKeycloakResources(); err != nil {
-		fmt.Printf("Warning: failed to create Keycloak VSO resources: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Warning: failed to create Keycloak VSO resources: %v\n", err)
 	}
 	if err := v.createMonitoringResources(); err != nil {
-		fmt.Printf("Warning: failed to create Monitoring VSO resources: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Warning: failed to create Monitoring VSO resources: %v\n", err)
 	}
 	if v.config.Ollama.APIKey != "" {
 		if err := v.createOllamaResources(); err != nil {
-			fmt.Printf("Warning: failed to create Ollama VSO resources: %v\n", err)
+			fmt.Fprintf(console(v.ctx), "Warning: failed to create Ollama VSO resources: %v\n", err)
 		}
 	}
 
-	fmt.Println("Waiting for secrets to sync from Vault...")
+	fmt.Fprintln(console(v.ctx), "Waiting for secrets to sync from Vault...")
 	if err := v.waitForSecrets(2 * time.Minute); err != nil {
-		fmt.Printf("Warning: secrets may not have fully synced yet: %v\n", err)
+		fmt.Fprintf(console(v.ctx), "Warning: secrets may not have fully synced yet: %v\n", err)
 	}
 
 	v.printStatus()
 	return nil
 }
 
+// vsoResources are the VaultAuth and VaultStaticSecret resources Install
+// creates, by namespace.
+var vsoResources = map[string][]string{
+	"keycloak":   {"vaultstaticsecret/keycloak-admin", "vaultstaticsecret/postgres-credentials", "vaultauth/vault-auth"},
+	"monitoring": {"vaultstaticsecret/grafana-admin", "vaultstaticsecret/grafana-oidc", "vaultauth/vault-auth"},
+	"ollama":     {"vaultstaticsecret/ollama-api-key", "vaultauth/vault-auth"},
+}
+
+// Uninstall deletes the VaultStaticSecrets and VaultAuths while the operator
+// can still clear their finalizers (the Secrets they created go with them),
+// then the operator release, its namespace and its CRDs.
+func (v *VaultSecretsOperator) Uninstall() error {
+	// Once the CRDs are gone (a re-run), there is nothing left to delete.
+	if _, err := v.exec.RunShell("kubectl get crd vaultstaticsecrets.secrets.hashicorp.com 2>/dev/null"); err == nil {
+		fmt.Fprintln(console(v.ctx), "Removing VaultStaticSecrets and VaultAuths...")
+		for _, ns := range []string{"keycloak", "monitoring", "ollama"} {
+			if err := deleteResources(v.exec, ns, vsoResources[ns]...); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintln(console(v.ctx), "Removing Vault Secrets Operator...")
+	if err := helmUninstall(v.exec, "vault-secrets-operator", "vault-secrets-operator-system"); err != nil {
+		return err
+	}
+	if err := deleteNamespace(v.exec, "vault-secrets-operator-system"); err != nil {
+		return err
+	}
+	// helm leaves chart CRDs behind.
+	_, err := v.exec.RunShell("kubectl get crd -o name | grep '\\.secrets\\.hashicorp\\.com$' | xargs -r kubectl delete --ignore-not-found")
+	return err
+}
+
+// Verify checks that the operator is ready and that every Secret its
+// VaultStaticSecrets sync from Vault exists.
+func (v *VaultSecretsOperator) Verify() error {
+	if err := expectReplicas(v.ctx, v.exec, "vault-secrets-operator-system", "vault-secrets-operator-controller-manager"); err != nil {
+		return err
+	}
+	for _, secret := range v.syncedSecrets() {
+		what := fmt.Sprintf("secret %s/%s to be synced from Vault", secret.namespace, secret.name)
+		if err := expect(v.ctx, v.exec, what, secret.check(), nonEmpty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var vsoChart = offline.Artifact{Kind: offline.Chart, Name: "vault-secrets-operator", URL: "https://helm.releases.hashicorp.com"}
+
+// Artifacts returns helm and the vault-secrets-operator chart.
+func (v *VaultSecretsOperator) Artifacts(arch string) []offline.Artifact {
+	return append(HelmArtifacts(arch), vsoChart)
+}
+
 func (v *VaultSecretsOperator) installHelm() error {
 	if _, err := v.exec.RunShell("helm version 2>/dev/null"); err == nil {
 		return nil
 	}
-	fmt.Println("Installing Helm...")
-	_, err := v.exec.RunShell("curl -fsSL --connect-timeout 10 --max-time 300 https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3 | bash")
-	return err
+	fmt.Fprintln(console(v.ctx), "Installing Helm...")
+	return installHelmBinary(v.ctx, v.config, v.exec)
 }
 
 func (v *VaultSecretsOperator) installVSO() error {
-	if _, err := v.exec.RunShell("helm repo add hashicorp https://helm.releases.hashicorp.com 2>/dev/null || true"); err != nil {
-		fmt.Printf("Warning: could not add HashiCorp Helm repo: %v\n", err)
-	}
-	if _, err := v.exec.RunShell("helm repo update hashicorp"); err != nil {
-		fmt.Printf("Warning: helm repo update failed: %v\n", err)
+	if !v.config.Offline.Enabled() {
+		helmMu.Lock()
+		if _, err := v.exec.RunShell("helm repo add hashicorp https://helm.releases.hashicorp.com 2>/dev/null || true"); err != nil {
+			fmt.Fprintf(console(v.ctx), "Warning: could not add HashiCorp Helm repo: %v\n", err)
+		}
+		if _, err := v.exec.RunShell("helm repo update hashicorp"); err != nil {
+			fmt.Fprintf(console(v.ctx), "Warning: helm repo update failed: %v\n", err)
+		}
+		helmMu.Unlock()
 	}
 
 	cmd := fmt.Sprintf(
-		"helm upgrade --install vault-secrets-operator hashicorp/vault-secrets-operator"+
+		"helm upgrade --install vault-secrets-operator %s"+
 			" -n vault-secrets-operator-system --create-namespace"+
 			" --set defaultVaultConnection.enabled=true"+
 			" --set 'defaultVaultConnection.address=%s'"+
 			" --wait --timeout=3m",
-		v.address,
+		chartRef(v.config, "hashicorp", vsoChart), v.address,
 	)
 	_, err := v.exec.RunShell(cmd)
 	return err
 }
 
 func (v *VaultSecretsOperator) waitForVSO(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return waitFor(v.ctx, "VSO controller", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, err := v.exec.RunShell(
 			"kubectl get deployment vault-secrets-operator-controller-manager" +
 				" -n vault-secrets-operator-system -o jsonpath='{.status.readyReplicas}' 2>/dev/null",
 		)
-		if err == nil && strings.TrimSpace(out) == "1" {
-			return nil
-		}
-		fmt.Println("Waiting for VSO controller...")
-		time.Sleep(defaultPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for VSO deployment")
+		return err == nil && strings.TrimSpace(out) == "1"
+	})
 }
 
 func (v *VaultSecretsOperator) createKeycloakResources() error {
@@ -161,7 +217,7 @@ spec:
         password:
           text: '{{- get .Secrets "keycloak_postgres_password" -}}'`
 
-	if err := executor.WriteFile("/tmp/vso-keycloak.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(v.exec, "/tmp/vso-keycloak.yaml", manifest); err != nil {
 		return err
 	}
 	_, err := v.exec.RunShell("kubectl apply -f /tmp/vso-keycloak.yaml")
@@ -224,7 +280,7 @@ spec:
         client-secret:
           text: '{{- get .Secrets "keycloak_grafana_client_secret" -}}'`
 
-	if err := executor.WriteFile("/tmp/vso-monitoring.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(v.exec, "/tmp/vso-monitoring.yaml", manifest); err != nil {
 		return err
 	}
 	_, err := v.exec.RunShell("kubectl apply -f /tmp/vso-monitoring.yaml")
@@ -268,61 +324,70 @@ spec:
         api-key:
           text: '{{- get .Secrets "ollama_api_key" -}}'`
 
-	if err := executor.WriteFile("/tmp/vso-ollama.yaml", manifest); err != nil {
+	if err := executor.WriteFileOn(v.exec, "/tmp/vso-ollama.yaml", manifest); err != nil {
 		return err
 	}
 	_, err := v.exec.RunShell("kubectl apply -f /tmp/vso-ollama.yaml")
 	return err
 }
 
-func (v *VaultSecretsOperator) waitForSecrets(timeout time.Duration) error {
-	checks := []string{
-		"kubectl get secret keycloak-admin -n keycloak 2>/dev/null",
-		"kubectl get secret postgres-credentials -n keycloak 2>/dev/null",
-		"kubectl get secret grafana-admin -n monitoring 2>/dev/null",
-		"kubectl get secret grafana-oidc -n monitoring 2>/dev/null",
+// syncedSecret is a Secret a VaultStaticSecret creates from Vault.
+type syncedSecret struct{ namespace, name string }
+
+// check is the command that prints the Secret, or nothing until it is synced.
+func (s syncedSecret) check() string {
+	return fmt.Sprintf("kubectl get secret %s -n %s 2>/dev/null", s.name, s.namespace)
+}
+
+// syncedSecrets returns the Secrets the VaultStaticSecrets Install creates
+// keep in sync.
+func (v *VaultSecretsOperator) syncedSecrets() []syncedSecret {
+	secrets := []syncedSecret{
+		{"keycloak", "keycloak-admin"},
+		{"keycloak", "postgres-credentials"},
+		{"monitoring", "grafana-admin"},
+		{"monitoring", "grafana-oidc"},
 	}
 	if v.config.Ollama.APIKey != "" {
-		checks = append(checks, "kubectl get secret ollama-api-key -n ollama 2>/dev/null")
+		secrets = append(secrets, syncedSecret{"ollama", "ollama-api-key"})
 	}
+	return secrets
+}
 
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		allReady := true
-		for _, cmd := range checks {
-			if out, _ := v.exec.RunShell(cmd); out == "" {
-				allReady = false
-				break
+func (v *VaultSecretsOperator) waitForSecrets(timeout time.Duration) error {
+	secrets := v.syncedSecrets()
+	if err := waitFor(v.ctx, "secrets to sync from Vault", pollUntil(timeout, defaultPollInterval), func() bool {
+		for _, secret := range secrets {
+			if out, _ := v.exec.RunShell(secret.check()); out == "" {
+				return false
 			}
 		}
-		if allReady {
-			fmt.Println("All secrets synced from Vault!")
-			return nil
-		}
-		fmt.Println("Waiting for secrets to sync...")
-		time.Sleep(defaultPollInterval)
+		return true
+	}); err != nil {
+		return err
 	}
-	return fmt.Errorf("timeout: not all secrets synced within %s", timeout)
+	fmt.Fprintln(console(v.ctx), "All secrets synced from Vault!")
+	return nil
 }
 
 func (v *VaultSecretsOperator) printStatus() {
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("   Vault Secrets Operator instalado!")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Println("\nRecursos criados:")
-	fmt.Println("  VaultStaticSecret/keycloak-admin       → Secret keycloak-admin (keycloak)")
-	fmt.Println("  VaultStaticSecret/postgres-credentials → Secret postgres-credentials (keycloak)")
-	fmt.Println("  VaultStaticSecret/grafana-admin        → Secret grafana-admin (monitoring)")
-	fmt.Println("  VaultStaticSecret/grafana-oidc         → Secret grafana-oidc (monitoring)")
+	fmt.Fprintln(console(v.ctx), "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "   Vault Secrets Operator instalado!")
+	fmt.Fprintln(console(v.ctx), strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "\nRecursos criados:")
+	fmt.Fprintln(console(v.ctx), "  VaultStaticSecret/keycloak-admin       → Secret keycloak-admin (keycloak)")
+	fmt.Fprintln(console(v.ctx), "  VaultStaticSecret/postgres-credentials → Secret postgres-credentials (keycloak)")
+	fmt.Fprintln(console(v.ctx), "  VaultStaticSecret/grafana-admin        → Secret grafana-admin (monitoring)")
+	fmt.Fprintln(console(v.ctx), "  VaultStaticSecret/grafana-oidc         → Secret grafana-oidc (monitoring)")
 	if v.config.Ollama.APIKey != "" {
-		fmt.Println("  VaultStaticSecret/ollama-api-key       → Secret ollama-api-key (ollama)")
+		fmt.Fprintln(console(v.ctx), "  VaultStaticSecret/ollama-api-key       → Secret ollama-api-key (ollama)")
 	}
-	fmt.Println("\nPara verificar o status dos secrets:")
-	fmt.Println("  kubectl get vaultstaticsecret -A")
-	fmt.Println("  kubectl get secrets -n keycloak")
-	fmt.Println("  kubectl get secrets -n monitoring")
+	fmt.Fprintln(console(v.ctx), "\nPara verificar o status dos secrets:")
+	fmt.Fprintln(console(v.ctx), "  kubectl get vaultstaticsecret -A")
+	fmt.Fprintln(console(v.ctx), "  kubectl get secrets -n keycloak")
+	fmt.Fprintln(console(v.ctx), "  kubectl get secrets -n monitoring")
 	if v.config.Ollama.APIKey != "" {
-		fmt.Println("  kubectl get secrets -n ollama")
+		fmt.Fprintln(console(v.ctx), "  kubectl get secrets -n ollama")
 	}
-	fmt.Println(strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), strings.Repeat("=", 50))
 }
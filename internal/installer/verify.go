@@ -0,0 +1,84 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// Helpers shared by the Verifier implementations.
+
+// expect runs command until ok accepts its trimmed output, for up to
+// verifyTimeout. The error names what and the last output or error.
+func expect(ctx context.Context, exec executor.ShellExecutor, what, command string, ok func(string) bool) error {
+	_, err := expectOutput(ctx, exec, what, command, ok)
+	return err
+}
+
+// expectOutput is expect, also returning the last output, accepted or not.
+func expectOutput(ctx context.Context, exec executor.ShellExecutor, what, command string, ok func(string) bool) (string, error) {
+	var last string
+	err := retry(ctx, what, pollUntil(verifyTimeout, shortPollInterval), func() error {
+		out, err := exec.RunShell(command)
+		if err != nil {
+			return err
+		}
+		if last = strings.TrimSpace(out); !ok(last) {
+			return fmt.Errorf("got %q", last)
+		}
+		return nil
+	})
+	return last, err
+}
+
+// nonEmpty accepts any output.
+func nonEmpty(out string) bool { return out != "" }
+
+// equals accepts exactly want.
+func equals(want string) func(string) bool {
+	return func(out string) bool { return out == want }
+}
+
+// contains accepts output containing want.
+func contains(want string) func(string) bool {
+	return func(out string) bool { return strings.Contains(out, want) }
+}
+
+// httpAnswered accepts any HTTP status printed by curl -w '%{http_code}';
+// curl prints 000 when nothing answered.
+func httpAnswered(code string) bool { return code != "" && code != "000" }
+
+// expectReplicas checks that the deployment in ns has at least one ready
+// replica.
+func expectReplicas(ctx context.Context, exec executor.ShellExecutor, ns, deployment string) error {
+	return expect(ctx, exec, fmt.Sprintf("deployment %s/%s to have a ready replica", ns, deployment),
+		fmt.Sprintf("kubectl get deployment %s -n %s -o jsonpath='{.status.readyReplicas}'", deployment, ns),
+		func(out string) bool { return out != "" && out != "0" })
+}
+
+// expectDaemonSet checks that the daemonset in ns has a ready pod on every
+// node it is scheduled to.
+func expectDaemonSet(ctx context.Context, exec executor.ShellExecutor, ns, daemonset string) error {
+	return expect(ctx, exec, fmt.Sprintf("daemonset %s/%s to be ready on every node", ns, daemonset),
+		fmt.Sprintf("kubectl get daemonset %s -n %s -o jsonpath='{.status.numberReady}/{.status.desiredNumberScheduled}'", daemonset, ns),
+		func(out string) bool {
+			ready, desired, ok := strings.Cut(out, "/")
+			return ok && ready != "0" && ready == desired
+		})
+}
+
+// expectServiceProxy checks that GET path on the in-cluster service
+// (name:port in ns), through the API server's service proxy, answers with
+// output ok accepts. The proxy needs no exposed port and no DNS.
+func expectServiceProxy(ctx context.Context, exec executor.ShellExecutor, what, ns, service, path string, ok func(string) bool) error {
+	return expect(ctx, exec, what,
+		fmt.Sprintf("kubectl get --raw /api/v1/namespaces/%s/services/%s/proxy%s", ns, service, path), ok)
+}
+
+// loadBalancerIP is the command that prints the external IP MetalLB assigned
+// to the LoadBalancer Service name in ns.
+func loadBalancerIP(ns, name string) string {
+	return fmt.Sprintf("kubectl get svc %s -n %s -o jsonpath='{.status.loadBalancer.ingress[0].ip}'", name, ns)
+}
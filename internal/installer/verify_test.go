@@ -0,0 +1,57 @@
+package installer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+func TestVaultVerify_ReportsSealed(t *testing.T) {
+	noWait(t)
+	cfg := &config.Config{}
+	cfg.Vault.Addr = "http://10.0.0.5:8200"
+	f := &fakeShell{outputs: map[string]string{"/v1/sys/health": "503"}}
+
+	err := NewVaultInstaller(context.Background(), cfg, f).Verify()
+	require.Error(t, err)
+	assert.Equal(t, "vault at http://10.0.0.5:8200 is sealed", err.Error())
+}
+
+func TestMetalLBVerify_RemovesTestService(t *testing.T) {
+	noWait(t)
+	f := &fakeShell{outputs: map[string]string{"{.status.loadBalancer.ingress[0].ip}": "192.168.56.200"}}
+
+	require.NoError(t, NewMetalLB(context.Background(), &config.Config{}, f).Verify())
+
+	last := f.calls[len(f.calls)-1]
+	assert.True(t, strings.HasPrefix(last, "kubectl delete service/k8s-provisioner-verify"), last)
+}
+
+func TestMetalLBVerify_FailsWithoutAnIP(t *testing.T) {
+	noWait(t)
+	f := &fakeShell{}
+
+	err := NewMetalLB(context.Background(), &config.Config{}, f).Verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MetalLB to assign a LoadBalancer IP")
+	assert.Contains(t, f.calls[len(f.calls)-1], "kubectl delete service/k8s-provisioner-verify", "cleaned up after a failure too")
+}
+
+func TestIstioVerify_AnyHTTPStatusCounts(t *testing.T) {
+	noWait(t)
+	f := &fakeShell{outputs: map[string]string{
+		"{.status.readyReplicas}":              "1",
+		"{.status.loadBalancer.ingress[0].ip}": "192.168.56.200",
+		"http_code":                            "404",
+	}}
+	require.NoError(t, NewIstio(context.Background(), &config.Config{}, f).Verify())
+	assert.Contains(t, f.calls, "curl -s -o /dev/null -w '%{http_code}' --max-time 5 http://192.168.56.200/")
+
+	f.outputs["http_code"] = "000"
+	assert.Error(t, NewIstio(context.Background(), &config.Config{}, f).Verify(), "nothing answered")
+}
@@ -1,45 +1,52 @@
 package installer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
 )
 
 type VPA struct {
+	ctx    context.Context
 	config *config.Config
 	exec   executor.ShellExecutor
 }
 
-func NewVPA(cfg *config.Config, exec executor.ShellExecutor) *VPA {
-	return &VPA{config: cfg, exec: exec}
+func NewVPA(ctx context.Context, cfg *config.Config, exec executor.ShellExecutor) *VPA {
+	return &VPA{ctx: ctx, config: cfg, exec: executor.WithShellContext(ctx, exec)}
 }
 
 func (v *VPA) Install() error {
-	fmt.Println("Installing VPA (Vertical Pod Autoscaler)...")
+	fmt.Fprintln(console(v.ctx), "Installing VPA (Vertical Pod Autoscaler)...")
 
-	if err := v.installHelm(); err != nil {
+	if err := withHelm(v.installHelm); err != nil {
 		return fmt.Errorf("helm installation failed: %w", err)
 	}
 
-	if _, err := v.exec.RunShell("helm repo add cowboysysop https://cowboysysop.github.io/charts 2>/dev/null || true"); err != nil {
-		fmt.Printf("Warning: could not add cowboysysop Helm repo: %v\n", err)
-	}
-	if _, err := v.exec.RunShell("helm repo update cowboysysop"); err != nil {
-		fmt.Printf("Warning: helm repo update failed: %v\n", err)
+	if !v.config.Offline.Enabled() {
+		helmMu.Lock()
+		if _, err := v.exec.RunShell("helm repo add cowboysysop https://cowboysysop.github.io/charts 2>/dev/null || true"); err != nil {
+			fmt.Fprintf(console(v.ctx), "Warning: could not add cowboysysop Helm repo: %v\n", err)
+		}
+		if _, err := v.exec.RunShell("helm repo update cowboysysop"); err != nil {
+			fmt.Fprintf(console(v.ctx), "Warning: helm repo update failed: %v\n", err)
+		}
+		helmMu.Unlock()
 	}
 
-	cmd := "helm upgrade --install vpa cowboysysop/vertical-pod-autoscaler" +
+	cmd := "helm upgrade --install vpa " + chartRef(v.config, "cowboysysop", vpaChart) +
 		" --namespace kube-system" +
 		" --wait --timeout=3m"
 	if _, err := v.exec.RunShell(cmd); err != nil {
 		return fmt.Errorf("vpa helm install failed: %w", err)
 	}
 
-	fmt.Println("Waiting for VPA to be ready...")
+	fmt.Fprintln(console(v.ctx), "Waiting for VPA to be ready...")
 	if err := v.waitForReady(shortReadyTimeout); err != nil {
 		return fmt.Errorf("vpa did not become ready: %w", err)
 	}
@@ -48,37 +55,44 @@ func (v *VPA) Install() error {
 	return nil
 }
 
+// Uninstall removes the VPA helm release. VerticalPodAutoscaler objects
+// created by users go with its CRDs.
+func (v *VPA) Uninstall() error {
+	fmt.Fprintln(console(v.ctx), "Removing VPA...")
+	return helmUninstall(v.exec, "vpa", "kube-system")
+}
+
+var vpaChart = offline.Artifact{Kind: offline.Chart, Name: "vertical-pod-autoscaler", URL: "https://cowboysysop.github.io/charts"}
+
+// Artifacts returns helm and the vertical-pod-autoscaler chart.
+func (v *VPA) Artifacts(arch string) []offline.Artifact {
+	return append(HelmArtifacts(arch), vpaChart)
+}
+
 func (v *VPA) installHelm() error {
 	if _, err := v.exec.RunShell("helm version 2>/dev/null"); err == nil {
 		return nil
 	}
-	fmt.Println("Installing Helm...")
-	_, err := v.exec.RunShell("curl -fsSL --connect-timeout 10 --max-time 300 https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3 | bash")
-	return err
+	fmt.Fprintln(console(v.ctx), "Installing Helm...")
+	return installHelmBinary(v.ctx, v.config, v.exec)
 }
 
 func (v *VPA) waitForReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return waitFor(v.ctx, "VPA recommender", pollUntil(timeout, defaultPollInterval), func() bool {
 		out, err := v.exec.RunShell(
 			"kubectl get deployment vpa-vertical-pod-autoscaler-recommender -n kube-system -o jsonpath='{.status.readyReplicas}' 2>/dev/null",
 		)
-		if err == nil && strings.TrimSpace(out) == "1" {
-			return nil
-		}
-		fmt.Println("Waiting for VPA recommender...")
-		time.Sleep(defaultPollInterval)
-	}
-	return fmt.Errorf("timeout waiting for VPA recommender")
+		return err == nil && strings.TrimSpace(out) == "1"
+	})
 }
 
 func (v *VPA) printAccessInfo() {
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("   VPA instalado!")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Println("\nComponentes: Recommender, Updater, Admission Controller")
-	fmt.Println("\nExemplo — VerticalPodAutoscaler em modo Auto:")
-	fmt.Println(`  apiVersion: autoscaling.k8s.io/v1
+	fmt.Fprintln(console(v.ctx), "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "   VPA instalado!")
+	fmt.Fprintln(console(v.ctx), strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "\nComponentes: Recommender, Updater, Admission Controller")
+	fmt.Fprintln(console(v.ctx), "\nExemplo — VerticalPodAutoscaler em modo Auto:")
+	fmt.Fprintln(console(v.ctx), `  apiVersion: autoscaling.k8s.io/v1
   kind: VerticalPodAutoscaler
   metadata:
     name: prometheus-vpa
@@ -99,9 +113,9 @@ func (v *VPA) printAccessInfo() {
         maxAllowed:
           cpu: 2
           memory: 2Gi`)
-	fmt.Println("\nPara ver recomendações:")
-	fmt.Println("  kubectl get vpa -A")
-	fmt.Println("  kubectl describe vpa <name> -n <namespace>")
-	fmt.Println("\nAtenção: não use VPA e HPA/KEDA no mesmo deployment.")
-	fmt.Println(strings.Repeat("=", 50))
+	fmt.Fprintln(console(v.ctx), "\nPara ver recomendações:")
+	fmt.Fprintln(console(v.ctx), "  kubectl get vpa -A")
+	fmt.Fprintln(console(v.ctx), "  kubectl describe vpa <name> -n <namespace>")
+	fmt.Fprintln(console(v.ctx), "\nAtenção: não use VPA e HPA/KEDA no mesmo deployment.")
+	fmt.Fprintln(console(v.ctx), strings.Repeat("=", 50))
 }
@@ -0,0 +1,68 @@
+package join
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// fetchTimeout bounds one request to the join endpoint.
+const fetchTimeout = 30 * time.Second
+
+// Fetch asks the join endpoint at baseURL (https://host:port, see Server) for
+// node's credentials. The endpoint's certificate must have the SHA-256
+// fingerprint given: it is self-signed, so it is pinned rather than verified
+// against a CA.
+func Fetch(ctx context.Context, baseURL, fingerprint, node string) (Credentials, error) {
+	client := &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			MinVersion:            tls.VersionTLS12,
+			InsecureSkipVerify:    true, //nolint:gosec // pinned by fingerprint instead
+			VerifyPeerCertificate: pinned(fingerprint),
+		}},
+	}
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/join?node=" + url.QueryEscape(node)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("join endpoint: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("join endpoint: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Credentials{}, fmt.Errorf("join endpoint: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var creds Credentials
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return Credentials{}, fmt.Errorf("join endpoint: decode credentials: %w", err)
+	}
+	if creds.APIServerEndpoint == "" || creds.Token == "" || creds.CACertHash == "" {
+		return Credentials{}, fmt.Errorf("join endpoint: incomplete credentials")
+	}
+	return creds, nil
+}
+
+// pinned accepts only a leaf certificate with the given fingerprint.
+func pinned(want string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("join endpoint presented no certificate")
+		}
+		if got := fingerprint(rawCerts[0]); !strings.EqualFold(got, want) {
+			return fmt.Errorf("join endpoint certificate fingerprint %s does not match provisioning.join.fingerprint", got)
+		}
+		return nil
+	}
+}
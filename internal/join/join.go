@@ -0,0 +1,125 @@
+// Package join issues the credentials a node needs to join the cluster and
+// renders them as a kubeadm JoinConfiguration: a bootstrap token that expires
+// after TokenTTL, the hash of the cluster CA's public key so the node can
+// authenticate the API server, and, for additional control planes, the key of
+// the control plane certificates kubeadm uploaded to the cluster.
+//
+// The credentials are created on a running control plane and handed to the
+// joining node either over SSH or through the one-shot HTTPS endpoint Serve
+// runs there; they are never left in a shared folder.
+package join
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/kubeadm"
+)
+
+// TokenTTL is how long an issued bootstrap token stays valid: long enough for
+// one kubeadm join, short enough that a leaked one is useless soon after.
+const TokenTTL = 15 * time.Minute
+
+// CACertPath is the cluster CA certificate on a control plane node.
+const CACertPath = "/etc/kubernetes/pki/ca.crt"
+
+// ConfigPath is where the joining node's JoinConfiguration is written. It holds
+// the bootstrap token, so it is created mode 0600 and removed after the join.
+const ConfigPath = "/etc/kubernetes/kubeadm-join.yaml"
+
+// Credentials is what a node needs to join the cluster.
+type Credentials struct {
+	// APIServerEndpoint is host:port of the API server to join through.
+	APIServerEndpoint string `json:"apiServerEndpoint"`
+	// Token is the bootstrap token, valid for TokenTTL.
+	Token string `json:"token"`
+	// CACertHash pins the cluster CA ("sha256:<hex>").
+	CACertHash string `json:"caCertHash"`
+	// CertificateKey decrypts the uploaded control plane certificates. Set
+	// only for control plane joins.
+	CertificateKey string `json:"certificateKey,omitempty"`
+}
+
+// ControlPlane reports whether c lets a node join as a control plane.
+func (c Credentials) ControlPlane() bool { return c.CertificateKey != "" }
+
+// Issue creates credentials on a control plane through exec, for a node
+// joining through endpoint (host, port 6443). controlPlane also uploads the
+// control plane certificates again for a fresh key: the one from kubeadm init
+// expires after two hours.
+func Issue(exec executor.ShellExecutor, endpoint string, controlPlane bool) (Credentials, error) {
+	creds := Credentials{APIServerEndpoint: endpoint + ":6443"}
+
+	token, err := exec.RunShell(fmt.Sprintf("kubeadm token create --ttl %s --description 'k8s-provisioner node join'", TokenTTL))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("create bootstrap token: %w", err)
+	}
+	creds.Token = lastLine(token)
+	if creds.Token == "" {
+		return Credentials{}, fmt.Errorf("create bootstrap token: kubeadm printed no token")
+	}
+
+	ca, err := exec.RunShell("cat " + CACertPath)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("read cluster CA: %w", err)
+	}
+	if creds.CACertHash, err = CACertHash([]byte(ca)); err != nil {
+		return Credentials{}, err
+	}
+
+	if controlPlane {
+		out, err := exec.RunShell("kubeadm init phase upload-certs --upload-certs")
+		if err != nil {
+			return Credentials{}, fmt.Errorf("upload control plane certificates: %w", err)
+		}
+		creds.CertificateKey = lastLine(out)
+		if creds.CertificateKey == "" {
+			return Credentials{}, fmt.Errorf("upload control plane certificates: no certificate key in %q", out)
+		}
+	}
+	return creds, nil
+}
+
+// lastLine returns the last line of out, trimmed: kubeadm prints the value
+// asked for after any informational lines.
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// CACertHash returns the discovery hash of the PEM-encoded CA certificate
+// caPEM: the SHA-256 of its Subject Public Key Info, as kubeadm's
+// --discovery-token-ca-cert-hash expects it.
+func CACertHash(caPEM []byte) (string, error) {
+	block, _ := pem.Decode(caPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("cluster CA: no PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("cluster CA: %w", err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Configuration renders the kubeadm JoinConfiguration for the node named name,
+// whose container runtime listens on criSocket: token discovery pinned to the
+// cluster CA. Credentials for a control plane add the control plane section,
+// with the API server advertising advertise (the node's nodes[].ip).
+func Configuration(c Credentials, criSocket, name, advertise string) (string, error) {
+	doc := kubeadm.NewJoinConfiguration(c.APIServerEndpoint, c.Token, c.CACertHash, criSocket, name)
+	if c.ControlPlane() {
+		doc.ControlPlane = &kubeadm.JoinControlPlane{CertificateKey: c.CertificateKey}
+		if advertise != "" {
+			doc.ControlPlane.LocalAPIEndpoint = &kubeadm.APIEndpoint{AdvertiseAddress: advertise, BindPort: 6443}
+		}
+	}
+	return kubeadm.Render(doc)
+}
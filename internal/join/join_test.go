@@ -0,0 +1,196 @@
+package join
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+// cannedShell answers each command with the output of the first key it
+// contains, recording the commands.
+type cannedShell struct {
+	answers map[string]string
+	calls   []string
+}
+
+func (c *cannedShell) RunShell(command string) (string, error) {
+	c.calls = append(c.calls, command)
+	for k, v := range c.answers {
+		if strings.Contains(command, k) {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *cannedShell) RunShellWithOutput(command string) error {
+	_, err := c.RunShell(command)
+	return err
+}
+
+func (c *cannedShell) RunShellWithStdin(command, _ string) (string, error) {
+	return c.RunShell(command)
+}
+
+func testCA(t *testing.T) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), key
+}
+
+func TestCACertHash_PinsThePublicKey(t *testing.T) {
+	ca, key := testCA(t)
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	sum := sha256.Sum256(spki)
+
+	hash, err := CACertHash([]byte(ca))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), hash)
+
+	_, err = CACertHash([]byte("not a certificate"))
+	assert.Error(t, err)
+}
+
+func TestIssue(t *testing.T) {
+	ca, _ := testCA(t)
+	hash, err := CACertHash([]byte(ca))
+	require.NoError(t, err)
+	exec := &cannedShell{answers: map[string]string{
+		"token create": "abcdef.0123456789abcdef\n",
+		CACertPath:     ca,
+		"upload-certs": "[upload-certs] Using certificate key:\n0f3c9a\n",
+	}}
+
+	creds, err := Issue(exec, "192.168.56.9", false)
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{APIServerEndpoint: "192.168.56.9:6443", Token: "abcdef.0123456789abcdef", CACertHash: hash}, creds)
+	assert.Equal(t, []string{"kubeadm token create --ttl 15m0s --description 'k8s-provisioner node join'", "cat " + CACertPath}, exec.calls)
+
+	creds, err = Issue(exec, "192.168.56.9", true)
+	require.NoError(t, err)
+	assert.Equal(t, "0f3c9a", creds.CertificateKey, "control planes also get a fresh certificate key")
+}
+
+func TestConfiguration(t *testing.T) {
+	creds := Credentials{APIServerEndpoint: "192.168.56.9:6443", Token: "abcdef.0123456789abcdef", CACertHash: "sha256:00"}
+	worker, err := Configuration(creds, "unix:///run/containerd/containerd.sock", "node01", "192.168.56.11")
+	require.NoError(t, err)
+	assert.Contains(t, worker, "kind: JoinConfiguration\n")
+	assert.Contains(t, worker, "    apiServerEndpoint: 192.168.56.9:6443\n")
+	assert.Contains(t, worker, "    token: abcdef.0123456789abcdef\n")
+	assert.Contains(t, worker, "    - sha256:00\n")
+	assert.Contains(t, worker, "  criSocket: unix:///run/containerd/containerd.sock\n  name: node01\n")
+	assert.NotContains(t, worker, "controlPlane:")
+
+	creds.CertificateKey = "0f3c9a"
+	cp, err := Configuration(creds, "unix:///var/run/crio/crio.sock", "cp2", "192.168.56.13")
+	require.NoError(t, err)
+	assert.Contains(t, cp, "controlPlane:\n  certificateKey: 0f3c9a\n  localAPIEndpoint:\n    advertiseAddress: 192.168.56.13\n")
+}
+
+func serverConfig() *config.Config {
+	return &config.Config{Nodes: []config.NodeConfig{
+		{Name: "cp1", IP: "127.0.0.1", Role: "controlplane"},
+		{Name: "nfs", IP: "127.0.0.1", Role: "storage"},
+		{Name: "node01", IP: "127.0.0.1", Role: "worker"},
+		{Name: "node02", IP: "192.168.56.12", Role: "worker"},
+	}}
+}
+
+func TestServer_ChecksTheNode(t *testing.T) {
+	server := NewServer(serverConfig(), func(node config.NodeConfig) (Credentials, error) {
+		return Credentials{APIServerEndpoint: "192.168.56.10:6443", Token: "t." + node.Name, CACertHash: "sha256:00"}, nil
+	}, io.Discard)
+	endpoint := httptest.NewTLSServer(server)
+	defer endpoint.Close()
+	fingerprint := Fingerprint(endpoint.TLS.Certificates[0])
+	assert.Equal(t, []string{"node01", "node02"}, server.Pending())
+
+	creds, err := Fetch(context.Background(), endpoint.URL, fingerprint, "node01")
+	require.NoError(t, err)
+	assert.Equal(t, "t.node01", creds.Token)
+
+	tests := []struct {
+		node, err string
+	}{
+		{"node01", "410 Gone: credentials already served"},
+		{"node02", "403 Forbidden: request does not come from nodes[].ip (192.168.56.12)"},
+		{"cp1", "403 Forbidden: controlplane nodes do not join through this endpoint"},
+		{"nfs", "403 Forbidden: storage nodes do not join through this endpoint"},
+		{"intruder", "403 Forbidden: not a node in config"},
+	}
+	for _, tt := range tests {
+		_, err := Fetch(context.Background(), endpoint.URL, fingerprint, tt.node)
+		require.Error(t, err, tt.node)
+		assert.Contains(t, err.Error(), tt.err, tt.node)
+	}
+	assert.Equal(t, []string{"node02"}, server.Pending())
+}
+
+func TestFetch_RejectsAnotherCertificate(t *testing.T) {
+	server := NewServer(serverConfig(), func(config.NodeConfig) (Credentials, error) {
+		t.Fatal("credentials issued to a client that did not trust the endpoint")
+		return Credentials{}, nil
+	}, io.Discard)
+	endpoint := httptest.NewTLSServer(server)
+	defer endpoint.Close()
+
+	_, err := Fetch(context.Background(), endpoint.URL, "sha256:"+strings.Repeat("00", 32), "node01")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match provisioning.join.fingerprint")
+}
+
+func TestServe_StopsOnceEveryNodeIsServed(t *testing.T) {
+	cfg := serverConfig()
+	cfg.Nodes = cfg.Nodes[:3]
+	server := NewServer(cfg, func(config.NodeConfig) (Credentials, error) {
+		return Credentials{APIServerEndpoint: "192.168.56.10:6443", Token: "t", CACertHash: "sha256:00"}, nil
+	}, io.Discard)
+	dir := t.TempDir()
+	cert, err := LoadOrCreateCertificate(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), []string{"127.0.0.1"})
+	require.NoError(t, err)
+	again, err := LoadOrCreateCertificate(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), []string{"127.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint(cert), Fingerprint(again), "the certificate is kept across runs")
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(context.Background(), "127.0.0.1:0", cert, time.Minute) }()
+	_, _, err = server.serve("node01", "127.0.0.1")
+	require.NoError(t, err)
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("join endpoint still serving after every node got its credentials")
+	}
+}
@@ -0,0 +1,247 @@
+package join
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+// DefaultPort is the port Serve listens on unless told otherwise.
+const DefaultPort = 9444
+
+// Serving certificate of the join endpoint. It is kept across runs so the
+// fingerprint configured on the nodes (provisioning.join.fingerprint) stays
+// valid.
+const (
+	CertPath = "/etc/k8s-provisioner/join-tls.crt"
+	KeyPath  = "/etc/k8s-provisioner/join-tls.key"
+)
+
+// IssueFunc creates the credentials for node.
+type IssueFunc func(node config.NodeConfig) (Credentials, error)
+
+// Server hands out join credentials over HTTPS, once per node: a node asks for
+// its own (GET /join?node=<name>), and gets them only when the name is a worker
+// or an additional control plane in config.Nodes, the request comes from that
+// node's nodes[].ip, and it has not been served yet.
+type Server struct {
+	cfg   *config.Config
+	issue IssueFunc
+	out   io.Writer
+
+	mu      sync.Mutex
+	pending map[string]bool
+	done    chan struct{}
+}
+
+// NewServer returns a Server for the nodes of cfg, issuing credentials with
+// issue and logging each request to out.
+func NewServer(cfg *config.Config, issue IssueFunc, out io.Writer) *Server {
+	s := &Server{cfg: cfg, issue: issue, out: out, pending: map[string]bool{}, done: make(chan struct{})}
+	for _, name := range Joiners(cfg) {
+		s.pending[name] = true
+	}
+	if len(s.pending) == 0 {
+		close(s.done)
+	}
+	return s
+}
+
+// Joiners returns the names of the nodes that join an existing cluster: the
+// workers and every control plane but the first, which bootstraps it.
+func Joiners(cfg *config.Config) []string {
+	var names []string
+	first := cfg.GetControlPlane()
+	for _, n := range cfg.Nodes {
+		switch {
+		case n.Role == "worker":
+		case n.Role == "controlplane" && first != nil && n.Name != first.Name:
+		default:
+			continue
+		}
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// Pending returns the nodes not served yet, sorted.
+func (s *Server) Pending() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.pending))
+	for name := range s.pending {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.Path != "/join" {
+		http.NotFound(w, r)
+		return
+	}
+	name := r.URL.Query().Get("node")
+	peer, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	creds, status, err := s.serve(name, peer)
+	if err != nil {
+		fmt.Fprintf(s.out, "✗ %s from %s: %v\n", name, peer, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	fmt.Fprintf(s.out, "✓ %s from %s\n", name, peer)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(creds)
+}
+
+// serve checks that peer may fetch name's credentials and issues them,
+// returning the HTTP status to answer with on error.
+func (s *Server) serve(name, peer string) (Credentials, int, error) {
+	node := s.cfg.GetNode(name)
+	if node == nil {
+		return Credentials{}, http.StatusForbidden, fmt.Errorf("not a node in config")
+	}
+	if node.IP == "" || node.IP != peer {
+		return Credentials{}, http.StatusForbidden, fmt.Errorf("request does not come from nodes[].ip (%s)", node.IP)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pending[name] {
+		if slices.Contains(Joiners(s.cfg), name) {
+			return Credentials{}, http.StatusGone, fmt.Errorf("credentials already served")
+		}
+		return Credentials{}, http.StatusForbidden, fmt.Errorf("%s nodes do not join through this endpoint", node.Role)
+	}
+	creds, err := s.issue(*node)
+	if err != nil {
+		return Credentials{}, http.StatusInternalServerError, err
+	}
+	delete(s.pending, name)
+	if len(s.pending) == 0 {
+		close(s.done)
+	}
+	return creds, http.StatusOK, nil
+}
+
+// Serve answers join requests on addr with cert until every joining node has
+// been served, ttl has passed, or ctx is cancelled. Running out of time with
+// nodes left unserved is an error naming them.
+func (s *Server) Serve(ctx context.Context, addr string, cert tls.Certificate, ttl time.Duration) error {
+	ln, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return fmt.Errorf("join endpoint: %w", err)
+	}
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+	var waitErr error
+	select {
+	case <-s.done:
+	case <-timer.C:
+		waitErr = fmt.Errorf("join endpoint closed after %s; not served: %s", ttl, strings.Join(s.Pending(), ", "))
+	case <-ctx.Done():
+		waitErr = context.Cause(ctx)
+	case err := <-errc:
+		return fmt.Errorf("join endpoint: %w", err)
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil && waitErr == nil {
+		waitErr = err
+	}
+	return waitErr
+}
+
+// Fingerprint returns the SHA-256 of cert's leaf certificate ("sha256:<hex>"),
+// which clients pin in place of a CA.
+func Fingerprint(cert tls.Certificate) string {
+	return fingerprint(cert.Certificate[0])
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// LoadOrCreateCertificate loads the serving certificate from certPath and
+// keyPath, first creating a self-signed one valid for hosts when they do not
+// exist yet.
+func LoadOrCreateCertificate(certPath, keyPath string, hosts []string) (tls.Certificate, error) {
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		return cert, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return tls.Certificate{}, fmt.Errorf("join endpoint certificate: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate join endpoint key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "k8s-provisioner join"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if h != "" {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create join endpoint certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("write join endpoint key: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("write join endpoint certificate: %w", err)
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
@@ -0,0 +1,211 @@
+// Package kubeadm renders the configuration documents kubeadm init and
+// kubeadm join read (kubeadm.k8s.io/v1beta4 and the kubelet's
+// kubelet.config.k8s.io/v1beta1): the settings the provisioner manages, merged
+// with the kubeadm section of config.yaml.
+package kubeadm
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+const (
+	apiVersion        = "kubeadm.k8s.io/v1beta4"
+	kubeletAPIVersion = "kubelet.config.k8s.io/v1beta1"
+)
+
+// Arg is one component flag, without the leading dashes.
+type Arg struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// HostPathMount mounts a host path into a control plane static pod.
+type HostPathMount struct {
+	Name      string `yaml:"name"`
+	HostPath  string `yaml:"hostPath"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+	PathType  string `yaml:"pathType,omitempty"`
+}
+
+// ControlPlaneComponent holds the extra flags and volumes of a control plane
+// static pod.
+type ControlPlaneComponent struct {
+	ExtraArgs    []Arg           `yaml:"extraArgs,omitempty"`
+	ExtraVolumes []HostPathMount `yaml:"extraVolumes,omitempty"`
+}
+
+// AddArgs appends args in name order.
+func (c *ControlPlaneComponent) AddArgs(args map[string]string) {
+	for _, name := range slices.Sorted(maps.Keys(args)) {
+		c.ExtraArgs = append(c.ExtraArgs, Arg{Name: name, Value: args[name]})
+	}
+}
+
+type APIEndpoint struct {
+	AdvertiseAddress string `yaml:"advertiseAddress"`
+	BindPort         int    `yaml:"bindPort,omitempty"`
+}
+
+type NodeRegistration struct {
+	CRISocket string `yaml:"criSocket"`
+	Name      string `yaml:"name"`
+}
+
+type InitConfiguration struct {
+	APIVersion       string           `yaml:"apiVersion"`
+	Kind             string           `yaml:"kind"`
+	LocalAPIEndpoint APIEndpoint      `yaml:"localAPIEndpoint"`
+	NodeRegistration NodeRegistration `yaml:"nodeRegistration"`
+}
+
+type Networking struct {
+	PodSubnet     string `yaml:"podSubnet,omitempty"`
+	ServiceSubnet string `yaml:"serviceSubnet,omitempty"`
+}
+
+type ClusterConfiguration struct {
+	APIVersion           string                `yaml:"apiVersion"`
+	Kind                 string                `yaml:"kind"`
+	ControlPlaneEndpoint string                `yaml:"controlPlaneEndpoint"`
+	Networking           Networking            `yaml:"networking"`
+	APIServer            ControlPlaneComponent `yaml:"apiServer,omitempty"`
+	ControllerManager    ControlPlaneComponent `yaml:"controllerManager,omitempty"`
+	Scheduler            ControlPlaneComponent `yaml:"scheduler,omitempty"`
+}
+
+type KubeletConfiguration struct {
+	APIVersion     string            `yaml:"apiVersion"`
+	Kind           string            `yaml:"kind"`
+	MaxPods        int               `yaml:"maxPods,omitempty"`
+	SystemReserved map[string]string `yaml:"systemReserved,omitempty"`
+	KubeReserved   map[string]string `yaml:"kubeReserved,omitempty"`
+	EvictionHard   map[string]string `yaml:"evictionHard,omitempty"`
+	FeatureGates   map[string]bool   `yaml:"featureGates,omitempty"`
+}
+
+type BootstrapTokenDiscovery struct {
+	APIServerEndpoint string   `yaml:"apiServerEndpoint"`
+	Token             string   `yaml:"token"`
+	CACertHashes      []string `yaml:"caCertHashes"`
+}
+
+type Discovery struct {
+	BootstrapToken BootstrapTokenDiscovery `yaml:"bootstrapToken"`
+}
+
+type JoinControlPlane struct {
+	CertificateKey   string       `yaml:"certificateKey"`
+	LocalAPIEndpoint *APIEndpoint `yaml:"localAPIEndpoint,omitempty"`
+}
+
+type JoinConfiguration struct {
+	APIVersion       string            `yaml:"apiVersion"`
+	Kind             string            `yaml:"kind"`
+	Discovery        Discovery         `yaml:"discovery"`
+	NodeRegistration NodeRegistration  `yaml:"nodeRegistration"`
+	ControlPlane     *JoinControlPlane `yaml:"controlPlane,omitempty"`
+}
+
+// NewInitConfiguration returns the InitConfiguration for the first control
+// plane, node, whose API server advertises advertise.
+func NewInitConfiguration(advertise, criSocket, node string) InitConfiguration {
+	return InitConfiguration{
+		APIVersion:       apiVersion,
+		Kind:             "InitConfiguration",
+		LocalAPIEndpoint: APIEndpoint{AdvertiseAddress: advertise},
+		NodeRegistration: NodeRegistration{CRISocket: criSocket, Name: node},
+	}
+}
+
+// NewClusterConfiguration returns the ClusterConfiguration for cfg's control
+// plane endpoint and pod and service subnets. Set the managed flags on its
+// components, then Merge the kubeadm section of config.yaml.
+func NewClusterConfiguration(cfg *config.Config) ClusterConfiguration {
+	return ClusterConfiguration{
+		APIVersion:           apiVersion,
+		Kind:                 "ClusterConfiguration",
+		ControlPlaneEndpoint: cfg.ControlPlaneEndpoint() + ":6443",
+		Networking:           Networking{PodSubnet: cfg.Cluster.PodCIDR, ServiceSubnet: cfg.Cluster.ServiceCIDR},
+	}
+}
+
+// Merge appends the extra args and feature gates of k to the control plane
+// components. Validation keeps them apart from the managed flags
+// (config.ManagedFlags).
+func (c *ClusterConfiguration) Merge(k config.KubeadmConfig) {
+	c.APIServer.AddArgs(k.APIServerExtraArgs)
+	c.ControllerManager.AddArgs(k.ControllerManagerExtraArgs)
+	c.Scheduler.AddArgs(k.SchedulerExtraArgs)
+	if gates := featureGates(k.FeatureGates); gates != "" {
+		for _, component := range []*ControlPlaneComponent{&c.APIServer, &c.ControllerManager, &c.Scheduler} {
+			component.ExtraArgs = append(component.ExtraArgs, Arg{Name: "feature-gates", Value: gates})
+		}
+	}
+}
+
+// featureGates renders gates as the --feature-gates value, in name order.
+func featureGates(gates map[string]bool) string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(gates)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, gates[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// NewKubeletConfiguration returns the KubeletConfiguration kubeadm init
+// stores in the cluster, for every node's kubelet.
+func NewKubeletConfiguration(cfg *config.Config) KubeletConfiguration {
+	k := cfg.Kubeadm
+	return KubeletConfiguration{
+		APIVersion:     kubeletAPIVersion,
+		Kind:           "KubeletConfiguration",
+		MaxPods:        k.Kubelet.MaxPods,
+		SystemReserved: k.Kubelet.SystemReserved,
+		KubeReserved:   k.Kubelet.KubeReserved,
+		EvictionHard:   k.Kubelet.EvictionHard,
+		FeatureGates:   k.FeatureGates,
+	}
+}
+
+// NewJoinConfiguration returns the JoinConfiguration for token discovery,
+// pinned to the cluster CA with caCertHash.
+func NewJoinConfiguration(endpoint, token, caCertHash, criSocket, node string) JoinConfiguration {
+	return JoinConfiguration{
+		APIVersion: apiVersion,
+		Kind:       "JoinConfiguration",
+		Discovery: Discovery{BootstrapToken: BootstrapTokenDiscovery{
+			APIServerEndpoint: endpoint,
+			Token:             token,
+			CACertHashes:      []string{caCertHash},
+		}},
+		NodeRegistration: NodeRegistration{CRISocket: criSocket, Name: node},
+	}
+}
+
+// Render joins docs into one multi-document YAML file.
+func Render(docs ...any) (string, error) {
+	var out bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return "", fmt.Errorf("render kubeadm configuration: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return "", fmt.Errorf("render kubeadm configuration: %w", err)
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,89 @@
+package kubeadm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+func testConfig() *config.Config {
+	cfg := &config.Config{
+		Cluster: config.ClusterConfig{PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.100.0.0/16"},
+		Network: config.NetworkConfig{ControlPlaneIP: "192.168.56.10"},
+	}
+	cfg.Kubeadm.FeatureGates = map[string]bool{"InPlacePodVerticalScaling": true, "AnonymousAuthConfigurableEndpoints": false}
+	cfg.Kubeadm.APIServerExtraArgs = map[string]string{"max-requests-inflight": "800", "enable-admission-plugins": "NodeRestriction,AlwaysPullImages"}
+	cfg.Kubeadm.Kubelet = config.KubeletConfig{
+		MaxPods:        200,
+		SystemReserved: map[string]string{"cpu": "100m", "memory": "256Mi"},
+		EvictionHard:   map[string]string{"memory.available": "200Mi"},
+	}
+	return cfg
+}
+
+func TestClusterConfiguration_MergesAfterManagedArgs(t *testing.T) {
+	cfg := testConfig()
+	cluster := NewClusterConfiguration(cfg)
+	cluster.APIServer.ExtraArgs = append(cluster.APIServer.ExtraArgs, Arg{Name: "audit-log-path", Value: "-"})
+	cluster.Merge(cfg.Kubeadm)
+
+	assert.Equal(t, Networking{PodSubnet: "10.244.0.0/16", ServiceSubnet: "10.100.0.0/16"}, cluster.Networking)
+	gates := Arg{Name: "feature-gates", Value: "AnonymousAuthConfigurableEndpoints=false,InPlacePodVerticalScaling=true"}
+	assert.Equal(t, []Arg{
+		{Name: "audit-log-path", Value: "-"},
+		{Name: "enable-admission-plugins", Value: "NodeRestriction,AlwaysPullImages"},
+		{Name: "max-requests-inflight", Value: "800"},
+		gates,
+	}, cluster.APIServer.ExtraArgs)
+	assert.Equal(t, []Arg{gates}, cluster.ControllerManager.ExtraArgs)
+	assert.Equal(t, []Arg{gates}, cluster.Scheduler.ExtraArgs)
+}
+
+// TestRender_ParsesBack verifies every document round-trips with its
+// apiVersion and kind, and values that look like other YAML types stay
+// strings.
+func TestRender_ParsesBack(t *testing.T) {
+	cfg := testConfig()
+	cfg.Kubeadm.APIServerExtraArgs["profiling"] = "false"
+	cluster := NewClusterConfiguration(cfg)
+	cluster.Merge(cfg.Kubeadm)
+
+	out, err := Render(NewInitConfiguration("192.168.56.10", "unix:///var/run/crio/crio.sock", "controlplane"), cluster, NewKubeletConfiguration(cfg))
+	require.NoError(t, err)
+	assert.Contains(t, out, "\n---\n")
+	assert.Contains(t, out, "      value: \"false\"\n")
+
+	var init InitConfiguration
+	var parsedCluster ClusterConfiguration
+	var kubelet KubeletConfiguration
+	dec := yaml.NewDecoder(strings.NewReader(out))
+	require.NoError(t, dec.Decode(&init))
+	require.NoError(t, dec.Decode(&parsedCluster))
+	require.NoError(t, dec.Decode(&kubelet))
+
+	assert.Equal(t, "InitConfiguration", init.Kind)
+	assert.Equal(t, "controlplane", init.NodeRegistration.Name)
+	assert.Equal(t, cluster, parsedCluster)
+	assert.Equal(t, "kubelet.config.k8s.io/v1beta1", kubelet.APIVersion)
+	assert.Equal(t, 200, kubelet.MaxPods)
+	assert.Equal(t, map[string]string{"cpu": "100m", "memory": "256Mi"}, kubelet.SystemReserved)
+	assert.Equal(t, map[string]string{"memory.available": "200Mi"}, kubelet.EvictionHard)
+	assert.Equal(t, cfg.Kubeadm.FeatureGates, kubelet.FeatureGates)
+}
+
+func TestRender_DefaultsStayOut(t *testing.T) {
+	cfg := &config.Config{Network: config.NetworkConfig{ControlPlaneIP: "192.168.56.10"}}
+	cluster := NewClusterConfiguration(cfg)
+	cluster.Merge(cfg.Kubeadm)
+
+	out, err := Render(cluster, NewKubeletConfiguration(cfg))
+	require.NoError(t, err)
+	assert.NotContains(t, out, "controllerManager")
+	assert.NotContains(t, out, "maxPods")
+	assert.NotContains(t, out, "featureGates")
+}
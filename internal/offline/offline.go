@@ -0,0 +1,132 @@
+// Package offline describes the air-gapped bundle `k8s-provisioner bundle`
+// builds: the files, Helm charts and container images a configuration needs,
+// where each lives once the bundle is unpacked on a node, and the bundle.yaml
+// that records what the bundle was built for.
+package offline
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is where the bundle is unpacked on every node.
+const Dir = "/opt/k8s-offline"
+
+// Layout of the bundle, relative to Dir.
+const (
+	ManifestFile = "bundle.yaml"
+	PackagesDir  = "packages" // a local apt or dnf repository
+	filesDir     = "files"
+	chartsDir    = "charts"
+	imagesDir    = "images"
+)
+
+// HelmVersion is the Helm release chart-based components install, online or
+// from the bundle.
+const HelmVersion = "v3.19.0"
+
+type Kind string
+
+const (
+	// File is a manifest, script or release tarball, downloaded as is.
+	File Kind = "file"
+	// Chart is a Helm chart pulled from a repository.
+	Chart Kind = "chart"
+	// Image is a container image, saved as an archive and loaded into the
+	// container runtime of every node.
+	Image Kind = "image"
+)
+
+// Artifact is one thing an installer downloads.
+type Artifact struct {
+	Kind Kind
+	// Name is the file name under files/ for a File, the chart name for a
+	// Chart and the image reference for an Image.
+	Name string
+	// URL is where a File is downloaded from, or the repository of a Chart.
+	URL string
+	// Version pins a Chart; empty pulls the latest.
+	Version string
+	// Values are the --set flags a Chart is installed with, so the bundle
+	// finds the images they enable.
+	Values []string
+}
+
+// Path returns where a File or Chart is on a node once the bundle is
+// unpacked.
+func (a Artifact) Path() string {
+	return Dir + "/" + a.bundlePath()
+}
+
+func (a Artifact) bundlePath() string {
+	if a.Kind == Chart {
+		return chartsDir + "/" + a.Name + ".tgz"
+	}
+	return filesDir + "/" + a.Name
+}
+
+// BundlePath returns where a File or Chart goes in a bundle being built
+// under root.
+func (a Artifact) BundlePath(root string) string {
+	return root + "/" + a.bundlePath()
+}
+
+// Manifest is bundle.yaml, at the top of the bundle.
+type Manifest struct {
+	// Arch and Family are those of the host the bundle was built on (amd64 or
+	// arm64; debian or rhel): packages and binaries only install on the same.
+	Arch       string         `yaml:"arch"`
+	Family     string         `yaml:"family"`
+	Kubernetes string         `yaml:"kubernetes"`
+	Images     []ImageArchive `yaml:"images"`
+}
+
+// ImageArchive is a saved image and its reference.
+type ImageArchive struct {
+	Ref     string `yaml:"ref"`
+	Archive string `yaml:"archive,omitempty"` // path under Dir; empty = listed only
+}
+
+// ParseManifest reads bundle.yaml.
+func ParseManifest(data string) (Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal([]byte(data), &m); err != nil {
+		return Manifest{}, fmt.Errorf("parse %s: %w", ManifestFile, err)
+	}
+	if m.Arch == "" || m.Family == "" {
+		return Manifest{}, fmt.Errorf("%s has no arch or family: not a k8s-provisioner bundle", ManifestFile)
+	}
+	return m, nil
+}
+
+// Render returns m as bundle.yaml.
+func (m Manifest) Render() (string, error) {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("render %s: %w", ManifestFile, err)
+	}
+	return string(data), nil
+}
+
+// ArchivePath returns the archive path under Dir an image ref is saved to.
+func ArchivePath(ref string) string {
+	return imagesDir + "/" + strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref) + ".tar"
+}
+
+// imageField matches the image of a container in a rendered manifest.
+var imageField = regexp.MustCompile(`(?m)^[ \t-]*image:[ \t]*["']?([^\s"'#]+)`)
+
+// ImageRefs returns the images a Kubernetes manifest references, sorted and
+// without duplicates.
+func ImageRefs(manifest string) []string {
+	var refs []string
+	for _, m := range imageField.FindAllStringSubmatch(manifest, -1) {
+		refs = append(refs, m[1])
+	}
+	slices.Sort(refs)
+	return slices.Compact(refs)
+}
@@ -0,0 +1,53 @@
+package offline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRefs(t *testing.T) {
+	manifest := `spec:
+  containers:
+  - name: controller
+    image: quay.io/metallb/controller:v0.15.3
+  - image: "quay.io/metallb/speaker:v0.15.3" # pinned
+    imagePullPolicy: IfNotPresent
+  initContainers:
+    - name: init
+      image: 'busybox:1.36'
+---
+      image: quay.io/metallb/controller:v0.15.3
+  notAnImage: foo:1
+`
+	assert.Equal(t, []string{
+		"busybox:1.36",
+		"quay.io/metallb/controller:v0.15.3",
+		"quay.io/metallb/speaker:v0.15.3",
+	}, ImageRefs(manifest))
+}
+
+func TestArtifactPaths(t *testing.T) {
+	chart := Artifact{Kind: Chart, Name: "keda"}
+	file := Artifact{Kind: File, Name: "cert-manager.yaml"}
+	assert.Equal(t, "/opt/k8s-offline/charts/keda.tgz", chart.Path())
+	assert.Equal(t, "/opt/k8s-offline/files/cert-manager.yaml", file.Path())
+	assert.Equal(t, "/tmp/b/files/cert-manager.yaml", file.BundlePath("/tmp/b"))
+	assert.Equal(t, "images/ghcr.io_kube-vip_kube-vip_v1.0.1.tar", ArchivePath("ghcr.io/kube-vip/kube-vip:v1.0.1"))
+}
+
+func TestManifest_RoundTrip(t *testing.T) {
+	m := Manifest{Arch: "arm64", Family: "rhel", Kubernetes: "1.34", Images: []ImageArchive{
+		{Ref: "grafana/loki:3.7.1", Archive: ArchivePath("grafana/loki:3.7.1")},
+		{Ref: "postgres:16"},
+	}}
+	data, err := m.Render()
+	require.NoError(t, err)
+	parsed, err := ParseManifest(data)
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+
+	_, err = ParseManifest("kubernetes: \"1.34\"\n")
+	assert.ErrorContains(t, err, "not a k8s-provisioner bundle")
+}
@@ -0,0 +1,128 @@
+package provisioner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/techiescamp/k8s-provisioner/internal/audit"
+	"github.com/techiescamp/k8s-provisioner/internal/kubeadm"
+)
+
+// The audit policy and webhook kubeconfig live in one host directory, mounted
+// read-only into the API server static pod.
+const (
+	auditDir         = "/etc/kubernetes/audit"
+	auditPolicyPath  = auditDir + "/policy.yaml"
+	auditWebhookPath = auditDir + "/webhook.kubeconfig"
+)
+
+// auditArgs wire API server audit logging in through the ClusterConfiguration
+// rather than bare `kubeadm init` flags: kubeadm injects the flags, volume and
+// mount into the static pod manifest idempotently (a sed patch would be far
+// more fragile and could crashloop the API server).
+//
+// audit-log-path is "-" (stdout): audit events become part of the kube-apiserver
+// container's stdout, so the existing (non-root, hardened) Alloy DaemonSet collects
+// them via the Kubernetes API — the same path as every other pod log — and ships
+// them to Loki. No file on disk, no privileged log shipper, no host mount for logs.
+// Only the read-only audit volume is needed. (Rotation flags are file-only
+// and therefore omitted; the container runtime handles stdout log rotation.)
+// With audit.webhook.url set, events are posted there as well.
+func (p *Provisioner) auditArgs() []kubeadm.Arg {
+	args := []kubeadm.Arg{
+		{Name: "audit-policy-file", Value: auditPolicyPath},
+		{Name: "audit-log-path", Value: "-"},
+	}
+	if w := p.config.Audit.Webhook; w.URL != "" {
+		mode := w.Mode
+		if mode == "" {
+			mode = "batch"
+		}
+		args = append(args,
+			kubeadm.Arg{Name: "audit-webhook-config-file", Value: auditWebhookPath},
+			kubeadm.Arg{Name: "audit-webhook-mode", Value: mode},
+		)
+	}
+	return args
+}
+
+var auditVolume = kubeadm.HostPathMount{
+	Name:      "audit-policy",
+	HostPath:  auditDir,
+	MountPath: auditDir,
+	ReadOnly:  true,
+	PathType:  "DirectoryOrCreate",
+}
+
+// auditPolicy returns the policy audit.policy_file or the audit.policy preset
+// selects, validated as an audit.k8s.io/v1 Policy.
+func (p *Provisioner) auditPolicy() (string, error) {
+	a := p.config.Audit
+	if a.PolicyFile == "" {
+		name := a.Policy
+		if name == "" {
+			name = "default"
+		}
+		return audit.Presets[name], nil
+	}
+	data, err := os.ReadFile(a.PolicyFile)
+	if err != nil {
+		return "", fmt.Errorf("audit.policy_file: %w", err)
+	}
+	if err := audit.Validate(data); err != nil {
+		return "", fmt.Errorf("audit.policy_file %s: %w", a.PolicyFile, err)
+	}
+	return string(data), nil
+}
+
+// auditWebhookKubeconfig renders the kubeconfig of audit.webhook, or "" when
+// there is no webhook.
+func (p *Provisioner) auditWebhookKubeconfig() (string, error) {
+	w := p.config.Audit.Webhook
+	if w.URL == "" {
+		return "", nil
+	}
+	var ca []byte
+	if w.CAFile != "" {
+		var err error
+		if ca, err = os.ReadFile(w.CAFile); err != nil {
+			return "", fmt.Errorf("audit.webhook.ca_file: %w", err)
+		}
+	}
+	return audit.WebhookKubeconfig(w.URL, ca)
+}
+
+// writeAuditFiles writes the audit policy and, with a webhook, its kubeconfig
+// to the control plane node. Every control plane needs them: kubeadm gives each
+// API server the same flags. Under dry-run, they are only rendered and checked.
+func (p *Provisioner) writeAuditFiles() error {
+	policy, err := p.auditPolicy()
+	if err != nil {
+		return err
+	}
+	webhook, err := p.auditWebhookKubeconfig()
+	if err != nil {
+		return err
+	}
+
+	if p.dryRun {
+		fmt.Println("[dry-run] would write the API server audit policy")
+		if webhook != "" {
+			fmt.Println("[dry-run] would write the audit webhook kubeconfig")
+		}
+		return nil
+	}
+	if _, err := p.exec.RunShell("mkdir -p " + auditDir); err != nil {
+		return fmt.Errorf("create audit directory: %w", err)
+	}
+	if err := p.writeFile(auditPolicyPath, policy); err != nil {
+		return fmt.Errorf("write audit policy: %w", err)
+	}
+	if webhook == "" {
+		return nil
+	}
+	if err := p.writeFile(auditWebhookPath, webhook); err != nil {
+		return fmt.Errorf("write audit webhook kubeconfig: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package provisioner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteKubeadmConfig_AuditWebhook(t *testing.T) {
+	node := &fileExecutor{}
+	cfg := haConfig()
+	cfg.Audit.Policy = "minimal"
+	cfg.Audit.Webhook.URL = "https://audit.example.com/events"
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+
+	path, err := p.writeKubeadmConfig("cp1")
+	require.NoError(t, err)
+
+	assert.Contains(t, node.files[path],
+		"    - name: audit-webhook-config-file\n      value: /etc/kubernetes/audit/webhook.kubeconfig\n    - name: audit-webhook-mode\n      value: batch\n")
+	assert.NotContains(t, node.files[auditPolicyPath], "RequestResponse", "minimal logs metadata only")
+	assert.Contains(t, node.files[auditWebhookPath], "server: https://audit.example.com/events\n")
+}
+
+// TestWriteKubeadmConfig_RejectsInvalidPolicyFile verifies a broken policy
+// stops provisioning before anything lands on the control plane.
+func TestWriteKubeadmConfig_RejectsInvalidPolicyFile(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(policy, []byte("apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n  - level: Everything\n"), 0o600))
+	node := &fileExecutor{}
+	cfg := haConfig()
+	cfg.Audit.PolicyFile = policy
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+
+	_, err := p.writeKubeadmConfig("cp1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `rules[0].level "Everything" is invalid`)
+	assert.Empty(t, node.files)
+	assert.Empty(t, node.shellCmds)
+}
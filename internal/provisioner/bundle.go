@@ -0,0 +1,254 @@
+package provisioner
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// bundleDir is where Bundle assembles the bundle before packing it.
+const bundleDir = "/tmp/k8s-bundle"
+
+// Bundle collects everything provisioning the config downloads into the
+// tarball output, for offline.bundle: the packages InstallCommon installs
+// with their dependencies, the manifests, charts and binaries of the CNI and
+// of every enabled workload, and the container images they run. It runs on
+// an online machine of the same distribution family and architecture as the
+// nodes, ideally a fresh one: packages and binaries are fetched for this
+// host. Without withImages, the image list is written but no image is saved.
+// extraImages are added to the images found.
+func (p *Provisioner) Bundle(output string, withImages bool, extraImages []string) error {
+	// The bundle is built online, whatever offline.bundle says.
+	cfg := *p.config
+	cfg.Offline = config.OfflineConfig{}
+	p.config = &cfg
+	return p.runPhase("Building offline bundle", func() error {
+		return p.bundle(output, withImages, extraImages)
+	})
+}
+
+func (p *Provisioner) bundle(output string, withImages bool, extraImages []string) error {
+	host, err := p.hostOS()
+	if err != nil {
+		return err
+	}
+	arch, err := p.goArch()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Building a bundle for %s on %s\n", arch, host.name())
+	if _, err := p.exec.RunShell(fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s/%[2]s %[1]s/files %[1]s/charts %[1]s/images", bundleDir, offline.PackagesDir)); err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Downloading packages...")
+	images, err := p.bundlePackages(host)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Downloading manifests, charts and binaries...")
+	artifacts, inline := p.bundleArtifacts(arch)
+	if p.config.Runtime() == "containerd" {
+		artifacts = append(artifacts, p.containerdRelease(arch))
+	}
+	found, err := p.downloadArtifacts(artifacts)
+	if err != nil {
+		return err
+	}
+	images = append(images, found...)
+	images = append(images, inline...)
+	if p.config.Network.ControlPlaneVIP != "" {
+		images = append(images, "ghcr.io/kube-vip/kube-vip:"+p.config.Versions.KubeVIP)
+	}
+	images = append(images, extraImages...)
+	slices.Sort(images)
+	images = slices.Compact(images)
+
+	manifest := offline.Manifest{Arch: arch, Family: host.family(), Kubernetes: p.config.Versions.Kubernetes}
+	fmt.Printf("\n>>> Saving %d images...\n", len(images))
+	if withImages {
+		if err := host.install("skopeo"); err != nil {
+			return err
+		}
+	}
+	for _, ref := range images {
+		image := offline.ImageArchive{Ref: ref}
+		if withImages {
+			image.Archive = offline.ArchivePath(ref)
+			if err := p.saveImage(ref, arch, bundleDir+"/"+image.Archive); err != nil {
+				return err
+			}
+		}
+		manifest.Images = append(manifest.Images, image)
+	}
+	if err := p.writeFile(bundleDir+"/images.txt", strings.Join(images, "\n")+"\n"); err != nil {
+		return err
+	}
+	rendered, err := manifest.Render()
+	if err != nil {
+		return err
+	}
+	if err := p.writeFile(bundleDir+"/"+offline.ManifestFile, rendered); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n>>> Packing %s...\n", output)
+	if _, err := p.exec.RunShell(fmt.Sprintf("tar -C %s -czf %s .", bundleDir, output)); err != nil {
+		return fmt.Errorf("pack %s: %w", output, err)
+	}
+	fmt.Printf("✓ Bundle written to %s (%d images). Copy it to every node and set offline.bundle to its path there.\n", output, len(images))
+	return nil
+}
+
+// bundlePackages downloads the packages of offlinePackages into the bundle's
+// repository and returns the control plane images of the kubeadm they
+// include.
+func (p *Provisioner) bundlePackages(host hostOS) ([]string, error) {
+	if err := host.installDependencies(); err != nil {
+		return nil, err
+	}
+	if p.config.Runtime() != "containerd" {
+		if err := host.addCRIORepo(); err != nil {
+			return nil, err
+		}
+	}
+	if err := host.addKubernetesRepo(); err != nil {
+		return nil, err
+	}
+	if err := host.downloadPackages(bundleDir+"/"+offline.PackagesDir, p.offlinePackages(host)...); err != nil {
+		return nil, err
+	}
+
+	// kubeadm knows the images of the control plane and of CoreDNS, and the
+	// pause image, for the version it ships.
+	if err := host.install("kubeadm"); err != nil {
+		return nil, err
+	}
+	out, err := p.exec.RunShell(`kubeadm config images list --kubernetes-version "$(kubeadm version -o short)"`)
+	if err != nil {
+		return nil, fmt.Errorf("list the Kubernetes images: %w", err)
+	}
+	return strings.Fields(out), nil
+}
+
+// bundleArtifacts returns what the CNI and the enabled workloads download
+// (see installer.Bundler), for arch, and the images of the manifests they
+// apply inline. Those are found by walking every installer through a dry run
+// and reading the images out of the commands it recorded.
+func (p *Provisioner) bundleArtifacts(arch string) (artifacts []offline.Artifact, images []string) {
+	script := executor.NewScript()
+	ctx := executor.WithOutput(installer.WithDryRun(p.ctx), io.Discard)
+	dry := NewWithExecutor(ctx, p.config, executor.DryRunExecutor{Responses: installer.DryRunResponses(p.config), Script: script}, false)
+	dry.dryRun = true
+
+	installers := []installer.Installer{dry.cni()}
+	for _, step := range dry.workloadSteps() {
+		if step.enabled == nil || step.enabled(p.config) {
+			installers = append(installers, dry.buildStep(step.build))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, inst := range installers {
+		if b, ok := inst.(installer.Bundler); ok {
+			for _, a := range b.Artifacts(arch) {
+				key := string(a.Kind) + "/" + a.Name
+				if !seen[key] {
+					seen[key] = true
+					artifacts = append(artifacts, a)
+				}
+			}
+		}
+		if err := inst.Install(); err != nil {
+			fmt.Printf("Warning: could not walk through %s; images of its inline manifests may be missing: %v\n", inst.Name(), err)
+		}
+	}
+	for _, step := range script.Steps() {
+		images = append(images, offline.ImageRefs(script.Render(step))...)
+	}
+	return artifacts, images
+}
+
+// downloadArtifacts saves the File and Chart artifacts into the bundle, Files
+// once verified against their pinned checksums (see fetch.Fetcher), and
+// returns the images they reference: those of the manifests, and those of each
+// chart rendered with its values. Image artifacts are returned as is.
+func (p *Provisioner) downloadArtifacts(artifacts []offline.Artifact) ([]string, error) {
+	var images []string
+	var charts []offline.Artifact
+	for _, a := range artifacts {
+		switch a.Kind {
+		case offline.Image:
+			images = append(images, a.Name)
+		case offline.Chart:
+			charts = append(charts, a)
+		case offline.File:
+			verified, err := p.fetcher().Fetch(a)
+			if err != nil {
+				return nil, err
+			}
+			path := a.BundlePath(bundleDir)
+			if _, err := p.exec.RunShell(fmt.Sprintf("cp %s %s", verified, path)); err != nil {
+				return nil, err
+			}
+			if strings.HasSuffix(a.Name, ".yaml") || strings.HasSuffix(a.Name, ".yml") {
+				manifest, err := p.exec.RunShell("cat " + path)
+				if err != nil {
+					return nil, err
+				}
+				images = append(images, offline.ImageRefs(manifest)...)
+			}
+		}
+	}
+	if len(charts) == 0 {
+		return images, nil
+	}
+
+	// The bundled helm pulls and renders the charts.
+	helm := offline.Artifact{Kind: offline.File, Name: "helm.tar.gz"}
+	if _, err := p.exec.RunShell(fmt.Sprintf("tar -xzf %s -C /usr/local/bin --strip-components=1 --wildcards '*/helm'", helm.BundlePath(bundleDir))); err != nil {
+		return nil, fmt.Errorf("install helm: %w", err)
+	}
+	for _, chart := range charts {
+		pull := fmt.Sprintf("helm pull %s --repo %s", chart.Name, chart.URL)
+		if chart.Version != "" {
+			pull += " --version " + chart.Version
+		}
+		// helm pull names the file after the chart version: pull into an empty
+		// directory and rename it to the name Install reads.
+		tmp := bundleDir + "/charts/.pull"
+		if _, err := p.exec.RunShell(fmt.Sprintf("rm -rf %[1]s && mkdir %[1]s && %[2]s -d %[1]s && mv %[1]s/*.tgz %[3]s && rmdir %[1]s", tmp, pull, chart.BundlePath(bundleDir))); err != nil {
+			return nil, fmt.Errorf("pull chart %s: %w", chart.Name, err)
+		}
+		render := fmt.Sprintf("helm template %s %s", chart.Name, chart.BundlePath(bundleDir))
+		for _, v := range chart.Values {
+			render += " --set " + v
+		}
+		manifest, err := p.exec.RunShell(render)
+		if err != nil {
+			return nil, fmt.Errorf("render chart %s: %w", chart.Name, err)
+		}
+		images = append(images, offline.ImageRefs(manifest)...)
+	}
+	return images, nil
+}
+
+// saveImage saves the arch variant of ref as a docker archive at path,
+// tagged ref so the runtime imports it under that name.
+func (p *Provisioner) saveImage(ref, arch, path string) error {
+	dest := "docker-archive:" + path
+	if !strings.Contains(ref, "@") {
+		dest += ":" + ref
+	}
+	if _, err := p.exec.RunShell(fmt.Sprintf("skopeo copy --override-os linux --override-arch %s docker://%s %s", arch, ref, dest)); err != nil {
+		return fmt.Errorf("save image %s: %w", ref, err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// StateFile is where step progress is checkpointed on the node being
+// provisioned (the control plane for workloads).
+const StateFile = "/etc/k8s-provisioner/state.json"
+
+// Checkpointed phases.
+const (
+	phaseCommon    = "common"
+	phaseWorkloads = "workloads"
+)
+
+// Step statuses in the state file.
+const (
+	stepRunning   = "running"
+	stepCompleted = "completed"
+	stepFailed    = "failed"
+)
+
+// runState is the content of StateFile: per phase, the last outcome of each
+// step (InstallCommon step name, workload step id).
+type runState struct {
+	Phases map[string]map[string]*stepState `json:"phases"`
+}
+
+type stepState struct {
+	Status     string    `json:"status"`
+	ConfigHash string    `json:"config_hash"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// checkpoint records step progress in the node's state file. A nil checkpoint
+// records nothing and skips nothing, so callers need no guard.
+type checkpoint struct {
+	mu     sync.Mutex
+	exec   executor.CommandExecutor
+	path   string
+	hash   string
+	resume bool
+	state  runState
+	// dirMade is set once save created the state file's directory.
+	dirMade bool
+}
+
+// Checkpoint records the progress of InstallCommon and InstallWorkloads in
+// the state file on the node. With resume, steps already completed against an
+// unchanged config (same config hash) are skipped; without it, the steps a run covers
+// start their records afresh.
+func (p *Provisioner) Checkpoint(resume bool) error {
+	// Bound to a context that survives Ctrl-C, so an interrupted step is
+	// still recorded as failed.
+	exec := executor.WithContext(context.WithoutCancel(p.ctx), p.base)
+	c := &checkpoint{exec: exec, path: StateFile, hash: p.config.Hash(), resume: resume}
+	if err := c.load(); err != nil {
+		return err
+	}
+	p.checkpoint = c
+	return nil
+}
+
+// load reads the state file, if any. It changes nothing on the node: the
+// directory is only created by the first save.
+func (c *checkpoint) load() error {
+	out, err := c.exec.RunShell(fmt.Sprintf("cat %s 2>/dev/null || true", c.path))
+	if err != nil {
+		return fmt.Errorf("read checkpoint %s: %w", c.path, err)
+	}
+	if out != "" {
+		if err := json.Unmarshal([]byte(out), &c.state); err != nil {
+			fmt.Printf("Warning: ignoring unreadable checkpoint %s: %v\n", c.path, err)
+			c.state = runState{}
+		}
+	}
+	if c.state.Phases == nil {
+		c.state.Phases = make(map[string]map[string]*stepState)
+	}
+	return nil
+}
+
+// begin opens phase for steps: without resume their previous records are
+// dropped. Records of steps outside this run (see SetSelection) are kept.
+func (c *checkpoint) begin(phase string, steps []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.Phases[phase] == nil {
+		c.state.Phases[phase] = make(map[string]*stepState)
+	}
+	if !c.resume {
+		for _, step := range steps {
+			delete(c.state.Phases[phase], step)
+		}
+	}
+}
+
+// done reports whether step can be skipped: resuming, and it completed
+// against the current config.
+func (c *checkpoint) done(phase, step string) (stepState, bool) {
+	if c == nil || !c.resume {
+		return stepState{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.state.Phases[phase][step]
+	if st == nil || st.Status != stepCompleted || st.ConfigHash != c.hash {
+		return stepState{}, false
+	}
+	return *st, true
+}
+
+// start records step as running.
+func (c *checkpoint) start(phase, step string) {
+	if c == nil {
+		return
+	}
+	c.update(phase, step, &stepState{Status: stepRunning, ConfigHash: c.hash, StartedAt: time.Now().UTC()})
+}
+
+// finish records the outcome of step; err includes a failure the step's
+// policy tolerated, so --resume retries it.
+func (c *checkpoint) finish(phase, step string, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	st := c.state.Phases[phase][step]
+	c.mu.Unlock()
+	if st == nil {
+		st = &stepState{ConfigHash: c.hash}
+	}
+	next := *st
+	next.Status, next.Error, next.FinishedAt = stepCompleted, "", time.Now().UTC()
+	if err != nil {
+		next.Status, next.Error = stepFailed, err.Error()
+	}
+	c.update(phase, step, &next)
+}
+
+// forget drops the record of step, so --resume runs it again.
+func (c *checkpoint) forget(phase, step string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.state.Phases[phase], step)
+	c.mu.Unlock()
+	c.save()
+}
+
+// update stores st and rewrites the state file.
+func (c *checkpoint) update(phase, step string, st *stepState) {
+	c.mu.Lock()
+	if c.state.Phases[phase] == nil {
+		c.state.Phases[phase] = make(map[string]*stepState)
+	}
+	c.state.Phases[phase][step] = st
+	c.mu.Unlock()
+	c.save()
+}
+
+// save rewrites the state file. A failed write is a warning: losing a
+// checkpoint only costs a longer resume.
+func (c *checkpoint) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err == nil && !c.dirMade {
+		if _, err = c.exec.RunShell("mkdir -p " + filepath.Dir(c.path)); err == nil {
+			c.dirMade = true
+		}
+	}
+	if err == nil {
+		err = executor.WriteFileOn(c.exec, c.path, string(data)+"\n")
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not save checkpoint %s: %v\n", c.path, err)
+	}
+}
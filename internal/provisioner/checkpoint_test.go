@@ -0,0 +1,147 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+// stateExecutor keeps the node's state file in memory: WriteFile stores it
+// and the checkpoint's cat returns it.
+type stateExecutor struct {
+	mockExecutor
+	mu   sync.Mutex
+	file string
+	cmds []string
+}
+
+func (s *stateExecutor) RunShell(command string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cmds = append(s.cmds, command)
+	if strings.Contains(command, "cat "+StateFile) {
+		return s.file, nil
+	}
+	return "", nil
+}
+
+func (s *stateExecutor) WriteFile(path, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file = content
+	return nil
+}
+
+// checkpointed returns a Provisioner for cfg checkpointing to node.
+func checkpointed(t *testing.T, node *stateExecutor, cfg *config.Config, resume bool) *Provisioner {
+	t.Helper()
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+	require.NoError(t, p.Checkpoint(resume))
+	return p
+}
+
+func TestCheckpoint_ResumeSkipsCompletedSteps(t *testing.T) {
+	node := &stateExecutor{}
+	cfg := &config.Config{}
+	var runs []string
+	run := func(id string, err error) func() error {
+		return func() error { runs = append(runs, id); return err }
+	}
+
+	steps := []workloadStep{
+		testStep("a", nil, run("a", nil)),
+		testStep("b", []string{"a"}, run("b", errors.New("boom"))),
+	}
+	steps[1].fatal = true
+	_, err := checkpointed(t, node, cfg, false).runWorkloads(steps)
+	require.Error(t, err)
+
+	var state runState
+	require.NoError(t, json.Unmarshal([]byte(node.file), &state))
+	assert.Equal(t, stepCompleted, state.Phases[phaseWorkloads]["a"].Status)
+	assert.Equal(t, stepFailed, state.Phases[phaseWorkloads]["b"].Status)
+	assert.Equal(t, "boom", state.Phases[phaseWorkloads]["b"].Error)
+
+	runs = nil
+	steps[1] = testStep("b", []string{"a"}, run("b", nil))
+	_, err = checkpointed(t, node, cfg, true).runWorkloads(steps)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, runs, "a completed against the same config")
+}
+
+// TestCheckpoint_LoadChangesNothing verifies reading the checkpoint, which
+// every provision subcommand does (preflight included), leaves the node as
+// it is, and the state directory is created once something is saved.
+func TestCheckpoint_LoadChangesNothing(t *testing.T) {
+	node := &stateExecutor{}
+	p := checkpointed(t, node, &config.Config{}, false)
+	assert.Equal(t, []string{"cat " + StateFile + " 2>/dev/null || true"}, node.cmds)
+	assert.Empty(t, node.file)
+
+	_, err := p.runWorkloads([]workloadStep{testStep("a", nil, func() error { return nil })})
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(strings.Join(node.cmds, "\n"), "mkdir -p /etc/k8s-provisioner"))
+	assert.NotEmpty(t, node.file)
+}
+
+func TestCheckpoint_ConfigChangeRerunsSteps(t *testing.T) {
+	node := &stateExecutor{}
+	ran := 0
+	steps := []workloadStep{testStep("a", nil, func() error { ran++; return nil })}
+
+	_, err := checkpointed(t, node, &config.Config{}, false).runWorkloads(steps)
+	require.NoError(t, err)
+
+	changed := &config.Config{}
+	changed.Cluster.Name = "other"
+	_, err = checkpointed(t, node, changed, true).runWorkloads(steps)
+	require.NoError(t, err)
+	assert.Equal(t, 2, ran, "a step completed under another config runs again")
+}
+
+func TestCheckpoint_WarnedFailureIsRetriedOnResume(t *testing.T) {
+	node := &stateExecutor{}
+	ran := 0
+	steps := []workloadStep{testStep("a", nil, func() error { ran++; return errors.New("flaky") })}
+
+	_, err := checkpointed(t, node, &config.Config{}, false).runWorkloads(steps)
+	require.NoError(t, err, "a non-fatal failure only warns")
+	_, err = checkpointed(t, node, &config.Config{}, true).runWorkloads(steps)
+	require.NoError(t, err)
+	assert.Equal(t, 2, ran)
+}
+
+func TestCheckpoint_WithoutResumeRunsEverything(t *testing.T) {
+	node := &stateExecutor{}
+	ran := 0
+	steps := []workloadStep{testStep("a", nil, func() error { ran++; return nil })}
+
+	for range 2 {
+		_, err := checkpointed(t, node, &config.Config{}, false).runWorkloads(steps)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, ran)
+}
+
+func TestUninstall_ForgetsCheckpoint(t *testing.T) {
+	node := &stateExecutor{}
+	cfg := &config.Config{}
+	cfg.Components.KEDA = "enabled"
+	p := checkpointed(t, node, cfg, false)
+	p.checkpoint.start(phaseWorkloads, "keda")
+	p.checkpoint.finish(phaseWorkloads, "keda", nil)
+
+	require.NoError(t, p.Uninstall("keda"))
+
+	var state runState
+	require.NoError(t, json.Unmarshal([]byte(node.file), &state))
+	assert.NotContains(t, state.Phases[phaseWorkloads], "keda", "--resume must install it again")
+}
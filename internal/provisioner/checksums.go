@@ -0,0 +1,52 @@
+package provisioner
+
+import (
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// Downloads returns the File artifacts provisioning the config downloads on
+// amd64 and arm64 nodes, whose checksums must be pinned (see fetch.Fetcher):
+// the containerd release, and the manifests and release tarballs of the CNI
+// and of the enabled workloads. With all, every runtime, CNI and workload
+// counts, at the versions of the config, as for the checksums shipped with
+// the binary.
+func (p *Provisioner) Downloads(all bool) []offline.Artifact {
+	var installers []installer.Installer
+	cnis := []string{p.config.CNI()}
+	if all {
+		cnis = []string{"calico", "cilium", "flannel"}
+	}
+	for _, cni := range cnis {
+		cfg := *p.config
+		cfg.Components.CNI = cni
+		installers = append(installers, installer.NewCNI(p.ctx, &cfg, p.exec))
+	}
+	for _, step := range p.workloadSteps() {
+		if all || step.enabled == nil || step.enabled(p.config) {
+			installers = append(installers, step.build(p.ctx, p.config, p.exec))
+		}
+	}
+
+	var files []offline.Artifact
+	seen := map[string]bool{}
+	add := func(a offline.Artifact) {
+		if a.Kind == offline.File && !seen[a.URL] {
+			seen[a.URL] = true
+			files = append(files, a)
+		}
+	}
+	for _, arch := range []string{"amd64", "arm64"} {
+		if all || p.config.Runtime() == "containerd" {
+			add(p.containerdRelease(arch))
+		}
+		for _, inst := range installers {
+			if b, ok := inst.(installer.Bundler); ok {
+				for _, a := range b.Artifacts(arch) {
+					add(a)
+				}
+			}
+		}
+	}
+	return files
+}
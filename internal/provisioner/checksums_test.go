@@ -0,0 +1,30 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/fetch"
+)
+
+// TestDownloads_DefaultVersionsArePinned verifies the binary ships a pin for
+// every download at the versions of the repository's config.yaml, so a
+// default install needs no checksums: section. `make checksums` fixes it.
+func TestDownloads_DefaultVersionsArePinned(t *testing.T) {
+	cfg, err := config.Load("../../config.yaml")
+	require.NoError(t, err)
+	shipped, err := fetch.Shipped()
+	require.NoError(t, err)
+
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
+	var missing []string
+	for _, a := range p.Downloads(true) {
+		if _, ok := shipped[a.URL]; !ok {
+			missing = append(missing, a.URL)
+		}
+	}
+	require.Empty(t, missing, "downloads without a shipped pin; run make checksums")
+}
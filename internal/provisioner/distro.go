@@ -0,0 +1,349 @@
+package provisioner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// offlineRepo is the unpacked bundle's package repository.
+const offlineRepo = offline.Dir + "/" + offline.PackagesDir
+
+// hostOS is the part of host preparation that differs between Linux
+// distribution families: the package manager, where the CRI-O and Kubernetes
+// repositories are configured, how packages are pinned, and which DHCP client
+// must be kept off /etc/resolv.conf.
+type hostOS interface {
+	// name describes the distribution, e.g. "Debian GNU/Linux 13 (trixie)".
+	name() string
+	// installDependencies installs the packages the later steps need.
+	installDependencies() error
+	// addCRIORepo configures the CRI-O repository for versions.crio.
+	addCRIORepo() error
+	// addKubernetesRepo points the package manager at the pkgs.k8s.io
+	// repository for versions.kubernetes and refreshes its index.
+	addKubernetesRepo() error
+	install(pkgs ...string) error
+	// hold pins pkgs at their installed version; unhold lifts it.
+	hold(pkgs ...string) error
+	unhold(pkgs ...string) error
+	// keepResolvConf stops the network stack from rewriting /etc/resolv.conf.
+	keepResolvConf() error
+
+	// family is "debian" or "rhel": an offline bundle only installs on the
+	// family it was built on.
+	family() string
+	// dependencies are the packages installDependencies installs.
+	dependencies() []string
+	// addOfflineRepo makes the unpacked bundle's package repository the one
+	// packages are installed from.
+	addOfflineRepo() error
+	// downloadPackages saves pkgs and everything they depend on into dir and
+	// indexes it as a repository addOfflineRepo can use.
+	downloadPackages(dir string, pkgs ...string) error
+}
+
+// osRelease is the parsed /etc/os-release.
+type osRelease struct {
+	id, prettyName string
+	idLike         []string
+}
+
+// parseOSRelease reads the KEY=value lines of an os-release file.
+func parseOSRelease(content string) osRelease {
+	var r osRelease
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			r.id = strings.ToLower(value)
+		case "ID_LIKE":
+			r.idLike = strings.Fields(strings.ToLower(value))
+		case "PRETTY_NAME":
+			r.prettyName = value
+		}
+	}
+	return r
+}
+
+// is reports whether the distribution is one of ids or derives from one.
+func (r osRelease) is(ids ...string) bool {
+	if slices.Contains(ids, r.id) {
+		return true
+	}
+	for _, like := range r.idLike {
+		if slices.Contains(ids, like) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostOS detects the distribution of the node being provisioned from
+// /etc/os-release, once.
+func (p *Provisioner) hostOS() (hostOS, error) {
+	if p.host != nil {
+		return p.host, nil
+	}
+	content, err := p.readOSRelease()
+	if err != nil {
+		return nil, fmt.Errorf("detect distribution: %w", err)
+	}
+	release := parseOSRelease(content)
+	if release.prettyName == "" {
+		release.prettyName = release.id
+	}
+	switch {
+	case release.is("debian", "ubuntu"):
+		p.host = debian{p: p, release: release}
+	case release.is("rhel", "centos", "rocky", "almalinux"):
+		p.host = rhel{p: p, release: release}
+	case release.id == "":
+		return nil, fmt.Errorf("detect distribution: no ID in /etc/os-release")
+	default:
+		return nil, fmt.Errorf("unsupported distribution %q: host preparation supports Debian/Ubuntu and RHEL-family (RHEL, Rocky, AlmaLinux, CentOS Stream)", release.prettyName)
+	}
+	return p.host, nil
+}
+
+// readOSRelease returns the node's /etc/os-release.
+func (p *Provisioner) readOSRelease() (string, error) {
+	if p.dryRun {
+		// The dry-run executor answers nothing: preview as the local host,
+		// or as the Debian lab box where there is no os-release (macOS).
+		data, err := os.ReadFile("/etc/os-release")
+		if err != nil {
+			return "ID=debian\nPRETTY_NAME=\"Debian GNU/Linux\"\n", nil
+		}
+		return string(data), nil
+	}
+	return p.exec.RunShell("cat /etc/os-release")
+}
+
+// debian prepares Debian and Ubuntu hosts with apt.
+type debian struct {
+	p       *Provisioner
+	release osRelease
+}
+
+func (d debian) name() string { return d.release.prettyName }
+
+func (d debian) family() string { return "debian" }
+
+func (d debian) dependencies() []string {
+	return []string{"apt-transport-https", "ca-certificates", "curl", "gnupg", "conntrack", "ethtool", "socat"}
+}
+
+// installDependencies refreshes the package index first, except offline:
+// addOfflineRepo indexed the bundle's repository, and the others cannot be
+// reached.
+func (d debian) installDependencies() error {
+	if !d.p.config.Offline.Enabled() {
+		if _, err := d.p.exec.RunShell("apt-get update"); err != nil {
+			return err
+		}
+	}
+	return d.install(d.dependencies()...)
+}
+
+func (d debian) addCRIORepo() error {
+	version := d.p.config.Versions.CriO
+
+	keyCmd := fmt.Sprintf("curl -fsSL https://download.opensuse.org/repositories/isv:/cri-o:/stable:/%s/deb/Release.key | gpg --dearmor -o /etc/apt/keyrings/cri-o-apt-keyring.gpg", version)
+	if _, err := d.p.exec.RunShell(keyCmd); err != nil {
+		return err
+	}
+
+	repoLine := fmt.Sprintf("deb [signed-by=/etc/apt/keyrings/cri-o-apt-keyring.gpg] https://download.opensuse.org/repositories/isv:/cri-o:/stable:/%s/deb/ /", version)
+	if err := d.p.writeFile("/etc/apt/sources.list.d/cri-o.list", repoLine); err != nil {
+		return err
+	}
+
+	_, err := d.p.exec.RunShell("apt-get update")
+	return err
+}
+
+// addKubernetesRepo overwrites the key rather than appending to it on a
+// re-run.
+func (d debian) addKubernetesRepo() error {
+	version := d.p.config.Versions.Kubernetes
+
+	keyCmd := fmt.Sprintf("curl -fsSL https://pkgs.k8s.io/core:/stable:/v%s/deb/Release.key | gpg --batch --yes --dearmor -o /etc/apt/keyrings/kubernetes-apt-keyring.gpg", version)
+	if _, err := d.p.exec.RunShell(keyCmd); err != nil {
+		return err
+	}
+
+	repoLine := fmt.Sprintf("deb [signed-by=/etc/apt/keyrings/kubernetes-apt-keyring.gpg] https://pkgs.k8s.io/core:/stable:/v%s/deb/ /", version)
+	if err := d.p.writeFile("/etc/apt/sources.list.d/kubernetes.list", repoLine); err != nil {
+		return err
+	}
+
+	_, err := d.p.exec.RunShell("apt-get update")
+	return err
+}
+
+func (d debian) install(pkgs ...string) error {
+	_, err := d.p.exec.RunShell("apt-get install -y " + strings.Join(pkgs, " "))
+	return err
+}
+
+func (d debian) hold(pkgs ...string) error {
+	_, err := d.p.exec.RunShell("apt-mark hold " + strings.Join(pkgs, " "))
+	return err
+}
+
+func (d debian) unhold(pkgs ...string) error {
+	_, err := d.p.exec.RunShell("apt-mark unhold " + strings.Join(pkgs, " "))
+	return err
+}
+
+// keepResolvConf stops dhcpcd from overwriting resolv.conf on lease renewal.
+func (d debian) keepResolvConf() error {
+	_, err := d.p.exec.RunShell(`grep -q 'nohook resolv.conf' /etc/dhcpcd.conf 2>/dev/null || echo 'nohook resolv.conf' >> /etc/dhcpcd.conf`)
+	return err
+}
+
+// addOfflineRepo refreshes the index of the bundle's flat repository alone,
+// keeping the lists of the others: apt-get update would fail on them.
+// The bundle is trusted as a whole, so the repository is unsigned.
+func (d debian) addOfflineRepo() error {
+	const list = "/etc/apt/sources.list.d/k8s-offline.list"
+	if err := d.p.writeFile(list, "deb [trusted=yes] file:"+offlineRepo+" ./\n"); err != nil {
+		return err
+	}
+	_, err := d.p.exec.RunShell("apt-get update -o Dir::Etc::sourcelist=" + list + " -o Dir::Etc::sourceparts=- -o APT::Get::List-Cleanup=0")
+	return err
+}
+
+// downloadPackages fetches the full dependency closure, not only what this
+// host lacks, so nothing is missing on a node with fewer packages installed.
+// Virtual packages (<name>) are left out; apt resolves them to a real one in
+// the closure.
+func (d debian) downloadPackages(dir string, pkgs ...string) error {
+	if err := d.install("apt-utils"); err != nil {
+		return err
+	}
+	closure := "apt-cache depends --recurse --no-recommends --no-suggests --no-conflicts --no-breaks --no-replaces --no-enhances " + strings.Join(pkgs, " ") + " | grep '^[a-z0-9]' | sort -u"
+	if _, err := d.p.exec.RunShell(fmt.Sprintf("cd %s && apt-get download $(%s)", dir, closure)); err != nil {
+		return fmt.Errorf("download packages: %w", err)
+	}
+	_, err := d.p.exec.RunShell(fmt.Sprintf("cd %s && apt-ftparchive packages . > Packages", dir))
+	return err
+}
+
+// rhel prepares RHEL-family hosts (RHEL, Rocky Linux, AlmaLinux, CentOS
+// Stream) with dnf; yum is an alias for it since RHEL 8.
+type rhel struct {
+	p       *Provisioner
+	release osRelease
+}
+
+func (r rhel) name() string { return r.release.prettyName }
+
+func (r rhel) family() string { return "rhel" }
+
+func (r rhel) dependencies() []string {
+	return []string{"ca-certificates", "curl", "conntrack-tools", "ethtool", "socat", "iproute-tc", "python3-dnf-plugin-versionlock"}
+}
+
+// installDependencies also switches SELinux to permissive, as the Kubernetes
+// install guide does for RHEL-family hosts: the kubelet and CRI-O run
+// containers that mount host paths SELinux would deny.
+func (r rhel) installDependencies() error {
+	if err := r.install(r.dependencies()...); err != nil {
+		return err
+	}
+	if _, err := r.p.exec.RunShell("setenforce 0 2>/dev/null || true"); err != nil {
+		return err
+	}
+	_, err := r.p.exec.RunShell("sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config")
+	return err
+}
+
+// repoTemplate is a dnf repository whose packages are signed with the key
+// published next to the repository metadata. %s = id, %s = name,
+// %s = base URL, %s = base URL.
+const repoTemplate = `[%s]
+name=%s
+baseurl=%s
+enabled=1
+gpgcheck=1
+gpgkey=%srepodata/repomd.xml.key
+`
+
+func (r rhel) addCRIORepo() error {
+	url := fmt.Sprintf("https://download.opensuse.org/repositories/isv:/cri-o:/stable:/%s/rpm/", r.p.config.Versions.CriO)
+	return r.p.writeFile("/etc/yum.repos.d/cri-o.repo", fmt.Sprintf(repoTemplate, "cri-o", "CRI-O", url, url))
+}
+
+// addKubernetesRepo rewrites the repository file in place: dnf caches
+// metadata per base URL, so moving to another minor version needs no cache
+// cleanup.
+func (r rhel) addKubernetesRepo() error {
+	url := fmt.Sprintf("https://pkgs.k8s.io/core:/stable:/v%s/rpm/", r.p.config.Versions.Kubernetes)
+	if err := r.p.writeFile("/etc/yum.repos.d/kubernetes.repo", fmt.Sprintf(repoTemplate, "kubernetes", "Kubernetes", url, url)); err != nil {
+		return err
+	}
+	_, err := r.p.exec.RunShell("dnf makecache")
+	return err
+}
+
+// install only reads the bundle's repository offline: dnf fails on any
+// enabled repository it cannot reach.
+func (r rhel) install(pkgs ...string) error {
+	cmd := "dnf install -y "
+	if r.p.config.Offline.Enabled() {
+		cmd += "--disablerepo='*' --enablerepo=k8s-offline "
+	}
+	_, err := r.p.exec.RunShell(cmd + strings.Join(pkgs, " "))
+	return err
+}
+
+func (r rhel) hold(pkgs ...string) error {
+	_, err := r.p.exec.RunShell("dnf versionlock add " + strings.Join(pkgs, " "))
+	return err
+}
+
+func (r rhel) unhold(pkgs ...string) error {
+	_, err := r.p.exec.RunShell("dnf versionlock delete " + strings.Join(pkgs, " "))
+	return err
+}
+
+// addOfflineRepo adds the bundle's repository, unsigned: the bundle is
+// trusted as a whole.
+func (r rhel) addOfflineRepo() error {
+	repo := "[k8s-offline]\nname=k8s-provisioner offline bundle\nbaseurl=file://" + offlineRepo + "\nenabled=1\ngpgcheck=0\n"
+	return r.p.writeFile("/etc/yum.repos.d/k8s-offline.repo", repo)
+}
+
+// downloadPackages fetches the full dependency closure (--alldeps), not only
+// what this host lacks, so nothing is missing on a node with fewer packages
+// installed.
+func (r rhel) downloadPackages(dir string, pkgs ...string) error {
+	if err := r.install("dnf-plugins-core", "createrepo_c"); err != nil {
+		return err
+	}
+	if _, err := r.p.exec.RunShell(fmt.Sprintf("dnf download --resolve --alldeps --destdir %s %s", dir, strings.Join(pkgs, " "))); err != nil {
+		return fmt.Errorf("download packages: %w", err)
+	}
+	_, err := r.p.exec.RunShell("createrepo_c " + dir)
+	return err
+}
+
+// keepResolvConf tells NetworkManager to leave resolv.conf alone.
+func (r rhel) keepResolvConf() error {
+	if err := r.p.writeFile("/etc/NetworkManager/conf.d/90-k8s-provisioner-dns.conf", "[main]\ndns=none\n"); err != nil {
+		return err
+	}
+	_, err := r.p.exec.RunShell("systemctl reload NetworkManager")
+	return err
+}
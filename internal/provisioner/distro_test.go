@@ -0,0 +1,82 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const debianOSRelease = `PRETTY_NAME="Debian GNU/Linux 13 (trixie)"
+NAME="Debian GNU/Linux"
+VERSION_ID="13"
+ID=debian
+`
+
+const rockyOSRelease = `NAME="Rocky Linux"
+VERSION="9.4 (Blue Onyx)"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+VERSION_ID="9.4"
+PRETTY_NAME="Rocky Linux 9.4 (Blue Onyx)"
+`
+
+func TestHostOS_Detection(t *testing.T) {
+	tests := []struct {
+		name, osRelease string
+		family          hostOS
+		err             string
+	}{
+		{name: "Debian", osRelease: debianOSRelease, family: debian{}},
+		{name: "Ubuntu", osRelease: "ID=ubuntu\nID_LIKE=debian\nPRETTY_NAME=\"Ubuntu 24.04 LTS\"\n", family: debian{}},
+		{name: "Rocky Linux", osRelease: rockyOSRelease, family: rhel{}},
+		{name: "AlmaLinux", osRelease: "ID=\"almalinux\"\nID_LIKE=\"rhel centos fedora\"\n", family: rhel{}},
+		{name: "Fedora", osRelease: "ID=fedora\nPRETTY_NAME=\"Fedora Linux 41\"\n", err: `unsupported distribution "Fedora Linux 41"`},
+		{name: "no os-release", osRelease: "", err: "no ID in /etc/os-release"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &answeringExecutor{answers: map[string]string{"/etc/os-release": tt.osRelease}}
+			p := NewWithExecutor(context.Background(), preflightConfig(), node, false)
+			host, err := p.hostOS()
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.family, host)
+
+			_, _ = p.hostOS()
+			assert.Equal(t, []string{"cat /etc/os-release"}, node.shellCmds, "detected once")
+		})
+	}
+}
+
+// TestInstallCommon_RockyUsesDnf verifies a RHEL-family host gets dnf
+// repository files, versionlock pins and NetworkManager DNS handling instead
+// of apt and dhcpcd.
+func TestInstallCommon_RockyUsesDnf(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"/etc/os-release": rockyOSRelease}}}
+	cfg := preflightConfig()
+	cfg.Versions.Kubernetes = "1.34"
+	cfg.Versions.CriO = "v1.34"
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+	p.SkipPreflight()
+
+	require.NoError(t, p.InstallCommon())
+
+	assert.Contains(t, node.shellCmds, "dnf install -y cri-o")
+	assert.Contains(t, node.shellCmds, "dnf install -y kubelet kubeadm kubectl")
+	assert.Contains(t, node.shellCmds, "dnf versionlock add kubelet kubeadm kubectl")
+	assert.Contains(t, node.shellCmds, "systemctl reload NetworkManager")
+	for _, c := range node.shellCmds {
+		assert.NotContains(t, c, "apt")
+		assert.NotContains(t, c, "dhcpcd")
+	}
+	assert.Contains(t, node.files["/etc/yum.repos.d/kubernetes.repo"], "baseurl=https://pkgs.k8s.io/core:/stable:/v1.34/rpm/\n")
+	assert.Contains(t, node.files["/etc/yum.repos.d/kubernetes.repo"], "gpgkey=https://pkgs.k8s.io/core:/stable:/v1.34/rpm/repodata/repomd.xml.key\n")
+	assert.Contains(t, node.files["/etc/yum.repos.d/cri-o.repo"], "baseurl=https://download.opensuse.org/repositories/isv:/cri-o:/stable:/v1.34/rpm/\n")
+	assert.Equal(t, "[main]\ndns=none\n", node.files["/etc/NetworkManager/conf.d/90-k8s-provisioner-dns.conf"])
+}
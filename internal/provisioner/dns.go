@@ -0,0 +1,110 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+// configureDNS points the node at dns.upstream. VirtualBox NAT DHCP advertises
+// the host's home router IP as DNS, which is unreachable from inside the
+// 10.0.2.x NAT network, so resolv.conf is written by hand, kept from the DHCP
+// client on renewal, and locked with chattr.
+func (p *Provisioner) configureDNS() error {
+	host, err := p.hostOS()
+	if err != nil {
+		return err
+	}
+	if _, err := p.exec.RunShell("chattr -i /etc/resolv.conf 2>/dev/null; rm -f /etc/resolv.conf"); err != nil {
+		return err
+	}
+	if err := p.writeFile("/etc/resolv.conf", p.resolvConf()); err != nil {
+		return err
+	}
+	if _, err := p.exec.RunShell("chattr +i /etc/resolv.conf"); err != nil {
+		return err
+	}
+	return host.keepResolvConf()
+}
+
+func (p *Provisioner) resolvConf() string {
+	var b strings.Builder
+	if search := p.config.DNS.SearchDomains; len(search) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(search, " "))
+	}
+	for _, ip := range p.config.DNSUpstream() {
+		fmt.Fprintf(&b, "nameserver %s\n", ip)
+	}
+	return b.String()
+}
+
+// corefile is kubeadm's default CoreDNS configuration, forwarding to
+// dns.upstream instead of the node's resolv.conf, plus a server block per
+// stub zone. An address zone answers A queries for every name in it with
+// the one address, and every other type with an empty NOERROR, so clients
+// do not fall back to the next search domain.
+var corefile = template.Must(template.New("Corefile").Funcs(template.FuncMap{"join": strings.Join}).Parse(`.:53 {
+    errors
+    health {
+       lameduck 5s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+       pods insecure
+       fallthrough in-addr.arpa ip6.arpa
+       ttl 30
+    }
+    prometheus :9153
+    forward . {{ join .Upstream " " }}
+    cache 30
+    loop
+    reload
+    loadbalance
+}
+{{- range .StubZones }}
+{{ .Zone }}:53 {
+    errors
+{{- if .Address }}
+    template IN A {{ .Zone }} {
+       answer "{{ "{{ .Name }}" }} 60 IN A {{ .Address }}"
+    }
+    template ANY ANY {{ .Zone }} {
+       rcode NOERROR
+    }
+{{- else }}
+    forward . {{ join .Forward " " }}
+{{- end }}
+    cache 30
+}
+{{- end }}
+`))
+
+func (p *Provisioner) renderCorefile() (string, error) {
+	var b strings.Builder
+	err := corefile.Execute(&b, struct {
+		Upstream  []string
+		StubZones []config.StubZone
+	}{p.config.DNSUpstream(), p.config.DNS.StubZones})
+	if err != nil {
+		return "", fmt.Errorf("render Corefile: %w", err)
+	}
+	return b.String(), nil
+}
+
+// patchCoreDNS replaces the Corefile kubeadm installed. CoreDNS's reload
+// plugin picks the change up without a restart.
+func (p *Provisioner) patchCoreDNS() error {
+	corefile, err := p.renderCorefile()
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]any{"data": map[string]string{"Corefile": corefile}})
+	if err != nil {
+		return err
+	}
+	_, err = p.exec.RunShellWithStdin("kubectl patch configmap coredns -n kube-system --type=merge --patch-file=/dev/stdin", string(patch))
+	return err
+}
@@ -0,0 +1,46 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+func TestConfigureDNS_WritesUpstreamAndSearchDomains(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"os-release": debianOSRelease}}}
+	cfg := &config.Config{DNS: config.DNSConfig{
+		Upstream:      []string{"10.1.0.53", "10.2.0.53"},
+		SearchDomains: []string{"corp.example.com"},
+	}}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+
+	require.NoError(t, p.configureDNS())
+
+	assert.Equal(t, "search corp.example.com\nnameserver 10.1.0.53\nnameserver 10.2.0.53\n", node.files["/etc/resolv.conf"])
+	assert.Contains(t, node.shellCmds, "chattr +i /etc/resolv.conf")
+}
+
+func TestRenderCorefile(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	corefile, err := p.renderCorefile()
+	require.NoError(t, err)
+	assert.Contains(t, corefile, "    forward . 8.8.8.8 1.1.1.1\n", "public resolvers by default")
+	assert.NotContains(t, corefile, "template")
+
+	p.config.DNS = config.DNSConfig{
+		Upstream: []string{"10.1.0.53"},
+		StubZones: []config.StubZone{
+			{Zone: "corp.example.com", Forward: []string{"10.1.0.10", "10.1.0.11"}},
+			{Zone: "local", Address: "192.168.56.200"},
+		},
+	}
+	corefile, err = p.renderCorefile()
+	require.NoError(t, err)
+	assert.Contains(t, corefile, "    forward . 10.1.0.53\n    cache 30\n")
+	assert.Contains(t, corefile, "}\ncorp.example.com:53 {\n    errors\n    forward . 10.1.0.10 10.1.0.11\n    cache 30\n}\n")
+	assert.Contains(t, corefile, "local:53 {\n    errors\n    template IN A local {\n       answer \"{{ .Name }} 60 IN A 192.168.56.200\"\n    }\n")
+}
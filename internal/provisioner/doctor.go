@@ -0,0 +1,64 @@
+package provisioner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+)
+
+// Doctor runs the functional checks (see installer.Verifier) of the workload
+// steps enabled in the config, or of the steps in ids when given, and prints
+// a pass or fail line per component. Components without checks are listed as
+// such. It returns an error naming the components that failed.
+func (p *Provisioner) Doctor(ids ...string) error {
+	steps := p.workloadSteps()
+	for _, id := range ids {
+		if !slices.Contains(WorkloadIDs(), id) {
+			return fmt.Errorf("unknown workload %q (known: %s)", id, strings.Join(WorkloadIDs(), ", "))
+		}
+	}
+
+	var failed []string
+	checked := 0
+	for _, step := range steps {
+		if len(ids) > 0 && !slices.Contains(ids, step.id) {
+			continue
+		}
+		if step.enabled != nil && !step.enabled(p.config) {
+			if len(ids) > 0 {
+				fmt.Printf("-  %s: disabled in config.yaml\n", step.id)
+			}
+			continue
+		}
+
+		inst := p.buildStep(step.build)
+		v, ok := inst.(installer.Verifier)
+		if !ok {
+			fmt.Printf("-  %s: no checks\n", inst.Name())
+			continue
+		}
+		checked++
+		err := v.Verify()
+		if p.interrupted() {
+			return p.reportInterrupted("Verify "+inst.Name(), err)
+		}
+		if err != nil {
+			fmt.Printf("✗  %s: %v\n", inst.Name(), err)
+			failed = append(failed, step.id)
+			continue
+		}
+		fmt.Printf("✓  %s\n", inst.Name())
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d components failed their checks: %s", len(failed), checked, strings.Join(failed, ", "))
+	}
+	if p.dryRun {
+		fmt.Println("\n[dry-run] Checks walked through; nothing was verified.")
+		return nil
+	}
+	fmt.Printf("\nAll %d checked components are healthy.\n", checked)
+	return nil
+}
@@ -0,0 +1,179 @@
+package provisioner
+
+import "fmt"
+
+// Kubeconfigs kubeadm writes on a control plane node. super-admin.conf
+// bypasses RBAC and exists only on the node kubeadm init ran on.
+const (
+	adminConf      = "/etc/kubernetes/admin.conf"
+	superAdminConf = "/etc/kubernetes/super-admin.conf"
+)
+
+// kubeVIPManifest is the kube-vip static pod, started by the kubelet on every
+// control plane node.
+const kubeVIPManifest = "/etc/kubernetes/manifests/kube-vip.yaml"
+
+// kubeVIPTemplate runs kube-vip in ARP mode for the control plane only:
+// the control plane nodes elect a leader through a Lease, and the leader
+// answers ARP for the VIP on network.interface. LoadBalancer Services stay
+// with MetalLB. %s = kube-vip version, %s = interface, %s = VIP,
+// %s = kubeconfig path.
+const kubeVIPTemplate = `apiVersion: v1
+kind: Pod
+metadata:
+  name: kube-vip
+  namespace: kube-system
+spec:
+  containers:
+  - name: kube-vip
+    image: ghcr.io/kube-vip/kube-vip:%s
+    imagePullPolicy: IfNotPresent
+    args: ["manager"]
+    env:
+    - name: vip_arp
+      value: "true"
+    - name: port
+      value: "6443"
+    - name: vip_interface
+      value: %s
+    - name: vip_cidr
+      value: "32"
+    - name: cp_enable
+      value: "true"
+    - name: cp_namespace
+      value: kube-system
+    - name: vip_leaderelection
+      value: "true"
+    - name: vip_leasename
+      value: plndr-cp-lock
+    - name: vip_leaseduration
+      value: "5"
+    - name: vip_renewdeadline
+      value: "3"
+    - name: vip_retryperiod
+      value: "1"
+    - name: address
+      value: %s
+    securityContext:
+      capabilities:
+        add: ["NET_ADMIN", "NET_RAW"]
+    volumeMounts:
+    - mountPath: /etc/kubernetes/admin.conf
+      name: kubeconfig
+  hostAliases:
+  - hostnames: ["kubernetes"]
+    ip: 127.0.0.1
+  hostNetwork: true
+  volumes:
+  - name: kubeconfig
+    hostPath:
+      path: %s
+`
+
+// writeKubeVIP writes the kube-vip static pod serving the control plane VIP,
+// authenticating with kubeconfig.
+func (p *Provisioner) writeKubeVIP(kubeconfig string) error {
+	cfg := p.config
+	manifest := fmt.Sprintf(kubeVIPTemplate, cfg.Versions.KubeVIP, cfg.Network.Interface, cfg.Network.ControlPlaneVIP, kubeconfig)
+	if _, err := p.exec.RunShell("mkdir -p /etc/kubernetes/manifests"); err != nil {
+		return err
+	}
+	if err := p.writeFile(kubeVIPManifest, manifest); err != nil {
+		return fmt.Errorf("write kube-vip manifest: %w", err)
+	}
+	return nil
+}
+
+// advertiseAddress returns the address the API server on this node
+// advertises: network.controlplane_ip on the first control plane, the node's
+// own nodes[].ip on the others.
+func (p *Provisioner) advertiseAddress() (string, error) {
+	node, hostname, err := p.thisNode()
+	if err != nil {
+		return "", err
+	}
+	if first := p.config.GetControlPlane(); first == nil || node == nil || node.Name == first.Name {
+		if ip := p.config.Network.ControlPlaneIP; ip != "" {
+			return ip, nil
+		}
+		return "", fmt.Errorf("network.controlplane_ip is not set and no controlplane node has an ip")
+	}
+	if node.IP == "" {
+		return "", fmt.Errorf("node %s (hostname %s) has no ip in config", node.Name, hostname)
+	}
+	return node.IP, nil
+}
+
+// JoinControlPlane joins the node as an additional control plane of an HA
+// cluster (network.controlplane_vip set), through the VIP, with short-lived
+// credentials and a fresh certificate key issued on the first control plane
+// (see joinCredentials). kube-vip then runs on this node too, so the VIP
+// survives the loss of any control plane.
+func (p *Provisioner) JoinControlPlane() error {
+	return p.runPhase("Joining control plane", p.joinControlPlane)
+}
+
+func (p *Provisioner) joinControlPlane() error {
+	cfg := p.config
+	if cfg.Network.ControlPlaneVIP == "" {
+		return fmt.Errorf("additional control planes need network.controlplane_vip: the API server endpoint must outlive any one node")
+	}
+	node, hostname, err := p.thisNode()
+	if err != nil {
+		return err
+	}
+	if node == nil || node.Role != "controlplane" {
+		return fmt.Errorf("hostname %s is not a controlplane node in config", hostname)
+	}
+	if first := cfg.GetControlPlane(); first != nil && first.Name == node.Name {
+		return fmt.Errorf("%s is the first control plane and bootstraps the cluster: run provision init on it", node.Name)
+	}
+
+	if err := p.preflight("Control plane preflight checks", p.controlPlaneChecks()); err != nil {
+		return err
+	}
+	advertise, err := p.advertiseAddress()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Waiting for control plane...")
+	if err := p.waitForAPIServer(cfg.ControlPlaneEndpoint(), apiServerReadyTimeout); err != nil {
+		return err
+	}
+
+	// kubeadm gives this API server the audit flags of the ClusterConfiguration;
+	// the files they point at must be here before its static pod starts.
+	if err := p.writeAuditFiles(); err != nil {
+		return err
+	}
+
+	creds, err := p.joinCredentials(node.Name, true)
+	if err != nil {
+		return err
+	}
+	fmt.Println("\n>>> Joining as a control plane...")
+	if err := p.kubeadmJoin(creds, node.Name, advertise); err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Configuring kubectl...")
+	if err := p.configureKubectl(); err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Starting kube-vip...")
+	if err := p.writeKubeVIP(adminConf); err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Removing control-plane taint...")
+	p.removeControlPlaneTaint(node.Name)
+
+	fmt.Println("\n>>> Waiting for node to be ready...")
+	if err := p.waitForNode(node.Name, nodeReadyTimeout); err != nil {
+		return err
+	}
+	fmt.Printf("\n>>> %s joined the control plane.\n", node.Name)
+	return nil
+}
@@ -0,0 +1,109 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/audit"
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/join"
+)
+
+// fileExecutor is an answeringExecutor that keeps the files written through it
+// instead of touching the local filesystem.
+type fileExecutor struct {
+	answeringExecutor
+	files map[string]string
+}
+
+func (f *fileExecutor) WriteFile(path, content string) error {
+	if f.files == nil {
+		f.files = map[string]string{}
+	}
+	f.files[path] = content
+	return nil
+}
+
+func haConfig() *config.Config {
+	cfg := &config.Config{Nodes: []config.NodeConfig{
+		{Name: "cp1", IP: "192.168.56.10", Role: "controlplane"},
+		{Name: "cp2", IP: "192.168.56.13", Role: "controlplane"},
+		{Name: "node01", IP: "192.168.56.11", Role: "worker"},
+	}}
+	cfg.Network.Interface = "eth1"
+	cfg.Network.ControlPlaneIP = "192.168.56.10"
+	cfg.Network.ControlPlaneVIP = "192.168.56.9"
+	cfg.Versions.KubeVIP = "v0.9.2"
+	cfg.Cluster.PodCIDR = "10.244.0.0/16"
+	return cfg
+}
+
+const uploadCertsOutput = `[upload-certs] Storing the certificates in Secret "kubeadm-certs" in the "kube-system" Namespace
+[upload-certs] Using certificate key:
+0f3c9a`
+
+func TestWriteKubeadmConfig_UsesTheVIPAsEndpoint(t *testing.T) {
+	node := &fileExecutor{}
+	cfg := haConfig()
+	cfg.Cluster.ServiceCIDR = "10.100.0.0/16"
+	cfg.Kubeadm.APIServerExtraArgs = map[string]string{"max-requests-inflight": "800"}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+
+	path, err := p.writeKubeadmConfig("cp1")
+	require.NoError(t, err)
+
+	rendered := node.files[path]
+	assert.Contains(t, rendered, "advertiseAddress: 192.168.56.10\n")
+	assert.Contains(t, rendered, "  criSocket: unix:///var/run/crio/crio.sock\n  name: cp1\n")
+	assert.Contains(t, rendered, "controlPlaneEndpoint: 192.168.56.9:6443\n")
+	assert.Contains(t, rendered, "  serviceSubnet: 10.100.0.0/16\n")
+	assert.Contains(t, rendered, "    - name: audit-log-path\n      value: '-'\n    - name: max-requests-inflight\n",
+		"config.yaml's extra args follow the managed audit flags")
+	assert.Contains(t, rendered, "kind: KubeletConfiguration\n")
+}
+
+// TestJoinControlPlane_Remote verifies a second control plane joins through
+// the VIP with its own advertise address and then runs kube-vip itself.
+func TestJoinControlPlane_Remote(t *testing.T) {
+	ca, hash := testCA(t)
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"hostname": "cp2"}}}
+	cp := &answeringExecutor{answers: map[string]string{
+		"token create":      "abcdef.0123456789abcdef",
+		join.CACertPath:     ca,
+		"upload-certs":      uploadCertsOutput,
+		"kubectl get node ": "True",
+	}}
+	p := NewWithExecutor(context.Background(), haConfig(), node, false)
+	p.remote = true
+	p.controlPlane = cp
+	p.SkipPreflight()
+
+	require.NoError(t, p.JoinControlPlane())
+
+	assert.Contains(t, node.shellCmds, "nc -z 192.168.56.9 6443", "waits for the API server on the VIP")
+	assert.Contains(t, node.shellCmds, "kubeadm join --config "+join.ConfigPath)
+	rendered := node.files[join.ConfigPath]
+	assert.Contains(t, rendered, "apiServerEndpoint: 192.168.56.9:6443\n")
+	assert.Contains(t, rendered, "- "+hash+"\n")
+	assert.Contains(t, rendered, "  name: cp2\n")
+	assert.Contains(t, rendered, "  certificateKey: 0f3c9a\n")
+	assert.Contains(t, rendered, "    advertiseAddress: 192.168.56.13\n")
+	manifest := node.files[kubeVIPManifest]
+	assert.Contains(t, manifest, "value: 192.168.56.9\n")
+	assert.Contains(t, manifest, "path: "+adminConf+"\n")
+	assert.Equal(t, audit.Presets["default"], node.files[auditPolicyPath],
+		"the joined API server gets the same audit flags, so it needs the policy too")
+}
+
+func TestJoinControlPlane_FirstControlPlaneBootstraps(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"hostname": "cp1"}}}
+	p := NewWithExecutor(context.Background(), haConfig(), node, false)
+
+	err := p.JoinControlPlane()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "run provision init on it")
+	assert.Equal(t, []string{"hostname"}, node.shellCmds)
+}
@@ -2,37 +2,66 @@ package provisioner
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
 )
 
-// writeFile writes content to path, or prints the intent and skips when in
-// dry-run mode (file writes bypass the executor, so they need their own guard).
+// writeFile writes content to path on the node being provisioned (local, or
+// remote over SSH), or prints the intent and skips when in dry-run mode.
 func (p *Provisioner) writeFile(path, content string) error {
 	if p.dryRun {
 		fmt.Printf("[dry-run] write %s\n", path)
 		return nil
 	}
-	return executor.WriteFile(path, content)
+	return executor.WriteFileOn(p.exec, path, content)
+}
+
+type commonStep struct {
+	name string
+	fn   func() error
 }
 
 func (p *Provisioner) InstallCommon() error {
-	steps := []struct {
-		name string
-		fn   func() error
-	}{
+	runtimeStep, installRuntime := p.runtimeStep()
+	steps := []commonStep{
 		{"Disabling swap", p.disableSwap},
 		{"Loading kernel modules", p.loadKernelModules},
 		{"Configuring sysctl", p.configureSysctl},
 		{"Configuring DNS", p.configureDNS},
 		{"Installing dependencies", p.installDependencies},
-		{"Installing CRI-O", p.installCRIO},
+		{runtimeStep, installRuntime},
 		{"Installing Kubernetes tools", p.installKubernetesTools},
 	}
+	if p.config.Offline.Enabled() {
+		steps = p.offlineSteps(steps)
+	}
+
+	if err := p.preflight("Preflight checks", p.hostChecks()); err != nil {
+		if p.interrupted() {
+			return p.reportInterrupted("Preflight checks", err)
+		}
+		return err
+	}
 
+	var names []string
 	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	p.checkpoint.begin(phaseCommon, names)
+	for _, step := range steps {
+		if st, ok := p.checkpoint.done(phaseCommon, step.name); ok {
+			fmt.Printf("\n✓ %s already completed at %s, skipping (--resume)\n", step.name, st.FinishedAt.Format(time.RFC3339))
+			continue
+		}
 		fmt.Printf("\n>>> %s...\n", step.name)
-		if err := step.fn(); err != nil {
+		p.checkpoint.start(phaseCommon, step.name)
+		err := p.inStep(step.name, step.fn)
+		p.checkpoint.finish(phaseCommon, step.name, err)
+		if err != nil {
+			if p.interrupted() {
+				return p.reportInterrupted(step.name, err)
+			}
 			return fmt.Errorf("%s failed: %w", step.name, err)
 		}
 		fmt.Printf("✓ %s completed\n", step.name)
@@ -80,94 +109,46 @@ net.ipv4.ip_forward                 = 1`
 	return err
 }
 
-func (p *Provisioner) configureDNS() error {
-	// VirtualBox NAT DHCP advertises the host's home router IP as DNS, which is
-	// unreachable from inside the 10.0.2.x NAT network. Override with public resolvers,
-	// prevent dhcpcd from overwriting on renewal, and lock the file with chattr.
-	if _, err := p.exec.RunShell("chattr -i /etc/resolv.conf 2>/dev/null; rm -f /etc/resolv.conf"); err != nil {
-		return err
-	}
-	if err := p.writeFile("/etc/resolv.conf", "nameserver 8.8.8.8\nnameserver 1.1.1.1\n"); err != nil {
-		return err
-	}
-	if _, err := p.exec.RunShell("chattr +i /etc/resolv.conf"); err != nil {
-		return err
-	}
-	_, err := p.exec.RunShell(`grep -q 'nohook resolv.conf' /etc/dhcpcd.conf 2>/dev/null || echo 'nohook resolv.conf' >> /etc/dhcpcd.conf`)
-	return err
-}
-
 func (p *Provisioner) installDependencies() error {
-	if _, err := p.exec.RunShell("apt-get update"); err != nil {
+	host, err := p.hostOS()
+	if err != nil {
 		return err
 	}
-
-	pkgs := "apt-transport-https ca-certificates curl gnupg conntrack ethtool socat"
-	_, err := p.exec.RunShell(fmt.Sprintf("apt-get install -y %s", pkgs))
-	return err
+	fmt.Printf("Distribution: %s\n", host.name())
+	return host.installDependencies()
 }
 
-func (p *Provisioner) installCRIO() error {
-	version := p.config.Versions.CriO
-
-	// Add CRI-O repository
-	keyCmd := fmt.Sprintf("curl -fsSL https://download.opensuse.org/repositories/isv:/cri-o:/stable:/%s/deb/Release.key | gpg --dearmor -o /etc/apt/keyrings/cri-o-apt-keyring.gpg", version)
-	if _, err := p.exec.RunShell(keyCmd); err != nil {
-		return err
-	}
-
-	repoLine := fmt.Sprintf("deb [signed-by=/etc/apt/keyrings/cri-o-apt-keyring.gpg] https://download.opensuse.org/repositories/isv:/cri-o:/stable:/%s/deb/ /", version)
-	if err := p.writeFile("/etc/apt/sources.list.d/cri-o.list", repoLine); err != nil {
-		return err
-	}
-
-	if _, err := p.exec.RunShell("apt-get update"); err != nil {
-		return err
-	}
-
-	if _, err := p.exec.RunShell("apt-get install -y cri-o"); err != nil {
+func (p *Provisioner) installKubernetesTools() error {
+	if err := p.configureKubernetesRepo(); err != nil {
 		return err
 	}
-
-	if _, err := p.exec.Run("systemctl", "daemon-reload"); err != nil {
+	host, err := p.hostOS()
+	if err != nil {
 		return err
 	}
-	if _, err := p.exec.Run("systemctl", "enable", "crio"); err != nil {
+	if err := host.install("kubelet", "kubeadm", "kubectl"); err != nil {
 		return err
 	}
-	if _, err := p.exec.Run("systemctl", "start", "crio"); err != nil {
+	if err := host.hold("kubelet", "kubeadm", "kubectl"); err != nil {
 		return err
 	}
 
-	return nil
+	_, err = p.exec.Run("systemctl", "enable", "kubelet")
+	return err
 }
 
-func (p *Provisioner) installKubernetesTools() error {
-	version := p.config.Versions.Kubernetes
-
-	// Add Kubernetes repository
-	keyCmd := fmt.Sprintf("curl -fsSL https://pkgs.k8s.io/core:/stable:/v%s/deb/Release.key | gpg --dearmor -o /etc/apt/keyrings/kubernetes-apt-keyring.gpg", version)
-	if _, err := p.exec.RunShell(keyCmd); err != nil {
-		return err
-	}
-
-	repoLine := fmt.Sprintf("deb [signed-by=/etc/apt/keyrings/kubernetes-apt-keyring.gpg] https://pkgs.k8s.io/core:/stable:/v%s/deb/ /", version)
-	if err := p.writeFile("/etc/apt/sources.list.d/kubernetes.list", repoLine); err != nil {
-		return err
-	}
-
-	if _, err := p.exec.RunShell("apt-get update"); err != nil {
-		return err
-	}
-
-	if _, err := p.exec.RunShell("apt-get install -y kubelet kubeadm kubectl"); err != nil {
-		return err
+// configureKubernetesRepo points the package manager at the pkgs.k8s.io
+// repository for versions.kubernetes and refreshes the package index. The
+// repository only carries that minor version, so moving it is what lets an
+// upgrade install the next one. Offline, the bundle's repository carries the
+// packages instead.
+func (p *Provisioner) configureKubernetesRepo() error {
+	if p.config.Offline.Enabled() {
+		return nil
 	}
-
-	if _, err := p.exec.RunShell("apt-mark hold kubelet kubeadm kubectl"); err != nil {
+	host, err := p.hostOS()
+	if err != nil {
 		return err
 	}
-
-	_, err := p.exec.Run("systemctl", "enable", "kubelet")
-	return err
+	return host.addKubernetesRepo()
 }
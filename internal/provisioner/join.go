@@ -0,0 +1,107 @@
+package provisioner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/join"
+)
+
+// joinCredentials gets the credentials for the node named name to join the
+// cluster, as a control plane when controlPlane is set. They are issued on the
+// first control plane: through the SSH connection to it when provisioning
+// remotely; otherwise fetched from the join endpoint at
+// provisioning.join.url, or issued over a new SSH connection with the
+// provisioning credentials.
+func (p *Provisioner) joinCredentials(name string, controlPlane bool) (join.Credentials, error) {
+	cfg := p.config
+	endpoint := cfg.ControlPlaneEndpoint()
+	switch {
+	case p.controlPlane != nil:
+		fmt.Println("\n>>> Issuing join credentials on the control plane...")
+		return join.Issue(p.controlPlane, endpoint, controlPlane)
+	case p.dryRun:
+		fmt.Println("[dry-run] skip fetching join credentials")
+		return join.Credentials{APIServerEndpoint: endpoint + ":6443"}, nil
+	case cfg.Provisioning.Join.URL != "":
+		fmt.Printf("\n>>> Fetching join credentials from %s...\n", cfg.Provisioning.Join.URL)
+		return join.Fetch(p.ctx, cfg.Provisioning.Join.URL, cfg.Provisioning.Join.Fingerprint, name)
+	}
+
+	cp := cfg.GetControlPlane()
+	if cp == nil {
+		return join.Credentials{}, fmt.Errorf("no controlplane node in config")
+	}
+	fmt.Printf("\n>>> Issuing join credentials on %s over SSH...\n", cp.Name)
+	exec, err := dialNode(cfg, cp.Name, 0, nil, p.verbose)
+	if err != nil {
+		return join.Credentials{}, fmt.Errorf("connect to control plane %s (or set provisioning.join.url): %w", cp.Name, err)
+	}
+	defer func() { _ = exec.Close() }()
+	return join.Issue(executor.WithContext(p.ctx, exec), endpoint, controlPlane)
+}
+
+// kubeadmJoin joins this node to the cluster as name with creds, from a
+// JoinConfiguration rather than a join command line, which would expose the
+// token in the process list and the audit log. The file is readable by root
+// only and removed afterwards, whether the join succeeded or not.
+func (p *Provisioner) kubeadmJoin(creds join.Credentials, name, advertise string) error {
+	if _, err := p.exec.RunShell("install -D -m 600 /dev/null " + join.ConfigPath); err != nil {
+		return fmt.Errorf("create %s: %w", join.ConfigPath, err)
+	}
+	joinConfig, err := join.Configuration(creds, p.config.CRISocket(), name, advertise)
+	if err != nil {
+		return err
+	}
+	if err := p.writeFile(join.ConfigPath, joinConfig); err != nil {
+		return fmt.Errorf("write join configuration: %w", err)
+	}
+	err = p.exec.RunShellWithOutput("kubeadm join --config " + join.ConfigPath)
+	if _, rmErr := p.exec.RunShell("rm -f " + join.ConfigPath); rmErr != nil && err == nil {
+		err = fmt.Errorf("remove %s: %w", join.ConfigPath, rmErr)
+	}
+	return err
+}
+
+// ServeJoin runs the one-shot join endpoint on this control plane: every
+// worker and additional control plane in config can fetch its join
+// credentials once over HTTPS, from its own nodes[].ip, until all have or ttl
+// passes. Nodes pin the endpoint's self-signed certificate with the
+// fingerprint printed here (provisioning.join.fingerprint).
+func (p *Provisioner) ServeJoin(port int, ttl time.Duration) error {
+	if p.dryRun || p.remote {
+		return fmt.Errorf("the join endpoint runs on the control plane itself, without --dry-run")
+	}
+	cfg := p.config
+	hosts := []string{cfg.Network.ControlPlaneIP, cfg.Network.ControlPlaneVIP}
+	for _, cp := range cfg.GetControlPlanes() {
+		hosts = append(hosts, cp.Name, cp.IP)
+	}
+	cert, err := join.LoadOrCreateCertificate(join.CertPath, join.KeyPath, hosts)
+	if err != nil {
+		return err
+	}
+
+	server := join.NewServer(cfg, func(node config.NodeConfig) (join.Credentials, error) {
+		return join.Issue(p.exec, cfg.ControlPlaneEndpoint(), node.Role == "controlplane")
+	}, executor.Output(p.ctx))
+	pending := server.Pending()
+	if len(pending) == 0 {
+		fmt.Println("No worker or additional control plane in config: nothing to serve.")
+		return nil
+	}
+
+	fmt.Printf("Join endpoint on port %d for %s (closes after %s).\n", port, strings.Join(pending, ", "), ttl)
+	fmt.Println("Set on the nodes (config.yaml):")
+	fmt.Printf("  provisioning:\n    join:\n      url: \"https://%s:%d\"\n      fingerprint: %q\n\n",
+		cfg.Network.ControlPlaneIP, port, join.Fingerprint(cert))
+	if err := server.Serve(p.ctx, ":"+strconv.Itoa(port), cert, ttl); err != nil {
+		return err
+	}
+	fmt.Println("\nEvery node fetched its join credentials; join endpoint closed.")
+	return nil
+}
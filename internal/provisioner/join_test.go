@@ -0,0 +1,84 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/join"
+)
+
+// testCA returns a PEM-encoded self-signed CA certificate and its discovery
+// hash.
+func testCA(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	hash, err := join.CACertHash(caPEM)
+	require.NoError(t, err)
+	return string(caPEM), hash
+}
+
+// TestJoinWorker_FetchesFromJoinEndpoint verifies a worker provisioned on
+// itself with provisioning.join.url set takes its credentials from the pinned
+// endpoint, once.
+func TestJoinWorker_FetchesFromJoinEndpoint(t *testing.T) {
+	cfg := &config.Config{Nodes: []config.NodeConfig{
+		{Name: "cp1", IP: "192.168.56.10", Role: "controlplane"},
+		{Name: "node01", IP: "127.0.0.1", Role: "worker"},
+	}}
+	cfg.Network.ControlPlaneIP = "192.168.56.10"
+	issued := 0
+	server := join.NewServer(cfg, func(node config.NodeConfig) (join.Credentials, error) {
+		issued++
+		return join.Credentials{APIServerEndpoint: "192.168.56.10:6443", Token: "abcdef.0123456789abcdef", CACertHash: "sha256:00"}, nil
+	}, io.Discard)
+	endpoint := httptest.NewTLSServer(server)
+	defer endpoint.Close()
+	cfg.Provisioning.Join.URL = endpoint.URL
+	cfg.Provisioning.Join.Fingerprint = join.Fingerprint(endpoint.TLS.Certificates[0])
+
+	worker := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"hostname": "node01"}}}
+	p := NewWithExecutor(context.Background(), cfg, worker, false)
+	require.NoError(t, p.JoinWorker())
+	assert.Equal(t, 1, issued)
+	assert.Contains(t, worker.files[join.ConfigPath], "token: abcdef.0123456789abcdef\n")
+	assert.Contains(t, worker.shellCmds, "kubeadm join --config "+join.ConfigPath)
+
+	err := p.JoinWorker()
+	require.Error(t, err, "the endpoint serves each node once")
+	assert.Contains(t, err.Error(), "410")
+}
+
+func TestJoinWorker_RefusesControlPlanes(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"hostname": "cp2"}}}
+	p := NewWithExecutor(context.Background(), haConfig(), node, false)
+
+	err := p.JoinWorker()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "run provision controlplane-join on it")
+}
@@ -0,0 +1,108 @@
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// offlineSteps adds the bundle to the InstallCommon steps: it is unpacked
+// first, and its images are loaded once the container runtime runs.
+func (p *Provisioner) offlineSteps(steps []commonStep) []commonStep {
+	out := []commonStep{{"Unpacking offline bundle", p.unpackBundle}}
+	for _, step := range steps {
+		out = append(out, step)
+		if runtimeStep, _ := p.runtimeStep(); step.name == runtimeStep {
+			out = append(out, commonStep{"Loading offline images", p.loadImages})
+		}
+	}
+	return out
+}
+
+// unpackBundle unpacks offline.bundle into offline.Dir, checks that it was
+// built for this node's architecture and distribution family, and installs
+// packages from it from now on.
+func (p *Provisioner) unpackBundle() error {
+	host, err := p.hostOS()
+	if err != nil {
+		return err
+	}
+	bundle := p.config.Offline.Bundle
+	if _, err := p.exec.RunShell(fmt.Sprintf("mkdir -p %s && tar -xzf %s -C %s", offline.Dir, bundle, offline.Dir)); err != nil {
+		return fmt.Errorf("unpack %s: %w", bundle, err)
+	}
+
+	if p.dryRun {
+		fmt.Printf("[dry-run] skip checking %s\n", offline.ManifestFile)
+	} else {
+		m, err := p.bundleManifest()
+		if err != nil {
+			return err
+		}
+		arch, err := p.goArch()
+		if err != nil {
+			return err
+		}
+		if m.Arch != arch || m.Family != host.family() {
+			return fmt.Errorf("%s was built for %s/%s, this node is %s/%s", bundle, m.Family, m.Arch, host.family(), arch)
+		}
+	}
+	return host.addOfflineRepo()
+}
+
+// bundleManifest reads bundle.yaml of the unpacked bundle.
+func (p *Provisioner) bundleManifest() (offline.Manifest, error) {
+	data, err := p.exec.RunShell("cat " + offline.Dir + "/" + offline.ManifestFile)
+	if err != nil {
+		return offline.Manifest{}, fmt.Errorf("read %s: %w", offline.ManifestFile, err)
+	}
+	return offline.ParseManifest(data)
+}
+
+// loadImages imports the bundled images into the container runtime, under
+// the references the manifests and charts name, so the kubelet finds them
+// without pulling: containerd through ctr, CRI-O through skopeo into its
+// containers-storage.
+func (p *Provisioner) loadImages() error {
+	if p.dryRun {
+		fmt.Println("[dry-run] would load the bundled images")
+		return nil
+	}
+	m, err := p.bundleManifest()
+	if err != nil {
+		return err
+	}
+	loaded := 0
+	for _, image := range m.Images {
+		if image.Archive == "" {
+			continue
+		}
+		archive := offline.Dir + "/" + image.Archive
+		cmd := fmt.Sprintf("skopeo copy docker-archive:%s containers-storage:%s", archive, image.Ref)
+		if p.config.Runtime() == "containerd" {
+			cmd = "ctr -n k8s.io images import " + archive
+		}
+		if _, err := p.exec.RunShell(cmd); err != nil {
+			return fmt.Errorf("load image %s: %w", image.Ref, err)
+		}
+		loaded++
+	}
+	if loaded == 0 {
+		fmt.Println("Warning: the bundle holds no image archives (built with --images=false); nodes must reach a registry")
+	}
+	fmt.Printf("Loaded %d images\n", loaded)
+	return nil
+}
+
+// offlinePackages are the packages InstallCommon installs on host, which a
+// bundle carries with their dependencies.
+func (p *Provisioner) offlinePackages(host hostOS) []string {
+	pkgs := host.dependencies()
+	if p.config.Runtime() == "containerd" {
+		pkgs = append(pkgs, "runc")
+	} else {
+		// skopeo loads the bundled images into CRI-O's storage.
+		pkgs = append(pkgs, "cri-o", "skopeo")
+	}
+	return append(pkgs, "kubelet", "kubeadm", "kubectl")
+}
@@ -0,0 +1,71 @@
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBundleManifest = `arch: arm64
+family: debian
+kubernetes: "1.34"
+images:
+  - ref: registry.k8s.io/pause:3.10.1
+    archive: images/registry.k8s.io_pause_3.10.1.tar
+  - ref: quay.io/prometheus/prometheus:v3.5.0
+`
+
+// TestInstallCommon_Offline verifies a node provisioned from a bundle unpacks
+// it, installs packages from its repository only, takes containerd from it
+// and loads its images, without reaching the network.
+func TestInstallCommon_Offline(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{
+		"/etc/os-release":          debianOSRelease,
+		"uname -m":                 "aarch64\n",
+		"/opt/k8s-offline/bundle.": testBundleManifest,
+	}}}
+	cfg := preflightConfig()
+	cfg.Versions.Kubernetes = "1.34"
+	cfg.Versions.Containerd = "v2.1.4"
+	cfg.Components.Runtime = "containerd"
+	cfg.Offline.Bundle = "/vagrant/k8s-bundle.tar.gz"
+	cfg.Checksums = map[string]string{"https://github.com/containerd/containerd/releases/download/v2.1.4/containerd-2.1.4-linux-arm64.tar.gz": testSum}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+	p.SkipPreflight()
+
+	require.NoError(t, p.InstallCommon())
+
+	assert.Contains(t, node.shellCmds, "mkdir -p /opt/k8s-offline && tar -xzf /vagrant/k8s-bundle.tar.gz -C /opt/k8s-offline")
+	assert.Equal(t, "deb [trusted=yes] file:/opt/k8s-offline/packages ./\n", node.files["/etc/apt/sources.list.d/k8s-offline.list"])
+	assert.Contains(t, node.shellCmds, "echo '"+testSum+"  /opt/k8s-offline/files/containerd.tar.gz' | sha256sum -c --status -")
+	assert.Contains(t, node.shellCmds, "tar Cxzf /usr/local /opt/k8s-offline/files/containerd.tar.gz")
+	assert.Contains(t, node.shellCmds, "ctr -n k8s.io images import /opt/k8s-offline/images/registry.k8s.io_pause_3.10.1.tar")
+	assert.Contains(t, node.shellCmds, "apt-get install -y kubelet kubeadm kubectl")
+	for _, c := range node.shellCmds {
+		assert.NotContains(t, c, "curl -fsSL")
+		assert.NotEqual(t, "apt-get update", c)
+	}
+	assert.NotContains(t, node.files, "/etc/apt/sources.list.d/kubernetes.list")
+}
+
+// TestInstallCommon_OfflineRejectsForeignBundle verifies a bundle built for
+// another architecture or distribution family is refused before anything is
+// installed from it.
+func TestInstallCommon_OfflineRejectsForeignBundle(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{
+		"/etc/os-release":          rockyOSRelease,
+		"uname -m":                 "aarch64\n",
+		"/opt/k8s-offline/bundle.": testBundleManifest,
+	}}}
+	cfg := preflightConfig()
+	cfg.Offline.Bundle = "/vagrant/k8s-bundle.tar.gz"
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+	p.SkipPreflight()
+
+	err := p.InstallCommon()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/vagrant/k8s-bundle.tar.gz was built for debian/arm64, this node is rhel/arm64")
+	assert.NotContains(t, node.files, "/etc/yum.repos.d/k8s-offline.repo")
+}
@@ -0,0 +1,318 @@
+package provisioner
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+)
+
+// SetParallel lets InstallWorkloads install up to n workload steps at once,
+// each as soon as its dependencies have finished. Concurrent steps print with
+// a "[component]" prefix on every line. n <= 1 keeps the sequential run.
+func (p *Provisioner) SetParallel(n int) {
+	p.parallel = n
+}
+
+// SetSelection limits InstallWorkloads to the workload steps in only (every
+// step when empty) minus those in skip, both given as step ids (see
+// WorkloadIDs). A step disabled in the config stays disabled. Unknown ids are
+// rejected when the plan runs.
+func (p *Provisioner) SetSelection(only, skip []string) {
+	p.only, p.skip = only, skip
+}
+
+// WorkloadIDs returns the stable ids of the workload steps, in plan order.
+func WorkloadIDs() []string {
+	var ids []string
+	for _, step := range (&Provisioner{}).workloadSteps() {
+		ids = append(ids, step.id)
+	}
+	return ids
+}
+
+// selected reports whether id passes the --only/--skip selection.
+func (p *Provisioner) selected(id string) bool {
+	return (len(p.only) == 0 || slices.Contains(p.only, id)) && !slices.Contains(p.skip, id)
+}
+
+// checkSelection rejects selected ids that name no step, and warns about
+// selected steps whose dependency the config enables but the selection drops:
+// that dependency is assumed to be installed already.
+func (p *Provisioner) checkSelection(steps []workloadStep) error {
+	known := make(map[string]workloadStep, len(steps))
+	for _, step := range steps {
+		known[step.id] = step
+	}
+	for _, id := range slices.Concat(p.only, p.skip) {
+		if _, ok := known[id]; !ok {
+			return fmt.Errorf("unknown workload %q (known: %s)", id, strings.Join(WorkloadIDs(), ", "))
+		}
+	}
+	for _, id := range p.only {
+		if step := known[id]; step.enabled != nil && !step.enabled(p.config) && !slices.Contains(p.skip, id) {
+			fmt.Printf("Warning: %s is disabled in config.yaml and will not be installed\n", id)
+		}
+	}
+
+	enabled := p.enabledSteps(steps)
+	for _, step := range steps {
+		if !enabled[step.id] {
+			continue
+		}
+		for _, dep := range step.deps {
+			if d := known[dep]; !p.selected(dep) && (d.enabled == nil || d.enabled(p.config)) {
+				fmt.Printf("Warning: %s depends on %s, which is not selected; it must already be installed\n", step.id, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePlan checks that step ids are unique, that every dependency names a
+// step, and that the dependencies form no cycle.
+func validatePlan(steps []workloadStep) error {
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		if _, dup := index[step.id]; dup {
+			return fmt.Errorf("workload plan: duplicate step id %q", step.id)
+		}
+		index[step.id] = i
+	}
+	for _, step := range steps {
+		for _, dep := range step.deps {
+			if _, ok := index[dep]; !ok {
+				return fmt.Errorf("workload plan: %s depends on unknown step %q", step.id, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(steps))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			cycle := path[slices.Index(path, steps[i].id):]
+			return fmt.Errorf("workload plan: dependency cycle %s -> %s", strings.Join(cycle, " -> "), steps[i].id)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		path = append(path, steps[i].id)
+		for _, dep := range steps[i].deps {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enabledSteps returns the ids of the steps enabled in p's config and
+// selected (see SetSelection).
+func (p *Provisioner) enabledSteps(steps []workloadStep) map[string]bool {
+	enabled := make(map[string]bool)
+	for _, step := range steps {
+		if (step.enabled == nil || step.enabled(p.config)) && p.selected(step.id) {
+			enabled[step.id] = true
+		}
+	}
+	return enabled
+}
+
+// enabledDeps returns step's dependencies that are enabled; the others count
+// as finished (disabled, or left out of the selection as already installed).
+func enabledDeps(step workloadStep, enabled map[string]bool) []string {
+	var deps []string
+	for _, dep := range step.deps {
+		if enabled[dep] {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// stepResult reports a finished step to runWorkloads.
+type stepResult struct {
+	step  workloadStep
+	inst  installer.Installer
+	phase string // "installation", "post-install" or "verification" when err is set
+	err   error
+}
+
+// runWorkloads installs the enabled steps, starting each once its enabled
+// dependencies have finished, at most p.parallel at a time. Ready steps start
+// in plan order; an exclusive step waits for the running ones to finish and
+// holds back every later step until it is done. A fatal failure (or an
+// interruption) starts nothing new and returns once the running steps finish.
+// Steps already completed (see Checkpoint) are skipped. It returns the
+// installers of the steps that ran or were skipped, in completion order.
+func (p *Provisioner) runWorkloads(steps []workloadStep) ([]installer.Installer, error) {
+	enabled := p.enabledSteps(steps)
+	var pending []workloadStep
+	for _, step := range steps {
+		if enabled[step.id] {
+			pending = append(pending, step)
+		}
+	}
+
+	limit := max(p.parallel, 1)
+	finished := make(map[string]bool)
+	results := make(chan stepResult)
+	var mu sync.Mutex // serialises prefixed lines on the shared console
+	running, exclusive := 0, false
+	var installed []installer.Installer
+	var runErr error
+
+	ready := func(step workloadStep) bool {
+		for _, dep := range enabledDeps(step, enabled) {
+			if !finished[dep] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var ids []string
+	for _, step := range pending {
+		ids = append(ids, step.id)
+	}
+	p.checkpoint.begin(phaseWorkloads, ids)
+	for {
+		for i := 0; runErr == nil && !p.interrupted() && !exclusive && i < len(pending) && running < limit; {
+			step := pending[i]
+			if !ready(step) {
+				i++
+				continue
+			}
+			if st, ok := p.checkpoint.done(phaseWorkloads, step.id); ok {
+				// Kept in installed, so end-of-run hooks (Grafana OAuth2
+				// for Keycloak) still apply to the resumed cluster.
+				inst := step.build(p.ctx, p.config, p.exec)
+				fmt.Printf("\n✓ %s already completed at %s, skipping (--resume)\n", inst.Name(), st.FinishedAt.Format(time.RFC3339))
+				pending = append(pending[:i], pending[i+1:]...)
+				finished[step.id] = true
+				installed = append(installed, inst)
+				i = 0 // its dependents may now be ready
+				continue
+			}
+			if step.exclusive && running > 0 {
+				break // let the running steps drain first
+			}
+			pending = append(pending[:i], pending[i+1:]...)
+			running++
+			exclusive = step.exclusive
+			go func() { results <- p.installStep(step, limit > 1, &mu) }()
+		}
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		exclusive = false
+		finished[r.step.id] = true
+		installed = append(installed, r.inst)
+		if r.err != nil && runErr == nil {
+			if p.interrupted() {
+				runErr = p.reportInterrupted(r.inst.Name(), r.err)
+			} else {
+				runErr = fmt.Errorf("%s %s failed: %w", r.inst.Name(), r.phase, r.err)
+			}
+		}
+	}
+
+	// Cancelled between steps: name the first one that never started.
+	if runErr == nil && p.interrupted() && len(pending) > 0 {
+		name := pending[0].build(p.ctx, p.config, p.exec).Name()
+		runErr = p.reportInterrupted(name, p.ctx.Err())
+	}
+	return installed, runErr
+}
+
+// installStep builds and installs step, runs its post hook, then its
+// functional checks (see installer.Verifier). Its commands
+// are tagged with the component name in the audit log and, when prefixed, its
+// output lines too. A failure the step's policy tolerates is printed as a
+// warning; the rest are returned for runWorkloads to act on.
+func (p *Provisioner) installStep(step workloadStep, prefixed bool, mu *sync.Mutex) stepResult {
+	name := step.build(p.ctx, p.config, p.exec).Name()
+	ctx := executor.WithStep(p.ctx, name)
+	var out io.Writer = executor.Output(p.ctx)
+	if prefixed {
+		w := executor.Prefixed(out, mu, name)
+		defer func() { _ = w.Flush() }()
+		out = w
+		ctx = executor.WithOutput(ctx, w)
+	}
+
+	inst := step.build(ctx, p.config, p.exec)
+	result := stepResult{step: step, inst: inst}
+	// failure includes what the policy tolerates: a warned step is retried
+	// on --resume.
+	var failure error
+	p.checkpoint.start(phaseWorkloads, step.id)
+	defer func() { p.checkpoint.finish(phaseWorkloads, step.id, failure) }()
+
+	fmt.Fprintf(out, "\n>>> Installing %s...\n", name)
+	if err := inst.Install(); err != nil {
+		failure = err
+		if step.fatal || p.interrupted() {
+			result.phase, result.err = "installation", err
+			return result
+		}
+		fmt.Fprintf(out, "Warning: %s installation failed: %v\n", name, err)
+	}
+
+	if step.post != nil {
+		// The hook gets a copy of p bound to the step, so concurrent steps
+		// never swap p.exec under each other.
+		sp := *p
+		sp.ctx = ctx
+		sp.exec = executor.WithContext(ctx, p.base)
+		if err := step.post(&sp); err != nil {
+			failure = err
+			if step.fatal || p.interrupted() {
+				result.phase, result.err = "post-install", err
+				return result
+			}
+			fmt.Fprintf(out, "Warning: %s post-install failed: %v\n", name, err)
+		}
+	}
+
+	// Verify only what installed cleanly: a warned failure already says the
+	// component may be broken.
+	if v, ok := inst.(installer.Verifier); ok && failure == nil {
+		fmt.Fprintf(out, "Verifying %s...\n", name)
+		if err := v.Verify(); err != nil {
+			failure = err
+			if step.fatal || p.interrupted() {
+				result.phase, result.err = "verification", err
+				return result
+			}
+			fmt.Fprintf(out, "Warning: %s verification failed: %v\n", name, err)
+		} else {
+			fmt.Fprintf(out, "✓ %s verified\n", name)
+		}
+	}
+	return result
+}
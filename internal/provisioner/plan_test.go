@@ -0,0 +1,226 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+)
+
+// funcInstaller is an installer whose Install is fn.
+type funcInstaller struct {
+	name string
+	fn   func() error
+}
+
+func (f funcInstaller) Name() string   { return f.name }
+func (f funcInstaller) Install() error { return f.fn() }
+
+// testStep builds a workloadStep named id running fn.
+func testStep(id string, deps []string, fn func() error) workloadStep {
+	return workloadStep{id: id, deps: deps, build: func(context.Context, *config.Config, executor.CommandExecutor) installer.Installer {
+		return funcInstaller{name: id, fn: fn}
+	}}
+}
+
+func TestValidatePlan_ProductionPlanIsAcyclic(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	require.NoError(t, validatePlan(p.workloadSteps()))
+}
+
+// TestWorkloadSteps_TempoAfterLoki verifies Tempo's Grafana datasources,
+// which include Loki's, are written last: Loki's would drop Tempo's.
+func TestWorkloadSteps_TempoAfterLoki(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	for _, step := range p.workloadSteps() {
+		if step.id == "tempo" {
+			assert.Contains(t, step.deps, "loki")
+			return
+		}
+	}
+	t.Fatal("no tempo step")
+}
+
+func TestValidatePlan_RejectsCycleAndUnknownDep(t *testing.T) {
+	noop := func() error { return nil }
+
+	err := validatePlan([]workloadStep{
+		testStep("a", []string{"c"}, noop),
+		testStep("b", []string{"a"}, noop),
+		testStep("c", []string{"b"}, noop),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency cycle a -> c -> b -> a")
+
+	err = validatePlan([]workloadStep{testStep("a", []string{"nope"}, noop)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `a depends on unknown step "nope"`)
+}
+
+func TestRunWorkloads_IndependentStepsRunConcurrently(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	p.SetParallel(4)
+
+	// a and b each wait for the other to start: run one at a time, they
+	// would time out.
+	var started sync.WaitGroup
+	started.Add(2)
+	both := func() error {
+		started.Done()
+		ch := make(chan struct{})
+		go func() { started.Wait(); close(ch) }()
+		select {
+		case <-ch:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("the other step never started")
+		}
+	}
+	var mu sync.Mutex
+	var order []string
+	record := func(id string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, id)
+			return nil
+		}
+	}
+	steps := []workloadStep{
+		testStep("a", nil, both),
+		testStep("b", nil, both),
+		testStep("c", []string{"a", "b"}, record("c")),
+	}
+	steps[0].fatal, steps[1].fatal = true, true
+
+	_, err := p.runWorkloads(steps)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, order, "c runs once both dependencies finished")
+}
+
+func TestRunWorkloads_ExclusiveStepRunsAlone(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	p.SetParallel(4)
+
+	var running, peak atomic.Int32
+	track := func(exclusive bool) func() error {
+		return func() error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			if exclusive && n != 1 {
+				peak.Store(n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}
+	}
+	steps := []workloadStep{
+		testStep("a", nil, track(false)),
+		testStep("b", nil, track(false)),
+		testStep("x", nil, track(true)),
+		testStep("d", nil, track(false)),
+	}
+	steps[2].exclusive = true
+
+	_, err := p.runWorkloads(steps)
+	require.NoError(t, err)
+	assert.Zero(t, peak.Load(), "another step ran alongside the exclusive one")
+}
+
+func TestRunWorkloads_FatalFailureStartsNothingNew(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	p.SetParallel(4)
+
+	ran := false
+	steps := []workloadStep{
+		testStep("a", nil, func() error { return errors.New("boom") }),
+		testStep("b", []string{"a"}, func() error { ran = true; return nil }),
+	}
+	steps[0].fatal = true
+
+	_, err := p.runWorkloads(steps)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a installation failed: boom")
+	assert.False(t, ran, "a dependent of a fatal failure must not start")
+}
+
+func TestRunWorkloads_WarnedFailureStillUnblocksDependents(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+
+	ran := false
+	steps := []workloadStep{
+		testStep("a", nil, func() error { return errors.New("boom") }),
+		testStep("b", []string{"a"}, func() error { ran = true; return nil }),
+	}
+
+	_, err := p.runWorkloads(steps)
+	require.NoError(t, err)
+	assert.True(t, ran, "a non-fatal failure keeps the run going, as before")
+}
+
+// verifiedInstaller is a funcInstaller whose Verify is verify.
+type verifiedInstaller struct {
+	funcInstaller
+	verify func() error
+}
+
+func (v verifiedInstaller) Verify() error { return v.verify() }
+
+func TestRunWorkloads_FailedVerificationFollowsStepPolicy(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	verified := func(id string, err error) workloadStep {
+		step := testStep(id, nil, func() error { return nil })
+		step.build = func(context.Context, *config.Config, executor.CommandExecutor) installer.Installer {
+			return verifiedInstaller{funcInstaller{name: id, fn: func() error { return nil }}, func() error { return err }}
+		}
+		return step
+	}
+
+	_, err := p.runWorkloads([]workloadStep{verified("a", errors.New("no IP"))})
+	require.NoError(t, err, "a non-fatal step only warns")
+
+	fatal := verified("b", errors.New("no IP"))
+	fatal.fatal = true
+	_, err = p.runWorkloads([]workloadStep{fatal})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "b verification failed: no IP")
+}
+
+func TestRunWorkloads_SelectionTreatsDroppedDepsAsInstalled(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	p.SetSelection([]string{"b", "c"}, []string{"c"})
+
+	var ran []string
+	run := func(id string) func() error {
+		return func() error { ran = append(ran, id); return nil }
+	}
+	steps := []workloadStep{
+		testStep("a", nil, run("a")),
+		testStep("b", []string{"a"}, run("b")),
+		testStep("c", nil, run("c")),
+	}
+
+	require.NoError(t, p.checkSelection(steps))
+	_, err := p.runWorkloads(steps)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ran)
+}
+
+func TestCheckSelection_RejectsUnknownID(t *testing.T) {
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
+	p.SetSelection(nil, []string{"grafana"})
+
+	err := p.checkSelection(p.workloadSteps())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown workload "grafana"`)
+	assert.Contains(t, err.Error(), "metallb, istio")
+}
@@ -0,0 +1,418 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Minimums kubeadm itself enforces on a control plane node.
+const (
+	controlPlaneMinMemoryMiB = 1700
+	controlPlaneMinCPUs      = 2
+)
+
+// workerMinMemoryMiB is what a worker needs before any workload lands on it.
+const workerMinMemoryMiB = 1024
+
+// workloadMemoryMiB estimates the memory each workload step requests across
+// the cluster. Preflight spreads the total over the control plane (untainted)
+// and the workers to warn about nodes short of memory for the enabled
+// components. Vault runs on the storage node and is not counted.
+var workloadMemoryMiB = map[string]int{
+	"metallb":        128,
+	"istio":          1024,
+	"cert-manager":   256,
+	"metrics-server": 128,
+	"vpa":            256,
+	"keda":           256,
+	"nfs":            64,
+	"vso":            128,
+	"monitoring":     2048,
+	"loki":           1024,
+	"tempo":          512,
+	"kiali":          256,
+	"hubble":         128,
+	"keycloak":       1536,
+	"ollama":         4096,
+	"karpor":         1024,
+}
+
+// controlPlanePorts are the ports kubeadm init binds on the control plane:
+// API server, etcd client and peer, controller manager and scheduler. Each
+// may be held by its own component, as after an interrupted init that is
+// being re-run; owner is the process name ss prints, cut to 15 characters.
+var controlPlanePorts = []struct {
+	port  int
+	owner string
+}{
+	{6443, "kube-apiserver"},
+	{2379, "etcd"},
+	{2380, "etcd"},
+	{10257, "kube-controller"},
+	{10259, "kube-scheduler"},
+}
+
+// kubeletPort is bound by the kubelet on every node.
+const kubeletPort = 10250
+
+// preflightCheck is one host check. A failed blocking check stops
+// provisioning; any other failure is reported as a warning.
+type preflightCheck struct {
+	name     string
+	blocking bool
+	// run returns a short description of what it found, or an error saying
+	// what is wrong.
+	run func() (string, error)
+}
+
+// SkipPreflight turns off the checks InstallCommon and InitCluster run before
+// touching the host.
+func (p *Provisioner) SkipPreflight() {
+	p.skipPreflight = true
+}
+
+// Preflight checks the host against the config without changing it: the
+// checks InstallCommon runs, plus those InitCluster runs when controlPlane is
+// set. It prints a report and fails if a blocking check failed.
+func (p *Provisioner) Preflight(controlPlane bool) error {
+	checks := p.hostChecks()
+	if controlPlane {
+		checks = append(checks, p.controlPlaneChecks()...)
+	}
+	return p.preflight("Preflight checks", checks)
+}
+
+// preflight runs checks under title and prints a line per check. It returns
+// an error listing the failed blocking checks. Dry runs skip the checks: the
+// host they would inspect is not the one being previewed.
+func (p *Provisioner) preflight(title string, checks []preflightCheck) error {
+	if p.skipPreflight {
+		fmt.Printf("\n>>> %s skipped (--skip-preflight)\n", title)
+		return nil
+	}
+	if p.dryRun {
+		fmt.Printf("[dry-run] skip %s\n", strings.ToLower(title))
+		return nil
+	}
+
+	fmt.Printf("\n>>> %s...\n", title)
+	var blocking, warnings []string
+	for _, check := range checks {
+		if p.interrupted() {
+			return context.Cause(p.ctx)
+		}
+		found, err := check.run()
+		switch {
+		case err == nil:
+			fmt.Printf("  ✓ %s: %s\n", check.name, found)
+		case check.blocking:
+			fmt.Printf("  ✗ %s: %v\n", check.name, err)
+			blocking = append(blocking, check.name)
+		default:
+			fmt.Printf("  ! %s: %v\n", check.name, err)
+			warnings = append(warnings, check.name)
+		}
+	}
+
+	if len(blocking) > 0 {
+		return fmt.Errorf("preflight: %d blocking problem(s): %s (fix them, or pass --skip-preflight to go ahead anyway)",
+			len(blocking), strings.Join(blocking, ", "))
+	}
+	if len(warnings) > 0 {
+		fmt.Printf("✓ %s passed with %d warning(s)\n", title, len(warnings))
+		return nil
+	}
+	fmt.Printf("✓ %s passed\n", title)
+	return nil
+}
+
+// hostChecks run before InstallCommon changes anything on the node.
+func (p *Provisioner) hostChecks() []preflightCheck {
+	return []preflightCheck{
+		{name: "node", blocking: true, run: p.checkNodeIdentity},
+		{name: "distribution", blocking: true, run: p.checkDistribution},
+		{name: "memory", blocking: true, run: p.checkMemory},
+		{name: "memory for workloads", run: p.checkWorkloadMemory},
+		{name: "CPUs", blocking: true, run: p.checkCPUs},
+		{name: "kernel modules", blocking: true, run: p.checkKernelModules},
+		{name: "cgroup v2", blocking: true, run: p.checkCgroupV2},
+		{name: "network interface", blocking: true, run: p.checkInterface},
+		{name: fmt.Sprintf("port %d", kubeletPort), blocking: true, run: func() (string, error) {
+			return p.checkPort(kubeletPort, "kubelet")
+		}},
+	}
+}
+
+// controlPlaneChecks run before kubeadm init or a control plane join. NFS and
+// Vault are only needed once workloads install, so they warn instead of
+// blocking.
+func (p *Provisioner) controlPlaneChecks() []preflightCheck {
+	checks := []preflightCheck{
+		{name: "advertise address", blocking: true, run: p.checkAdvertiseAddress},
+	}
+	for _, cp := range controlPlanePorts {
+		checks = append(checks, preflightCheck{name: fmt.Sprintf("port %d", cp.port), blocking: true, run: func() (string, error) {
+			return p.checkPort(cp.port, cp.owner)
+		}})
+	}
+	checks = append(checks, preflightCheck{name: "NFS server", run: p.checkNFS})
+	if p.config.Vault.Enabled {
+		checks = append(checks, preflightCheck{name: "Vault", run: p.checkVault})
+	}
+	return checks
+}
+
+func (p *Provisioner) checkNodeIdentity() (string, error) {
+	node, hostname, err := p.thisNode()
+	if err != nil {
+		return "", err
+	}
+	if node == nil {
+		return "", fmt.Errorf("hostname %q is not listed in nodes:", hostname)
+	}
+	return fmt.Sprintf("%s (%s)", node.Name, node.Role), nil
+}
+
+func (p *Provisioner) checkDistribution() (string, error) {
+	host, err := p.hostOS()
+	if err != nil {
+		return "", err
+	}
+	return host.name(), nil
+}
+
+// memoryMiB returns the host's total memory.
+func (p *Provisioner) memoryMiB() (int, error) {
+	out, err := p.exec.RunShell("awk '/^MemTotal:/ {print $2}' /proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("read /proc/meminfo: %w", err)
+	}
+	kib, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, fmt.Errorf("read /proc/meminfo: unexpected MemTotal %q", strings.TrimSpace(out))
+	}
+	return kib / 1024, nil
+}
+
+// minMemoryMiB is the memory a node with role needs before workloads, or 0
+// for nodes outside the Kubernetes cluster.
+func minMemoryMiB(role string) int {
+	switch role {
+	case "controlplane":
+		return controlPlaneMinMemoryMiB
+	case "worker":
+		return workerMinMemoryMiB
+	}
+	return 0
+}
+
+// checkMemory enforces the minimum memory of the node's role.
+func (p *Provisioner) checkMemory() (string, error) {
+	have, err := p.memoryMiB()
+	if err != nil {
+		return "", err
+	}
+	node, _, err := p.thisNode()
+	if err != nil {
+		return "", err
+	}
+	if node != nil && have < minMemoryMiB(node.Role) {
+		return "", fmt.Errorf("%d MiB, a %s needs at least %d MiB", have, node.Role, minMemoryMiB(node.Role))
+	}
+	return fmt.Sprintf("%d MiB", have), nil
+}
+
+// checkWorkloadMemory estimates whether a cluster node has room for its share
+// of the workloads enabled in the config. The estimate is rough, so falling
+// short only warns.
+func (p *Provisioner) checkWorkloadMemory() (string, error) {
+	node, _, err := p.thisNode()
+	if err != nil {
+		return "", err
+	}
+	if node == nil || minMemoryMiB(node.Role) == 0 {
+		return "not a cluster node", nil
+	}
+	have, err := p.memoryMiB()
+	if err != nil {
+		return "", err
+	}
+	share := p.workloadMemoryShare()
+	if need := minMemoryMiB(node.Role) + share; have < need {
+		return "", fmt.Errorf("%d MiB, about %d MiB needed with the enabled components (%d MiB per node); disable some in config.yaml or add memory",
+			have, need, share)
+	}
+	return fmt.Sprintf("about %d MiB per node needed", share), nil
+}
+
+// workloadMemoryShare estimates the memory, in MiB, each cluster node needs
+// for the workloads enabled in the config.
+func (p *Provisioner) workloadMemoryShare() int {
+	total := 0
+	for id := range p.enabledSteps(p.workloadSteps()) {
+		total += workloadMemoryMiB[id]
+	}
+	nodes := len(p.config.GetWorkers())
+	if p.config.GetControlPlane() != nil {
+		nodes++
+	}
+	if nodes == 0 {
+		return total
+	}
+	return (total + nodes - 1) / nodes
+}
+
+func (p *Provisioner) checkCPUs() (string, error) {
+	out, err := p.exec.RunShell("nproc")
+	if err != nil {
+		return "", fmt.Errorf("nproc: %w", err)
+	}
+	cpus, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return "", fmt.Errorf("nproc: unexpected output %q", strings.TrimSpace(out))
+	}
+	node, _, err := p.thisNode()
+	if err != nil {
+		return "", err
+	}
+	if node != nil && node.Role == "controlplane" && cpus < controlPlaneMinCPUs {
+		return "", fmt.Errorf("%d, kubeadm needs at least %d on a control plane", cpus, controlPlaneMinCPUs)
+	}
+	return strconv.Itoa(cpus), nil
+}
+
+// checkKernelModules checks that the modules loadKernelModules loads exist for
+// the running kernel (built in or loadable).
+func (p *Provisioner) checkKernelModules() (string, error) {
+	modules := []string{"overlay", "br_netfilter"}
+	var missing []string
+	for _, m := range modules {
+		if _, err := p.exec.RunShell("modprobe -n -q " + m); err != nil {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("not available for this kernel: %s", strings.Join(missing, ", "))
+	}
+	return strings.Join(modules, ", "), nil
+}
+
+// checkCgroupV2 rejects hosts on the legacy cgroup v1 hierarchy, which the
+// kubelet no longer starts on by default.
+func (p *Provisioner) checkCgroupV2() (string, error) {
+	out, err := p.exec.RunShell("stat -fc %T /sys/fs/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("stat /sys/fs/cgroup: %w", err)
+	}
+	if fs := strings.TrimSpace(out); fs != "cgroup2fs" {
+		return "", fmt.Errorf("/sys/fs/cgroup is %s (cgroup v1); boot with systemd.unified_cgroup_hierarchy=1", fs)
+	}
+	return "unified hierarchy", nil
+}
+
+// interfaceAddrs returns the IPv4 addresses of network.interface, as printed
+// by "ip -o -4 addr show".
+func (p *Provisioner) interfaceAddrs() (string, error) {
+	iface := p.config.Network.Interface
+	if iface == "" {
+		return "", fmt.Errorf("network.interface is not set")
+	}
+	out, err := p.exec.RunShell("ip -o -4 addr show dev " + iface)
+	if err != nil || strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("network.interface %s does not exist or has no IPv4 address", iface)
+	}
+	return out, nil
+}
+
+// hasAddr reports whether ip is among the addresses interfaceAddrs printed.
+func hasAddr(addrs, ip string) bool {
+	return strings.Contains(addrs, " "+ip+"/")
+}
+
+// checkInterface checks that network.interface exists and carries the node's
+// nodes[].ip.
+func (p *Provisioner) checkInterface() (string, error) {
+	addrs, err := p.interfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	iface := p.config.Network.Interface
+	node, _, err := p.thisNode()
+	if err != nil {
+		return "", err
+	}
+	if node == nil || node.IP == "" {
+		return iface, nil
+	}
+	if !hasAddr(addrs, node.IP) {
+		return "", fmt.Errorf("%s does not carry %s (nodes[].ip of %s)", iface, node.IP, node.Name)
+	}
+	return fmt.Sprintf("%s has %s", iface, node.IP), nil
+}
+
+// checkAdvertiseAddress checks that the address the node's API server will
+// advertise is on network.interface.
+func (p *Provisioner) checkAdvertiseAddress() (string, error) {
+	ip, err := p.advertiseAddress()
+	if err != nil {
+		return "", err
+	}
+	addrs, err := p.interfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	if !hasAddr(addrs, ip) {
+		return "", fmt.Errorf("%s is not on %s: kubeadm would advertise an address this host does not have", ip, p.config.Network.Interface)
+	}
+	return fmt.Sprintf("%s on %s", ip, p.config.Network.Interface), nil
+}
+
+// listenerProcess matches the process name in "ss -p" output.
+var listenerProcess = regexp.MustCompile(`users:\(\("([^"]+)"`)
+
+// checkPort checks that nothing listens on port, except owner when set: a
+// node re-provisioned after a kubelet is running keeps its port.
+func (p *Provisioner) checkPort(port int, owner string) (string, error) {
+	out, err := p.exec.RunShell(fmt.Sprintf("ss -Htlnp 'sport = :%d'", port))
+	if err != nil {
+		return "", fmt.Errorf("ss: %w", err)
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return "free", nil
+	}
+	process := "another process"
+	if m := listenerProcess.FindStringSubmatch(out); m != nil {
+		process = m[1]
+	}
+	if owner != "" && process == owner {
+		return "held by " + owner, nil
+	}
+	return "", fmt.Errorf("in use by %s", process)
+}
+
+func (p *Provisioner) checkNFS() (string, error) {
+	server := p.config.Storage.NFSServer
+	if server == "" {
+		return "", fmt.Errorf("storage.nfs_server is not set")
+	}
+	if _, err := p.exec.RunShell(fmt.Sprintf("timeout 3 bash -c '</dev/tcp/%s/2049'", server)); err != nil {
+		return "", fmt.Errorf("%s:2049 is unreachable; the NFS provisioner will fail", server)
+	}
+	return server + ":2049 reachable", nil
+}
+
+func (p *Provisioner) checkVault() (string, error) {
+	addr := p.config.VaultAddress()
+	if addr == "" {
+		return "", fmt.Errorf("vault is enabled but has no address (no vault.addr and no storage node)")
+	}
+	out, _ := p.exec.RunShell(fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --max-time 5 %s/v1/sys/health", addr))
+	if code := strings.TrimSpace(out); code == "" || code == "000" {
+		return "", fmt.Errorf("%s does not answer; Vault and VSO will fail", addr)
+	}
+	return addr + " answers", nil
+}
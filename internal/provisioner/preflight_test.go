@@ -0,0 +1,131 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+func preflightConfig() *config.Config {
+	cfg := &config.Config{Nodes: []config.NodeConfig{
+		{Name: "controlplane", IP: "192.168.56.10", Role: "controlplane"},
+		{Name: "node01", IP: "192.168.56.11", Role: "worker"},
+	}}
+	cfg.Network.Interface = "eth1"
+	cfg.Network.ControlPlaneIP = "192.168.56.10"
+	cfg.Storage.NFSServer = "storage"
+	return cfg
+}
+
+// healthyHost answers the preflight commands as a well-sized control plane.
+func healthyHost() *answeringExecutor {
+	return &answeringExecutor{answers: map[string]string{
+		"hostname":        "controlplane",
+		"/etc/os-release": debianOSRelease,
+		"MemTotal":        "8000000",
+		"nproc":           "4",
+		"stat -fc":        "cgroup2fs",
+		"addr show":       "3: eth1    inet 192.168.56.10/24 brd 192.168.56.255 scope global eth1",
+	}}
+}
+
+func TestInstallCommon_BlockingFindingsTouchNothing(t *testing.T) {
+	host := healthyHost()
+	host.answers["stat -fc"] = "tmpfs"
+	host.errs = map[string]error{"modprobe -n -q br_netfilter": errors.New("exit status 1")}
+	p := NewWithExecutor(context.Background(), preflightConfig(), host, false)
+
+	err := p.InstallCommon()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 blocking problem(s): kernel modules, cgroup v2")
+	for _, c := range host.shellCmds {
+		assert.NotContains(t, c, "swapoff", "nothing may change on the host")
+	}
+}
+
+func TestPreflight_ControlPlane(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers map[string]string
+		errs    map[string]error
+		err     string
+	}{
+		{name: "healthy"},
+		{
+			name:    "API server port taken",
+			answers: map[string]string{"sport = :6443": `LISTEN 0 4096 *:6443 *:* users:(("haproxy",pid=812,fd=3))`},
+			err:     "1 blocking problem(s): port 6443",
+		},
+		{
+			// Re-running provision init after an interrupted kubeadm init.
+			name: "control plane ports held by its components",
+			answers: map[string]string{
+				"sport = :6443":  `LISTEN 0 4096 *:6443 *:* users:(("kube-apiserver",pid=812,fd=3))`,
+				"sport = :2379":  `LISTEN 0 4096 192.168.56.10:2379 *:* users:(("etcd",pid=790,fd=9))`,
+				"sport = :2380":  `LISTEN 0 4096 192.168.56.10:2380 *:* users:(("etcd",pid=790,fd=7))`,
+				"sport = :10257": `LISTEN 0 4096 127.0.0.1:10257 *:* users:(("kube-controller",pid=801,fd=3))`,
+				"sport = :10259": `LISTEN 0 4096 127.0.0.1:10259 *:* users:(("kube-scheduler",pid=805,fd=3))`,
+				"sport = :10250": `LISTEN 0 4096 *:10250 *:* users:(("kubelet",pid=640,fd=17))`,
+			},
+		},
+		{
+			name:    "kubelet port held by the kubelet",
+			answers: map[string]string{"sport = :10250": `LISTEN 0 4096 *:10250 *:* users:(("kubelet",pid=640,fd=17))`},
+		},
+		{
+			name:    "advertise address on another interface",
+			answers: map[string]string{"addr show": "3: eth1    inet 10.0.2.15/24 scope global eth1"},
+			err:     "network interface, advertise address",
+		},
+		{
+			name:    "too little memory",
+			answers: map[string]string{"MemTotal": "1500000"},
+			err:     "1 blocking problem(s): memory",
+		},
+		{
+			name: "NFS server unreachable only warns",
+			errs: map[string]error{"/dev/tcp/storage/2049": errors.New("exit status 124")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := healthyHost()
+			for k, v := range tt.answers {
+				host.answers[k] = v
+			}
+			host.errs = tt.errs
+			p := NewWithExecutor(context.Background(), preflightConfig(), host, false)
+
+			err := p.Preflight(true)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWorkloadMemoryShare_SpreadsEnabledComponents(t *testing.T) {
+	cfg := preflightConfig()
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
+	base := p.workloadMemoryShare()
+
+	cfg.Components.Keycloak = "enabled"
+	assert.Equal(t, base+workloadMemoryMiB["keycloak"]/2, p.workloadMemoryShare(), "two cluster nodes share it")
+}
+
+func TestPreflight_SkippedRunsNothing(t *testing.T) {
+	host := healthyHost()
+	p := NewWithExecutor(context.Background(), preflightConfig(), host, false)
+	p.SkipPreflight()
+
+	require.NoError(t, p.Preflight(true))
+	assert.Empty(t, host.shellCmds)
+}
@@ -1,153 +1,126 @@
 package provisioner
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/techiescamp/k8s-provisioner/internal/backoff"
 	"github.com/techiescamp/k8s-provisioner/internal/config"
 	"github.com/techiescamp/k8s-provisioner/internal/executor"
 	"github.com/techiescamp/k8s-provisioner/internal/installer"
+	"github.com/techiescamp/k8s-provisioner/internal/kubeadm"
 )
 
 // Timeout constants for provisioner operations
 const (
 	nodeReadyTimeout      = 5 * time.Minute
 	apiServerReadyTimeout = 5 * time.Minute
+	drainTimeout          = 10 * time.Minute
 	defaultPollInterval   = 10 * time.Second
 )
 
 type Provisioner struct {
-	config  *config.Config
-	exec    executor.CommandExecutor
+	// ctx is cancelled on Ctrl-C/SIGTERM (see cmd.Execute); every command and
+	// readiness wait started by the provisioner or its installers is bound to it.
+	ctx    context.Context
+	config *config.Config
+	exec   executor.CommandExecutor
+	// base is the unbound executor behind exec, re-bound per step by inStep.
+	base    executor.CommandExecutor
 	verbose bool
 	dryRun  bool
+	// parallel bounds how many workload steps install at once (see
+	// SetParallel); 0 or 1 installs them one at a time, in plan order.
+	parallel int
+	// only and skip select workload steps by id (see SetSelection).
+	only, skip []string
+	// checkpoint records step progress on the node (see Checkpoint); nil
+	// records nothing.
+	checkpoint *checkpoint
+	// skipPreflight turns off the host checks (see SkipPreflight).
+	skipPreflight bool
+	// host is the node's distribution, detected on first use (see hostOS).
+	host hostOS
+
+	// remote is set by NewRemote: exec targets a node over SSH, so the local
+	// /vagrant shared folder is neither read nor written.
+	remote bool
+	// controlPlane runs commands on the control plane when provisioning a
+	// different node remotely (worker join command); nil otherwise.
+	controlPlane executor.CommandExecutor
+	closers      []func() error
 }
 
 // New builds a Provisioner with the production executor.
-func New(cfg *config.Config, verbose bool) *Provisioner {
-	return NewWithExecutor(cfg, executor.New(verbose), verbose)
+func New(ctx context.Context, cfg *config.Config, verbose bool) *Provisioner {
+	return NewWithExecutor(ctx, cfg, executor.New(verbose), verbose)
 }
 
 // NewDryRun builds a Provisioner that previews commands without mutating the
-// host. Shell/command calls are printed via the Null-Object executor, file
-// writes are skipped, readiness waits short-circuit, and InstallWorkloads prints
-// the component plan instead of running installers.
-func NewDryRun(cfg *config.Config, verbose bool) *Provisioner {
-	p := NewWithExecutor(cfg, executor.DryRunExecutor{}, verbose)
+// host. Shell/command calls are printed via the Null-Object executor, host file
+// writes are skipped and the provisioner's own readiness waits short-circuit.
+// Installers run to completion: the executor answers their polls as ready (see
+// installer.DryRunResponses). When script is non-nil it collects every command,
+// grouped by step, for review as shell scripts.
+func NewDryRun(ctx context.Context, cfg *config.Config, verbose bool, script *executor.Script) *Provisioner {
+	exec := executor.DryRunExecutor{Responses: installer.DryRunResponses(cfg), Script: script}
+	p := NewWithExecutor(installer.WithDryRun(ctx), cfg, exec, verbose)
 	p.dryRun = true
 	return p
 }
 
 // NewWithExecutor builds a Provisioner with an injected executor. Tests pass a
 // mock CommandExecutor here to assert orchestration without touching the host.
-func NewWithExecutor(cfg *config.Config, exec executor.CommandExecutor, verbose bool) *Provisioner {
+// exec is bound to ctx, so cancelling ctx aborts the in-flight command.
+func NewWithExecutor(ctx context.Context, cfg *config.Config, exec executor.CommandExecutor, verbose bool) *Provisioner {
 	return &Provisioner{
+		ctx:     ctx,
 		config:  cfg,
-		exec:    exec,
+		exec:    executor.WithContext(ctx, exec),
+		base:    exec,
 		verbose: verbose,
 	}
 }
 
-// auditPolicy is the kube-apiserver audit policy mounted into the control plane.
-// First match wins: drop read/health noise and high-frequency coordination
-// heartbeats (leases/events/endpoints/endpointslices — huge volume, low value),
-// capture security-sensitive objects at full fidelity, exec/attach at Request, and
-// every other mutation at Metadata. Reads of secrets are intentionally not logged
-// (matched by the get/list/watch=None rule first) to bound volume; reorder if
-// secret reads must be audited.
-const auditPolicy = `apiVersion: audit.k8s.io/v1
-kind: Policy
-omitStages:
-  - RequestReceived
-rules:
-  - level: None
-    verbs: ["get", "list", "watch"]
-  - level: None
-    nonResourceURLs: ["/healthz*", "/livez*", "/readyz*", "/version", "/metrics"]
-  - level: None
-    resources:
-      - group: "coordination.k8s.io"
-        resources: ["leases"]
-      - group: ""
-        resources: ["events", "endpoints"]
-      - group: "discovery.k8s.io"
-        resources: ["endpointslices"]
-  - level: RequestResponse
-    resources:
-      - group: ""
-        resources: ["secrets", "serviceaccounts"]
-      - group: "rbac.authorization.k8s.io"
-        resources: ["roles", "rolebindings", "clusterroles", "clusterrolebindings"]
-  - level: Request
-    resources:
-      - group: ""
-        resources: ["pods/exec", "pods/attach", "pods/portforward"]
-  - level: Metadata
-`
-
-// kubeadmConfigTemplate renders InitConfiguration + ClusterConfiguration used in
-// place of bare `kubeadm init` flags, so API server audit logging is wired in
-// cleanly via extraArgs/extraVolumes — kubeadm injects the flags, volume and mount
-// into the static pod manifest idempotently (a sed patch would be far more fragile
-// and could crashloop the API server). %s = advertise address, %s = pod subnet.
-//
-// audit-log-path is "-" (stdout): audit events become part of the kube-apiserver
-// container's stdout, so the existing (non-root, hardened) Alloy DaemonSet collects
-// them via the Kubernetes API — the same path as every other pod log — and ships
-// them to Loki. No file on disk, no privileged log shipper, no host mount for logs.
-// Only the read-only audit-policy volume is needed. (Rotation flags are file-only
-// and therefore omitted; the container runtime handles stdout log rotation.)
-const kubeadmConfigTemplate = `apiVersion: kubeadm.k8s.io/v1beta4
-kind: InitConfiguration
-localAPIEndpoint:
-  advertiseAddress: %s
-nodeRegistration:
-  criSocket: unix:///var/run/crio/crio.sock
-  name: controlplane
----
-apiVersion: kubeadm.k8s.io/v1beta4
-kind: ClusterConfiguration
-networking:
-  podSubnet: %s
-apiServer:
-  extraArgs:
-  - name: audit-policy-file
-    value: /etc/kubernetes/audit/policy.yaml
-  - name: audit-log-path
-    value: "-"
-  extraVolumes:
-  - name: audit-policy
-    hostPath: /etc/kubernetes/audit
-    mountPath: /etc/kubernetes/audit
-    readOnly: true
-    pathType: DirectoryOrCreate
-`
-
-// writeKubeadmConfig writes the API server audit policy and the kubeadm config to
-// the control-plane node, returning the config path for `kubeadm init --config`.
-// The policy must exist before the API server static pod starts, so it is written
-// first. No-op (returns the path) under dry-run.
-func (p *Provisioner) writeKubeadmConfig() (string, error) {
-	const (
-		auditDir   = "/etc/kubernetes/audit"
-		policyPath = auditDir + "/policy.yaml"
-		configPath = "/etc/kubernetes/kubeadm-config.yaml"
+// kubeadmConfig renders the InitConfiguration, ClusterConfiguration and
+// KubeletConfiguration kubeadm init creates the cluster with on the control
+// plane node named node: the managed settings, merged with config.yaml's
+// kubeadm section.
+func (p *Provisioner) kubeadmConfig(node string) (string, error) {
+	cfg := p.config
+	cluster := kubeadm.NewClusterConfiguration(cfg)
+	cluster.APIServer.ExtraArgs = append(cluster.APIServer.ExtraArgs, p.auditArgs()...)
+	cluster.APIServer.ExtraVolumes = append(cluster.APIServer.ExtraVolumes, auditVolume)
+	cluster.Merge(cfg.Kubeadm)
+	return kubeadm.Render(
+		kubeadm.NewInitConfiguration(cfg.Network.ControlPlaneIP, cfg.CRISocket(), node),
+		cluster,
+		kubeadm.NewKubeletConfiguration(cfg),
 	)
+}
 
-	if p.dryRun {
-		fmt.Println("[dry-run] would write kubeadm config + API server audit policy")
-		return configPath, nil
-	}
+// writeKubeadmConfig writes the API server audit files and the kubeadm config to
+// the control-plane node named node, returning the config path for `kubeadm init
+// --config`. The audit files must exist before the API server static pod starts,
+// so they are written first. Under dry-run, both are only rendered and checked.
+func (p *Provisioner) writeKubeadmConfig(node string) (string, error) {
+	const configPath = "/etc/kubernetes/kubeadm-config.yaml"
 
-	if _, err := p.exec.RunShell("mkdir -p " + auditDir); err != nil {
-		return "", fmt.Errorf("create audit policy directory: %w", err)
+	config, err := p.kubeadmConfig(node)
+	if err != nil {
+		return "", err
 	}
-	if err := executor.WriteFile(policyPath, auditPolicy); err != nil {
-		return "", fmt.Errorf("write audit policy: %w", err)
+	if err := p.writeAuditFiles(); err != nil {
+		return "", err
 	}
-	config := fmt.Sprintf(kubeadmConfigTemplate, p.config.Network.ControlPlaneIP, p.config.Cluster.PodCIDR)
-	if err := executor.WriteFile(configPath, config); err != nil {
+	if p.dryRun {
+		fmt.Println("[dry-run] would write kubeadm config")
+		return configPath, nil
+	}
+	if err := executor.WriteFileOn(p.exec, configPath, config); err != nil {
 		return "", fmt.Errorf("write kubeadm config: %w", err)
 	}
 	return configPath, nil
@@ -157,75 +130,117 @@ func (p *Provisioner) writeKubeadmConfig() (string, error) {
 // command for worker nodes. It does NOT install any workloads — call
 // InstallWorkloads after all workers have joined.
 func (p *Provisioner) InitCluster() error {
-	cfg := p.config
+	return p.runPhase("Initializing cluster", p.initCluster)
+}
+
+func (p *Provisioner) initCluster() error {
+	if err := p.preflight("Control plane preflight checks", p.controlPlaneChecks()); err != nil {
+		return err
+	}
+	cp := p.config.GetControlPlane()
+	if cp == nil {
+		return fmt.Errorf("no controlplane node in config")
+	}
+	ha := p.config.Network.ControlPlaneVIP != ""
 
-	configPath, err := p.writeKubeadmConfig()
+	configPath, err := p.writeKubeadmConfig(cp.Name)
 	if err != nil {
 		return fmt.Errorf("prepare kubeadm config: %w", err)
 	}
 
+	init := "kubeadm init --config=" + configPath
+	if ha {
+		// kube-vip must hold the VIP before kubeadm reaches the API server
+		// through it; until init grants admin.conf its RBAC, only
+		// super-admin.conf works.
+		fmt.Printf("\n>>> Starting kube-vip for the control plane VIP %s...\n", p.config.Network.ControlPlaneVIP)
+		if err := p.writeKubeVIP(superAdminConf); err != nil {
+			return err
+		}
+		// Upload the control plane certificates so other control planes can
+		// join; the manifests directory now holds kube-vip.
+		init += " --upload-certs --ignore-preflight-errors=DirAvailable--etc-kubernetes-manifests"
+	}
+
 	fmt.Println("\n>>> Initializing Kubernetes cluster (with API server audit logging)...")
-	if err := p.exec.RunShellWithOutput("kubeadm init --config=" + configPath); err != nil {
+	if err := p.exec.RunShellWithOutput(init); err != nil {
 		return err
 	}
 
-	fmt.Println("\n>>> Configuring kubectl...")
-	cmds := []string{
-		"mkdir -p /home/vagrant/.kube",
-		"cp /etc/kubernetes/admin.conf /home/vagrant/.kube/config",
-		"chown -R vagrant:vagrant /home/vagrant/.kube",
-		"mkdir -p /root/.kube",
-		"cp /etc/kubernetes/admin.conf /root/.kube/config",
-	}
-	for _, cmd := range cmds {
-		if _, err := p.exec.RunShell(cmd); err != nil {
-			return err
+	if ha {
+		if _, err := p.exec.RunShell(fmt.Sprintf("sed -i 's#%s#%s#' %s", superAdminConf, adminConf, kubeVIPManifest)); err != nil {
+			return fmt.Errorf("switch kube-vip to admin.conf: %w", err)
 		}
 	}
 
+	fmt.Println("\n>>> Configuring kubectl...")
+	if err := p.configureKubectl(); err != nil {
+		return err
+	}
+
 	fmt.Println("\n>>> Removing control-plane taint...")
-	_, _ = p.exec.RunShell("kubectl taint nodes controlplane node-role.kubernetes.io/control-plane:NoSchedule- 2>/dev/null || true")
+	p.removeControlPlaneTaint(cp.Name)
 
 	fmt.Println("\n>>> Patching CoreDNS upstream DNS...")
 	if err := p.patchCoreDNS(); err != nil {
 		fmt.Printf("Warning: CoreDNS patch failed: %v\n", err)
 	}
 
-	fmt.Println("\n>>> Installing Calico CNI...")
-	if p.dryRun {
-		fmt.Println("[dry-run] would install Calico CNI")
-	} else {
-		calicoInstaller := installer.NewCalico(cfg, p.exec)
-		if err := calicoInstaller.Install(); err != nil {
-			return err
-		}
+	cni := p.cni()
+	fmt.Printf("\n>>> Installing %s...\n", cni.Name())
+	if err := cni.Install(); err != nil {
+		return err
 	}
 
 	fmt.Println("\n>>> Waiting for node to be ready...")
-	if err := p.waitForNode("controlplane", nodeReadyTimeout); err != nil {
+	if err := p.waitForNode(cp.Name, nodeReadyTimeout); err != nil {
 		return err
 	}
 
-	fmt.Println("\n>>> Generating join command...")
-	if _, err := p.exec.RunShell("kubeadm token create --print-join-command > /vagrant/join-command.sh"); err != nil {
-		return err
+	fmt.Println("\n>>> Control plane ready. Nodes can now join the cluster: they fetch short-lived join credentials from it.")
+	return nil
+}
+
+// configureKubectl gives root and the vagrant user the cluster admin
+// kubeconfig.
+func (p *Provisioner) configureKubectl() error {
+	cmds := []string{
+		"mkdir -p /home/vagrant/.kube",
+		"cp /etc/kubernetes/admin.conf /home/vagrant/.kube/config",
+		"chown -R vagrant:vagrant /home/vagrant/.kube",
+		"mkdir -p /root/.kube",
+		"cp /etc/kubernetes/admin.conf /root/.kube/config",
 	}
-	if _, err := p.exec.RunShell("chmod +x /vagrant/join-command.sh"); err != nil {
-		return err
+	for _, cmd := range cmds {
+		if _, err := p.exec.RunShell(cmd); err != nil {
+			return err
+		}
 	}
-
-	fmt.Println("\n>>> Control plane ready. Workers can now join the cluster.")
 	return nil
 }
 
-// workloadStep declares one component in the install sequence. Ordering,
+// removeControlPlaneTaint lets workloads schedule on the control plane node,
+// as the lab has few nodes. Failures are ignored (already removed).
+func (p *Provisioner) removeControlPlaneTaint(node string) {
+	_, _ = p.exec.RunShell(fmt.Sprintf("kubectl taint nodes %s node-role.kubernetes.io/control-plane:NoSchedule- 2>/dev/null || true", node))
+}
+
+// workloadStep declares one component in the install plan. Dependencies,
 // enablement, and failure policy are data here instead of control flow, so the
-// sequence can be read, reordered, and unit-tested in one place.
+// plan can be read, reordered, and unit-tested in one place.
 type workloadStep struct {
+	// id is the stable identifier other steps name in deps.
+	id string
+	// deps lists the ids of steps that must finish before this one starts. A
+	// dependency that is disabled in config counts as finished.
+	deps []string
+	// exclusive steps run alone: nothing else starts until they finish (e.g.
+	// Keycloak, whose OIDC patch restarts the API server).
+	exclusive bool
 	// enabled gates the step; nil means always install.
 	enabled func(*config.Config) bool
 	// build constructs the installer for this step.
-	build func(*config.Config, executor.CommandExecutor) installer.Installer
+	build func(context.Context, *config.Config, executor.CommandExecutor) installer.Installer
 	// fatal: true aborts the run on failure; false logs a warning and continues.
 	fatal bool
 	// post runs after a successful (or warned) install, for side effects that
@@ -233,83 +248,113 @@ type workloadStep struct {
 	post func(*Provisioner) error
 }
 
-// workloadSteps is the ordered install plan executed by InstallWorkloads.
-// Dependency order: networking → mesh → certs → metrics/autoscaling → storage →
-// secrets → observability → identity → AI.
+// workloadSteps is the install plan executed by InstallWorkloads, listed in a
+// dependency order (networking → mesh → certs → metrics/autoscaling → storage →
+// secrets → observability → identity → AI) that is also the sequential order.
+// With SetParallel, steps whose deps have finished install concurrently.
 func (p *Provisioner) workloadSteps() []workloadStep {
 	enabledMonitoring := func(c *config.Config) bool { return c.Components.Monitoring == "prometheus-stack" }
 
 	return []workloadStep{
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewMetalLB(c, e)
+		{id: "metallb", build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewMetalLB(ctx, c, e)
 		}, fatal: true},
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewIstio(c, e)
+		// The ingress gateway is a LoadBalancer Service: it needs a MetalLB IP.
+		{id: "istio", deps: []string{"metallb"}, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewIstio(ctx, c, e)
 		}, fatal: true},
-		// cert-manager: TLS certificates for all *.local services.
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewCertManager(c, e)
+		// cert-manager: TLS certificates for all *.local services (lab-tls lives
+		// in istio-system).
+		{id: "cert-manager", deps: []string{"istio"}, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewCertManager(ctx, c, e)
 		}},
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewMetricsServer(c, e)
+		{id: "metrics-server", build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewMetricsServer(ctx, c, e)
 		}, fatal: true},
 		{
+			id:      "vpa",
+			deps:    []string{"metrics-server"},
 			enabled: func(c *config.Config) bool { return c.Components.VPA == "enabled" },
-			build:   func(c *config.Config, e executor.CommandExecutor) installer.Installer { return installer.NewVPA(c, e) },
+			build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+				return installer.NewVPA(ctx, c, e)
+			},
 		},
 		{
+			id:      "keda",
 			enabled: func(c *config.Config) bool { return c.Components.KEDA == "enabled" },
-			build:   func(c *config.Config, e executor.CommandExecutor) installer.Installer { return installer.NewKEDA(c, e) },
+			build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+				return installer.NewKEDA(ctx, c, e)
+			},
 		},
 		// NFS provisioner: provides nfs-dynamic and nfs-static StorageClasses.
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewNFSProvisioner(c, e)
+		{id: "nfs", build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewNFSProvisioner(ctx, c, e)
 		}, fatal: true},
 		// Vault runs on the storage node (secrets management).
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewVaultInstaller(c, e)
+		{id: "vault", build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewVaultInstaller(ctx, c, e)
 		}},
 		// VSO syncs Vault secrets into K8s Secrets before components start.
-		{build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewVaultSecretsOperator(c, e)
+		{id: "vso", deps: []string{"vault"}, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewVaultSecretsOperator(ctx, c, e)
 		}},
-		{enabled: enabledMonitoring, build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewMonitoring(c, e)
+		{id: "monitoring", deps: []string{"istio", "cert-manager", "nfs", "vso"}, enabled: enabledMonitoring, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewMonitoring(ctx, c, e)
+		}, fatal: true},
+		{id: "loki", deps: []string{"monitoring", "nfs"}, enabled: enabledMonitoring, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewLoki(ctx, c, e)
 		}, fatal: true},
-		{enabled: enabledMonitoring, build: func(c *config.Config, e executor.CommandExecutor) installer.Installer { return installer.NewLoki(c, e) }, fatal: true},
 		{
 			// Tracing requires the monitoring stack and otel-tempo enabled.
+			// After Loki, whose datasource ConfigMap would drop Tempo's.
+			id:      "tempo",
+			deps:    []string{"monitoring", "nfs", "loki"},
 			enabled: func(c *config.Config) bool { return enabledMonitoring(c) && c.Components.Tracing == "otel-tempo" },
-			build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-				return installer.NewTempo(c, e)
+			build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+				return installer.NewTempo(ctx, c, e)
 			},
 		},
 		// Kiali: service mesh observability — requires Prometheus.
-		{enabled: enabledMonitoring, build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-			return installer.NewKiali(c, e)
+		{id: "kiali", deps: []string{"monitoring", "istio", "cert-manager"}, enabled: enabledMonitoring, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewKiali(ctx, c, e)
+		}},
+		// Hubble UI: Cilium's network observability, behind the Istio gateway.
+		{id: "hubble", deps: []string{"istio", "cert-manager"}, enabled: func(c *config.Config) bool {
+			return c.CNI() == "cilium" && c.Components.Hubble == "enabled"
+		}, build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+			return installer.NewHubble(ctx, c, e)
 		}},
 		{
 			// Keycloak after monitoring so Grafana OAuth2 can be configured later.
-			enabled: func(c *config.Config) bool { return c.Components.Keycloak == "enabled" },
-			build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-				return installer.NewKeycloak(c, e)
+			// Exclusive: its OIDC patch restarts the API server under any
+			// concurrent install.
+			id:        "keycloak",
+			deps:      []string{"istio", "cert-manager", "nfs", "vso", "monitoring"},
+			exclusive: true,
+			enabled:   func(c *config.Config) bool { return c.Components.Keycloak == "enabled" },
+			build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+				return installer.NewKeycloak(ctx, c, e)
 			},
-			post: (*Provisioner).refreshCalicoAfterKeycloak,
+			post: (*Provisioner).refreshCNIAfterKeycloak,
 		},
 		{
 			// Ollama before Karpor when AI is enabled with the ollama backend.
+			id:   "ollama",
+			deps: []string{"nfs", "vso"},
 			enabled: func(c *config.Config) bool {
 				return c.Components.Karpor == "enabled" && c.KarporAI.Enabled && c.KarporAI.Backend == "ollama"
 			},
-			build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-				return installer.NewOllama(c, e)
+			build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+				return installer.NewOllama(ctx, c, e)
 			},
 			fatal: true,
 		},
 		{
+			id:      "karpor",
+			deps:    []string{"istio", "cert-manager", "nfs", "ollama"},
 			enabled: func(c *config.Config) bool { return c.Components.Karpor == "enabled" },
-			build: func(c *config.Config, e executor.CommandExecutor) installer.Installer {
-				return installer.NewKarpor(c, e)
+			build: func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+				return installer.NewKarpor(ctx, c, e)
 			},
 			fatal: true,
 		},
@@ -319,91 +364,84 @@ func (p *Provisioner) workloadSteps() []workloadStep {
 // InstallWorkloads installs all cluster workloads on an already-running cluster
 // where all worker nodes have joined. Called after InitCluster + JoinWorker.
 func (p *Provisioner) InstallWorkloads() error {
-	cfg := p.config
+	steps := p.workloadSteps()
+	if err := validatePlan(steps); err != nil {
+		return err
+	}
+	if err := p.checkSelection(steps); err != nil {
+		return err
+	}
 
 	if p.dryRun {
-		return p.printWorkloadPlan()
+		p.printWorkloadPlan()
 	}
 
-	// Keep the Keycloak installer to configure Grafana OAuth2 after every other
-	// component is up (Grafana must already exist).
-	var keycloak *installer.Keycloak
+	installed, err := p.runWorkloads(steps)
+	if err != nil {
+		return err
+	}
 
-	for _, step := range p.workloadSteps() {
-		if step.enabled != nil && !step.enabled(cfg) {
+	// Configure Grafana OAuth2 with Keycloak after all components are installed
+	// (Grafana must already exist).
+	for _, inst := range installed {
+		keycloak, ok := inst.(*installer.Keycloak)
+		if !ok || p.interrupted() {
 			continue
 		}
-
-		inst := step.build(cfg, p.exec)
-		fmt.Printf("\n>>> Installing %s...\n", inst.Name())
-		if err := inst.Install(); err != nil {
-			if step.fatal {
-				return fmt.Errorf("%s installation failed: %w", inst.Name(), err)
-			}
-			fmt.Printf("Warning: %s installation failed: %v\n", inst.Name(), err)
-		}
-
-		if kc, ok := inst.(*installer.Keycloak); ok {
-			keycloak = kc
-		}
-
-		if step.post != nil {
-			if err := step.post(p); err != nil {
-				if step.fatal {
-					return fmt.Errorf("%s post-install failed: %w", inst.Name(), err)
-				}
-				fmt.Printf("Warning: %s post-install failed: %v\n", inst.Name(), err)
-			}
-		}
-	}
-
-	// Configure Grafana OAuth2 with Keycloak after all components are installed.
-	if keycloak != nil {
 		fmt.Println("\n>>> Configuring Grafana OAuth2 with Keycloak...")
 		if err := keycloak.ConfigureGrafanaOAuth(); err != nil {
+			if p.interrupted() {
+				return p.reportInterrupted("Grafana OAuth2 configuration", err)
+			}
 			fmt.Printf("Warning: Grafana OAuth2 configuration failed: %v\n", err)
 		}
 	}
 
+	if p.dryRun {
+		fmt.Println("\n[dry-run] Workload install walked through without changes.")
+		return nil
+	}
 	p.printSuccess()
 	return nil
 }
 
 // printWorkloadPlan lists the components that would be installed (respecting
-// enablement) and their failure policy, without running any installer. Used by
-// the dry-run path, where executing installers would block on readiness waits.
-func (p *Provisioner) printWorkloadPlan() error {
+// enablement) and their failure policy. Printed ahead of a dry run, whose
+// installer output is otherwise long to scan.
+func (p *Provisioner) printWorkloadPlan() {
 	fmt.Println("\n[dry-run] Workload install plan:")
-	for _, step := range p.workloadSteps() {
-		if step.enabled != nil && !step.enabled(p.config) {
+	steps := p.workloadSteps()
+	enabled := p.enabledSteps(steps)
+	for _, step := range steps {
+		if !enabled[step.id] {
 			continue
 		}
 		policy := "warn-on-failure"
 		if step.fatal {
 			policy = "fatal-on-failure"
 		}
-		fmt.Printf("  - %s (%s)\n", step.build(p.config, p.exec).Name(), policy)
+		if deps := enabledDeps(step, enabled); len(deps) > 0 {
+			policy += ", after " + strings.Join(deps, ", ")
+		}
+		fmt.Printf("  - %s (%s)\n", step.build(p.ctx, p.config, p.exec).Name(), policy)
 	}
-	if p.config.Components.Keycloak == "enabled" {
+	if enabled["keycloak"] {
 		fmt.Println("  - (post) configure Grafana OAuth2 with Keycloak")
 	}
-	return nil
 }
 
-// refreshCalicoAfterKeycloak restarts calico-node after Keycloak installs the
-// AuthenticationConfiguration that restarts the API server. That restart
-// invalidates the CNI kubeconfig token calico-node wrote at install time, so
-// each node needs a fresh token before workers join. Failures are non-fatal.
-func (p *Provisioner) refreshCalicoAfterKeycloak() error {
-	fmt.Println("\n>>> Refreshing Calico CNI kubeconfig after API server restart...")
-	if _, err := p.exec.RunShell("kubectl rollout restart daemonset/calico-node -n calico-system"); err != nil {
-		fmt.Printf("Warning: calico-node restart failed: %v\n", err)
-		return nil
-	}
-	if _, err := p.exec.RunShell("kubectl rollout status daemonset/calico-node -n calico-system --timeout=3m"); err != nil {
-		fmt.Printf("Warning: calico-node rollout status: %v\n", err)
-	}
-	return nil
+// cni returns the installer for components.cni.
+func (p *Provisioner) cni() installer.CNI {
+	return p.buildStep(func(ctx context.Context, c *config.Config, e executor.CommandExecutor) installer.Installer {
+		return installer.NewCNI(ctx, c, e)
+	}).(installer.CNI)
+}
+
+// refreshCNIAfterKeycloak lets the cluster network recover from the API
+// server restart the Keycloak AuthenticationConfiguration causes, before
+// workers join (see installer.CNI).
+func (p *Provisioner) refreshCNIAfterKeycloak() error {
+	return p.cni().AfterAPIServerRestart()
 }
 
 // InitControlPlane is kept for backward compatibility and runs InitCluster + InstallWorkloads.
@@ -414,36 +452,37 @@ func (p *Provisioner) InitControlPlane() error {
 	return p.InstallWorkloads()
 }
 
+// JoinWorker joins the node to the cluster as a worker, with short-lived
+// credentials issued on the control plane (see joinCredentials).
 func (p *Provisioner) JoinWorker() error {
-	cfg := p.config
+	return p.runPhase("Joining cluster", p.joinWorker)
+}
 
-	// Wait for join command file or API server
-	fmt.Println("\n>>> Waiting for control plane...")
-	if err := p.waitForAPIServer(cfg.Network.ControlPlaneIP, apiServerReadyTimeout); err != nil {
+func (p *Provisioner) joinWorker() error {
+	cfg := p.config
+	node, hostname, err := p.thisNode()
+	if err != nil {
 		return err
 	}
-
-	// Try to use join command file first
-	if executor.FileExists("/vagrant/join-command.sh") {
-		fmt.Println("\n>>> Using join command from shared file...")
-		return p.exec.RunShellWithOutput("bash /vagrant/join-command.sh")
+	name := hostname
+	if node != nil {
+		if node.Role == "controlplane" {
+			return fmt.Errorf("%s is a controlplane node: run provision controlplane-join on it", node.Name)
+		}
+		name = node.Name
 	}
 
-	// Fallback: get join command via SSH
-	fmt.Println("\n>>> Getting join command via SSH...")
-	if _, err := p.exec.RunShell("apt-get install -y sshpass"); err != nil {
+	fmt.Println("\n>>> Waiting for control plane...")
+	if err := p.waitForAPIServer(cfg.ControlPlaneEndpoint(), apiServerReadyTimeout); err != nil {
 		return err
 	}
 
-	joinCmd := fmt.Sprintf("sshpass -p 'vagrant' ssh -o StrictHostKeyChecking=no vagrant@%s 'sudo kubeadm token create --print-join-command'",
-		cfg.Network.ControlPlaneIP)
-
-	out, err := p.exec.RunShell(joinCmd)
+	creds, err := p.joinCredentials(name, false)
 	if err != nil {
 		return err
 	}
-
-	return p.exec.RunShellWithOutput(out)
+	fmt.Println("\n>>> Joining the cluster...")
+	return p.kubeadmJoin(creds, name, "")
 }
 
 func (p *Provisioner) waitForNode(name string, timeout time.Duration) error {
@@ -451,15 +490,36 @@ func (p *Provisioner) waitForNode(name string, timeout time.Duration) error {
 		fmt.Printf("[dry-run] skip waiting for node %s\n", name)
 		return nil
 	}
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		out, err := p.exec.RunShell(fmt.Sprintf("kubectl get node %s -o jsonpath='{.status.conditions[?(@.type==\"Ready\")].status}'", name))
-		if err == nil && out == "True" {
-			return nil
-		}
-		time.Sleep(defaultPollInterval)
+	kube := p.kubectl()
+	return backoff.WaitFor(p.ctx, fmt.Sprintf("node %s to be Ready", name), backoff.PollUntil(timeout, defaultPollInterval), func() bool {
+		out, err := kube.RunShell(fmt.Sprintf("kubectl get node %s -o jsonpath='{.status.conditions[?(@.type==\"Ready\")].status}'", name))
+		return err == nil && out == "True"
+	})
+}
+
+// thisNode returns the nodes: entry of the host p provisions, looked up by its
+// hostname (nil when it is not listed), and the hostname.
+func (p *Provisioner) thisNode() (*config.NodeConfig, string, error) {
+	if p.dryRun {
+		// The dry-run executor answers nothing: preview as the local host.
+		hostname, err := os.Hostname()
+		return p.config.GetNode(hostname), hostname, err
 	}
-	return fmt.Errorf("timeout waiting for node %s", name)
+	out, err := p.exec.RunShell("hostname")
+	if err != nil {
+		return nil, "", fmt.Errorf("read hostname: %w", err)
+	}
+	hostname := strings.TrimSpace(out)
+	return p.config.GetNode(hostname), hostname, nil
+}
+
+// kubectl returns the executor kubectl runs on: the control plane when
+// provisioning another node remotely, otherwise the node itself.
+func (p *Provisioner) kubectl() executor.CommandExecutor {
+	if p.controlPlane != nil {
+		return p.controlPlane
+	}
+	return p.exec
 }
 
 func (p *Provisioner) waitForAPIServer(ip string, timeout time.Duration) error {
@@ -467,22 +527,54 @@ func (p *Provisioner) waitForAPIServer(ip string, timeout time.Duration) error {
 		fmt.Printf("[dry-run] skip waiting for API server at %s:6443\n", ip)
 		return nil
 	}
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	return backoff.WaitFor(p.ctx, fmt.Sprintf("API server at %s:6443", ip), backoff.PollUntil(timeout, defaultPollInterval), func() bool {
 		_, err := p.exec.RunShell(fmt.Sprintf("nc -z %s 6443", ip))
-		if err == nil {
-			return nil
+		return err == nil
+	})
+}
+
+// inStep runs fn with every command it issues tagged with step in the audit
+// log (see executor.WithStep).
+func (p *Provisioner) inStep(step string, fn func() error) error {
+	exec := p.exec
+	p.exec = executor.WithContext(executor.WithStep(p.ctx, step), p.base)
+	defer func() { p.exec = exec }()
+	return fn()
+}
+
+// runPhase runs fn as step, reporting an interruption the way InstallCommon and
+// InstallWorkloads do: kubeadm init/join cut short are the likeliest to leave a
+// half-configured node behind.
+func (p *Provisioner) runPhase(step string, fn func() error) error {
+	if err := p.inStep(step, fn); err != nil {
+		if p.interrupted() {
+			return p.reportInterrupted(step, err)
 		}
-		fmt.Printf("Waiting for API server at %s:6443...\n", ip)
-		time.Sleep(defaultPollInterval)
+		return err
 	}
-	return fmt.Errorf("timeout waiting for API server at %s:6443", ip)
+	return nil
+}
+
+// buildStep constructs an installer with its commands tagged by component name
+// in the audit log and dry-run script. Constructors only capture their
+// arguments, so the first build just reads the name.
+func (p *Provisioner) buildStep(build func(context.Context, *config.Config, executor.CommandExecutor) installer.Installer) installer.Installer {
+	name := build(p.ctx, p.config, p.exec).Name()
+	return build(executor.WithStep(p.ctx, name), p.config, p.exec)
+}
+
+// interrupted reports whether the run was cancelled (Ctrl-C or SIGTERM).
+// Errors seen after that point are a consequence of the abort, not a
+// component failure.
+func (p *Provisioner) interrupted() bool {
+	return p.ctx.Err() != nil
 }
 
-func (p *Provisioner) patchCoreDNS() error {
-	patch := `'[{"op":"replace","path":"/data/Corefile","value":".:53 {\n    errors\n    health {\n       lameduck 5s\n    }\n    ready\n    kubernetes cluster.local in-addr.arpa ip6.arpa {\n       pods insecure\n       fallthrough in-addr.arpa ip6.arpa\n       ttl 30\n    }\n    prometheus :9153\n    forward . 8.8.8.8 1.1.1.1\n    cache 30\n    loop\n    reload\n    loadbalance\n}\n"}]'`
-	_, err := p.exec.RunShell(fmt.Sprintf("kubectl patch configmap coredns -n kube-system --type=json -p %s", patch))
-	return err
+// reportInterrupted prints which step was cut short and returns an error that
+// still matches context.Canceled, so the CLI can exit with the interrupt code.
+func (p *Provisioner) reportInterrupted(step string, err error) error {
+	fmt.Printf("\n✗ %s interrupted — the node may be partially configured; re-run the same command to continue\n", step)
+	return fmt.Errorf("%s interrupted: %w", step, context.Cause(p.ctx))
 }
 
 func (p *Provisioner) printSuccess() {
@@ -494,7 +586,7 @@ func (p *Provisioner) printSuccess() {
 	fmt.Println("\n  1. Copy kubeconfig:")
 	fmt.Printf("     vagrant ssh controlplane -c 'sudo cat /etc/kubernetes/admin.conf' > ~/.kube/config-lab\n")
 	fmt.Println("\n  2. Adjust server IP:")
-	fmt.Printf("     sed -i '' 's/127.0.0.1/%s/' ~/.kube/config-lab\n", cfg.Network.ControlPlaneIP)
+	fmt.Printf("     sed -i '' 's/127.0.0.1/%s/' ~/.kube/config-lab\n", cfg.ControlPlaneEndpoint())
 	fmt.Println("\n  3. Use the config:")
 	fmt.Println("     export KUBECONFIG=~/.kube/config-lab")
 	fmt.Println("\n  4. Test:")
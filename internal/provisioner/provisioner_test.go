@@ -1,19 +1,25 @@
 package provisioner
 
 import (
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+	"github.com/techiescamp/k8s-provisioner/internal/join"
 )
 
 // mockExecutor records shell invocations so orchestration can be asserted
 // without touching the host. It satisfies executor.CommandExecutor.
 type mockExecutor struct {
 	shellCmds []string
+	out       string // returned by RunShell
 }
 
 func (m *mockExecutor) Run(name string, args ...string) (string, error) { return "", nil }
@@ -28,17 +34,19 @@ func (m *mockExecutor) RunShellWithStdin(c, s string) (string, error) {
 }
 func (m *mockExecutor) RunShell(command string) (string, error) {
 	m.shellCmds = append(m.shellCmds, command)
-	return "", nil
+	return m.out, nil
 }
 
 // planNames returns the ordered names of the steps that would run for cfg.
 func planNames(p *Provisioner) []string {
 	var names []string
-	for _, step := range p.workloadSteps() {
-		if step.enabled != nil && !step.enabled(p.config) {
+	steps := p.workloadSteps()
+	enabled := p.enabledSteps(steps)
+	for _, step := range steps {
+		if !enabled[step.id] {
 			continue
 		}
-		names = append(names, step.build(p.config, p.exec).Name())
+		names = append(names, step.build(p.ctx, p.config, p.exec).Name())
 	}
 	return names
 }
@@ -54,7 +62,7 @@ func TestWorkloadPlan_AllEnabled(t *testing.T) {
 	cfg.KarporAI.Enabled = true
 	cfg.KarporAI.Backend = "ollama"
 
-	p := NewWithExecutor(cfg, &mockExecutor{}, false)
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
 
 	want := []string{
 		"MetalLB",
@@ -81,7 +89,7 @@ func TestWorkloadPlan_AllEnabled(t *testing.T) {
 func TestWorkloadPlan_Minimal(t *testing.T) {
 	// Everything optional disabled; only the always-on core should run.
 	cfg := &config.Config{}
-	p := NewWithExecutor(cfg, &mockExecutor{}, false)
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
 
 	want := []string{
 		"MetalLB",
@@ -96,11 +104,21 @@ func TestWorkloadPlan_Minimal(t *testing.T) {
 	require.Equal(t, want, planNames(p))
 }
 
+func TestWorkloadPlan_HubbleRequiresCilium(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Components.Hubble = "enabled"
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
+	assert.NotContains(t, planNames(p), "Hubble UI", "Hubble is part of Cilium")
+
+	cfg.Components.CNI = "cilium"
+	assert.Contains(t, planNames(p), "Hubble UI")
+}
+
 func TestWorkloadPlan_TracingRequiresMonitoring(t *testing.T) {
 	// Tracing enabled but monitoring off → Tempo must NOT be planned.
 	cfg := &config.Config{}
 	cfg.Components.Tracing = "otel-tempo"
-	p := NewWithExecutor(cfg, &mockExecutor{}, false)
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
 
 	for _, name := range planNames(p) {
 		require.False(t, strings.HasPrefix(name, "Tracing Stack"),
@@ -114,7 +132,7 @@ func TestWorkloadPlan_FatalPolicy(t *testing.T) {
 	cfg.Components.Tracing = "otel-tempo"
 	cfg.Components.Keycloak = "enabled"
 	cfg.Components.Karpor = "enabled"
-	p := NewWithExecutor(cfg, &mockExecutor{}, false)
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
 
 	// Components whose failure must abort the whole run.
 	wantFatal := map[string]bool{
@@ -131,26 +149,67 @@ func TestWorkloadPlan_FatalPolicy(t *testing.T) {
 		if step.enabled != nil && !step.enabled(cfg) {
 			continue
 		}
-		name := step.build(cfg, p.exec).Name()
+		name := step.build(p.ctx, cfg, p.exec).Name()
 		assert.Equal(t, wantFatal[name], step.fatal, "%s fatal flag", name)
 	}
 }
 
-func TestInstallWorkloads_DryRunPrintsPlanWithoutExecuting(t *testing.T) {
+func TestInstallWorkloads_DryRunRecordsScriptWithoutExecuting(t *testing.T) {
 	cfg := &config.Config{}
 	cfg.Components.Monitoring = "prometheus-stack"
 	cfg.Components.Keycloak = "enabled"
 
-	mock := &mockExecutor{}
-	p := NewWithExecutor(cfg, mock, false)
-	p.dryRun = true
+	script := executor.NewScript()
+	p := NewDryRun(context.Background(), cfg, false, script)
+
+	require.NoError(t, p.InstallWorkloads(), "dry-run InstallWorkloads should walk every installer")
+
+	steps := script.Steps()
+	require.NotEmpty(t, steps)
+	assert.Equal(t, "MetalLB", steps[0], "steps are grouped in run order")
+	assert.Contains(t, script.Render("MetalLB"), "metallb-native.yaml")
+	assert.Contains(t, script.Render("Istio"), "istioctl install")
+	assert.Contains(t, steps, "Keycloak (OIDC)")
+}
+
+// TestInstallWorkloads_DryRunCompletesEveryInstaller runs each installer's
+// Install under the dry-run executor: every wait must be answered by
+// installer.DryRunResponses, or the run would fail or block on a poll.
+func TestInstallWorkloads_DryRunCompletesEveryInstaller(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Components.Monitoring = "prometheus-stack"
+	cfg.Components.Logging = "loki"
+	cfg.Components.Tracing = "otel-tempo"
+	cfg.Components.VPA = "enabled"
+	cfg.Components.KEDA = "enabled"
+	cfg.Components.Keycloak = "enabled"
+	cfg.Components.Karpor = "enabled"
+	cfg.KarporAI.Enabled = true
+	cfg.KarporAI.Backend = "ollama"
+
+	script := executor.NewScript()
+	p := NewDryRun(context.Background(), cfg, false, script)
+	p.SetParallel(4) // also exercises the installers concurrently (go test -race)
+
+	done := make(chan error, 1)
+	go func() { done <- p.InstallWorkloads() }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("dry-run InstallWorkloads blocked on a readiness wait")
+	}
 
-	require.NoError(t, p.InstallWorkloads(), "dry-run InstallWorkloads should not error")
-	require.Empty(t, mock.shellCmds, "dry-run must not execute a single shell command")
+	for _, name := range planNames(p) {
+		if name == "Vault (secrets management)" {
+			continue // bootstraps over the Vault HTTP API, which a dry run skips
+		}
+		assert.Contains(t, script.Steps(), name, "%s issued no command", name)
+	}
 }
 
 func TestWriteFile_DryRunSkips(t *testing.T) {
-	p := NewWithExecutor(&config.Config{}, &mockExecutor{}, false)
+	p := NewWithExecutor(context.Background(), &config.Config{}, &mockExecutor{}, false)
 	p.dryRun = true
 
 	// A path that would fail if actually written (no such directory).
@@ -158,14 +217,14 @@ func TestWriteFile_DryRunSkips(t *testing.T) {
 		"dry-run writeFile should be a no-op")
 }
 
-// TestRefreshCalicoAfterKeycloak verifies the injected executor is actually used
+// TestRefreshCNIAfterKeycloak verifies the injected executor is actually used
 // — the payoff of NewWithExecutor. The Keycloak post-hook must restart and then
 // poll the calico-node daemonset.
-func TestRefreshCalicoAfterKeycloak(t *testing.T) {
+func TestRefreshCNIAfterKeycloak(t *testing.T) {
 	mock := &mockExecutor{}
-	p := NewWithExecutor(&config.Config{}, mock, false)
+	p := NewWithExecutor(context.Background(), &config.Config{}, mock, false)
 
-	require.NoError(t, p.refreshCalicoAfterKeycloak())
+	require.NoError(t, p.refreshCNIAfterKeycloak())
 
 	// Assert the meaningful commands are present and ordered (restart then
 	// status poll) rather than an exact total count, so a benign extra shell
@@ -174,3 +233,153 @@ func TestRefreshCalicoAfterKeycloak(t *testing.T) {
 	assert.Contains(t, mock.shellCmds[0], "rollout restart daemonset/calico-node")
 	assert.Contains(t, mock.shellCmds[len(mock.shellCmds)-1], "rollout status daemonset/calico-node")
 }
+
+func TestRefreshCNIAfterKeycloak_NothingToRefreshForFlannel(t *testing.T) {
+	mock := &mockExecutor{}
+	cfg := &config.Config{Components: config.ComponentsConfig{CNI: "flannel"}}
+	p := NewWithExecutor(context.Background(), cfg, mock, false)
+
+	require.NoError(t, p.refreshCNIAfterKeycloak())
+	assert.Empty(t, mock.shellCmds)
+}
+
+func TestInstallWorkloads_InterruptedAbortsEvenNonFatalSteps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mock := &mockExecutor{}
+	p := NewWithExecutor(ctx, &config.Config{}, mock, false)
+
+	err := p.InstallWorkloads()
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, mock.shellCmds, "no command may run after the context is cancelled")
+}
+
+func TestInitCluster_InterruptedReportsStep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mock := &mockExecutor{}
+	p := NewWithExecutor(ctx, &config.Config{}, mock, false)
+
+	err := p.InitCluster()
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "Initializing cluster interrupted")
+	assert.Empty(t, mock.shellCmds, "no command may run after the context is cancelled")
+}
+
+func TestSSHOptions_KeyPreferredOverPassword(t *testing.T) {
+	cfg := &config.Config{}
+	opts := sshOptions(cfg)
+	assert.Equal(t, "vagrant", opts.User)
+	assert.Equal(t, "vagrant", opts.Password, "default Vagrant credential when nothing is configured")
+
+	cfg.Provisioning = config.ProvisioningConfig{
+		SSHUser: "ops", SSHPassword: "secret", SSHKeyPath: "/keys/id_ed25519", SSHPort: 2222,
+	}
+	opts = sshOptions(cfg)
+	assert.Equal(t, "ops", opts.User)
+	assert.Equal(t, "/keys/id_ed25519", opts.KeyPath)
+	assert.Empty(t, opts.Password, "password is ignored when a key is set")
+	assert.Equal(t, 2222, opts.Port)
+}
+
+// TestJoinWorker_RemoteIssuesCredentialsOnControlPlane verifies remote workers
+// get a fresh token and the CA hash from the control plane executor and join
+// from a JoinConfiguration on the worker — no /vagrant share, no sshpass, and
+// no token on a command line.
+func TestJoinWorker_RemoteIssuesCredentialsOnControlPlane(t *testing.T) {
+	ca, hash := testCA(t)
+	worker := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"hostname": "node01"}}}
+	cp := &answeringExecutor{answers: map[string]string{
+		"token create":  "abcdef.0123456789abcdef\n",
+		join.CACertPath: ca,
+	}}
+	cfg := haConfig()
+	cfg.Network.ControlPlaneVIP = ""
+	p := NewWithExecutor(context.Background(), cfg, worker, false)
+	p.remote = true
+	p.controlPlane = cp
+
+	require.NoError(t, p.JoinWorker())
+
+	assert.Equal(t, []string{"kubeadm token create --ttl 15m0s --description 'k8s-provisioner node join'", "cat " + join.CACertPath}, cp.shellCmds)
+	assert.Equal(t, []string{
+		"install -D -m 600 /dev/null " + join.ConfigPath,
+		"kubeadm join --config " + join.ConfigPath,
+		"rm -f " + join.ConfigPath,
+	}, worker.shellCmds[len(worker.shellCmds)-3:])
+	rendered := worker.files[join.ConfigPath]
+	assert.Contains(t, rendered, "apiServerEndpoint: 192.168.56.10:6443\n")
+	assert.Contains(t, rendered, "token: abcdef.0123456789abcdef\n")
+	assert.Contains(t, rendered, "- "+hash+"\n")
+	assert.Contains(t, rendered, "  name: node01\n")
+	assert.NotContains(t, rendered, "controlPlane:")
+	for _, c := range worker.shellCmds {
+		assert.NotContains(t, c, "sshpass")
+		assert.NotContains(t, c, "abcdef.0123456789abcdef")
+	}
+}
+
+func TestWorkloadPlan_Selection(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Components.Monitoring = "prometheus-stack"
+	p := NewWithExecutor(context.Background(), cfg, &mockExecutor{}, false)
+	p.SetSelection([]string{"loki", "vpa"}, nil)
+
+	assert.Equal(t, []string{"Loki Stack"}, planNames(p), "vpa stays disabled by the config")
+}
+
+func TestUninstall_RefusesWhileEnabledDependentsRemain(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Components.Monitoring = "prometheus-stack"
+	m := &mockExecutor{}
+	p := NewWithExecutor(context.Background(), cfg, m, false)
+
+	err := p.Uninstall("monitoring")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "enabled components depend on it (loki, kiali)")
+	assert.Empty(t, m.shellCmds, "nothing runs when the check fails")
+
+	err = p.Uninstall("grafana")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown workload "grafana"`)
+}
+
+func TestUninstall_DisabledDependentsDoNotBlock(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Components.Karpor = "enabled"
+	cfg.Checksums = map[string]string{"https://github.com/kubernetes-sigs/metrics-server/releases/download/v0.7.2/components.yaml": testSum}
+	m := &mockExecutor{}
+	p := NewWithExecutor(context.Background(), cfg, m, false)
+
+	require.NoError(t, p.Uninstall("metrics-server"), "vpa, its only dependent, is disabled")
+	assert.Contains(t, m.shellCmds, "kubectl delete -f /var/cache/k8s-provisioner/"+testSum+"/metrics-server.yaml --ignore-not-found")
+
+	err := p.Uninstall("nfs")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "(karpor)")
+}
+
+// TestDoctor_ReportsEachComponent runs real Verify methods against canned
+// answers: KEDA healthy, Vault sealed.
+func TestDoctor_ReportsEachComponent(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Components.KEDA = "enabled"
+	cfg.Vault.Addr = "http://10.0.0.5:8200"
+	exec := executor.DryRunExecutor{Responses: []executor.DryRunResponse{
+		{Match: "{.status.readyReplicas}", Output: "1"},
+		{Match: `@.type=="Available")].status}`, Output: "True"},
+		{Match: "/v1/sys/health", Output: "503"},
+	}}
+	// The dry-run context skips the installers' retry pauses.
+	p := NewWithExecutor(installer.WithDryRun(context.Background()), cfg, exec, false)
+
+	err := p.Doctor("keda", "vault")
+	require.Error(t, err)
+	assert.Equal(t, "1 of 2 components failed their checks: vault", err.Error())
+
+	assert.ErrorContains(t, p.Doctor("kafka"), `unknown workload "kafka"`)
+}
@@ -0,0 +1,197 @@
+package provisioner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/redact"
+)
+
+// Files the registries section is rendered into. CRI-O reads every drop-in
+// of registries.conf.d, and certs.d/<host>/ca.crt when it talks TLS to host.
+const (
+	registriesConf  = "/etc/containers/registries.conf.d/10-k8s-provisioner.conf"
+	registryAuth    = "/etc/containers/auth.json"
+	crioAuthDropIn  = "/etc/crio/crio.conf.d/10-k8s-provisioner-auth.conf"
+	registryCertDir = "/etc/containers/certs.d"
+	// registryCAList names the registries whose certs.d CA was written, so a
+	// CA dropped from the section is removed too.
+	registryCAList = "/etc/k8s-provisioner/registry-cas"
+)
+
+// registriesTemplate is the registries.conf (v2) drop-in: one [[registry]]
+// per registry with mirrors or insecure set, its mirrors in order.
+var registriesTemplate = template.Must(template.New("registries.conf").Parse(`# Generated by k8s-provisioner from the registries section of config.yaml.
+{{- range . }}
+
+[[registry]]
+prefix = "{{ .Registry }}"
+location = "{{ .Registry }}"
+{{- if .Insecure }}
+insecure = true
+{{- end }}
+{{- range .Mirrors }}
+
+[[registry.mirror]]
+location = "{{ .Location }}"
+{{- if .Insecure }}
+insecure = true
+{{- end }}
+{{- end }}
+{{- end }}
+`))
+
+// crioAuthConf makes CRI-O log in with registryAuth for every pull.
+const crioAuthConf = `[crio.image]
+global_auth_file = "` + registryAuth + `"
+`
+
+type registryMirror struct {
+	Location string
+	Insecure bool
+}
+
+type registryEntry struct {
+	Registry string
+	Insecure bool
+	Mirrors  []registryMirror
+}
+
+// managedFile is a file the registries section owns on the node. An empty
+// content means the file must not exist.
+type managedFile struct {
+	path    string
+	content string
+	mode    string // octal, e.g. 600; empty = umask default
+}
+
+// registryFiles renders the registries section into the files CRI-O reads.
+func (p *Provisioner) registryFiles() ([]managedFile, error) {
+	registries := p.config.Registries
+	byHost := map[string]config.RegistryConfig{}
+	for _, r := range registries {
+		byHost[r.Registry] = r
+	}
+
+	var entries []registryEntry
+	auths := map[string]map[string]string{}
+	var files []managedFile
+	var caHosts []string
+	for _, r := range registries {
+		if r.Insecure || len(r.Mirrors) > 0 {
+			entry := registryEntry{Registry: r.Registry, Insecure: r.Insecure}
+			for _, m := range r.Mirrors {
+				host, _, _ := strings.Cut(m, "/")
+				entry.Mirrors = append(entry.Mirrors, registryMirror{Location: m, Insecure: byHost[host].Insecure})
+			}
+			entries = append(entries, entry)
+		}
+		if r.Username != "" {
+			token := base64.StdEncoding.EncodeToString([]byte(r.Username + ":" + r.Password))
+			redact.Add(token)
+			auths[r.Registry] = map[string]string{"auth": token}
+		}
+		if r.CAFile != "" {
+			ca, err := os.ReadFile(r.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("registries %s: ca_file: %w", r.Registry, err)
+			}
+			files = append(files, managedFile{path: registryCACert(r.Registry), content: string(ca)})
+			caHosts = append(caHosts, r.Registry)
+		}
+	}
+	// CAs written by an earlier run for registries that no longer have one.
+	previous, _ := p.exec.RunShell("cat " + registryCAList + " 2>/dev/null")
+	for _, host := range strings.Fields(previous) {
+		if !slices.Contains(caHosts, host) {
+			files = append(files, managedFile{path: registryCACert(host)})
+		}
+	}
+	caList := ""
+	if len(caHosts) > 0 {
+		caList = strings.Join(caHosts, "\n") + "\n"
+	}
+
+	conf := ""
+	if len(entries) > 0 {
+		var b strings.Builder
+		if err := registriesTemplate.Execute(&b, entries); err != nil {
+			return nil, fmt.Errorf("render registries.conf: %w", err)
+		}
+		conf = b.String()
+	}
+	auth, authConf := "", ""
+	if len(auths) > 0 {
+		data, err := json.MarshalIndent(map[string]any{"auths": auths}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		auth, authConf = string(data)+"\n", crioAuthConf
+	}
+	files = append([]managedFile{
+		{path: registriesConf, content: conf},
+		{path: registryAuth, content: auth, mode: "600"},
+		{path: crioAuthDropIn, content: authConf},
+	}, files...)
+	// Last, so a CA whose removal failed stays listed.
+	return append(files, managedFile{path: registryCAList, content: caList}), nil
+}
+
+// registryCACert is the CA CRI-O trusts for host.
+func registryCACert(host string) string {
+	return registryCertDir + "/" + host + "/ca.crt"
+}
+
+// configureRegistries brings the node's registry configuration in line with
+// the registries section, and has a running CRI-O pick up what changed: a
+// reload re-reads registries.conf, certificates and the auth file are read
+// on every pull, and a new or removed auth drop-in needs a restart.
+func (p *Provisioner) configureRegistries() error {
+	files, err := p.registryFiles()
+	if err != nil {
+		return err
+	}
+	changed, restart := false, false
+	for _, f := range files {
+		current, _ := p.exec.RunShell("cat " + f.path + " 2>/dev/null")
+		if current == f.content {
+			continue
+		}
+		changed = true
+		restart = restart || f.path == crioAuthDropIn
+		if f.content == "" {
+			if _, err := p.exec.RunShell("rm -f " + f.path); err != nil {
+				return err
+			}
+			continue
+		}
+		prepare := "mkdir -p " + path.Dir(f.path)
+		if f.mode != "" {
+			// Restrict the file before the content lands in it.
+			prepare += fmt.Sprintf(" && install -m %s /dev/null %s", f.mode, f.path)
+		}
+		if _, err := p.exec.RunShell(prepare); err != nil {
+			return err
+		}
+		if err := p.writeFile(f.path, f.content); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case restart:
+		fmt.Println("Registry credentials changed, restarting CRI-O")
+		_, err = p.exec.RunShell("systemctl restart crio")
+	case changed:
+		fmt.Println("Registry configuration changed, reloading CRI-O")
+		_, err = p.exec.RunShell("systemctl reload crio")
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package provisioner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+const testRegistriesConf = `# Generated by k8s-provisioner from the registries section of config.yaml.
+
+[[registry]]
+prefix = "docker.io"
+location = "docker.io"
+
+[[registry.mirror]]
+location = "harbor.lab:5000/dockerhub"
+insecure = true
+
+[[registry.mirror]]
+location = "mirror.gcr.io"
+
+[[registry]]
+prefix = "harbor.lab:5000"
+location = "harbor.lab:5000"
+insecure = true
+`
+
+func registriesConfig(t *testing.T) *config.Config {
+	ca := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(ca, []byte("-----BEGIN CERTIFICATE-----\nLAB\n-----END CERTIFICATE-----\n"), 0o644))
+	cfg := &config.Config{}
+	cfg.Registries = []config.RegistryConfig{
+		{Registry: "docker.io", Mirrors: []string{"harbor.lab:5000/dockerhub", "mirror.gcr.io"}},
+		{Registry: "harbor.lab:5000", Insecure: true, Username: "robot", Password: "s3cret"},
+		{Registry: "registry.corp.example.com", CAFile: ca},
+	}
+	return cfg
+}
+
+// TestConfigureRegistries_RendersMirrorsTrustAndAuth verifies a fresh node
+// gets the registries.conf drop-in, the CA, the auth file (private before it
+// is written) and the CRI-O drop-in using it, and that CRI-O is restarted to
+// read the latter.
+func TestConfigureRegistries_RendersMirrorsTrustAndAuth(t *testing.T) {
+	node := &fileExecutor{}
+	p := NewWithExecutor(context.Background(), registriesConfig(t), node, false)
+
+	require.NoError(t, p.configureRegistries())
+
+	assert.Equal(t, testRegistriesConf, node.files[registriesConf])
+	assert.JSONEq(t, `{"auths": {"harbor.lab:5000": {"auth": "cm9ib3Q6czNjcmV0"}}}`, node.files[registryAuth])
+	assert.Contains(t, node.shellCmds, "mkdir -p /etc/containers && install -m 600 /dev/null /etc/containers/auth.json")
+	assert.Equal(t, "[crio.image]\nglobal_auth_file = \"/etc/containers/auth.json\"\n", node.files[crioAuthDropIn])
+	assert.Contains(t, node.files["/etc/containers/certs.d/registry.corp.example.com/ca.crt"], "LAB")
+	assert.Equal(t, "systemctl restart crio", node.shellCmds[len(node.shellCmds)-1])
+}
+
+// TestConfigureRegistries_OnlyReloadsOnChange verifies CRI-O is left alone
+// when the node already has the rendered files, reloaded when registries.conf
+// changes, and that dropping the section removes what it had rendered.
+func TestConfigureRegistries_OnlyReloadsOnChange(t *testing.T) {
+	cfg := &config.Config{Registries: []config.RegistryConfig{
+		{Registry: "docker.io", Mirrors: []string{"harbor.lab:5000/dockerhub", "mirror.gcr.io"}},
+		{Registry: "harbor.lab:5000", Insecure: true},
+	}}
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{"cat " + registriesConf: testRegistriesConf}}}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+	require.NoError(t, p.configureRegistries())
+	assert.Empty(t, node.files)
+	assert.NotContains(t, node.shellCmds, "systemctl reload crio")
+
+	cfg.Registries[0].Mirrors = []string{"mirror.gcr.io"}
+	require.NoError(t, p.configureRegistries())
+	assert.NotContains(t, node.files[registriesConf], "dockerhub")
+	assert.Equal(t, "systemctl reload crio", node.shellCmds[len(node.shellCmds)-1])
+
+	node.shellCmds = nil
+	cfg.Registries = nil
+	require.NoError(t, p.configureRegistries())
+	assert.Contains(t, node.shellCmds, "rm -f "+registriesConf)
+	assert.Equal(t, "systemctl reload crio", node.shellCmds[len(node.shellCmds)-1])
+}
+
+// TestConfigureRegistries_RemovesDroppedCAs verifies a CA written for a
+// registry that no longer has ca_file is removed, and the list of written
+// CAs follows the section.
+func TestConfigureRegistries_RemovesDroppedCAs(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{
+		"cat " + registryCAList:                              "registry.corp.example.com\nold.example.com\n",
+		"cat /etc/containers/certs.d/old.example.com/ca.crt": "-----BEGIN CERTIFICATE-----\nOLD\n-----END CERTIFICATE-----\n",
+	}}}
+	p := NewWithExecutor(context.Background(), registriesConfig(t), node, false)
+
+	require.NoError(t, p.configureRegistries())
+	assert.Contains(t, node.shellCmds, "rm -f /etc/containers/certs.d/old.example.com/ca.crt")
+	assert.Contains(t, node.files[registryCACert("registry.corp.example.com")], "LAB")
+	assert.Equal(t, "registry.corp.example.com\n", node.files[registryCAList])
+}
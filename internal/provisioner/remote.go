@@ -0,0 +1,88 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+	"github.com/techiescamp/k8s-provisioner/internal/executor"
+)
+
+// sshOptions maps provisioning config to SSH credentials with the same
+// precedence the Vault installer uses for node-to-node copies (see
+// VaultInstaller.sshConn): key when set, otherwise the password, defaulting to
+// the Vagrant box credential.
+func sshOptions(cfg *config.Config) executor.SSHOptions {
+	p := cfg.Provisioning
+	opts := executor.SSHOptions{
+		User:    cfg.SSHUser(),
+		KeyPath: p.SSHKeyPath,
+		Port:    p.SSHPort,
+	}
+	if opts.KeyPath == "" {
+		opts.Password = p.SSHPassword
+		if opts.Password == "" {
+			opts.Password = "vagrant"
+		}
+	}
+	return opts
+}
+
+// dialNode opens an SSH executor to the node named name (its nodes[].ip),
+// recording its commands in audit when non-nil.
+func dialNode(cfg *config.Config, name string, timeout time.Duration, audit *executor.AuditLog, verbose bool) (*executor.SSHExecutor, error) {
+	node := cfg.GetNode(name)
+	if node == nil {
+		return nil, fmt.Errorf("node %q not found in config", name)
+	}
+	if node.IP == "" {
+		return nil, fmt.Errorf("node %q has no ip in config", name)
+	}
+	exec, err := executor.DialSSH(node.IP, sshOptions(cfg), verbose)
+	if err != nil {
+		return nil, err
+	}
+	exec.Timeout = timeout
+	exec.Audit = audit
+	return exec, nil
+}
+
+// NewRemote builds a Provisioner that drives the node named name over SSH
+// instead of the local host, so a cluster can be provisioned from a workstation
+// or CI runner. Worker joins fetch a fresh join command from the control plane
+// over a second connection rather than relying on the /vagrant shared folder.
+// timeout bounds each remote command (0 = no limit); audit, when non-nil,
+// records the commands run on both connections. Call Close when done.
+func NewRemote(ctx context.Context, cfg *config.Config, name string, timeout time.Duration, audit *executor.AuditLog, verbose bool) (*Provisioner, error) {
+	node, err := dialNode(cfg, name, timeout, audit, verbose)
+	if err != nil {
+		return nil, err
+	}
+	p := NewWithExecutor(ctx, cfg, node, verbose)
+	p.remote = true
+	p.closers = append(p.closers, node.Close)
+
+	if cp := cfg.GetControlPlane(); cp != nil && cp.Name != name {
+		cpExec, err := dialNode(cfg, cp.Name, timeout, audit, verbose)
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("connect to control plane: %w", err)
+		}
+		p.controlPlane = executor.WithContext(ctx, cpExec)
+		p.closers = append(p.closers, cpExec.Close)
+	}
+	return p, nil
+}
+
+// Close releases the SSH connections held by a remote Provisioner. It is a
+// no-op for local and dry-run provisioners.
+func (p *Provisioner) Close() error {
+	var errs []error
+	for _, c := range p.closers {
+		errs = append(errs, c())
+	}
+	p.closers = nil
+	return errors.Join(errs...)
+}
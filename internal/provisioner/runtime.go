@@ -0,0 +1,148 @@
+package provisioner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/fetch"
+	"github.com/techiescamp/k8s-provisioner/internal/offline"
+)
+
+// runtimeStep returns the InstallCommon step that installs the container
+// runtime chosen by components.runtime.
+func (p *Provisioner) runtimeStep() (string, func() error) {
+	if p.config.Runtime() == "containerd" {
+		return "Installing containerd", p.installContainerd
+	}
+	return "Installing CRI-O", p.installCRIO
+}
+
+func (p *Provisioner) installCRIO() error {
+	host, err := p.hostOS()
+	if err != nil {
+		return err
+	}
+	if !p.config.Offline.Enabled() {
+		if err := host.addCRIORepo(); err != nil {
+			return err
+		}
+	}
+	if err := host.install("cri-o"); err != nil {
+		return err
+	}
+
+	if _, err := p.exec.Run("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := p.exec.Run("systemctl", "enable", "crio"); err != nil {
+		return err
+	}
+	if _, err := p.exec.Run("systemctl", "start", "crio"); err != nil {
+		return err
+	}
+
+	return p.configureRegistries()
+}
+
+// containerdUnit is the systemd unit shipped in the containerd repository,
+// for the release binaries unpacked under /usr/local.
+const containerdUnit = `[Unit]
+Description=containerd container runtime
+Documentation=https://containerd.io
+After=network.target dbus.service
+
+[Service]
+ExecStartPre=-/sbin/modprobe overlay
+ExecStart=/usr/local/bin/containerd
+Type=notify
+Delegate=yes
+KillMode=process
+Restart=always
+RestartSec=5
+LimitNPROC=infinity
+LimitCORE=infinity
+TasksMax=infinity
+OOMScoreAdjust=-999
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// installContainerd installs the containerd release pinned by
+// versions.containerd rather than the distribution's package, whose version
+// differs between Debian and RHEL-family hosts and lags behind Kubernetes.
+// runc comes from the distribution. The kubelet and containerd must agree on
+// the cgroup driver: kubeadm configures the kubelet for systemd, so the
+// generated config is switched from cgroupfs to it.
+func (p *Provisioner) installContainerd() error {
+	host, err := p.hostOS()
+	if err != nil {
+		return err
+	}
+	if err := host.install("runc"); err != nil {
+		return err
+	}
+
+	arch, err := p.goArch()
+	if err != nil {
+		return err
+	}
+	version := strings.TrimPrefix(p.config.Versions.Containerd, "v")
+	tarball, err := p.fetcher().Fetch(p.containerdRelease(arch))
+	if err != nil {
+		return fmt.Errorf("download containerd %s: %w", version, err)
+	}
+	if _, err := p.exec.RunShell("tar Cxzf /usr/local " + tarball); err != nil {
+		return fmt.Errorf("unpack containerd %s: %w", version, err)
+	}
+	if err := p.writeFile("/etc/systemd/system/containerd.service", containerdUnit); err != nil {
+		return err
+	}
+
+	if _, err := p.exec.RunShell("mkdir -p /etc/containerd && /usr/local/bin/containerd config default > /etc/containerd/config.toml"); err != nil {
+		return fmt.Errorf("generate containerd config: %w", err)
+	}
+	if _, err := p.exec.RunShell("sed -i 's/SystemdCgroup = false/SystemdCgroup = true/' /etc/containerd/config.toml"); err != nil {
+		return err
+	}
+	// A release whose default config no longer carries the option would leave
+	// containerd on cgroupfs and the kubelet crashlooping; fail here instead.
+	if _, err := p.exec.RunShell("grep -q 'SystemdCgroup = true' /etc/containerd/config.toml"); err != nil {
+		return fmt.Errorf("containerd %s: could not set SystemdCgroup = true in /etc/containerd/config.toml", version)
+	}
+
+	if _, err := p.exec.Run("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := p.exec.Run("systemctl", "enable", "containerd"); err != nil {
+		return err
+	}
+	if _, err := p.exec.Run("systemctl", "restart", "containerd"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// containerdRelease is the containerd release for arch.
+func (p *Provisioner) containerdRelease(arch string) offline.Artifact {
+	version := strings.TrimPrefix(p.config.Versions.Containerd, "v")
+	return offline.Artifact{
+		Kind: offline.File,
+		Name: "containerd.tar.gz",
+		URL:  fmt.Sprintf("https://github.com/containerd/containerd/releases/download/v%s/containerd-%s-linux-%s.tar.gz", version, version, arch),
+	}
+}
+
+// goArch maps the node's machine hardware name to the architecture suffix of
+// release downloads.
+func (p *Provisioner) goArch() (string, error) {
+	return fetch.Arch(p.exec, p.dryRun)
+}
+
+// fetcher downloads and verifies the release tarballs the host steps unpack
+// (see fetch.Fetcher).
+func (p *Provisioner) fetcher() fetch.Fetcher {
+	return fetch.Fetcher{Exec: p.exec, Config: p.config, Out: os.Stdout, DryRun: p.dryRun}
+}
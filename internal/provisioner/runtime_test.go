@@ -0,0 +1,82 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSum is a well-formed checksum the fake executors accept.
+const testSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestInstallCommon_Containerd(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{answers: map[string]string{
+		"/etc/os-release": debianOSRelease,
+		"uname -m":        "aarch64\n",
+	}}}
+	cfg := preflightConfig()
+	cfg.Versions.Kubernetes = "1.34"
+	cfg.Versions.Containerd = "v2.1.4"
+	cfg.Components.Runtime = "containerd"
+	cfg.Checksums = map[string]string{"https://github.com/containerd/containerd/releases/download/v2.1.4/containerd-2.1.4-linux-arm64.tar.gz": testSum}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+	p.SkipPreflight()
+
+	require.NoError(t, p.InstallCommon())
+
+	assert.Contains(t, node.shellCmds, "apt-get install -y runc")
+	assert.Contains(t, node.shellCmds, "tar Cxzf /usr/local /var/cache/k8s-provisioner/"+testSum+"/containerd.tar.gz")
+	assert.Contains(t, node.shellCmds, "sed -i 's/SystemdCgroup = false/SystemdCgroup = true/' /etc/containerd/config.toml")
+	assert.Contains(t, node.files["/etc/systemd/system/containerd.service"], "ExecStart=/usr/local/bin/containerd\n")
+	for _, c := range node.shellCmds {
+		assert.NotContains(t, c, "cri-o")
+	}
+
+	path, err := p.writeKubeadmConfig("controlplane")
+	require.NoError(t, err)
+	assert.Contains(t, node.files[path], "  criSocket: unix:///run/containerd/containerd.sock\n")
+}
+
+func TestInstallContainerd_RequiresSystemdCgroup(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{
+		answers: map[string]string{"/etc/os-release": debianOSRelease, "uname -m": "x86_64\n"},
+		errs:    map[string]error{"grep -q 'SystemdCgroup = true'": errors.New("exit status 1")},
+	}}
+	cfg := preflightConfig()
+	cfg.Versions.Containerd = "3.0.0"
+	cfg.Components.Runtime = "containerd"
+	cfg.Checksums = map[string]string{"https://github.com/containerd/containerd/releases/download/v3.0.0/containerd-3.0.0-linux-amd64.tar.gz": testSum}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+
+	err := p.installContainerd()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not set SystemdCgroup = true")
+}
+
+// TestInstallContainerd_ChecksumMismatchIsFatal verifies a release that does
+// not match its pin is deleted and never unpacked.
+func TestInstallContainerd_ChecksumMismatchIsFatal(t *testing.T) {
+	node := &fileExecutor{answeringExecutor: answeringExecutor{
+		answers: map[string]string{"/etc/os-release": debianOSRelease, "uname -m": "x86_64\n", "mktemp": "/var/cache/k8s-provisioner/" + testSum + "/containerd.tar.gz.Ab12Cd\n", "| cut -d' ' -f1": "0badc0de\n"},
+		errs:    map[string]error{"sha256sum -c": errors.New("exit status 1")},
+	}}
+	cfg := preflightConfig()
+	cfg.Versions.Containerd = "2.1.4"
+	cfg.Components.Runtime = "containerd"
+	cfg.Checksums = map[string]string{"https://github.com/containerd/containerd/releases/download/v2.1.4/containerd-2.1.4-linux-amd64.tar.gz": testSum}
+	p := NewWithExecutor(context.Background(), cfg, node, false)
+
+	err := p.installContainerd()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch for https://github.com/containerd/containerd/releases/download/v2.1.4/containerd-2.1.4-linux-amd64.tar.gz")
+	assert.Contains(t, err.Error(), "has sha256 0badc0de, pinned "+testSum)
+	part := "/var/cache/k8s-provisioner/" + testSum + "/containerd.tar.gz.Ab12Cd"
+	assert.Contains(t, node.shellCmds, "curl -fsSL --connect-timeout 10 --max-time 300 -o "+part+" https://github.com/containerd/containerd/releases/download/v2.1.4/containerd-2.1.4-linux-amd64.tar.gz")
+	assert.Contains(t, node.shellCmds, "rm -f "+part)
+	for _, c := range node.shellCmds {
+		assert.NotContains(t, c, "tar Cxzf")
+	}
+}
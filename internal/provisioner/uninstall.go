@@ -0,0 +1,64 @@
+package provisioner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/techiescamp/k8s-provisioner/internal/installer"
+)
+
+// Uninstall removes the workload step id (see WorkloadIDs) from the cluster.
+// It refuses while another step enabled in the config depends on it, since
+// that component would break. The step's post hook runs again afterwards: it
+// repairs what the step disturbs on the way in and out (Keycloak restarts the
+// API server both times). The step's checkpoint is dropped, so --resume
+// installs it again.
+func (p *Provisioner) Uninstall(id string) error {
+	steps := p.workloadSteps()
+	var step *workloadStep
+	var dependents []string
+	for i := range steps {
+		if steps[i].id == id {
+			step = &steps[i]
+		}
+	}
+	if step == nil {
+		return fmt.Errorf("unknown workload %q (known: %s)", id, strings.Join(WorkloadIDs(), ", "))
+	}
+	for _, s := range steps {
+		if (s.enabled == nil || s.enabled(p.config)) && slices.Contains(s.deps, id) {
+			dependents = append(dependents, s.id)
+		}
+	}
+	if len(dependents) > 0 {
+		return fmt.Errorf("cannot uninstall %s: enabled components depend on it (%s)", id, strings.Join(dependents, ", "))
+	}
+
+	inst := p.buildStep(step.build)
+	u, ok := inst.(installer.Uninstaller)
+	if !ok {
+		return fmt.Errorf("%s does not support uninstall", inst.Name())
+	}
+
+	fmt.Printf("\n>>> Uninstalling %s...\n", inst.Name())
+	err := p.runPhase("Uninstall "+inst.Name(), func() error {
+		if err := u.Uninstall(); err != nil {
+			return err
+		}
+		if step.post != nil {
+			return step.post(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.checkpoint.forget(phaseWorkloads, id)
+
+	fmt.Printf("✓ %s uninstalled\n", inst.Name())
+	if step.enabled == nil || step.enabled(p.config) {
+		fmt.Printf("Note: %s is still enabled in config.yaml; the next \"provision workloads\" installs it again (or pass --skip %s).\n", id, id)
+	}
+	return nil
+}
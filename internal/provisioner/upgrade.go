@@ -0,0 +1,247 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Upgrade moves the node named node (nodes[].name, also its Kubernetes node
+// name) to versions.kubernetes, following the kubeadm upgrade procedure:
+// point the package manager at the new minor version, upgrade kubeadm, run "kubeadm upgrade
+// apply" on the control plane or "kubeadm upgrade node" on a worker, then
+// drain the node, upgrade kubelet and kubectl, and uncordon it. The version
+// skew policy is checked before the node is touched; a node that already runs
+// the target version is skipped, so an interrupted cluster upgrade can simply
+// be re-run. Upgrade the first control plane, then the other control planes,
+// before the workers.
+func (p *Provisioner) Upgrade(node string) error {
+	n := p.config.GetNode(node)
+	if n == nil {
+		return fmt.Errorf("node %q not found in config", node)
+	}
+	if n.Role != "controlplane" && n.Role != "worker" {
+		return fmt.Errorf("node %s is a %s node, not part of the Kubernetes cluster", node, n.Role)
+	}
+	// The bundle's repository carries the version the cluster was built with.
+	if p.config.Offline.Enabled() {
+		return fmt.Errorf("upgrading from an offline bundle is not supported: the bundle holds a single Kubernetes version")
+	}
+	// A worker has no admin kubeconfig to drain itself with.
+	if n.Role == "worker" && p.controlPlane == nil && !p.dryRun {
+		return fmt.Errorf("upgrading worker %s drains it from the control plane: run provision upgrade --node %s", node, node)
+	}
+	return p.runPhase("Upgrading "+node, func() error {
+		return p.upgrade(node, n.Role)
+	})
+}
+
+func (p *Provisioner) upgrade(node, role string) error {
+	target := p.config.Versions.Kubernetes
+	kube := p.kubectl()
+	// Control planes other than the first follow it the way workers follow
+	// the control plane: "kubeadm upgrade node" upgrades their static pods.
+	first := p.config.GetControlPlane()
+	follower := role != "controlplane" || (first != nil && first.Name != node)
+
+	fmt.Println("\n>>> Checking version skew...")
+	if p.dryRun {
+		fmt.Println("[dry-run] skip version skew check")
+	} else {
+		apiServer, kubelets, err := p.clusterVersions()
+		if err != nil {
+			return fmt.Errorf("read cluster versions: %w", err)
+		}
+		skewRole := role
+		if role == "controlplane" && follower {
+			// The API server behind the VIP may be any control plane's: the
+			// reference is the first one, upgraded by "kubeadm upgrade apply".
+			skewRole, apiServer = "worker", kubelets[first.Name]
+		}
+		done, err := upgradeSkew(target, skewRole, node, apiServer, kubelets)
+		if err != nil {
+			return err
+		}
+		if done {
+			fmt.Printf("✓ %s already runs Kubernetes %s, skipping\n", node, kubelets[node])
+			return nil
+		}
+	}
+
+	fmt.Printf("\n>>> Moving the Kubernetes package repository to v%s...\n", target)
+	if err := p.configureKubernetesRepo(); err != nil {
+		return err
+	}
+
+	fmt.Println("\n>>> Upgrading kubeadm...")
+	if err := p.upgradePackages("kubeadm"); err != nil {
+		return err
+	}
+
+	switch {
+	case !follower:
+		fmt.Println("\n>>> Upgrading control plane components...")
+		if err := p.exec.RunShellWithOutput("kubeadm upgrade plan"); err != nil {
+			return err
+		}
+		// kubeadm came from the new repository: apply the version it ships.
+		if err := p.exec.RunShellWithOutput(`kubeadm upgrade apply -y "$(kubeadm version -o short)"`); err != nil {
+			return err
+		}
+	case role == "controlplane":
+		fmt.Println("\n>>> Upgrading control plane components...")
+		if err := p.exec.RunShellWithOutput("kubeadm upgrade node"); err != nil {
+			return err
+		}
+	default:
+		fmt.Println("\n>>> Upgrading kubelet configuration...")
+		if err := p.exec.RunShellWithOutput("kubeadm upgrade node"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n>>> Draining %s...\n", node)
+	drain := fmt.Sprintf("kubectl drain %s --ignore-daemonsets --delete-emptydir-data --timeout=%s", node, drainTimeout)
+	if err := kube.RunShellWithOutput(drain); err != nil {
+		// Nothing on the node has changed yet: let it schedule pods again.
+		_, _ = kube.RunShell("kubectl uncordon " + node)
+		return fmt.Errorf("drain %s: %w", node, err)
+	}
+
+	// A failure from here on leaves the node cordoned until a re-run
+	// completes: its kubelet may be half upgraded.
+	fmt.Println("\n>>> Upgrading kubelet and kubectl...")
+	if err := p.upgradePackages("kubelet", "kubectl"); err != nil {
+		return err
+	}
+	if _, err := p.exec.Run("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := p.exec.Run("systemctl", "restart", "kubelet"); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n>>> Uncordoning %s...\n", node)
+	if _, err := kube.RunShell("kubectl uncordon " + node); err != nil {
+		return err
+	}
+	if err := p.waitForNode(node, nodeReadyTimeout); err != nil {
+		return err
+	}
+	fmt.Printf("✓ %s upgraded to Kubernetes %s\n", node, target)
+	return nil
+}
+
+// upgradePackages installs pkgs from the current package repository,
+// lifting the hold installKubernetesTools placed on them for the duration.
+func (p *Provisioner) upgradePackages(pkgs ...string) error {
+	host, err := p.hostOS()
+	if err != nil {
+		return err
+	}
+	if err := host.unhold(pkgs...); err != nil {
+		return err
+	}
+	if err := host.install(pkgs...); err != nil {
+		return err
+	}
+	return host.hold(pkgs...)
+}
+
+// clusterVersions reads the API server version and the kubelet version each
+// registered node reports.
+func (p *Provisioner) clusterVersions() (apiServer string, kubelets map[string]string, err error) {
+	kube := p.kubectl()
+	out, err := kube.RunShell("kubectl version -o json")
+	if err != nil {
+		return "", nil, err
+	}
+	var v struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		return "", nil, fmt.Errorf("parse kubectl version: %w", err)
+	}
+
+	out, err = kube.RunShell(`kubectl get nodes -o jsonpath='{range .items[*]}{.metadata.name}{" "}{.status.nodeInfo.kubeletVersion}{"\n"}{end}'`)
+	if err != nil {
+		return "", nil, err
+	}
+	kubelets = map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if name, version, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			kubelets[name] = version
+		}
+	}
+	return v.ServerVersion.GitVersion, kubelets, nil
+}
+
+// upgradeSkew checks that moving node (of role) to the target minor version
+// respects the Kubernetes version skew policy, given the API server version
+// and each node's kubelet version:
+//
+//   - kubeadm upgrades one minor version at a time, and never downgrades;
+//   - a kubelet must not be newer than the API server, so workers follow the
+//     control plane;
+//   - a kubelet may be at most three minor versions older than the API
+//     server, so the control plane cannot leave an old worker behind.
+//
+// It reports done when node's kubelet already runs target.
+func upgradeSkew(target, role, node, apiServer string, kubelets map[string]string) (done bool, err error) {
+	want, err := minorVersion(target)
+	if err != nil {
+		return false, fmt.Errorf("versions.kubernetes: %w", err)
+	}
+	api, err := minorVersion(apiServer)
+	if err != nil {
+		return false, fmt.Errorf("API server version: %w", err)
+	}
+	kubelet, ok := kubelets[node]
+	if !ok {
+		return false, fmt.Errorf("node %s is not registered in the cluster", node)
+	}
+	current, err := minorVersion(kubelet)
+	if err != nil {
+		return false, fmt.Errorf("kubelet version on %s: %w", node, err)
+	}
+
+	switch {
+	case want < api:
+		return false, fmt.Errorf("versions.kubernetes %s is older than the cluster (%s): downgrades are not supported", target, apiServer)
+	case want > api+1:
+		return false, fmt.Errorf("cannot upgrade from %s to %s: kubeadm upgrades one minor version at a time (set versions.kubernetes to 1.%d first)", apiServer, target, api+1)
+	case role != "controlplane" && want > api:
+		return false, fmt.Errorf("upgrade the control plane to %s first: the kubelet on %s must not be newer than the API server (%s)", target, node, apiServer)
+	}
+	if current == want {
+		return true, nil
+	}
+	if role == "controlplane" {
+		for _, name := range slices.Sorted(maps.Keys(kubelets)) {
+			version := kubelets[name]
+			if m, err := minorVersion(version); err == nil && m < want-3 {
+				return false, fmt.Errorf("the kubelet on %s (%s) would fall more than three minor versions behind %s: upgrade it first", name, version, target)
+			}
+		}
+	}
+	return false, nil
+}
+
+// minorVersion returns the minor number of a 1.x version such as "1.34",
+// "v1.34.2" or "v1.34.2+build".
+func minorVersion(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("unrecognised Kubernetes version %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("unrecognised Kubernetes version %q", version)
+	}
+	return minor, nil
+}
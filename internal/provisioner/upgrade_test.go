@@ -0,0 +1,180 @@
+package provisioner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techiescamp/k8s-provisioner/internal/config"
+)
+
+// answeringExecutor records like mockExecutor and answers RunShell with the
+// output of the first entry whose key the command contains, or fails it with
+// the first matching entry of errs.
+type answeringExecutor struct {
+	mockExecutor
+	answers map[string]string
+	errs    map[string]error
+}
+
+func (a *answeringExecutor) RunShell(command string) (string, error) {
+	a.shellCmds = append(a.shellCmds, command)
+	for match, err := range a.errs {
+		if strings.Contains(command, match) {
+			return "", err
+		}
+	}
+	for match, out := range a.answers {
+		if strings.Contains(command, match) {
+			return out, nil
+		}
+	}
+	return "", nil
+}
+
+func TestUpgradeSkew(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		role     string
+		node     string
+		api      string
+		kubelets map[string]string
+		done     bool
+		err      string
+	}{
+		{
+			name: "control plane one minor up", target: "1.34", role: "controlplane", node: "controlplane", api: "v1.33.4",
+			kubelets: map[string]string{"controlplane": "v1.33.4", "node01": "v1.33.4"},
+		},
+		{
+			name: "worker after the control plane", target: "1.34", role: "worker", node: "node01", api: "v1.34.1",
+			kubelets: map[string]string{"controlplane": "v1.34.1", "node01": "v1.33.4"},
+		},
+		{
+			name: "worker already upgraded", target: "1.34", role: "worker", node: "node01", api: "v1.34.1",
+			kubelets: map[string]string{"controlplane": "v1.34.1", "node01": "v1.34.1"}, done: true,
+		},
+		{
+			name: "skipping a minor", target: "1.34", role: "controlplane", node: "controlplane", api: "v1.32.9",
+			kubelets: map[string]string{"controlplane": "v1.32.9"}, err: "one minor version at a time (set versions.kubernetes to 1.33 first)",
+		},
+		{
+			name: "downgrade", target: "1.33", role: "controlplane", node: "controlplane", api: "v1.34.1",
+			kubelets: map[string]string{"controlplane": "v1.34.1"}, err: "downgrades are not supported",
+		},
+		{
+			name: "worker ahead of the control plane", target: "1.34", role: "worker", node: "node01", api: "v1.33.4",
+			kubelets: map[string]string{"controlplane": "v1.33.4", "node01": "v1.33.4"}, err: "upgrade the control plane to 1.34 first",
+		},
+		{
+			name: "control plane leaving an old kubelet behind", target: "1.34", role: "controlplane", node: "controlplane", api: "v1.33.4",
+			kubelets: map[string]string{"controlplane": "v1.33.4", "node01": "v1.30.2"}, err: "the kubelet on node01 (v1.30.2)",
+		},
+		{
+			name: "unregistered node", target: "1.34", role: "worker", node: "node02", api: "v1.34.1",
+			kubelets: map[string]string{"controlplane": "v1.34.1"}, err: "node node02 is not registered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, err := upgradeSkew(tt.target, tt.role, tt.node, tt.api, tt.kubelets)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.done, done)
+		})
+	}
+}
+
+// TestUpgrade_WorkerIsDrainedFromTheControlPlane verifies a remote worker runs
+// "kubeadm upgrade node" itself while the control plane checks skew, drains
+// and uncordons it.
+func TestUpgrade_WorkerIsDrainedFromTheControlPlane(t *testing.T) {
+	cfg := &config.Config{Nodes: []config.NodeConfig{
+		{Name: "controlplane", Role: "controlplane"},
+		{Name: "node01", Role: "worker"},
+	}}
+	cfg.Versions.Kubernetes = "1.34"
+	worker := &answeringExecutor{answers: map[string]string{"/etc/os-release": debianOSRelease}}
+	cp := &answeringExecutor{answers: map[string]string{
+		"kubectl version":   `{"serverVersion":{"gitVersion":"v1.34.1"}}`,
+		"kubectl get nodes": "controlplane v1.34.1\nnode01 v1.33.4\n",
+		"kubectl get node ": "True",
+	}}
+	p := NewWithExecutor(context.Background(), cfg, worker, false)
+	p.remote = true
+	p.controlPlane = cp
+
+	require.NoError(t, p.Upgrade("node01"))
+
+	assert.Contains(t, worker.shellCmds, "kubeadm upgrade node")
+	assert.Contains(t, worker.shellCmds, "apt-mark unhold kubelet kubectl")
+	for _, c := range worker.shellCmds {
+		assert.NotContains(t, c, "kubectl drain")
+		assert.NotContains(t, c, "upgrade apply")
+	}
+	var drained, uncordoned int
+	for i, c := range cp.shellCmds {
+		if strings.HasPrefix(c, "kubectl drain node01 ") {
+			drained = i
+		}
+		if c == "kubectl uncordon node01" {
+			uncordoned = i
+		}
+	}
+	assert.NotZero(t, drained)
+	assert.Greater(t, uncordoned, drained)
+}
+
+func TestUpgrade_SkewViolationTouchesNothing(t *testing.T) {
+	cfg := &config.Config{Nodes: []config.NodeConfig{
+		{Name: "controlplane", Role: "controlplane"},
+		{Name: "node01", Role: "worker"},
+	}}
+	cfg.Versions.Kubernetes = "1.34"
+	worker := &mockExecutor{}
+	cp := &answeringExecutor{answers: map[string]string{
+		"kubectl version":   `{"serverVersion":{"gitVersion":"v1.33.4"}}`,
+		"kubectl get nodes": "controlplane v1.33.4\nnode01 v1.33.4\n",
+	}}
+	p := NewWithExecutor(context.Background(), cfg, worker, false)
+	p.controlPlane = cp
+
+	err := p.Upgrade("node01")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "upgrade the control plane to 1.34 first")
+	assert.Empty(t, worker.shellCmds)
+}
+
+// TestUpgrade_SecondControlPlaneFollowsTheFirst verifies an additional control
+// plane runs "kubeadm upgrade node", checked against the first control plane's
+// version rather than the API server's.
+func TestUpgrade_SecondControlPlaneFollowsTheFirst(t *testing.T) {
+	cfg := &config.Config{Nodes: []config.NodeConfig{
+		{Name: "cp1", Role: "controlplane"},
+		{Name: "cp2", Role: "controlplane"},
+	}}
+	cfg.Versions.Kubernetes = "1.34"
+	cp2 := &answeringExecutor{answers: map[string]string{
+		// The VIP still lands on cp2's own, not yet upgraded, API server.
+		"kubectl version":   `{"serverVersion":{"gitVersion":"v1.33.4"}}`,
+		"kubectl get nodes": "cp1 v1.34.1\ncp2 v1.33.4\n",
+		"kubectl get node ": "True",
+		"/etc/os-release":   debianOSRelease,
+	}}
+	p := NewWithExecutor(context.Background(), cfg, cp2, false)
+
+	require.NoError(t, p.Upgrade("cp2"))
+
+	assert.Contains(t, cp2.shellCmds, "kubeadm upgrade node")
+	for _, c := range cp2.shellCmds {
+		assert.NotContains(t, c, "upgrade apply")
+	}
+}
@@ -0,0 +1,64 @@
+// Package redact is the registry of literal secret values known to the current
+// run. Anything that prints, logs or records a command consults it (see
+// executor.scrub), so a secret is masked wherever it appears — in a flag, a
+// heredoc or a `kubectl create secret --from-literal` — not only in the
+// shapes the executor's patterns recognise.
+package redact
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every registered value.
+const Mask = "***"
+
+// minLen is the shortest value registered: masking a one- or two-character
+// value would mangle unrelated output without hiding anything.
+const minLen = 4
+
+var (
+	mu     sync.RWMutex
+	values []string // longest first, so a secret containing another is masked whole
+)
+
+// Add registers secret values. Empty and very short values are ignored, as are
+// values already registered.
+func Add(secrets ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range secrets {
+		if len(s) < minLen || contains(s) {
+			continue
+		}
+		values = append(values, s)
+	}
+	sort.SliceStable(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+}
+
+func contains(s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns s with every registered value replaced by Mask.
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+	return s
+}
+
+// reset forgets every registered value (tests only).
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	values = nil
+}
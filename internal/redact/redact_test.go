@@ -0,0 +1,30 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString_MasksRegisteredValues(t *testing.T) {
+	t.Cleanup(reset)
+	Add("s3cr3t-api-key", "", "ab")
+
+	got := String("kubectl create secret generic ollama --from-literal=key=s3cr3t-api-key")
+	assert.Equal(t, "kubectl create secret generic ollama --from-literal=key=***", got)
+	assert.Equal(t, "ab cd", String("ab cd"), "values shorter than minLen are not registered")
+}
+
+func TestString_LongerValueMaskedWhole(t *testing.T) {
+	t.Cleanup(reset)
+	// Registered shortest first; the longer value still wins.
+	Add("token", "token-with-suffix")
+
+	assert.Equal(t, "x=*** y=***", String("x=token-with-suffix y=token"))
+}
+
+func TestAdd_IgnoresDuplicates(t *testing.T) {
+	t.Cleanup(reset)
+	Add("hunter22", "hunter22")
+	assert.Len(t, values, 1)
+}
@@ -1 +0,0 @@
-kubeadm join 192.168.56.10:6443 --token l8uccf.n7i8quwzkiyznttz --discovery-token-ca-cert-hash sha256:a2722fa4a3eec12f51ffe98063228ad7f60b3a4b4442d5f0ea92e727c3b06503 